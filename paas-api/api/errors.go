@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned in ErrorBody.Code.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeTenantSuspended = "tenant_suspended"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeUnprocessable   = "unprocessable_entity"
+	CodeInternal        = "internal_error"
+	CodeUnavailable     = "service_unavailable"
+)
+
+// ErrorBody is the error envelope returned by every /v1 route.
+type ErrorBody struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// IsVersioned reports whether the request was made against the /v1 API.
+func IsVersioned(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return path == Prefix || strings.HasPrefix(path, Prefix+"/")
+}
+
+// Error writes an error response without aborting the handler chain.
+//
+// Versioned routes get the ErrorResponse envelope. The deprecated unversioned
+// routes keep the flat {"error": message} body existing clients parse, with
+// details merged into the top level.
+func Error(c *gin.Context, status int, code, message string) {
+	ErrorDetails(c, status, code, message, nil)
+}
+
+func ErrorDetails(c *gin.Context, status int, code, message string, details map[string]interface{}) {
+	if IsVersioned(c) {
+		c.JSON(status, ErrorResponse{Error: ErrorBody{
+			Code:      code,
+			Message:   message,
+			Details:   details,
+			RequestID: c.GetString("request_id"),
+		}})
+		return
+	}
+
+	body := gin.H{}
+	for k, v := range details {
+		body[k] = v
+	}
+	body["error"] = message
+	c.JSON(status, body)
+}
+
+// InternalError reports an unexpected failure as a 500.
+func InternalError(c *gin.Context, err error) {
+	Error(c, http.StatusInternalServerError, CodeInternal, err.Error())
+}
+
+// Abort writes an error response and stops the handler chain, for use in middleware.
+func Abort(c *gin.Context, status int, code, message string) {
+	Error(c, status, code, message)
+	c.Abort()
+}
+
+// NotFound answers requests that match no route.
+func NotFound(c *gin.Context) {
+	Error(c, http.StatusNotFound, CodeNotFound, "no route for "+c.Request.Method+" "+c.Request.URL.Path)
+}
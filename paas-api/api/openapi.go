@@ -0,0 +1,234 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+var (
+	pathParam    = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(metav1.Duration{})
+)
+
+// errorStatuses are documented on every operation in addition to its success status.
+var errorStatuses = []int{
+	http.StatusBadRequest,
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusConflict,
+	http.StatusInternalServerError,
+}
+
+// OpenAPI builds an OpenAPI 3.0 document for routes. Schemas are derived from
+// the request and response types by reflection, using their json tags; fields
+// tagged binding:"required" are marked required.
+func OpenAPI(info Info, routes []Route) map[string]interface{} {
+	schemas := schemaSet{}
+	errorRef := schemas.schema(reflect.TypeOf(ErrorResponse{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, rt := range routes {
+		path := pathParam.ReplaceAllString(Prefix+rt.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+
+		var params []interface{}
+		for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		names := make([]string, 0, len(rt.Query))
+		for name := range rt.Query {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			params = append(params, map[string]interface{}{
+				"name":        name,
+				"in":          "query",
+				"description": rt.Query[name],
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(rt.status())}
+		if rt.Response != nil {
+			success["content"] = jsonContent(schemas.schema(reflect.TypeOf(rt.Response)))
+		}
+		responses := map[string]interface{}{strconv.Itoa(rt.status()): success}
+		for _, status := range errorStatuses {
+			responses[strconv.Itoa(status)] = map[string]interface{}{
+				"description": http.StatusText(status),
+				"content":     jsonContent(errorRef),
+			}
+		}
+
+		op := map[string]interface{}{
+			"operationId": operationID(rt),
+			"summary":     rt.Summary,
+			"responses":   responses,
+		}
+		if rt.Tag != "" {
+			op["tags"] = []string{rt.Tag}
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemas.schema(reflect.TypeOf(rt.Request))),
+			}
+		}
+		if rt.Public {
+			op["security"] = []interface{}{}
+		}
+		if rt.Permission != "" {
+			op["x-required-permission"] = rt.Permission
+		}
+		if rt.Legacy {
+			op["x-legacy-path"] = pathParam.ReplaceAllString(rt.Path, "{$1}")
+		}
+		paths[path][strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"description":  "OIDC access token or API token (ctk_...)",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+// operationID derives a stable id such as "get_databases_username_db_name".
+func operationID(rt Route) string {
+	parts := []string{strings.ToLower(rt.Method)}
+	for _, seg := range strings.Split(rt.Path, "/") {
+		if seg = strings.TrimPrefix(seg, ":"); seg != "" {
+			parts = append(parts, strings.ReplaceAll(seg, "-", "_"))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemaSet collects named component schemas while types are walked.
+type schemaSet map[string]interface{}
+
+func (s schemaSet) schema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schema(t.Elem())
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t == durationType {
+			return map[string]interface{}{"type": "string", "example": "30s"}
+		}
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = map[string]interface{}{} // placeholder for recursive types
+			s[t.Name()] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (s schemaSet) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	s.fields(t, props, &required)
+
+	obj := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+func (s schemaSet) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// Embedded structs without a json name are flattened, as encoding/json does
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.fields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = s.schema(f.Type)
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package api
+
+import "time"
+
+// The resources below are what the k8s package reports about a tenant's
+// workloads. They are defined here, without Kubernetes dependencies, so
+// clients can decode responses without importing the k8s package.
+
+// Databases
+
+// Database states reported in DatabaseClusterInfo.Status. k8s/status.go
+// documents how they are derived and which transitions are possible.
+const (
+	StatusProvisioning = "Provisioning"
+	StatusInitializing = "Initializing"
+	StatusReady        = "Ready"
+	StatusDegraded     = "Degraded"
+	StatusFailingOver  = "Failing over"
+	StatusUpdating     = "Updating"
+	StatusPaused       = "Paused"
+	StatusFailed       = "Failed"
+	StatusDeleting     = "Deleting"
+)
+
+type DatabaseClusterInfo struct {
+	Name             string            `json:"name"`
+	Namespace        string            `json:"namespace"`
+	Engine           string            `json:"engine"`
+	Status           string            `json:"status"`
+	DetailedStatus   string            `json:"detailed_status"`
+	CredentialsReady bool              `json:"credentials_ready"`
+	ConnectionReady  bool              `json:"connection_ready"`
+	CreatedAt        string            `json:"created_at"`
+	Replicas         int               `json:"replicas"` // pods that exist right now
+	DesiredReplicas  int               `json:"desired_replicas"`
+	RunningReplicas  int               `json:"running_replicas"`
+	ConnectionInfo   map[string]string `json:"connection_info,omitempty"`
+	CreationMethod   string            `json:"creation_method"` // provider managing the cluster, e.g. "zalando"
+}
+
+// ProvisionResult is returned as soon as a database manifest has been applied,
+// before the operator has created the credentials secret.
+type ProvisionResult struct {
+	DatabaseName   string            `json:"database_name"`
+	Engine         string            `json:"engine"`
+	Host           string            `json:"host"`
+	Port           string            `json:"port"`
+	Status         string            `json:"status"`
+	Message        string            `json:"message"`
+	SecretName     string            `json:"secret_name"`
+	ConnectionInfo map[string]string `json:"connection_info"`
+	Instructions   map[string]string `json:"instructions"`
+}
+
+// DatabaseCredentials holds the owner credentials read from the operator secret.
+type DatabaseCredentials struct {
+	DatabaseName        string            `json:"database_name"`
+	Engine              string            `json:"engine"`
+	Host                string            `json:"host"`
+	Port                string            `json:"port"`
+	PrimaryUser         map[string]string `json:"primary_user"`
+	ConnectionString    string            `json:"connection_string,omitempty"`
+	ConnectionStringSSL string            `json:"connection_string_ssl,omitempty"`
+	ConnectionInfo      map[string]string `json:"connection_info,omitempty"`
+}
+
+// DatabaseBinding is a secret in the tenant namespace holding a database's
+// credentials in a layout workloads can consume directly.
+type DatabaseBinding struct {
+	Name       string `json:"name"`
+	Database   string `json:"database"`
+	Format     string `json:"format"`
+	SecretName string `json:"secret_name"`
+	CreatedAt  string `json:"created_at"`
+	SyncedAt   string `json:"synced_at"`
+}
+
+// Caches
+
+type CacheInfo struct {
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+	Status           string `json:"status"`
+	DetailedStatus   string `json:"detailed_status"`
+	Memory           string `json:"memory"`
+	Persistence      string `json:"persistence"`
+	Host             string `json:"host"`
+	Port             int    `json:"port"`
+	CredentialsReady bool   `json:"credentials_ready"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// CacheCredentials holds the AUTH password generated for a Redis instance.
+type CacheCredentials struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Password string `json:"password"`
+	URI      string `json:"uri"`
+}
+
+// Queues
+
+type QueueInfo struct {
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+	Engine           string `json:"engine"`
+	Status           string `json:"status"`
+	DetailedStatus   string `json:"detailed_status"`
+	Memory           string `json:"memory"`
+	Storage          string `json:"storage"`
+	Host             string `json:"host"`
+	Port             int    `json:"port"`
+	CredentialsReady bool   `json:"credentials_ready"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// QueueCredentials is the broker user of a queue. RabbitMQ users are scoped
+// to a vhost named after the queue; NATS has no vhosts.
+type QueueCredentials struct {
+	Name          string `json:"name"`
+	Engine        string `json:"engine"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	VHost         string `json:"vhost,omitempty"`
+	URI           string `json:"uri"`
+	ManagementURL string `json:"management_url,omitempty"`
+}
+
+// Buckets
+
+type BucketInfo struct {
+	Name       string `json:"name"`
+	Bucket     string `json:"bucket"` // name on the S3 endpoint
+	Namespace  string `json:"namespace"`
+	Endpoint   string `json:"endpoint"`
+	Quota      string `json:"quota,omitempty"` // empty when unlimited
+	QuotaBytes int64  `json:"quota_bytes"`
+	SizeBytes  int64  `json:"size_bytes"`
+	Objects    int64  `json:"objects"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// BucketCredentials is the tenant's key pair, valid for all of its buckets.
+type BucketCredentials struct {
+	Namespace  string `json:"namespace"`
+	Endpoint   string `json:"endpoint"`
+	AccessKey  string `json:"access_key"`
+	SecretKey  string `json:"secret_key"`
+	SecretName string `json:"secret_name"`
+}
+
+// Apps
+
+// AppSpec is the desired state of a tenant application. Replicas lives on the
+// Deployment, everything else on its pod template.
+type AppSpec struct {
+	Image           string            `json:"image"`
+	Port            int               `json:"port"`
+	Replicas        int               `json:"replicas"`
+	Plan            string            `json:"plan"`
+	Env             map[string]string `json:"env,omitempty"`
+	HealthCheckPath string            `json:"health_check_path,omitempty"`
+	Database        string            `json:"database,omitempty"`
+}
+
+type AppInfo struct {
+	Name           string  `json:"name"`
+	Namespace      string  `json:"namespace"`
+	Status         string  `json:"status"`
+	DetailedStatus string  `json:"detailed_status"`
+	Spec           AppSpec `json:"spec"`
+	ReadyReplicas  int     `json:"ready_replicas"`
+	Revision       int64   `json:"revision"`
+	Host           string  `json:"host"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// AppRevision is a pod template the Deployment has rolled out, kept as a
+// ReplicaSet so it can be rolled back to.
+type AppRevision struct {
+	Revision  int64  `json:"revision"`
+	Image     string `json:"image"`
+	Replicas  int    `json:"replicas"`
+	Current   bool   `json:"current"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AppRollout reports the progress of the latest rollout of an app.
+type AppRollout struct {
+	Name              string        `json:"name"`
+	Revision          int64         `json:"revision"`
+	Status            string        `json:"status"`
+	DetailedStatus    string        `json:"detailed_status"`
+	Complete          bool          `json:"complete"`
+	DesiredReplicas   int           `json:"desired_replicas"`
+	UpdatedReplicas   int           `json:"updated_replicas"`
+	ReadyReplicas     int           `json:"ready_replicas"`
+	AvailableReplicas int           `json:"available_replicas"`
+	Revisions         []AppRevision `json:"revisions"`
+}
+
+// Jobs
+
+// JobSpec is the desired state of a scheduled tenant job.
+type JobSpec struct {
+	Schedule string            `json:"schedule"`
+	TimeZone string            `json:"time_zone,omitempty"`
+	Image    string            `json:"image"`
+	Command  []string          `json:"command"`
+	Env      map[string]string `json:"env,omitempty"`
+	Plan     string            `json:"plan"`
+	Database string            `json:"database,omitempty"`
+}
+
+type JobInfo struct {
+	Name               string  `json:"name"`
+	Namespace          string  `json:"namespace"`
+	Spec               JobSpec `json:"spec"`
+	Suspended          bool    `json:"suspended"`
+	LastScheduleTime   string  `json:"last_schedule_time,omitempty"`
+	LastSuccessfulTime string  `json:"last_successful_time,omitempty"`
+	LastRun            *JobRun `json:"last_run,omitempty"`
+	CreatedAt          string  `json:"created_at"`
+}
+
+// JobRun is one execution of a job, scheduled or triggered by hand.
+type JobRun struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Message     string `json:"message,omitempty"`
+	Manual      bool   `json:"manual"`
+	StartedAt   string `json:"started_at,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+}
+
+// Routes
+
+type RouteInfo struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	Port      int    `json:"port"`
+	Path      string `json:"path"`
+	URL       string `json:"url"`
+	TLS       bool   `json:"tls"`
+	CreatedAt string `json:"created_at"`
+
+	// Custom hostname and the state of its ownership check
+	Hostname         string           `json:"hostname,omitempty"`
+	HostnameVerified bool             `json:"hostname_verified"`
+	Challenge        *DomainChallenge `json:"challenge,omitempty"`
+}
+
+// DomainChallenge tells the tenant how to prove control of a hostname.
+type DomainChallenge struct {
+	Token      string `json:"token"`
+	TXTRecord  string `json:"txt_record"` // name of the TXT record holding the token
+	HTTPURL    string `json:"http_url"`   // URL that must return the token
+	VerifiedAt string `json:"verified_at,omitempty"`
+}
+
+// Tenants
+
+type TenantQuota struct {
+	Name string            `json:"name"`
+	Hard map[string]string `json:"hard"`
+	Used map[string]string `json:"used"`
+}
+
+type TenantInfo struct {
+	Name          string        `json:"name"`
+	Namespace     string        `json:"namespace"`
+	Owner         string        `json:"owner"`
+	CreatedAt     string        `json:"created_at"`
+	Suspended     bool          `json:"suspended"`
+	SuspendedBy   string        `json:"suspended_by,omitempty"`
+	SuspendedAt   string        `json:"suspended_at,omitempty"`
+	DatabaseCount int           `json:"database_count"`
+	CacheCount    int           `json:"cache_count"`
+	QueueCount    int           `json:"queue_count"`
+	AppCount      int           `json:"app_count"`
+	JobCount      int           `json:"job_count"`
+	PodCount      int           `json:"pod_count"`
+	CPUUsage      string        `json:"cpu_usage"`
+	MemoryUsage   string        `json:"memory_usage"`
+	Quotas        []TenantQuota `json:"quotas"`
+
+	// Only filled in by GetTenant
+	Databases []DatabaseClusterInfo `json:"databases,omitempty"`
+}
+
+// Organizations
+
+type OrgMember struct {
+	Subject  string    `json:"subject"`
+	Email    string    `json:"email,omitempty"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type OrgInvitation struct {
+	ID        string    `json:"id"`
+	Invitee   string    `json:"invitee,omitempty"` // email or subject; empty means anyone with the code
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CodeHash  string    `json:"code_hash,omitempty"`
+}
+
+// Organization groups members sharing one tenant namespace.
+type Organization struct {
+	Name        string          `json:"name"`
+	DisplayName string          `json:"display_name"`
+	Namespace   string          `json:"namespace"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Members     []OrgMember     `json:"members"`
+	Invitations []OrgInvitation `json:"invitations"`
+}
+
+func (o *Organization) Member(subject string) *OrgMember {
+	for i := range o.Members {
+		if o.Members[i].Subject == subject {
+			return &o.Members[i]
+		}
+	}
+	return nil
+}
+
+// Tokens
+
+// APIToken is the stored form of a personal or service account token.
+// Only the SHA-256 hash of the token is ever persisted. Grants are the
+// creator's roles when the token was created, as "role" or "role:tenant".
+type APIToken struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Owner          string    `json:"owner"`
+	ServiceAccount string    `json:"service_account,omitempty"`
+	Grants         []string  `json:"grants"`
+	Scopes         []string  `json:"scopes"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	Hash           string    `json:"-"`
+}
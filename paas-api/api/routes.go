@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Prefix is the path prefix of the current API version.
+const Prefix = "/v1"
+
+// Route describes one endpoint. The same table drives routing and the
+// OpenAPI document, so the spec cannot drift from what is served.
+type Route struct {
+	Method     string
+	Path       string // gin syntax, relative to Prefix, e.g. /databases/:username
+	Summary    string
+	Tag        string
+	Permission string            // Enforced by the guard passed to Register, empty for none
+	Query      map[string]string // Query parameter name -> description
+	Request    interface{}       // Zero value of the JSON body type, nil if none
+	Response   interface{}       // Zero value of the success body type
+	Status     int               // Success status, defaults to 200
+	Public     bool              // No bearer token required
+
+	// Legacy also serves the route without the version prefix, as a
+	// deprecated alias for clients written before /v1 existed.
+	Legacy bool
+
+	Handlers []gin.HandlerFunc
+}
+
+func (rt Route) status() int {
+	if rt.Status == 0 {
+		return http.StatusOK
+	}
+	return rt.Status
+}
+
+// Register mounts routes under Prefix, their legacy aliases, and the
+// generated OpenAPI document at Prefix/openapi.json. guard builds the
+// middleware enforcing a route's Permission.
+func Register(r *gin.Engine, info Info, routes []Route, guard func(permission string) gin.HandlerFunc) {
+	v1 := r.Group(Prefix)
+	for _, rt := range routes {
+		var handlers []gin.HandlerFunc
+		if rt.Permission != "" {
+			handlers = append(handlers, guard(rt.Permission))
+		}
+		handlers = append(handlers, rt.Handlers...)
+
+		v1.Handle(rt.Method, rt.Path, handlers...)
+		if rt.Legacy {
+			r.Handle(rt.Method, rt.Path, append([]gin.HandlerFunc{Deprecated()}, handlers...)...)
+		}
+	}
+
+	spec := OpenAPI(info, routes)
+	v1.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	})
+}
+
+// Deprecated marks a response as coming from an unversioned alias and points
+// the client at its /v1 successor.
+func Deprecated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+Prefix+c.Request.URL.EscapedPath()+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,513 @@
+package api
+
+import (
+	"paas-api/audit"
+	"paas-api/config"
+)
+
+// Request bodies
+
+type CreateDatabaseRequest struct {
+	Username string `json:"username" binding:"required"`
+	DBName   string `json:"db_name"`  // Optional: will auto-generate if not provided
+	Replicas int    `json:"replicas"` // Optional: defaults to 1
+	Plan     string `json:"plan"`     // Optional: small, medium or large
+	Version  string `json:"version"`  // Optional: engine version, e.g. 16 or 8.4
+	Engine   string `json:"engine"`   // Optional: postgresql (default), mysql or mariadb
+}
+
+type UpdateDatabaseRequest struct {
+	Replicas int `json:"replicas" binding:"required"`
+}
+
+type DeleteDatabaseRequest struct {
+	Username string `json:"username" binding:"required"`
+	DBName   string `json:"db_name" binding:"required"`
+}
+
+type CreateBindingRequest struct {
+	Format string `json:"format" binding:"required"` // url, servicebinding or pg
+	Name   string `json:"name"`                      // Optional: secret name, defaults to <db_name>-<format>
+}
+
+type CreateCacheRequest struct {
+	Username    string `json:"username" binding:"required"`
+	Name        string `json:"name"`        // Optional: will auto-generate if not provided
+	Memory      string `json:"memory"`      // Optional: defaults to 256Mi
+	Persistence string `json:"persistence"` // Optional: none, rdb or aof
+}
+
+type CreateQueueRequest struct {
+	Username string `json:"username" binding:"required"`
+	Name     string `json:"name"`    // Optional: will auto-generate if not provided
+	Engine   string `json:"engine"`  // Optional: rabbitmq (default) or nats
+	Memory   string `json:"memory"`  // Optional: defaults to 256Mi
+	Storage  string `json:"storage"` // Optional: volume size, defaults to 1Gi
+}
+
+type CreateBucketRequest struct {
+	Username string `json:"username" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Quota    string `json:"quota"` // Optional: hard limit such as 10Gi, unlimited if empty
+}
+
+type UpdateBucketRequest struct {
+	Quota *string `json:"quota" binding:"required"` // Empty string removes the quota
+}
+
+type CreateAppRequest struct {
+	Username        string            `json:"username" binding:"required"`
+	Name            string            `json:"name" binding:"required"`
+	Image           string            `json:"image" binding:"required"`
+	Port            int               `json:"port"`              // Optional: container port, defaults to 8080
+	Replicas        int               `json:"replicas"`          // Optional: defaults to 1
+	Plan            string            `json:"plan"`              // Optional: CPU and memory of a database plan
+	Env             map[string]string `json:"env"`               // Optional
+	HealthCheckPath string            `json:"health_check_path"` // Optional: HTTP readiness and liveness probe
+	Database        string            `json:"database"`          // Optional: tenant database to inject as DATABASE_* variables
+}
+
+// UpdateAppRequest changes the fields that are set. Env replaces every
+// variable; an empty Database unbinds the database.
+type UpdateAppRequest struct {
+	Image           *string            `json:"image"`
+	Port            *int               `json:"port"`
+	Replicas        *int               `json:"replicas"`
+	Plan            *string            `json:"plan"`
+	Env             *map[string]string `json:"env"`
+	HealthCheckPath *string            `json:"health_check_path"`
+	Database        *string            `json:"database"`
+}
+
+type RollbackAppRequest struct {
+	Revision int64 `json:"revision"` // Optional: defaults to the previous revision
+}
+
+type CreateJobRequest struct {
+	Username string            `json:"username" binding:"required"`
+	Name     string            `json:"name" binding:"required"`
+	Schedule string            `json:"schedule" binding:"required"` // Cron expression such as "0 3 * * *" or a macro such as @daily
+	TimeZone string            `json:"time_zone"`                   // Optional: IANA time zone of the schedule, defaults to UTC
+	Image    string            `json:"image" binding:"required"`
+	Command  []string          `json:"command" binding:"required"`
+	Env      map[string]string `json:"env"`      // Optional
+	Plan     string            `json:"plan"`     // Optional: CPU and memory of a database plan
+	Database string            `json:"database"` // Optional: tenant database to inject as DATABASE_* variables
+}
+
+type CreateRouteRequest struct {
+	Username string `json:"username" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Service  string `json:"service" binding:"required"` // Service in the tenant namespace, e.g. an app
+	Port     int    `json:"port"`                       // Optional: service port, defaults to 80
+	Path     string `json:"path"`                       // Optional: path prefix, defaults to /
+	Hostname string `json:"hostname"`                   // Optional: custom hostname, served once verified
+}
+
+type VerifyRouteRequest struct {
+	Method string `json:"method" binding:"required"` // txt or http
+}
+
+type CreateTokenRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Scopes         []string `json:"scopes" binding:"required"`
+	ExpiresInDays  int      `json:"expires_in_days"` // Optional: defaults to 90
+	ServiceAccount string   `json:"service_account"` // Optional: marks the token as a CI/service identity
+}
+
+type CreateOrgRequest struct {
+	Name        string `json:"name" binding:"required"`
+	DisplayName string `json:"display_name"`
+}
+
+type CreateInvitationRequest struct {
+	Invitee string `json:"invitee"` // Optional: email or subject allowed to accept
+	Role    string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// Databases
+
+type CreateDatabaseResponse struct {
+	Message     string           `json:"message"`
+	Namespace   string           `json:"namespace"`
+	DBName      string           `json:"db_name"`
+	Credentials *ProvisionResult `json:"credentials"`
+}
+
+type UpdateDatabaseResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	DBName    string `json:"db_name"`
+	Replicas  int    `json:"replicas"`
+}
+
+type DeleteDatabaseResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	DBName    string `json:"db_name"`
+}
+
+// ClusterSummary counts a tenant's clusters by state.
+type ClusterSummary struct {
+	Total            int `json:"total"`
+	Ready            int `json:"ready"`
+	Creating         int `json:"creating"`
+	Degraded         int `json:"degraded"`
+	Paused           int `json:"paused"`
+	Failed           int `json:"failed"`
+	CredentialsReady int `json:"credentials_ready"`
+	ConnectionReady  int `json:"connection_ready"`
+	ManualCreated    int `json:"manual_created"`
+	ZalandoCreated   int `json:"zalando_created"`
+
+	// Providers counts clusters by the provider that runs them
+	Providers map[string]int `json:"providers"`
+	// Engines counts clusters by database engine
+	Engines map[string]int `json:"engines"`
+}
+
+type DatabaseListResponse struct {
+	Username      string                `json:"username"`
+	Namespace     string                `json:"namespace"`
+	Clusters      []DatabaseClusterInfo `json:"clusters"`
+	TotalClusters int                   `json:"total_clusters"`
+	Summary       ClusterSummary        `json:"summary"`
+}
+
+type DatabaseResponse struct {
+	Username string               `json:"username"`
+	Cluster  *DatabaseClusterInfo `json:"cluster"`
+}
+
+type DatabaseStatusResponse struct {
+	Username string `json:"username"`
+	DBName   string `json:"db_name"`
+	Status   string `json:"status"`
+}
+
+type CredentialsResponse struct {
+	Username    string               `json:"username"`
+	DBName      string               `json:"db_name"`
+	Credentials *DatabaseCredentials `json:"credentials"`
+}
+
+type DatabaseLogsResponse struct {
+	DBName string `json:"db_name"`
+	Pod    string `json:"pod"`
+	Logs   string `json:"logs"`
+}
+
+type BackupResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	DBName    string `json:"db_name"`
+	Job       string `json:"job,omitempty"` // Empty while backups are still being enabled
+}
+
+type CreateBindingResponse struct {
+	Message   string           `json:"message"`
+	Namespace string           `json:"namespace"`
+	Binding   *DatabaseBinding `json:"binding"`
+}
+
+type BindingListResponse struct {
+	Username string            `json:"username"`
+	DBName   string            `json:"db_name"`
+	Bindings []DatabaseBinding `json:"bindings"`
+	Total    int               `json:"total"`
+}
+
+type DeleteBindingResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	DBName    string `json:"db_name"`
+	Name      string `json:"name"`
+}
+
+// Caches
+
+type CreateCacheResponse struct {
+	Message   string     `json:"message"`
+	Namespace string     `json:"namespace"`
+	Cache     *CacheInfo `json:"cache"`
+}
+
+type CacheListResponse struct {
+	Username  string      `json:"username"`
+	Namespace string      `json:"namespace"`
+	Caches    []CacheInfo `json:"caches"`
+	Total     int         `json:"total"`
+}
+
+type CacheResponse struct {
+	Username string     `json:"username"`
+	Cache    *CacheInfo `json:"cache"`
+}
+
+type CacheStatusResponse struct {
+	Username       string `json:"username"`
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	DetailedStatus string `json:"detailed_status"`
+}
+
+type CacheCredentialsResponse struct {
+	Username    string            `json:"username"`
+	Name        string            `json:"name"`
+	Credentials *CacheCredentials `json:"credentials"`
+}
+
+type DeleteCacheResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Queues
+
+type CreateQueueResponse struct {
+	Message   string     `json:"message"`
+	Namespace string     `json:"namespace"`
+	Queue     *QueueInfo `json:"queue"`
+}
+
+type QueueListResponse struct {
+	Username  string      `json:"username"`
+	Namespace string      `json:"namespace"`
+	Queues    []QueueInfo `json:"queues"`
+	Total     int         `json:"total"`
+}
+
+type QueueResponse struct {
+	Username string     `json:"username"`
+	Queue    *QueueInfo `json:"queue"`
+}
+
+type QueueStatusResponse struct {
+	Username       string `json:"username"`
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	DetailedStatus string `json:"detailed_status"`
+}
+
+type QueueCredentialsResponse struct {
+	Username    string            `json:"username"`
+	Name        string            `json:"name"`
+	Credentials *QueueCredentials `json:"credentials"`
+}
+
+type DeleteQueueResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Buckets
+
+type CreateBucketResponse struct {
+	Message    string      `json:"message"`
+	Namespace  string      `json:"namespace"`
+	Bucket     *BucketInfo `json:"bucket"`
+	SecretName string      `json:"secret_name"` // Holds the tenant's access key
+}
+
+type BucketListResponse struct {
+	Username   string       `json:"username"`
+	Namespace  string       `json:"namespace"`
+	Buckets    []BucketInfo `json:"buckets"`
+	Total      int          `json:"total"`
+	TotalBytes int64        `json:"total_bytes"`
+}
+
+type BucketResponse struct {
+	Username string      `json:"username"`
+	Bucket   *BucketInfo `json:"bucket"`
+}
+
+type BucketCredentialsResponse struct {
+	Username    string             `json:"username"`
+	Credentials *BucketCredentials `json:"credentials"`
+}
+
+type DeleteBucketResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Apps
+
+type CreateAppResponse struct {
+	Message   string   `json:"message"`
+	Namespace string   `json:"namespace"`
+	App       *AppInfo `json:"app"`
+}
+
+type AppListResponse struct {
+	Username  string    `json:"username"`
+	Namespace string    `json:"namespace"`
+	Apps      []AppInfo `json:"apps"`
+	Total     int       `json:"total"`
+}
+
+type AppResponse struct {
+	Username string   `json:"username"`
+	App      *AppInfo `json:"app"`
+}
+
+type AppRolloutResponse struct {
+	Username string      `json:"username"`
+	Rollout  *AppRollout `json:"rollout"`
+}
+
+type DeleteAppResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Jobs
+
+type CreateJobResponse struct {
+	Message   string   `json:"message"`
+	Namespace string   `json:"namespace"`
+	Job       *JobInfo `json:"job"`
+}
+
+type JobListResponse struct {
+	Username  string    `json:"username"`
+	Namespace string    `json:"namespace"`
+	Jobs      []JobInfo `json:"jobs"`
+	Total     int       `json:"total"`
+}
+
+type JobResponse struct {
+	Username string   `json:"username"`
+	Job      *JobInfo `json:"job"`
+}
+
+type TriggerJobResponse struct {
+	Message string  `json:"message"`
+	Run     *JobRun `json:"run"`
+}
+
+type JobLogsResponse struct {
+	Name string  `json:"name"`
+	Run  *JobRun `json:"run"`
+	Logs string  `json:"logs"`
+}
+
+type DeleteJobResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Routes
+
+type CreateRouteResponse struct {
+	Message   string     `json:"message"`
+	Namespace string     `json:"namespace"`
+	Route     *RouteInfo `json:"route"`
+}
+
+type RouteListResponse struct {
+	Username  string      `json:"username"`
+	Namespace string      `json:"namespace"`
+	Routes    []RouteInfo `json:"routes"`
+	Total     int         `json:"total"`
+}
+
+type RouteResponse struct {
+	Username string     `json:"username"`
+	Route    *RouteInfo `json:"route"`
+}
+
+type DeleteRouteResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Tokens
+
+type CreateTokenResponse struct {
+	Message string   `json:"message"`
+	Token   string   `json:"token"` // Plaintext, only returned once
+	Details APIToken `json:"details"`
+}
+
+type TokenListResponse struct {
+	Tokens []APIToken `json:"tokens"`
+	Total  int        `json:"total"`
+}
+
+type RevokeTokenResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id"`
+}
+
+// Organizations
+
+type OrgListResponse struct {
+	Organizations []Organization `json:"organizations"`
+	Total         int            `json:"total"`
+}
+
+type OrgDatabasesResponse struct {
+	Organization  string                `json:"organization"`
+	Namespace     string                `json:"namespace"`
+	Clusters      []DatabaseClusterInfo `json:"clusters"`
+	TotalClusters int                   `json:"total_clusters"`
+	Summary       ClusterSummary        `json:"summary"`
+}
+
+type CreateInvitationResponse struct {
+	Message    string        `json:"message"`
+	Code       string        `json:"code"` // Only returned once
+	Invitation OrgInvitation `json:"invitation"`
+}
+
+type RevokeInvitationResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id"`
+}
+
+type AcceptInvitationResponse struct {
+	Message      string `json:"message"`
+	Organization string `json:"organization"`
+	Namespace    string `json:"namespace"`
+	Role         string `json:"role"`
+}
+
+type RemoveMemberResponse struct {
+	Message string `json:"message"`
+	Subject string `json:"subject"`
+}
+
+// Admin
+
+type TenantListResponse struct {
+	Tenants      []TenantInfo `json:"tenants"`
+	TotalTenants int          `json:"total_tenants"`
+}
+
+type TenantActionResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+}
+
+type AuditEventsResponse struct {
+	Events []audit.Event `json:"events"`
+	Total  int           `json:"total"`
+}
+
+// ConfigResponse is the running configuration with secrets redacted.
+type ConfigResponse struct {
+	Source string        `json:"source,omitempty"` // Config file, empty if only the environment was used
+	Config config.Config `json:"config"`
+}
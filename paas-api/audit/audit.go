@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Results recorded on an Event.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultDenied  = "denied"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength bounds the request IDs clients may choose.
+	maxRequestIDLength = 128
+)
+
+type Event struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"` // JWT sub
+	Role      string    `json:"role"`
+	Action    string    `json:"action"` // e.g. database.create, cache.credentials.read
+	Namespace string    `json:"namespace,omitempty"`
+	Database  string    `json:"database,omitempty"` // the resource acted on, of the kind in Action
+	RequestID string    `json:"request_id"`
+	SourceIP  string    `json:"source_ip"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Filter selects events in Query. Zero values match everything.
+type Filter struct {
+	Actor     string
+	Action    string
+	Namespace string
+	Database  string
+	Result    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (f Filter) Matches(e Event) bool {
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Namespace != "" && e.Namespace != f.Namespace {
+		return false
+	}
+	if f.Database != "" && e.Database != f.Database {
+		return false
+	}
+	if f.Result != "" && e.Result != f.Result {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Sink stores audit events. Query returns the newest matching events first.
+type Sink interface {
+	Write(e Event) error
+	Query(f Filter) ([]Event, error)
+}
+
+var (
+	mu   sync.RWMutex
+	sink Sink = NewStdoutSink()
+)
+
+// Init selects the sink by kind: "stdout" (default), "file" or "postgres".
+// target is the file path or the Postgres DSN respectively.
+func Init(kind, target string) error {
+	var s Sink
+	var err error
+
+	switch kind {
+	case "", "stdout":
+		s = NewStdoutSink()
+	case "file":
+		s, err = NewFileSink(target)
+	case "postgres":
+		s, err = NewSQLSink(target)
+	default:
+		return fmt.Errorf("unknown audit sink %q", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	sink = s
+	mu.Unlock()
+	return nil
+}
+
+func Query(f Filter) ([]Event, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sink.Query(f)
+}
+
+// Record writes an audit event for the current request. A nil err records
+// success; any other err records failure with its message.
+func Record(c *gin.Context, action, namespace, database string, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+	RecordResult(c, action, namespace, database, result, err)
+}
+
+func RecordResult(c *gin.Context, action, namespace, database, result string, err error) {
+	e := Event{
+		Time:      time.Now().UTC(),
+		Actor:     contextString(c, "user_id"),
+		Role:      contextString(c, "role"),
+		Action:    action,
+		Namespace: namespace,
+		Database:  database,
+		RequestID: contextString(c, "request_id"),
+		SourceIP:  c.ClientIP(),
+		Result:    result,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if werr := sink.Write(e); werr != nil {
+		// Never fail the request because of the audit trail, but make it loud
+		fmt.Printf("AUDIT WRITE FAILED: %v (event: %+v)\n", werr, e)
+	}
+}
+
+func contextString(c *gin.Context, key string) string {
+	if v, ok := c.Get(key); ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// RequestID propagates the X-Request-ID header, generating one when the client
+// did not send a valid one, so audit events can be correlated with logs.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set("request_id", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID accepts the IDs of common tracing schemes (UUIDs, hex,
+// base64url and dotted or colon separated IDs) and nothing that could break
+// a log line.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
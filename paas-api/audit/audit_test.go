@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useStdoutSink gives the test a fresh sink and restores the previous one.
+func useStdoutSink(t *testing.T) *StdoutSink {
+	t.Helper()
+	s := NewStdoutSink()
+	mu.Lock()
+	previous := sink
+	sink = s
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		sink = previous
+		mu.Unlock()
+	})
+	return s
+}
+
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("failed to distrust proxies: %v", err)
+	}
+	r.Use(RequestID())
+	r.POST("/databases", func(c *gin.Context) {
+		c.Set("user_id", "alice")
+		Record(c, "database.create", "tenant-alice", "db1", nil)
+		c.Status(http.StatusCreated)
+	})
+	r.DELETE("/databases", func(c *gin.Context) {
+		Record(c, "database.delete", "tenant-alice", "db1", errors.New("boom"))
+		c.Status(http.StatusInternalServerError)
+	})
+	return r
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "missing", header: "", keep: false},
+		{name: "uuid", header: "3f2b8c1e-7a4d-4e8f-9b2a-1c5d6e7f8a9b", keep: true},
+		{name: "dotted trace", header: "trace.01:span_02", keep: true},
+		{name: "longest allowed", header: strings.Repeat("a", maxRequestIDLength), keep: true},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1), keep: false},
+		{name: "spaces", header: "forged id", keep: false},
+		{name: "quotes", header: `abc"def`, keep: false},
+		{name: "non ascii", header: "récit", keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useStdoutSink(t)
+			req := httptest.NewRequest(http.MethodPost, "/databases", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			newRouter(t).ServeHTTP(w, req)
+
+			got := w.Header().Get(requestIDHeader)
+			if tt.keep && got != tt.header {
+				t.Errorf("request ID = %q, want the client's %q", got, tt.header)
+			}
+			if !tt.keep && (got == tt.header || !validRequestID(got)) {
+				t.Errorf("request ID = %q, want a generated one", got)
+			}
+			events, _ := s.Query(Filter{})
+			if len(events) != 1 || events[0].RequestID != got {
+				t.Errorf("recorded events = %+v, want one with request ID %q", events, got)
+			}
+		})
+	}
+}
+
+func TestRecordIgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	s := useStdoutSink(t)
+	req := httptest.NewRequest(http.MethodPost, "/databases", nil)
+	req.RemoteAddr = "192.0.2.10:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Real-IP", "203.0.113.8")
+	newRouter(t).ServeHTTP(httptest.NewRecorder(), req)
+
+	events, _ := s.Query(Filter{})
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if events[0].SourceIP != "192.0.2.10" {
+		t.Errorf("source IP = %q, want the peer address 192.0.2.10", events[0].SourceIP)
+	}
+}
+
+func TestRecordResult(t *testing.T) {
+	s := useStdoutSink(t)
+	r := newRouter(t)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/databases", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/databases", nil))
+
+	events, _ := s.Query(Filter{})
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	failed, created := events[0], events[1]
+	if created.Action != "database.create" || created.Result != ResultSuccess || created.Actor != "alice" || created.Error != "" {
+		t.Errorf("create event = %+v", created)
+	}
+	if failed.Action != "database.delete" || failed.Result != ResultFailure || failed.Error != "boom" {
+		t.Errorf("delete event = %+v", failed)
+	}
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	_ "github.com/lib/pq"
+)
+
+// recentEvents is how many events the stdout sink keeps in memory for Query.
+const recentEvents = 1000
+
+// StdoutSink prints one JSON line per event. Only the most recent events are
+// queryable since stdout itself cannot be read back.
+type StdoutSink struct {
+	mu     sync.Mutex
+	recent []Event
+}
+
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{}
+}
+
+func (s *StdoutSink) Write(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("AUDIT %s\n", line)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent = append(s.recent, e)
+	if len(s.recent) > recentEvents {
+		s.recent = s.recent[len(s.recent)-recentEvents:]
+	}
+	return nil
+}
+
+func (s *StdoutSink) Query(f Filter) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filterNewestFirst(s.recent, f), nil
+}
+
+// FileSink appends JSON lines to a file and scans it on Query.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit file sink needs a file path")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file %s: %w", path, err)
+	}
+	f.Close()
+	return &FileSink{path: path}, nil
+}
+
+func (s *FileSink) Write(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) Query(f Filter) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return filterNewestFirst(events, f), nil
+}
+
+func filterNewestFirst(events []Event, f Filter) []Event {
+	result := []Event{}
+	for i := len(events) - 1; i >= 0; i-- {
+		if !f.Matches(events[i]) {
+			continue
+		}
+		result = append(result, events[i])
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// SQLSink stores events in the audit_events table of a Postgres database.
+type SQLSink struct {
+	db *sql.DB
+}
+
+const createAuditTable = `CREATE TABLE IF NOT EXISTS audit_events (
+	id         BIGSERIAL PRIMARY KEY,
+	time       TIMESTAMPTZ NOT NULL,
+	actor      TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	action     TEXT NOT NULL,
+	namespace  TEXT NOT NULL,
+	database   TEXT NOT NULL,
+	request_id TEXT NOT NULL,
+	source_ip  TEXT NOT NULL,
+	result     TEXT NOT NULL,
+	error      TEXT NOT NULL
+)`
+
+func NewSQLSink(dsn string) (*SQLSink, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("audit postgres sink needs a DSN")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+	if _, err := db.Exec(createAuditTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create audit_events table: %w", err)
+	}
+	return &SQLSink{db: db}, nil
+}
+
+func (s *SQLSink) Write(e Event) error {
+	_, err := s.db.Exec(`INSERT INTO audit_events
+		(time, actor, role, action, namespace, database, request_id, source_ip, result, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		e.Time, e.Actor, e.Role, e.Action, e.Namespace, e.Database, e.RequestID, e.SourceIP, e.Result, e.Error)
+	return err
+}
+
+func (s *SQLSink) Query(f Filter) ([]Event, error) {
+	query, args := sqlQuery(f)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.Time, &e.Actor, &e.Role, &e.Action, &e.Namespace, &e.Database,
+			&e.RequestID, &e.SourceIP, &e.Result, &e.Error); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// sqlQuery builds the SELECT for a filter. Values are always bound as
+// arguments; only the limit, an int, is formatted into the query.
+func sqlQuery(f Filter) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, val interface{}) {
+		args = append(args, val)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.Namespace != "" {
+		add("namespace = $%d", f.Namespace)
+	}
+	if f.Database != "" {
+		add("database = $%d", f.Database)
+	}
+	if f.Result != "" {
+		add("result = $%d", f.Result)
+	}
+	if !f.Since.IsZero() {
+		add("time >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("time <= $%d", f.Until)
+	}
+
+	query := "SELECT time, actor, role, action, namespace, database, request_id, source_ip, result, error FROM audit_events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+	return query, args
+}
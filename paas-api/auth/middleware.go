@@ -1,76 +1,322 @@
 package auth
 
 import (
-	// "fmt"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/MicahParks/keyfunc"
 )
 
-const zitadelJWKSURL = "https://openstack-integration-3vzdfy.us1.zitadel.cloud/oauth/v2/keys"
-
-var jwks *keyfunc.JWKS
+var (
+	jwks       *keyfunc.JWKS
+	oidcConfig OIDCConfig
+)
 
-func InitJWT() error {
+// InitJWT discovers the provider's JWKS endpoint, unless configured, and
+// starts a background key refresh.
+func InitJWT(cfg OIDCConfig) error {
+	jwksURL := cfg.JWKSURL
 	var err error
-	jwks, err = keyfunc.Get(zitadelJWKSURL, keyfunc.Options{})
-	return err
+	if jwksURL == "" {
+		if jwksURL, err = discover(cfg.IssuerURL); err != nil {
+			return err
+		}
+	}
+
+
+	jwks, err = keyfunc.Get(jwksURL, keyfunc.Options{
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  5 * time.Minute,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			fmt.Printf("Failed to refresh JWKS from %s: %v\n", jwksURL, err)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if cfg.Audience == "" {
+		fmt.Printf("WARNING: OIDC audience not configured, aud claim will not be checked\n")
+	}
+	oidcConfig = cfg
+	return nil
 }
 
-func AuthMiddleware(requiredRoles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing Authorization header"})
-			return
+// validateClaims checks the registered claims jwt.Parse leaves optional.
+func validateClaims(claims jwt.MapClaims) error {
+	now := time.Now().Unix()
+
+	if !claims.VerifyExpiresAt(now, true) {
+		return fmt.Errorf("token is expired or has no exp claim")
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if !claims.VerifyIssuer(oidcConfig.IssuerURL, true) &&
+		!claims.VerifyIssuer(strings.TrimSuffix(oidcConfig.IssuerURL, "/"), true) {
+		return fmt.Errorf("unexpected token issuer")
+	}
+	if oidcConfig.Audience != "" && !claims.VerifyAudience(oidcConfig.Audience, true) {
+		return fmt.Errorf("token audience does not include %s", oidcConfig.Audience)
+	}
+	if oidcConfig.TokenUse != "" && claims["token_use"] != oidcConfig.TokenUse {
+		return fmt.Errorf("token_use must be %s", oidcConfig.TokenUse)
+	}
+	return nil
+}
+
+// principal is the authenticated caller of a request.
+type principal struct {
+	Subject string
+	Email   string
+	Method  string // "oidc" or "api_token"
+	Grants  []Grant
+	// Scopes limits what an API token may do; nil for OIDC callers.
+	Scopes         []string
+	TokenID        string
+	ServiceAccount string
+}
+
+// permissions returns what the caller may do on the tenant with the given slug.
+func (p *principal) permissions(tenant string) map[Permission]bool {
+	perms := permissionsFor(p.Grants, tenant)
+	if p.Scopes == nil {
+		return perms
+	}
+
+	allowed := map[Permission]bool{}
+	for _, scope := range p.Scopes {
+		for _, perm := range scopePermissions[scope] {
+			if perms[perm] {
+				allowed[perm] = true
+			}
 		}
+	}
+	return allowed
+}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+// authenticate resolves the caller from the Authorization header, which holds
+// either an OIDC access token or an API token.
+func authenticate(c *gin.Context) (*principal, error) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, fmt.Errorf("missing Authorization header")
+	}
 
-		token, err := jwt.Parse(tokenStr, jwks.Keyfunc)
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			return
+	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+
+	if strings.HasPrefix(tokenStr, TokenPrefix) {
+		return authenticateAPIToken(tokenStr)
+	}
+	return authenticateOIDC(tokenStr)
+}
+
+func authenticateOIDC(tokenStr string) (*principal, error) {
+	token, err := jwt.Parse(tokenStr, jwks.Keyfunc, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid claims")
+	}
+
+	if err := validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	p := &principal{
+		Subject: fmt.Sprint(claims["sub"]),
+		Method:  "oidc",
+	}
+	if email, ok := claims["email"].(string); ok {
+		p.Email = email
+	}
+
+	if rawRoles, ok := lookupClaim(claims, oidcConfig.RolesClaim); ok {
+		for _, role := range oidcConfig.mapRoles(extractRoles(rawRoles)) {
+			g := parseGrant(role)
+			if g.Role == "tenant" && g.Tenant == "" {
+				p.Grants = append(p.Grants, Grant{Role: "tenant", Tenant: tenantIdentity(claims, p.Subject)}, Grant{Role: "org-creator"})
+				continue
+			}
+			p.Grants = append(p.Grants, g)
 		}
+	}
+	return p, nil
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
-			return
+// tenantIdentity names the caller's own tenant the way the web console does:
+// the preferred_username, or else the email, up to any "@", lowercased and
+// stripped of everything but letters, digits and dashes. Callers with neither
+// claim fall back to their subject.
+func tenantIdentity(claims jwt.MapClaims, subject string) string {
+	raw, _ := claims["preferred_username"].(string)
+	if raw == "" {
+		raw, _ = claims["email"].(string)
+	}
+	raw, _, _ = strings.Cut(strings.ToLower(raw), "@")
+
+	identity := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
 		}
+		return -1
+	}, raw)
+	if identity == "" {
+		return subject
+	}
+	return identity
+}
 
-		// 🔍 Debugging (optional):
-		// fmt.Printf("JWT Claims: %+v\n", claims)
+// requestTenant finds the tenant a request targets, from the route parameters
+// or, for create/delete calls, from the "username" field of the JSON body. It
+// returns the identity as given and the tenant slug the handlers act on.
+func requestTenant(c *gin.Context) (identity, slug string) {
+	if namespace := c.Param("namespace"); namespace != "" {
+		name := strings.TrimPrefix(namespace, k8s.TenantNamespacePrefix)
+		return name, name
+	}
+	identity = requestIdentity(c)
+	if identity == "" {
+		return "", ""
+	}
+	return identity, k8s.TenantSlug(identity)
+}
+
+func requestIdentity(c *gin.Context) string {
+	if username := c.Param("username"); username != "" {
+		return username
+	}
+	if tenant := c.Param("tenant"); tenant != "" {
+		return tenant
+	}
+	if org := c.Param("org"); org != "" {
+		return org
+	}
 
-		rolesClaimKey := "urn:zitadel:iam:org:project:roles"
+	if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var target struct {
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(body, &target) != nil {
+		return ""
+	}
+	return target.Username
+}
 
-		rawRoles, ok := claims[rolesClaimKey].(map[string]interface{})
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "no roles in token"})
+// RequirePermission authenticates the caller and checks that one of their
+// roles grants perm on the tenant the request targets.
+func RequirePermission(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := authenticate(c)
+		if err != nil {
+			api.Abort(c, http.StatusUnauthorized, api.CodeUnauthorized, err.Error())
 			return
 		}
 
-		// ✅ Check for required role(s)
-		hasRole := false
-		for _, required := range requiredRoles {
-			if inner, exists := rawRoles[required]; exists {
-				if innerMap, ok := inner.(map[string]interface{}); ok && len(innerMap) > 0 {
-					hasRole = true
-					c.Set("role", required) // Optional
-					break
-				}
+		identity, tenant := requestTenant(c)
+
+		// Organization members get their org role on the org's tenant
+		if identity != "" && p.Method == "oidc" {
+			role, err := k8s.OrgMemberRole(identity, p.Subject)
+			if err != nil {
+				api.Abort(c, http.StatusInternalServerError, api.CodeInternal, err.Error())
+				return
+			}
+			if role != "" {
+				p.Grants = append(p.Grants, Grant{Role: role, Tenant: identity})
 			}
 		}
 
-		if !hasRole {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		setPrincipal(c, p)
+
+		perms := p.permissions(tenant)
+		if !perms[perm] {
+			audit.RecordResult(c, "auth.authorize", "", "", audit.ResultDenied,
+				fmt.Errorf("missing permission %s for %s %s", perm, c.Request.Method, c.FullPath()))
+			api.Abort(c, http.StatusForbidden, api.CodeForbidden, "insufficient permissions, requires "+string(perm))
 			return
 		}
 
-		c.Set("user_id", claims["sub"])
+		c.Set("permissions", perms)
+		c.Set("tenant", tenant)
 		c.Next()
 	}
 }
+
+// RequireAuthenticated only checks that the caller logged in through OIDC,
+// for routes that act on the caller themselves (e.g. accepting an invitation).
+func RequireAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := authenticate(c)
+		if err != nil {
+			api.Abort(c, http.StatusUnauthorized, api.CodeUnauthorized, err.Error())
+			return
+		}
+		if p.Method != "oidc" {
+			api.Abort(c, http.StatusForbidden, api.CodeForbidden, "this endpoint requires an interactive login")
+			return
+		}
+
+		setPrincipal(c, p)
+		c.Next()
+	}
+}
+
+func setPrincipal(c *gin.Context, p *principal) {
+	c.Set("user_id", p.Subject)
+	c.Set("email", p.Email)
+	c.Set("auth_method", p.Method)
+	c.Set("role", roleNames(p.Grants))
+	c.Set("grants", p.Grants)
+	if p.TokenID != "" {
+		c.Set("token_id", p.TokenID)
+	}
+	if p.ServiceAccount != "" {
+		c.Set("service_account", p.ServiceAccount)
+	}
+}
+
+// Grants returns the roles the caller of an authenticated request holds.
+func Grants(c *gin.Context) []Grant {
+	v, _ := c.Get("grants")
+	grants, _ := v.([]Grant)
+	return grants
+}
+
+// Tenant returns the slug of the tenant an authorized request targets, or ""
+// if it targets none.
+func Tenant(c *gin.Context) string {
+	return c.GetString("tenant")
+}
+
+// HasPermission reports whether the caller of an authorized request holds perm
+// on the tenant the request targets.
+func HasPermission(c *gin.Context, perm Permission) bool {
+	v, ok := c.Get("permissions")
+	if !ok {
+		return false
+	}
+	perms, ok := v.(map[Permission]bool)
+	return ok && perms[perm]
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"paas-api/auth/authtest"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// useIssuer trusts a local issuer for the rest of the test. cfg's issuer and
+// key set are filled in; its roles claim defaults to "roles".
+func useIssuer(t *testing.T, cfg OIDCConfig) *authtest.Issuer {
+	t.Helper()
+	issuer := authtest.NewIssuer(t)
+	cfg.IssuerURL = issuer.URL
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if err := InitJWT(cfg); err != nil {
+		t.Fatalf("InitJWT() error = %v", err)
+	}
+	t.Cleanup(func() {
+		jwks.EndBackground()
+		jwks, oidcConfig = nil, OIDCConfig{}
+	})
+
+	// Organization roles are looked up in the cluster
+	k8s.SetKubeClient(fake.NewSimpleClientset())
+	t.Cleanup(func() { k8s.SetKubeClient(nil) })
+	return issuer
+}
+
+// serve sends an authenticated GET to a router that requires perm on path.
+func serve(t *testing.T, perm Permission, route, path, token string) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(route, RequirePermission(perm), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestTenantIdentity(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   string
+	}{
+		{"preferred username", jwt.MapClaims{"preferred_username": "alice"}, "alice"},
+		{"lowercased", jwt.MapClaims{"preferred_username": "Alice"}, "alice"},
+		{"username that is an email", jwt.MapClaims{"preferred_username": "alice@example.com"}, "alice"},
+		{"email without username", jwt.MapClaims{"email": "Bob.Smith@example.com"}, "bobsmith"},
+		{"username before email", jwt.MapClaims{"preferred_username": "alice", "email": "bob@example.com"}, "alice"},
+		{"dashes kept", jwt.MapClaims{"preferred_username": "mary-jane_2"}, "mary-jane2"},
+		{"neither claim", jwt.MapClaims{}, "284739291"},
+		{"nothing left after stripping", jwt.MapClaims{"preferred_username": "__"}, "284739291"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tenantIdentity(tt.claims, "284739291"); got != tt.want {
+				t.Errorf("tenantIdentity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// The console names a user's tenant after their username, not the opaque
+// subject, and the tenant role must follow it.
+func TestTenantRoleIsScopedToUsername(t *testing.T) {
+	issuer := useIssuer(t, OIDCConfig{})
+	token := issuer.Sign(t, issuer.Claims("284739291", "alice", "tenant"))
+
+	for path, want := range map[string]int{
+		"/v1/databases/alice":     http.StatusOK,
+		"/v1/databases/284739291": http.StatusForbidden,
+		"/v1/databases/bob":       http.StatusForbidden,
+	} {
+		if got := serve(t, PermDatabaseRead, "/v1/databases/:username", path, token); got != want {
+			t.Errorf("GET %s = %d, want %d", path, got, want)
+		}
+	}
+
+	// An unscoped tenant role also lets the user found organizations
+	if got := serve(t, PermOrgCreate, "/v1/orgs", "/v1/orgs", token); got != http.StatusOK {
+		t.Errorf("org.create = %d, want 200", got)
+	}
+}
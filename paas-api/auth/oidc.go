@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OIDCConfig describes the identity provider the API trusts. Any OIDC compliant
+// provider works (Zitadel, Keycloak, Dex, ...) as long as it publishes
+// /.well-known/openid-configuration.
+type OIDCConfig struct {
+	IssuerURL string
+	// JWKSURL is the provider's key set. Left empty, it is discovered from
+	// the issuer.
+	JWKSURL string
+	// Audience is the expected aud claim (usually the client or project ID).
+	// Left empty, the audience is not checked.
+	Audience string
+	// RolesClaim is the claim holding the user's roles. Nested claims use dots,
+	// e.g. "realm_access.roles" for Keycloak.
+	RolesClaim string
+	// RoleMapping maps provider role names to API roles (see rbac.go).
+	// Roles without a mapping are used as-is.
+	RoleMapping map[string]string
+	// TokenUse, if set, must match the token_use claim (e.g. "access").
+	TokenUse string
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// discover fetches the provider metadata and returns the JWKS URL.
+func discover(issuerURL string) (string, error) {
+	wellKnown := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(wellKnown)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", wellKnown, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: status %d", wellKnown, resp.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return "", fmt.Errorf("discovery document issuer %q does not match configured issuer %q", doc.Issuer, issuerURL)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("discovery document for %s has no jwks_uri", issuerURL)
+	}
+	return doc.JWKSURI, nil
+}
+
+// lookupClaim resolves a claim by its full name first, then as a dotted path,
+// so both "urn:zitadel:iam:org:project:roles" and "realm_access.roles" work.
+func lookupClaim(claims map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := claims[path]; ok {
+		return v, true
+	}
+
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// extractRoles understands the common shapes of a roles claim:
+// Zitadel's {"role": {"orgID": "domain"}}, a JSON array of strings, or a
+// space separated string.
+func extractRoles(raw interface{}) []string {
+	var roles []string
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		for role, inner := range v {
+			if innerMap, ok := inner.(map[string]interface{}); ok && len(innerMap) == 0 {
+				continue
+			}
+			roles = append(roles, role)
+		}
+	case []interface{}:
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	case string:
+		roles = strings.Fields(v)
+	}
+	return roles
+}
+
+// mapRoles translates provider roles into API roles.
+func (cfg OIDCConfig) mapRoles(providerRoles []string) []string {
+	var roles []string
+	for _, r := range providerRoles {
+		if mapped, ok := cfg.RoleMapping[r]; ok {
+			roles = append(roles, mapped)
+		} else {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"paas-api/auth/authtest"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestAuthenticateOIDCClaims(t *testing.T) {
+	issuer := useIssuer(t, OIDCConfig{})
+	hour := time.Hour
+
+	tests := []struct {
+		name     string
+		audience string
+		tokenUse string
+		claims   func(jwt.MapClaims)
+		wantErr  bool
+	}{
+		{name: "valid", claims: func(jwt.MapClaims) {}},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-hour).Unix() }, wantErr: true},
+		{name: "no exp", claims: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "not valid yet", claims: func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(hour).Unix() }, wantErr: true},
+		{name: "valid since", claims: func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(-hour).Unix() }},
+		{name: "other issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: true},
+		{name: "no issuer", claims: func(c jwt.MapClaims) { delete(c, "iss") }, wantErr: true},
+		{name: "issuer with trailing slash", claims: func(c jwt.MapClaims) { c["iss"] = issuer.URL + "/" }, wantErr: true},
+		{name: "audience not checked", claims: func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{name: "audience", audience: "paas-api", claims: func(c jwt.MapClaims) { c["aud"] = "paas-api" }},
+		{name: "audience in a list", audience: "paas-api", claims: func(c jwt.MapClaims) { c["aud"] = []string{"console", "paas-api"} }},
+		{name: "other audience", audience: "paas-api", claims: func(c jwt.MapClaims) { c["aud"] = "another-client" }, wantErr: true},
+		{name: "no audience", audience: "paas-api", claims: func(jwt.MapClaims) {}, wantErr: true},
+		{name: "token use", tokenUse: "access", claims: func(c jwt.MapClaims) { c["token_use"] = "access" }},
+		{name: "id token", tokenUse: "access", claims: func(c jwt.MapClaims) { c["token_use"] = "id" }, wantErr: true},
+		{name: "no token use", tokenUse: "access", claims: func(jwt.MapClaims) {}, wantErr: true},
+	}
+
+	base := oidcConfig
+	t.Cleanup(func() { oidcConfig = base })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oidcConfig = base
+			oidcConfig.Audience = tt.audience
+			oidcConfig.TokenUse = tt.tokenUse
+
+			claims := issuer.Claims("284739291", "alice", "tenant")
+			tt.claims(claims)
+			_, err := authenticateOIDC(issuer.Sign(t, claims))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authenticateOIDC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The configured issuer may carry the trailing slash its tokens omit.
+func TestAuthenticateOIDCIssuerWithTrailingSlash(t *testing.T) {
+	issuer := useIssuer(t, OIDCConfig{})
+	oidcConfig.IssuerURL = issuer.URL + "/"
+
+	if _, err := authenticateOIDC(issuer.Sign(t, issuer.Claims("284739291", "alice"))); err != nil {
+		t.Errorf("authenticateOIDC() error = %v", err)
+	}
+}
+
+func TestAuthenticateOIDCSignature(t *testing.T) {
+	issuer := useIssuer(t, OIDCConfig{})
+	claims := issuer.Claims("284739291", "alice", "tenant")
+
+	stranger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "issuer's key", token: issuer.Sign(t, claims)},
+		{name: "another issuer's key", token: authtest.NewIssuer(t).Sign(t, claims), wantErr: true},
+		{name: "unknown key", token: sign(jwt.SigningMethodRS256, "unknown", stranger), wantErr: true},
+		{name: "symmetric algorithm", token: sign(jwt.SigningMethodHS256, authtest.KeyID, []byte("secret")), wantErr: true},
+		{name: "unsigned", token: sign(jwt.SigningMethodNone, authtest.KeyID, jwt.UnsafeAllowNoneSignatureType), wantErr: true},
+		{name: "garbage", token: "not.a.jwt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := authenticateOIDC(tt.token); (err != nil) != tt.wantErr {
+				t.Errorf("authenticateOIDC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDCRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    OIDCConfig
+		claims jwt.MapClaims
+		want   []Grant
+	}{
+		{
+			name:   "no roles claim",
+			cfg:    OIDCConfig{RolesClaim: "roles"},
+			claims: jwt.MapClaims{},
+		},
+		{
+			name:   "scoped and unscoped roles",
+			cfg:    OIDCConfig{RolesClaim: "roles"},
+			claims: jwt.MapClaims{"roles": []string{"viewer", "developer:acme"}},
+			want:   []Grant{{Role: "developer", Tenant: "acme"}, {Role: "viewer"}},
+		},
+		{
+			name:   "unscoped tenant role",
+			cfg:    OIDCConfig{RolesClaim: "roles"},
+			claims: jwt.MapClaims{"roles": []string{"tenant"}},
+			want:   []Grant{{Role: "org-creator"}, {Role: "tenant", Tenant: "alice"}},
+		},
+		{
+			name: "zitadel project roles",
+			cfg:  OIDCConfig{RolesClaim: "urn:zitadel:iam:org:project:roles"},
+			claims: jwt.MapClaims{"urn:zitadel:iam:org:project:roles": map[string]interface{}{
+				"admin": map[string]interface{}{"170283923": "example.com"},
+			}},
+			want: []Grant{{Role: "admin"}},
+		},
+		{
+			name: "keycloak realm roles",
+			cfg:  OIDCConfig{RolesClaim: "realm_access.roles"},
+			claims: jwt.MapClaims{"realm_access": map[string]interface{}{
+				"roles": []string{"offline_access", "viewer"},
+			}},
+			want: []Grant{{Role: "offline_access"}, {Role: "viewer"}},
+		},
+		{
+			name: "mapped roles",
+			cfg: OIDCConfig{RolesClaim: "groups", RoleMapping: map[string]string{
+				"idp-admins": "platform-admin",
+				"acme-devs":  "developer:acme",
+			}},
+			claims: jwt.MapClaims{"groups": "idp-admins acme-devs viewer"},
+			want:   []Grant{{Role: "developer", Tenant: "acme"}, {Role: "platform-admin"}, {Role: "viewer"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := useIssuer(t, tt.cfg)
+			claims := issuer.Claims("284739291", "alice")
+			delete(claims, "roles")
+			for k, v := range tt.claims {
+				claims[k] = v
+			}
+
+			p, err := authenticateOIDC(issuer.Sign(t, claims))
+			if err != nil {
+				t.Fatalf("authenticateOIDC() error = %v", err)
+			}
+			got := append([]Grant(nil), p.Grants...)
+			sort.Slice(got, func(i, j int) bool { return got[i].String() < got[j].String() })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("grants = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"roles":                             []interface{}{"viewer"},
+		"urn:zitadel:iam:org:project:roles": "zitadel",
+		"https://example.com/v1.0/roles":    "dotted name",
+		"realm_access":                      map[string]interface{}{"roles": "keycloak"},
+		"scalar":                            "not an object",
+	}
+	tests := []struct {
+		path   string
+		want   interface{}
+		wantOK bool
+	}{
+		{path: "roles", want: []interface{}{"viewer"}, wantOK: true},
+		{path: "urn:zitadel:iam:org:project:roles", want: "zitadel", wantOK: true},
+		{path: "https://example.com/v1.0/roles", want: "dotted name", wantOK: true},
+		{path: "realm_access.roles", want: "keycloak", wantOK: true},
+		{path: "realm_access.groups"},
+		{path: "scalar.roles"},
+		{path: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := lookupClaim(claims, tt.path)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lookupClaim(%q) = %v, %v; want %v, %v", tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractRoles(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  interface{}
+		want []string
+	}{
+		{
+			name: "zitadel roles with their organizations",
+			raw: map[string]interface{}{
+				"developer": map[string]interface{}{"170283923": "example.com"},
+				"viewer":    map[string]interface{}{"170283923": "example.com"},
+			},
+			want: []string{"developer", "viewer"},
+		},
+		{
+			name: "zitadel role granted in no organization",
+			raw:  map[string]interface{}{"developer": map[string]interface{}{}},
+		},
+		{name: "array", raw: []interface{}{"developer", "viewer:acme"}, want: []string{"developer", "viewer:acme"}},
+		{name: "array skips non strings", raw: []interface{}{"viewer", 42, nil}, want: []string{"viewer"}},
+		{name: "space separated", raw: " developer  viewer ", want: []string{"developer", "viewer"}},
+		{name: "number", raw: 42.0},
+		{name: "null", raw: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractRoles(tt.raw)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractRoles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapRoles(t *testing.T) {
+	cfg := OIDCConfig{RoleMapping: map[string]string{"idp-admins": "platform-admin"}}
+	got := cfg.mapRoles([]string{"idp-admins", "viewer"})
+	if want := []string{"platform-admin", "viewer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mapRoles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"paas-api/k8s"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Permission is a single action a caller may perform. credentials.read is
+// for databases; every other resource has its own credentials permission.
+type Permission string
+
+const (
+	PermDatabaseCreate        Permission = "database.create"
+	PermDatabaseRead          Permission = "database.read"
+	PermDatabaseUpdate        Permission = "database.update"
+	PermDatabaseDelete        Permission = "database.delete"
+	PermDatabaseBackup        Permission = "database.backup"
+	PermCredentialsRead       Permission = "credentials.read"
+	PermCacheCredentialsRead  Permission = "cache.credentials.read"
+	PermQueueCredentialsRead  Permission = "queue.credentials.read"
+	PermBucketCredentialsRead Permission = "bucket.credentials.read"
+	PermCacheCreate           Permission = "cache.create"
+	PermCacheRead             Permission = "cache.read"
+	PermCacheDelete           Permission = "cache.delete"
+	PermQueueCreate           Permission = "queue.create"
+	PermQueueRead             Permission = "queue.read"
+	PermQueueDelete           Permission = "queue.delete"
+	PermBucketCreate          Permission = "bucket.create"
+	PermBucketRead            Permission = "bucket.read"
+	PermBucketUpdate          Permission = "bucket.update"
+	PermBucketDelete          Permission = "bucket.delete"
+	PermAppCreate             Permission = "app.create"
+	PermAppRead               Permission = "app.read"
+	PermAppUpdate             Permission = "app.update"
+	PermAppDelete             Permission = "app.delete"
+	PermJobCreate             Permission = "job.create"
+	PermJobRead               Permission = "job.read"
+	PermJobRun                Permission = "job.run"
+	PermJobDelete             Permission = "job.delete"
+	PermRouteCreate           Permission = "route.create"
+	PermRouteRead             Permission = "route.read"
+	PermRouteUpdate           Permission = "route.update"
+	PermRouteDelete           Permission = "route.delete"
+	PermPodsRead              Permission = "pods.read"
+	PermTokensManage          Permission = "tokens.manage"
+	PermOrgCreate             Permission = "org.create"
+	PermOrgRead               Permission = "org.read"
+	PermOrgManage             Permission = "org.manage"
+	PermAdminTenantsRead      Permission = "admin.tenants.read"
+	PermAdminTenantsManage    Permission = "admin.tenants.manage"
+	PermAdminAuditRead        Permission = "admin.audit.read"
+	PermAdminConfigRead       Permission = "admin.config.read"
+)
+
+// AllPermissions is the catalogue used to validate custom roles.
+var AllPermissions = []Permission{
+	PermDatabaseCreate,
+	PermDatabaseRead,
+	PermDatabaseUpdate,
+	PermDatabaseDelete,
+	PermDatabaseBackup,
+	PermCredentialsRead,
+	PermCacheCredentialsRead,
+	PermQueueCredentialsRead,
+	PermBucketCredentialsRead,
+	PermCacheCreate,
+	PermCacheRead,
+	PermCacheDelete,
+	PermQueueCreate,
+	PermQueueRead,
+	PermQueueDelete,
+	PermBucketCreate,
+	PermBucketRead,
+	PermBucketUpdate,
+	PermBucketDelete,
+	PermAppCreate,
+	PermAppRead,
+	PermAppUpdate,
+	PermAppDelete,
+	PermJobCreate,
+	PermJobRead,
+	PermJobRun,
+	PermJobDelete,
+	PermRouteCreate,
+	PermRouteRead,
+	PermRouteUpdate,
+	PermRouteDelete,
+	PermPodsRead,
+	PermTokensManage,
+	PermOrgCreate,
+	PermOrgRead,
+	PermOrgManage,
+	PermAdminTenantsRead,
+	PermAdminTenantsManage,
+	PermAdminAuditRead,
+	PermAdminConfigRead,
+}
+
+// tenantPermissions are what a tenant may do with its own resources.
+var tenantPermissions = []Permission{
+	PermDatabaseCreate,
+	PermDatabaseRead,
+	PermDatabaseUpdate,
+	PermDatabaseDelete,
+	PermDatabaseBackup,
+	PermCacheCreate,
+	PermCacheRead,
+	PermCacheDelete,
+	PermQueueCreate,
+	PermQueueRead,
+	PermQueueDelete,
+	PermBucketCreate,
+	PermBucketRead,
+	PermBucketUpdate,
+	PermBucketDelete,
+	PermAppCreate,
+	PermAppRead,
+	PermAppUpdate,
+	PermAppDelete,
+	PermJobCreate,
+	PermJobRead,
+	PermJobRun,
+	PermJobDelete,
+	PermRouteCreate,
+	PermRouteRead,
+	PermRouteUpdate,
+	PermRouteDelete,
+	PermCredentialsRead,
+	PermCacheCredentialsRead,
+	PermQueueCredentialsRead,
+	PermBucketCredentialsRead,
+	PermPodsRead,
+	PermTokensManage,
+	PermOrgRead,
+}
+
+// Built-in roles. "admin" is kept as an alias of platform-admin so existing
+// identity provider role assignments keep working.
+//
+// "tenant" is the role of ordinary users. An unscoped tenant role from the
+// identity provider only applies to the user's own tenant, see
+// authenticateOIDC, and comes with "org-creator" so the user can found
+// organizations. Managing an organization needs its owner role.
+var builtinRoles = map[string][]Permission{
+	"tenant":      tenantPermissions,
+	"org-creator": {PermOrgCreate},
+	"viewer": {
+		PermDatabaseRead,
+		PermCacheRead,
+		PermQueueRead,
+		PermBucketRead,
+		PermAppRead,
+		PermJobRead,
+		PermRouteRead,
+		PermPodsRead,
+		PermOrgRead,
+	},
+	"developer": {
+		PermDatabaseRead,
+		PermCacheRead,
+		PermQueueRead,
+		PermBucketRead,
+		PermAppRead,
+		PermJobRead,
+		PermRouteRead,
+		PermPodsRead,
+		PermCredentialsRead,
+		PermCacheCredentialsRead,
+		PermQueueCredentialsRead,
+		PermBucketCredentialsRead,
+		PermTokensManage,
+		PermOrgRead,
+	},
+	"owner": {
+		PermDatabaseCreate,
+		PermDatabaseRead,
+		PermDatabaseUpdate,
+		PermDatabaseDelete,
+		PermDatabaseBackup,
+		PermCacheCreate,
+		PermCacheRead,
+		PermCacheDelete,
+		PermQueueCreate,
+		PermQueueRead,
+		PermQueueDelete,
+		PermBucketCreate,
+		PermBucketRead,
+		PermBucketUpdate,
+		PermBucketDelete,
+		PermAppCreate,
+		PermAppRead,
+		PermAppUpdate,
+		PermAppDelete,
+		PermJobCreate,
+		PermJobRead,
+		PermJobRun,
+		PermJobDelete,
+		PermRouteCreate,
+		PermRouteRead,
+		PermRouteUpdate,
+		PermRouteDelete,
+		PermCredentialsRead,
+		PermCacheCredentialsRead,
+		PermQueueCredentialsRead,
+		PermBucketCredentialsRead,
+		PermPodsRead,
+		PermTokensManage,
+		PermOrgCreate,
+		PermOrgRead,
+		PermOrgManage,
+	},
+	"platform-admin": AllPermissions,
+}
+
+var roleAliases = map[string]string{
+	"admin": "platform-admin",
+}
+
+var (
+	rolesMu     sync.RWMutex
+	customRoles = map[string][]Permission{}
+)
+
+// LoadCustomRoles reads additional roles from a JSON file of the form
+// {"role-name": ["database.read", "pods.read"]}. Built-in roles cannot be overridden.
+func LoadCustomRoles(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read roles file: %w", err)
+	}
+
+	var raw map[string][]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse roles file: %w", err)
+	}
+
+	roles := map[string][]Permission{}
+	for name, perms := range raw {
+		if _, builtin := builtinRoles[name]; builtin {
+			return fmt.Errorf("custom role %q conflicts with a built-in role", name)
+		}
+		if _, alias := roleAliases[name]; alias {
+			return fmt.Errorf("custom role %q conflicts with a built-in role", name)
+		}
+		for _, p := range perms {
+			if !isKnownPermission(Permission(p)) {
+				return fmt.Errorf("custom role %q has unknown permission %q", name, p)
+			}
+			roles[name] = append(roles[name], Permission(p))
+		}
+	}
+
+	rolesMu.Lock()
+	customRoles = roles
+	rolesMu.Unlock()
+	return nil
+}
+
+func isKnownPermission(p Permission) bool {
+	return hasPermission(AllPermissions, p)
+}
+
+// RolePermissions returns the permissions of a built-in or custom role.
+func RolePermissions(role string) ([]Permission, bool) {
+	if alias, ok := roleAliases[role]; ok {
+		role = alias
+	}
+	if perms, ok := builtinRoles[role]; ok {
+		return perms, true
+	}
+
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+	perms, ok := customRoles[role]
+	return perms, ok
+}
+
+// Roles lists every known role with its permissions, for display.
+func Roles() map[string][]Permission {
+	result := map[string][]Permission{}
+	for name, perms := range builtinRoles {
+		result[name] = perms
+	}
+
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+	for name, perms := range customRoles {
+		result[name] = perms
+	}
+	return result
+}
+
+// Grant is a role held by a caller. Tenant restricts the grant to a single
+// tenant; an empty Tenant applies to every tenant.
+//
+// Identity providers express tenant scoped grants as "role:tenant", e.g.
+// "developer:alice" grants developer only on tenant-alice.
+type Grant struct {
+	Role   string `json:"role"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func parseGrant(raw string) Grant {
+	role, tenant, _ := strings.Cut(raw, ":")
+	return Grant{Role: role, Tenant: tenant}
+}
+
+// selfPermissions act on the caller rather than on a tenant. A role holding
+// one on any tenant also holds it on tenant-less routes such as /tokens.
+var selfPermissions = []Permission{PermTokensManage}
+
+// permissionsFor collects the permissions the grants give on the tenant with
+// the given slug. Grants name tenants by identity, so they are compared by
+// their slug too. An empty tenant matches unscoped grants, and the
+// selfPermissions of scoped ones.
+func permissionsFor(grants []Grant, tenant string) map[Permission]bool {
+	perms := map[Permission]bool{}
+	for _, g := range grants {
+		rolePerms, ok := RolePermissions(g.Role)
+		if !ok {
+			continue
+		}
+		if g.Tenant != "" && k8s.TenantSlug(g.Tenant) != tenant {
+			if tenant == "" {
+				for _, p := range selfPermissions {
+					if hasPermission(rolePerms, p) {
+						perms[p] = true
+					}
+				}
+			}
+			continue
+		}
+		for _, p := range rolePerms {
+			perms[p] = true
+		}
+	}
+	return perms
+}
+
+func hasPermission(perms []Permission, p Permission) bool {
+	for _, perm := range perms {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the grant in the "role:tenant" form it is parsed from.
+func (g Grant) String() string {
+	if g.Tenant != "" {
+		return g.Role + ":" + g.Tenant
+	}
+	return g.Role
+}
+
+func grantStrings(grants []Grant) []string {
+	names := []string{}
+	for _, g := range grants {
+		names = append(names, g.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func roleNames(grants []Grant) string {
+	return strings.Join(grantStrings(grants), ",")
+}
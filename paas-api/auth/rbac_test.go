@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"paas-api/k8s"
+)
+
+func TestBuiltinRoles(t *testing.T) {
+	tests := []struct {
+		role  string
+		has   []Permission
+		lacks []Permission
+	}{
+		{
+			role:  "tenant",
+			has:   []Permission{PermDatabaseCreate, PermDatabaseDelete, PermCredentialsRead, PermBucketCredentialsRead, PermPodsRead, PermTokensManage, PermOrgRead},
+			lacks: []Permission{PermOrgCreate, PermOrgManage, PermAdminTenantsRead, PermAdminAuditRead},
+		},
+		{
+			role:  "org-creator",
+			has:   []Permission{PermOrgCreate},
+			lacks: []Permission{PermOrgRead, PermDatabaseRead, PermTokensManage},
+		},
+		{
+			role:  "viewer",
+			has:   []Permission{PermDatabaseRead, PermCacheRead, PermQueueRead, PermBucketRead, PermAppRead, PermJobRead, PermRouteRead, PermPodsRead, PermOrgRead},
+			lacks: []Permission{PermCredentialsRead, PermCacheCredentialsRead, PermDatabaseCreate, PermJobRun, PermTokensManage},
+		},
+		{
+			role:  "developer",
+			has:   []Permission{PermDatabaseRead, PermCredentialsRead, PermCacheCredentialsRead, PermQueueCredentialsRead, PermBucketCredentialsRead, PermTokensManage},
+			lacks: []Permission{PermDatabaseCreate, PermDatabaseDelete, PermAppUpdate, PermJobRun, PermOrgManage},
+		},
+		{
+			role:  "owner",
+			has:   []Permission{PermDatabaseCreate, PermDatabaseDelete, PermJobRun, PermRouteUpdate, PermOrgCreate, PermOrgManage},
+			lacks: []Permission{PermAdminTenantsRead, PermAdminTenantsManage, PermAdminAuditRead, PermAdminConfigRead},
+		},
+		{
+			role: "platform-admin",
+			has:  AllPermissions,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			perms, ok := RolePermissions(tt.role)
+			if !ok {
+				t.Fatalf("RolePermissions(%q) found no role", tt.role)
+			}
+			for _, p := range tt.has {
+				if !hasPermission(perms, p) {
+					t.Errorf("%s lacks %s", tt.role, p)
+				}
+			}
+			for _, p := range tt.lacks {
+				if hasPermission(perms, p) {
+					t.Errorf("%s has %s", tt.role, p)
+				}
+			}
+		})
+	}
+}
+
+func TestBuiltinRolesUseKnownPermissions(t *testing.T) {
+	for role, perms := range builtinRoles {
+		for _, p := range perms {
+			if !isKnownPermission(p) {
+				t.Errorf("role %s has %s, which is missing from AllPermissions", role, p)
+			}
+		}
+	}
+}
+
+func TestRoleAliases(t *testing.T) {
+	admin, ok := RolePermissions("admin")
+	if !ok {
+		t.Fatal("admin is not a role")
+	}
+	if platformAdmin, _ := RolePermissions("platform-admin"); !reflect.DeepEqual(admin, platformAdmin) {
+		t.Errorf("admin = %v, want the permissions of platform-admin", admin)
+	}
+	if _, ok := RolePermissions("root"); ok {
+		t.Error("RolePermissions found the unknown role root")
+	}
+}
+
+func TestPermissionsFor(t *testing.T) {
+	// Grants name tenants as the identity provider does; routes use slugs
+	email := "Alice@Example.com"
+	emailSlug := k8s.TenantSlug(email)
+
+	tests := []struct {
+		name   string
+		grants []Grant
+		tenant string
+		has    []Permission
+		lacks  []Permission
+	}{
+		{
+			name:   "scoped grant on its tenant",
+			grants: []Grant{{Role: "developer", Tenant: "alice"}},
+			tenant: "alice",
+			has:    []Permission{PermDatabaseRead, PermCredentialsRead},
+			lacks:  []Permission{PermDatabaseCreate},
+		},
+		{
+			name:   "scoped grant on another tenant",
+			grants: []Grant{{Role: "owner", Tenant: "alice"}},
+			tenant: "bob",
+			lacks:  []Permission{PermDatabaseRead, PermTokensManage},
+		},
+		{
+			name:   "scoped grant matched by slug",
+			grants: []Grant{{Role: "tenant", Tenant: email}},
+			tenant: emailSlug,
+			has:    []Permission{PermDatabaseCreate},
+		},
+		{
+			name:   "scoped grant not matched by identity",
+			grants: []Grant{{Role: "tenant", Tenant: email}},
+			tenant: email,
+			lacks:  []Permission{PermDatabaseCreate},
+		},
+		{
+			name:   "unscoped grant on every tenant",
+			grants: []Grant{{Role: "viewer"}},
+			tenant: "bob",
+			has:    []Permission{PermDatabaseRead},
+		},
+		{
+			name:   "unscoped grant without a tenant",
+			grants: []Grant{{Role: "platform-admin"}},
+			tenant: "",
+			has:    []Permission{PermAdminTenantsManage, PermDatabaseRead},
+		},
+		{
+			name:   "scoped grant without a tenant keeps only self permissions",
+			grants: []Grant{{Role: "tenant", Tenant: "alice"}},
+			tenant: "",
+			has:    []Permission{PermTokensManage},
+			lacks:  []Permission{PermDatabaseRead, PermOrgRead},
+		},
+		{
+			name:   "self permissions only from roles that hold them",
+			grants: []Grant{{Role: "viewer", Tenant: "alice"}},
+			tenant: "",
+			lacks:  []Permission{PermTokensManage},
+		},
+		{
+			name:   "grants add up",
+			grants: []Grant{{Role: "viewer", Tenant: "alice"}, {Role: "org-creator"}, {Role: "developer", Tenant: "bob"}},
+			tenant: "alice",
+			has:    []Permission{PermDatabaseRead, PermOrgCreate},
+			lacks:  []Permission{PermCredentialsRead},
+		},
+		{
+			name:   "alias",
+			grants: []Grant{{Role: "admin"}},
+			tenant: "alice",
+			has:    []Permission{PermAdminAuditRead},
+		},
+		{
+			name:   "unknown role",
+			grants: []Grant{{Role: "root"}},
+			tenant: "alice",
+			lacks:  []Permission{PermDatabaseRead},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perms := permissionsFor(tt.grants, tt.tenant)
+			for _, p := range tt.has {
+				if !perms[p] {
+					t.Errorf("lacks %s", p)
+				}
+			}
+			for _, p := range tt.lacks {
+				if perms[p] {
+					t.Errorf("has %s", p)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGrant(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Grant
+	}{
+		{"viewer", Grant{Role: "viewer"}},
+		{"developer:alice", Grant{Role: "developer", Tenant: "alice"}},
+		{"owner:alice:extra", Grant{Role: "owner", Tenant: "alice:extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got := parseGrant(tt.raw)
+			if got != tt.want {
+				t.Errorf("parseGrant(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+			if got.String() != tt.raw {
+				t.Errorf("String() = %q, want %q", got.String(), tt.raw)
+			}
+		})
+	}
+}
+
+func TestLoadCustomRoles(t *testing.T) {
+	t.Cleanup(func() {
+		rolesMu.Lock()
+		customRoles = map[string][]Permission{}
+		rolesMu.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: `{"auditor": ["database.read", "pods.read"]}`},
+		{name: "overrides a built-in role", content: `{"viewer": ["database.read"]}`, wantErr: true},
+		{name: "overrides an alias", content: `{"admin": ["database.read"]}`, wantErr: true},
+		{name: "unknown permission", content: `{"auditor": ["database.drop"]}`, wantErr: true},
+		{name: "not JSON", content: `auditor: [database.read]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "roles.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := LoadCustomRoles(path); (err != nil) != tt.wantErr {
+				t.Errorf("LoadCustomRoles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The last valid file is still in effect
+	perms, ok := RolePermissions("auditor")
+	if !ok {
+		t.Fatal("custom role auditor not found")
+	}
+	got := append([]Permission(nil), perms...)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if want := []Permission{PermDatabaseRead, PermPodsRead}; !reflect.DeepEqual(got, want) {
+		t.Errorf("auditor = %v, want %v", got, want)
+	}
+	if !permissionsFor([]Grant{{Role: "auditor", Tenant: "alice"}}, "alice")[PermPodsRead] {
+		t.Error("a scoped custom role grants nothing on its tenant")
+	}
+	if err := LoadCustomRoles(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadCustomRoles accepted a missing file")
+	}
+}
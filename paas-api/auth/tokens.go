@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"paas-api/k8s"
+	"strings"
+	"time"
+)
+
+// TokenPrefix distinguishes API tokens from OIDC JWTs in the Authorization header.
+const TokenPrefix = "ctk_"
+
+// ValidScopes lists the scopes an API token can be granted.
+var ValidScopes = []string{
+	"databases:read",
+	"databases:write",
+	"caches:read",
+	"caches:write",
+	"queues:read",
+	"queues:write",
+	"buckets:read",
+	"buckets:write",
+	"apps:read",
+	"apps:write",
+	"jobs:read",
+	"jobs:write",
+	"routes:read",
+	"routes:write",
+	"pods:read",
+}
+
+// scopePermissions maps token scopes to the permissions they unlock.
+var scopePermissions = map[string][]Permission{
+	"databases:read":  {PermDatabaseRead, PermCredentialsRead},
+	"databases:write": {PermDatabaseCreate, PermDatabaseUpdate, PermDatabaseDelete, PermDatabaseBackup},
+	"caches:read":     {PermCacheRead, PermCacheCredentialsRead},
+	"caches:write":    {PermCacheCreate, PermCacheDelete},
+	"queues:read":     {PermQueueRead, PermQueueCredentialsRead},
+	"queues:write":    {PermQueueCreate, PermQueueDelete},
+	"buckets:read":    {PermBucketRead, PermBucketCredentialsRead},
+	"buckets:write":   {PermBucketCreate, PermBucketUpdate, PermBucketDelete},
+	"apps:read":       {PermAppRead},
+	"apps:write":      {PermAppCreate, PermAppUpdate, PermAppDelete},
+	"jobs:read":       {PermJobRead},
+	"jobs:write":      {PermJobCreate, PermJobRun, PermJobDelete},
+	"routes:read":     {PermRouteRead},
+	"routes:write":    {PermRouteCreate, PermRouteUpdate, PermRouteDelete},
+	"pods:read":       {PermPodsRead},
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// UngrantedScopes returns the scopes none of the grants fully cover on the
+// grant's own tenant. A token must not be able to do more than the user who
+// creates it.
+func UngrantedScopes(grants []Grant, scopes []string) []string {
+	var missing []string
+	for _, scope := range scopes {
+		covered := false
+		for _, g := range grants {
+			tenant := ""
+			if g.Tenant != "" {
+				tenant = k8s.TenantSlug(g.Tenant)
+			}
+			perms := permissionsFor([]Grant{g}, tenant)
+			covered = true
+			for _, perm := range scopePermissions[scope] {
+				if !perms[perm] {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// GenerateAPIToken returns the plaintext token (shown to the user once) and
+// its stored form. Tokens look like ctk_<id>_<secret>, so the id can be used
+// to look the token up without scanning every stored hash. The token keeps
+// the creator's grants, including their tenant restrictions.
+func GenerateAPIToken(name, owner, serviceAccount string, grants []Grant, scopes []string, ttl time.Duration) (string, k8s.APIToken, error) {
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", k8s.APIToken{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", k8s.APIToken{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	id := hex.EncodeToString(idBytes)
+	plaintext := TokenPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	now := time.Now().UTC()
+	token := k8s.APIToken{
+		ID:             id,
+		Name:           name,
+		Owner:          owner,
+		ServiceAccount: serviceAccount,
+		Grants:         grantStrings(grants),
+		Scopes:         scopes,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ttl),
+		Hash:           hashToken(plaintext),
+	}
+	return plaintext, token, nil
+}
+
+// validateAPIToken looks up and checks a plaintext token.
+func validateAPIToken(plaintext string) (*k8s.APIToken, error) {
+	rest := strings.TrimPrefix(plaintext, TokenPrefix)
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("malformed API token")
+	}
+
+	token, err := k8s.GetAPIToken(id)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, fmt.Errorf("unknown or revoked API token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashToken(plaintext))) != 1 {
+		return nil, fmt.Errorf("unknown or revoked API token")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, fmt.Errorf("API token expired")
+	}
+	return token, nil
+}
+
+// authenticateAPIToken is the API token branch of authenticate. Tokens act
+// with the grants their creator held, narrowed down to the permissions of
+// their scopes. Scopes never include admin permissions. Tokens stored without
+// grants have no permissions.
+func authenticateAPIToken(plaintext string) (*principal, error) {
+	token, err := validateAPIToken(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := []Grant{}
+	for _, raw := range token.Grants {
+		grants = append(grants, parseGrant(raw))
+	}
+	return &principal{
+		Subject:        token.Owner,
+		Method:         "api_token",
+		Grants:         grants,
+		Scopes:         token.Scopes,
+		TokenID:        token.ID,
+		ServiceAccount: token.ServiceAccount,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUngrantedScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		grants []Grant
+		scopes []string
+		want   []string
+	}{
+		{"tenant", []Grant{{Role: "tenant", Tenant: "alice"}}, []string{"databases:write", "buckets:read"}, nil},
+		{"developer", []Grant{{Role: "developer", Tenant: "alice"}}, []string{"databases:read", "databases:write"}, []string{"databases:write"}},
+		{"viewer", []Grant{{Role: "viewer", Tenant: "alice"}}, []string{"databases:read"}, []string{"databases:read"}},
+		// Scopes are checked per grant; two partial grants do not add up
+		{"split over grants", []Grant{{Role: "viewer", Tenant: "alice"}, {Role: "developer", Tenant: "bob"}}, []string{"databases:read", "databases:write"}, []string{"databases:write"}},
+		{"org-creator only", []Grant{{Role: "org-creator"}}, []string{"pods:read"}, []string{"pods:read"}},
+		{"unknown role", []Grant{{Role: "nobody"}}, []string{"pods:read"}, []string{"pods:read"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UngrantedScopes(tt.grants, tt.scopes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UngrantedScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
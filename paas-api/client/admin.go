@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"paas-api/api"
+)
+
+// The methods below require the platform-admin role.
+
+func (c *Client) ListTenants(ctx context.Context) ([]api.TenantInfo, error) {
+	var out api.TenantListResponse
+	if err := c.do(ctx, http.MethodGet, "/admin/tenants", nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return out.Tenants, nil
+}
+
+func (c *Client) GetTenant(ctx context.Context, tenant string) (*api.TenantInfo, error) {
+	var out api.TenantInfo
+	if err := c.do(ctx, http.MethodGet, "/admin/tenants/"+url.PathEscape(tenant), nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) SuspendTenant(ctx context.Context, tenant string) (*api.TenantActionResponse, error) {
+	return c.tenantAction(ctx, http.MethodPost, "/admin/tenants/"+url.PathEscape(tenant)+"/suspend")
+}
+
+func (c *Client) UnsuspendTenant(ctx context.Context, tenant string) (*api.TenantActionResponse, error) {
+	return c.tenantAction(ctx, http.MethodPost, "/admin/tenants/"+url.PathEscape(tenant)+"/unsuspend")
+}
+
+// DeprovisionTenant deletes every database of the tenant and its namespace.
+func (c *Client) DeprovisionTenant(ctx context.Context, tenant string) (*api.TenantActionResponse, error) {
+	return c.tenantAction(ctx, http.MethodDelete, "/admin/tenants/"+url.PathEscape(tenant))
+}
+
+func (c *Client) tenantAction(ctx context.Context, method, path string) (*api.TenantActionResponse, error) {
+	var out api.TenantActionResponse
+	if err := c.do(ctx, method, path, nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,222 @@
+// Package client is a Go SDK for the PaaS API. It talks to the versioned /v1
+// routes and shares its request and response types with the server (package api).
+//
+//	c := client.New("https://paas.example.com", client.WithToken(os.Getenv("CLOUDTRACK_TOKEN")))
+//	db, err := c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"paas-api/api"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultBackoff    = 500 * time.Millisecond
+	maxBackoff        = 10 * time.Second
+)
+
+// TokenSource returns the bearer token for a request. It is called before
+// every attempt so short-lived OIDC tokens can be refreshed.
+type TokenSource func(ctx context.Context) (string, error)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      TokenSource
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithToken authenticates with a static OIDC access token or API token (ctk_...).
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+func WithTokenSource(src TokenSource) Option {
+	return func(c *Client) { c.token = src }
+}
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithRetries sets how often a failed request is retried and the initial
+// backoff, which doubles on each attempt. Zero retries disables retrying.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New returns a client for the API at baseURL, e.g. "https://paas.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  "cloudtrack-go-client",
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// do sends a request to path (relative to /v1) and decodes the JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}, header http.Header) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	// Only requests that are safe to repeat are retried after a server error
+	idempotent := method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete ||
+		header.Get("Idempotency-Key") != ""
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload, header)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.maxRetries || !idempotent {
+				return err
+			}
+			if err := c.sleep(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out == nil {
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+			return nil
+		}
+
+		apiErr := decodeError(resp)
+		resp.Body.Close()
+		if attempt >= c.maxRetries || !retryable(resp.StatusCode, idempotent) {
+			return apiErr
+		}
+		if err := c.sleep(ctx, attempt, retryAfter(resp)); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, header http.Header) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+api.Prefix+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+func retryable(status int, idempotent bool) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusInternalServerError:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// sleep waits before the next attempt using exponential backoff with jitter,
+// or the server's Retry-After if that is longer.
+func (c *Client) sleep(ctx context.Context, attempt int, min time.Duration) error {
+	wait := time.Duration(float64(c.backoff) * math.Pow(2, float64(attempt)))
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	wait = wait/2 + time.Duration(mathrand.Int63n(int64(wait/2)+1))
+	if wait < min {
+		wait = min
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
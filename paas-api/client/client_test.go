@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"paas-api/api"
+)
+
+// flakyServer fails the first failures requests with status, then answers 200.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	t.Helper()
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"username":"alice","db_name":"orders","status":"Ready"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &attempts
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		header   http.Header
+		status   int
+		wantErr  bool
+		attempts int32
+	}{
+		{"GET is retried after a server error", http.MethodGet, nil, http.StatusServiceUnavailable, false, 3},
+		{"POST is not retried after a server error", http.MethodPost, nil, http.StatusServiceUnavailable, true, 1},
+		{"POST with an idempotency key is retried", http.MethodPost, http.Header{"Idempotency-Key": {"k"}}, http.StatusBadGateway, false, 3},
+		{"POST is retried when rate limited", http.MethodPost, nil, http.StatusTooManyRequests, false, 3},
+		{"client errors are not retried", http.MethodGet, nil, http.StatusBadRequest, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, attempts := flakyServer(t, 2, tt.status)
+			c := New(srv.URL, WithRetries(3, time.Millisecond))
+
+			var out api.DatabaseStatusResponse
+			err := c.do(context.Background(), tt.method, "/status", nil, &out, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("do() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(attempts); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+			if !tt.wantErr && out.Status != "Ready" {
+				t.Errorf("status = %q, want Ready", out.Status)
+			}
+		})
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	srv, attempts := flakyServer(t, 10, http.StatusInternalServerError)
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+
+	_, err := c.GetDatabaseStatus(context.Background(), "alice", "orders")
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("error = %v, want ErrServer", err)
+	}
+	if got := atomic.LoadInt32(attempts); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestTokenSourceIsCalledPerAttempt(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if len(seen) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var calls int
+	c := New(srv.URL, WithRetries(1, time.Millisecond), WithTokenSource(func(context.Context) (string, error) {
+		calls++
+		return "token-" + strconv.Itoa(calls), nil
+	}))
+	if _, err := c.GetDatabaseStatus(context.Background(), "alice", "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer token-1" || seen[1] != "Bearer token-2" {
+		t.Errorf("Authorization headers = %v", seen)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		is        error
+		code      string
+		message   string
+		requestID string
+	}{
+		{
+			name:      "envelope",
+			status:    http.StatusConflict,
+			body:      `{"error":{"code":"conflict","message":"database orders already exists","request_id":"req-1"}}`,
+			is:        ErrConflict,
+			code:      api.CodeConflict,
+			message:   "database orders already exists",
+			requestID: "req-1",
+		},
+		{
+			name:    "suspended tenant",
+			status:  http.StatusForbidden,
+			body:    `{"error":{"code":"tenant_suspended","message":"tenant alice is suspended"}}`,
+			is:      ErrTenantSuspended,
+			code:    api.CodeTenantSuspended,
+			message: "tenant alice is suspended",
+		},
+		{
+			name:    "proxy error page",
+			status:  http.StatusBadGateway,
+			body:    "upstream unavailable",
+			is:      ErrServer,
+			code:    "http_502",
+			message: "upstream unavailable",
+		},
+		{
+			name:    "empty body",
+			status:  http.StatusNotFound,
+			is:      ErrNotFound,
+			code:    "http_404",
+			message: "Not Found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := New(srv.URL, WithRetries(0, 0)).GetDatabaseStatus(context.Background(), "alice", "orders")
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *Error", err)
+			}
+			if !errors.Is(err, tt.is) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.is)
+			}
+			if apiErr.Code != tt.code || apiErr.Message != tt.message || apiErr.RequestID != tt.requestID {
+				t.Errorf("error = %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":"validation_failed","message":"invalid request","details":{"fields":{"db_name":["must be lowercase"]}}}}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).CreateDatabase(context.Background(), api.CreateDatabaseRequest{Username: "alice", DBName: "Orders"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("error = %v, want a bad request", err)
+	}
+	if got := apiErr.FieldErrors()["db_name"]; len(got) != 1 || got[0] != "must be lowercase" {
+		t.Errorf("FieldErrors() = %v", apiErr.FieldErrors())
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"paas-api/api"
+)
+
+func databasePath(username, dbName string) string {
+	return "/databases/" + url.PathEscape(username) + "/" + url.PathEscape(dbName)
+}
+
+// CreateDatabase provisions a database. Every call carries a fresh
+// Idempotency-Key, so retries after a timeout never create a second cluster.
+// A database that already exists is reported as ErrConflict.
+func (c *Client) CreateDatabase(ctx context.Context, req api.CreateDatabaseRequest) (*api.CreateDatabaseResponse, error) {
+	header := http.Header{}
+	header.Set("Idempotency-Key", newIdempotencyKey())
+
+	var out api.CreateDatabaseResponse
+	if err := c.do(ctx, http.MethodPost, "/databases", req, &out, header); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListDatabaseClusters(ctx context.Context, username string) (*api.DatabaseListResponse, error) {
+	var out api.DatabaseListResponse
+	if err := c.do(ctx, http.MethodGet, "/databases/"+url.PathEscape(username), nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetDatabaseCluster(ctx context.Context, username, dbName string) (*api.DatabaseClusterInfo, error) {
+	var out api.DatabaseResponse
+	if err := c.do(ctx, http.MethodGet, databasePath(username, dbName), nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return out.Cluster, nil
+}
+
+// GetDatabaseCredentials returns ErrNotFound until the operator has created
+// the credentials secret.
+func (c *Client) GetDatabaseCredentials(ctx context.Context, username, dbName string) (*api.DatabaseCredentials, error) {
+	var out api.CredentialsResponse
+	if err := c.do(ctx, http.MethodGet, databasePath(username, dbName)+"/credentials", nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return out.Credentials, nil
+}
+
+func (c *Client) GetDatabaseStatus(ctx context.Context, username, dbName string) (string, error) {
+	var out api.DatabaseStatusResponse
+	if err := c.do(ctx, http.MethodGet, databasePath(username, dbName)+"/status", nil, &out, nil); err != nil {
+		return "", err
+	}
+	return out.Status, nil
+}
+
+// UpdateDatabase changes the number of replicas of a database.
+func (c *Client) UpdateDatabase(ctx context.Context, username, dbName string, req api.UpdateDatabaseRequest) (*api.UpdateDatabaseResponse, error) {
+	var out api.UpdateDatabaseResponse
+	if err := c.do(ctx, http.MethodPatch, databasePath(username, dbName), req, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteDatabase(ctx context.Context, username, dbName string) error {
+	req := api.DeleteDatabaseRequest{Username: username, DBName: dbName}
+	return c.do(ctx, http.MethodDelete, "/databases", req, nil, nil)
+}
+
+// GetDatabaseLogs returns the tail of the postgres log. pod may be empty to
+// read from the primary; lines <= 0 uses the server default.
+func (c *Client) GetDatabaseLogs(ctx context.Context, username, dbName, pod string, lines int) (*api.DatabaseLogsResponse, error) {
+	query := url.Values{}
+	if pod != "" {
+		query.Set("pod", pod)
+	}
+	if lines > 0 {
+		query.Set("lines", strconv.Itoa(lines))
+	}
+	path := databasePath(username, dbName) + "/logs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var out api.DatabaseLogsResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateBackup starts an on-demand logical backup. The returned Job is empty
+// if the server had to enable logical backups first; retry after a minute.
+func (c *Client) CreateBackup(ctx context.Context, username, dbName string) (*api.BackupResponse, error) {
+	var out api.BackupResponse
+	if err := c.do(ctx, http.MethodPost, databasePath(username, dbName)+"/backups", nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitForReady polls the cluster every interval until it accepts connections,
+// and returns it. It fails fast if the cluster reaches the Failed state;
+// use a context deadline to bound the wait.
+func (c *Client) WaitForReady(ctx context.Context, username, dbName string, interval time.Duration) (*api.DatabaseClusterInfo, error) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cluster, err := c.GetDatabaseCluster(ctx, username, dbName)
+		// The cluster may not be visible immediately after CreateDatabase
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if cluster != nil {
+			if cluster.ConnectionReady {
+				return cluster, nil
+			}
+			if cluster.Status == api.StatusFailed {
+				return cluster, fmt.Errorf("database %s failed to provision: %s", dbName, cluster.DetailedStatus)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return cluster, fmt.Errorf("waiting for database %s: %w", dbName, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
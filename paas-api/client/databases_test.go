@@ -0,0 +1,145 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"paas-api/api"
+	"paas-api/auth"
+	"paas-api/client"
+	"paas-api/k8s"
+	"paas-api/server/servertest"
+)
+
+func newClient(t *testing.T, srv *servertest.Server, grants ...auth.Grant) *client.Client {
+	t.Helper()
+	return client.New(srv.URL, client.WithToken(srv.Token(t, grants...)), client.WithRetries(0, 0))
+}
+
+func TestDatabaseLifecycle(t *testing.T) {
+	srv := servertest.New(t)
+	c := newClient(t, srv, auth.Grant{Role: "owner", Tenant: "alice"})
+	ctx := context.Background()
+
+	created, err := c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"})
+	if err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	if created.Namespace != "tenant-alice" || created.Credentials == nil || created.Credentials.Port != "5432" {
+		t.Errorf("CreateDatabase = %+v", created)
+	}
+
+	cluster, err := c.GetDatabaseCluster(ctx, "alice", "orders")
+	if err != nil {
+		t.Fatalf("GetDatabaseCluster: %v", err)
+	}
+	if cluster.Status != api.StatusProvisioning || cluster.ConnectionReady {
+		t.Errorf("cluster before its pods run = %s, connection ready %v", cluster.Status, cluster.ConnectionReady)
+	}
+
+	srv.RunStatefulSets(t)
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cluster, err = c.WaitForReady(waitCtx, "alice", "orders", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForReady: %v", err)
+	}
+	if cluster.Status != api.StatusReady || cluster.RunningReplicas != 1 {
+		t.Errorf("ready cluster = %+v", cluster)
+	}
+
+	status, err := c.GetDatabaseStatus(ctx, "alice", "orders")
+	if err != nil || status != api.StatusReady {
+		t.Errorf("GetDatabaseStatus = %q, %v", status, err)
+	}
+
+	credentials, err := c.GetDatabaseCredentials(ctx, "alice", "orders")
+	if err != nil {
+		t.Fatalf("GetDatabaseCredentials: %v", err)
+	}
+	if credentials.PrimaryUser["username"] != "orders" || credentials.PrimaryUser["password"] == "" {
+		t.Errorf("credentials = %+v", credentials)
+	}
+
+	list, err := c.ListDatabaseClusters(ctx, "alice")
+	if err != nil {
+		t.Fatalf("ListDatabaseClusters: %v", err)
+	}
+	if list.TotalClusters != 1 || list.Summary.Ready != 1 {
+		t.Errorf("ListDatabaseClusters = %+v", list)
+	}
+
+	if err := c.DeleteDatabase(ctx, "alice", "orders"); err != nil {
+		t.Fatalf("DeleteDatabase: %v", err)
+	}
+	if _, err := c.GetDatabaseCluster(ctx, "alice", "orders"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetDatabaseCluster after delete: %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateDatabaseErrors(t *testing.T) {
+	srv := servertest.New(t)
+	c := newClient(t, srv, auth.Grant{Role: "owner", Tenant: "alice"})
+	ctx := context.Background()
+
+	if _, err := c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"}); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	_, err := c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"})
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("second CreateDatabase: %v, want ErrConflict", err)
+	}
+
+	_, err = c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "Not_Valid"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != api.CodeValidation {
+		t.Fatalf("invalid CreateDatabase: %v, want a validation error", err)
+	}
+	if len(apiErr.FieldErrors()["db_name"]) == 0 {
+		t.Errorf("field errors = %v, want db_name", apiErr.FieldErrors())
+	}
+
+	_, err = c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "bob", DBName: "orders"})
+	if !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("CreateDatabase for another tenant: %v, want ErrForbidden", err)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	srv := servertest.New(t)
+	c := client.New(srv.URL, client.WithToken("ctk_0000_invalid"), client.WithRetries(0, 0))
+
+	if _, err := c.ListDatabaseClusters(context.Background(), "alice"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("ListDatabaseClusters with an unknown token: %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestAdminRoutesRefuseTokens(t *testing.T) {
+	srv := servertest.New(t)
+	admin := newClient(t, srv, auth.Grant{Role: "platform-admin"})
+
+	// API tokens only carry tenant scopes; platform admins sign in with OIDC
+	if _, err := admin.ListTenants(context.Background()); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("ListTenants with a token: %v, want ErrForbidden", err)
+	}
+}
+
+func TestSuspendedTenant(t *testing.T) {
+	srv := servertest.New(t)
+	c := newClient(t, srv, auth.Grant{Role: "owner", Tenant: "alice"})
+	ctx := context.Background()
+
+	if _, err := c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"}); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	if err := k8s.SuspendTenant("tenant-alice", "test-admin"); err != nil {
+		t.Fatalf("SuspendTenant: %v", err)
+	}
+
+	_, err := c.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "billing"})
+	if !errors.Is(err, client.ErrTenantSuspended) {
+		t.Errorf("CreateDatabase in a suspended tenant: %v, want ErrTenantSuspended", err)
+	}
+}
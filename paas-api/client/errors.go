@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"paas-api/api"
+)
+
+// Sentinel errors for use with errors.Is, e.g. errors.Is(err, client.ErrNotFound).
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrTenantSuspended = errors.New("tenant suspended")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrServer          = errors.New("server error")
+)
+
+// Error is a non-2xx response from the API.
+type Error struct {
+	StatusCode int
+	Code       string // api.Code* value, e.g. "validation_failed"
+	Message    string
+	Details    map[string]interface{}
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("cloudtrack: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrTenantSuspended:
+		return e.Code == api.CodeTenantSuspended
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// FieldErrors returns per-field validation messages from a validation_failed error.
+func (e *Error) FieldErrors() map[string][]string {
+	fields := map[string][]string{}
+	raw, ok := e.Details["fields"].(map[string]interface{})
+	if !ok {
+		return fields
+	}
+	for name, problems := range raw {
+		list, _ := problems.([]interface{})
+		for _, p := range list {
+			fields[name] = append(fields[name], fmt.Sprint(p))
+		}
+	}
+	return fields
+}
+
+func decodeError(resp *http.Response) *Error {
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		Code:       "http_" + fmt.Sprint(resp.StatusCode),
+		Message:    http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var envelope api.ErrorResponse
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Code != "" {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Details = envelope.Error.Details
+		if envelope.Error.RequestID != "" {
+			apiErr.RequestID = envelope.Error.RequestID
+		}
+	} else if len(body) > 0 {
+		// Not our envelope, e.g. an error page from a proxy
+		apiErr.Message = string(body)
+	}
+	return apiErr
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"paas-api/api"
+)
+
+// The methods below manage the caller's own API tokens. They need an
+// interactive login; API tokens cannot create other tokens.
+
+// CreateToken returns the new token with its plaintext, which the API never
+// shows again.
+func (c *Client) CreateToken(ctx context.Context, req api.CreateTokenRequest) (*api.CreateTokenResponse, error) {
+	var out api.CreateTokenResponse
+	if err := c.do(ctx, http.MethodPost, "/tokens", req, &out, nil); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListTokens(ctx context.Context) ([]api.APIToken, error) {
+	var out api.TokenListResponse
+	if err := c.do(ctx, http.MethodGet, "/tokens", nil, &out, nil); err != nil {
+		return nil, err
+	}
+	return out.Tokens, nil
+}
+
+func (c *Client) RevokeToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/tokens/"+url.PathEscape(id), nil, nil, nil)
+}
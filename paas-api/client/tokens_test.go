@@ -0,0 +1,99 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"paas-api/api"
+	"paas-api/client"
+	"paas-api/server/servertest"
+)
+
+// login returns a client for an interactive user holding roles.
+func login(t *testing.T, srv *servertest.Server, subject, username string, roles ...string) *client.Client {
+	t.Helper()
+	return client.New(srv.URL, client.WithToken(srv.Login(t, subject, username, roles...)), client.WithRetries(0, 0))
+}
+
+func TestTokenLifecycle(t *testing.T) {
+	srv := servertest.New(t)
+	alice := login(t, srv, "284739291", "alice", "tenant")
+	ctx := context.Background()
+
+	created, err := alice.CreateToken(ctx, api.CreateTokenRequest{Name: "ci", Scopes: []string{"databases:read", "databases:write"}})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if created.Token == "" || created.Details.Owner != "284739291" {
+		t.Errorf("CreateToken = %+v", created.Details)
+	}
+
+	ci := client.New(srv.URL, client.WithToken(created.Token), client.WithRetries(0, 0))
+	if _, err := ci.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"}); err != nil {
+		t.Errorf("CreateDatabase with the token: %v", err)
+	}
+	if _, err := ci.ListDatabaseClusters(ctx, "bob"); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("ListDatabaseClusters of another tenant: %v, want ErrForbidden", err)
+	}
+	if _, err := ci.ListTokens(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("ListTokens with a token: %v, want ErrForbidden", err)
+	}
+
+	tokens, err := alice.ListTokens(ctx)
+	if err != nil || len(tokens) != 1 || tokens[0].ID != created.Details.ID {
+		t.Fatalf("ListTokens = %+v, %v", tokens, err)
+	}
+
+	// Tokens of other users are invisible
+	bob := login(t, srv, "512000001", "bob", "tenant")
+	if err := bob.RevokeToken(ctx, created.Details.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("RevokeToken of another user: %v, want ErrNotFound", err)
+	}
+	if tokens, err := bob.ListTokens(ctx); err != nil || len(tokens) != 0 {
+		t.Errorf("ListTokens of another user = %d tokens, %v", len(tokens), err)
+	}
+
+	if err := alice.RevokeToken(ctx, created.Details.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := ci.ListDatabaseClusters(ctx, "alice"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("request with a revoked token: %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	srv := servertest.New(t)
+	ctx := context.Background()
+
+	// A read-only token cannot write, though its creator can
+	alice := login(t, srv, "284739291", "alice", "tenant")
+	created, err := alice.CreateToken(ctx, api.CreateTokenRequest{Name: "dashboard", Scopes: []string{"databases:read"}})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	readOnly := client.New(srv.URL, client.WithToken(created.Token), client.WithRetries(0, 0))
+	if _, err := readOnly.ListDatabaseClusters(ctx, "alice"); err != nil {
+		t.Errorf("ListDatabaseClusters with a read token: %v", err)
+	}
+	if _, err := readOnly.CreateDatabase(ctx, api.CreateDatabaseRequest{Username: "alice", DBName: "orders"}); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("CreateDatabase with a read token: %v, want ErrForbidden", err)
+	}
+
+	// A token cannot do more than its creator
+	developer := login(t, srv, "512000001", "bob", "developer:alice")
+	if _, err := developer.CreateToken(ctx, api.CreateTokenRequest{Name: "ci", Scopes: []string{"databases:read"}}); err != nil {
+		t.Errorf("CreateToken within the developer role: %v", err)
+	}
+	_, err = developer.CreateToken(ctx, api.CreateTokenRequest{Name: "ci", Scopes: []string{"databases:write"}})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrForbidden) || apiErr.Details["missing_scopes"] == nil {
+		t.Errorf("CreateToken beyond the developer role: %v, want missing_scopes", err)
+	}
+
+	// Viewers hold no tokens.manage on any tenant
+	viewer := login(t, srv, "512000002", "carol", "viewer:alice")
+	if _, err := viewer.ListTokens(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("ListTokens as a viewer: %v, want ErrForbidden", err)
+	}
+}
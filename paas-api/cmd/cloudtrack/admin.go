@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"paas-api/k8s"
+)
+
+func newAdminCmd(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Platform administration (requires the platform-admin role)",
+	}
+	cmd.AddCommand(newAdminTenantsCmd(opts))
+	return cmd
+}
+
+func newAdminTenantsCmd(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "tenants",
+		Aliases: []string{"tenant"},
+		Short:   "List and manage tenants",
+		Args:    cobra.NoArgs,
+		// `cloudtrack admin tenants` on its own lists tenants
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listTenants(cmd, opts)
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List tenants",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listTenants(cmd, opts)
+		},
+	}, &cobra.Command{
+		Use:   "get TENANT",
+		Short: "Show a tenant with its quotas and databases",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			tenant, err := c.GetTenant(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return printOutput(cmd, opts.output, tenant, func(t *table) {
+				t.header("FIELD", "VALUE")
+				t.row("Name", tenant.Name)
+				t.row("Namespace", tenant.Namespace)
+				t.row("Owner", orDash(tenant.Owner))
+				t.row("Suspended", suspendedLabel(tenant))
+				t.row("Databases", tenant.DatabaseCount)
+				t.row("Pods", tenant.PodCount)
+				t.row("CPU", tenant.CPUUsage)
+				t.row("Memory", tenant.MemoryUsage)
+				for _, db := range tenant.Databases {
+					t.row("Database "+db.Name, db.Status)
+				}
+			})
+		},
+	})
+
+	var yes bool
+	deleteCmd := &cobra.Command{
+		Use:   "delete TENANT",
+		Short: "Deprovision a tenant, deleting all its databases",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			if !yes && !confirm(cmd, fmt.Sprintf("Deprovision tenant %s and delete all its data?", args[0])) {
+				return errors.New("aborted")
+			}
+			resp, err := c.DeprovisionTenant(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), resp.Message+": "+resp.Namespace)
+			return nil
+		},
+	}
+	deleteCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "suspend TENANT",
+		Short: "Suspend a tenant, pausing its databases",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			resp, err := c.SuspendTenant(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), resp.Message+": "+resp.Namespace)
+			return nil
+		},
+	}, &cobra.Command{
+		Use:   "unsuspend TENANT",
+		Short: "Lift a suspension and resume the tenant's databases",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+			resp, err := c.UnsuspendTenant(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), resp.Message+": "+resp.Namespace)
+			return nil
+		},
+	}, deleteCmd)
+	return cmd
+}
+
+func listTenants(cmd *cobra.Command, opts *globalOptions) error {
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	tenants, err := c.ListTenants(cmd.Context())
+	if err != nil {
+		return err
+	}
+	return printOutput(cmd, opts.output, tenants, func(t *table) {
+		t.header("NAME", "OWNER", "DATABASES", "PODS", "CPU", "MEMORY", "SUSPENDED")
+		for i := range tenants {
+			tn := &tenants[i]
+			t.row(tn.Name, orDash(tn.Owner), tn.DatabaseCount, tn.PodCount, tn.CPUUsage, tn.MemoryUsage, suspendedLabel(tn))
+		}
+	})
+}
+
+func suspendedLabel(t *k8s.TenantInfo) string {
+	if !t.Suspended {
+		return "no"
+	}
+	return "yes (by " + orDash(t.SuspendedBy) + ")"
+}
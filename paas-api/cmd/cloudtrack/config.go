@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultIssuer = "https://openstack-integration-3vzdfy.us1.zitadel.cloud"
+
+// config is persisted in $XDG_CONFIG_HOME/cloudtrack/config.json.
+type config struct {
+	APIURL   string `json:"api_url,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// storedToken is the result of `cloudtrack login`, kept next to the config
+// with 0600 permissions.
+type storedToken struct {
+	AccessToken   string    `json:"access_token"`
+	RefreshToken  string    `json:"refresh_token,omitempty"`
+	Expiry        time.Time `json:"expiry"`
+	TokenEndpoint string    `json:"token_endpoint"`
+	ClientID      string    `json:"client_id"`
+}
+
+// configKeys maps `cloudtrack config set` keys to fields.
+var configKeys = map[string]func(*config) *string{
+	"api-url":   func(c *config) *string { return &c.APIURL },
+	"tenant":    func(c *config) *string { return &c.Tenant },
+	"issuer":    func(c *config) *string { return &c.Issuer },
+	"client-id": func(c *config) *string { return &c.ClientID },
+}
+
+func configDir() (string, error) {
+	if dir := os.Getenv("CLOUDTRACK_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(base, "cloudtrack"), nil
+}
+
+func readJSON(name string, v interface{}) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+func writeJSON(name string, v interface{}) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), data, 0o600)
+}
+
+func removeFile(name string) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func loadConfig() (*config, error) {
+	cfg := &config{}
+	if err := readJSON("config.json", cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = defaultIssuer
+	}
+	return cfg, nil
+}
+
+func saveConfig(cfg *config) error {
+	return writeJSON("config.json", cfg)
+}
+
+func loadToken() (*storedToken, error) {
+	tok := &storedToken{}
+	if err := readJSON("token.json", tok); err != nil {
+		return nil, err
+	}
+	if tok.AccessToken == "" {
+		return nil, nil
+	}
+	return tok, nil
+}
+
+func saveToken(tok *storedToken) error {
+	return writeJSON("token.json", tok)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"paas-api/api"
+	"paas-api/client"
+	"paas-api/k8s"
+)
+
+func newDBCmd(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "db",
+		Aliases: []string{"database", "databases"},
+		Short:   "Manage PostgreSQL databases",
+	}
+	cmd.AddCommand(
+		newDBCreateCmd(opts),
+		newDBListCmd(opts),
+		newDBGetCmd(opts),
+		newDBDeleteCmd(opts),
+		newDBCredentialsCmd(opts),
+		newDBLogsCmd(opts),
+		newDBBackupCmd(opts),
+		newDBConnectCmd(opts),
+	)
+	return cmd
+}
+
+func newDBCreateCmd(opts *globalOptions) *cobra.Command {
+	var replicas int
+	var engine string
+	var wait bool
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "create [NAME]",
+		Short: "Provision a database; the name is derived from the tenant if omitted",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			req := api.CreateDatabaseRequest{Username: tenant, Replicas: replicas, Engine: engine}
+			if len(args) == 1 {
+				req.DBName = args[0]
+			}
+
+			resp, err := c.CreateDatabase(cmd.Context(), req)
+			if err != nil {
+				return describeError(err)
+			}
+			if !wait {
+				return printOutput(cmd, opts.output, resp, func(t *table) {
+					t.header("NAME", "NAMESPACE", "STATUS", "HOST")
+					t.row(resp.DBName, resp.Namespace, resp.Credentials.Status, resp.Credentials.Host)
+				})
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Waiting for %s to become ready...\n", resp.DBName)
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			cluster, err := c.WaitForReady(ctx, tenant, resp.DBName, 5*time.Second)
+			if err != nil {
+				return err
+			}
+			return printCluster(cmd, opts, cluster)
+		},
+	}
+	cmd.Flags().IntVar(&replicas, "replicas", 1, "Number of instances")
+	cmd.Flags().StringVar(&engine, "engine", "", "postgresql (default), mysql or mariadb")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until the database accepts connections")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long --wait waits")
+	return cmd
+}
+
+func newDBListCmd(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the tenant's databases",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			resp, err := c.ListDatabaseClusters(cmd.Context(), tenant)
+			if err != nil {
+				return describeError(err)
+			}
+			return printOutput(cmd, opts.output, resp, func(t *table) {
+				t.header("NAME", "ENGINE", "STATUS", "REPLICAS", "CONNECTION", "CREATED")
+				for _, cl := range resp.Clusters {
+					t.row(cl.Name, orDash(cl.Engine), cl.Status, fmt.Sprintf("%d/%d", cl.RunningReplicas, cl.Replicas), readiness(cl.ConnectionReady), cl.CreatedAt)
+				}
+			})
+		},
+	}
+}
+
+func newDBGetCmd(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get NAME",
+		Short: "Show a database's status",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			cluster, err := c.GetDatabaseCluster(cmd.Context(), tenant, args[0])
+			if err != nil {
+				return describeError(err)
+			}
+			return printCluster(cmd, opts, cluster)
+		},
+	}
+}
+
+func printCluster(cmd *cobra.Command, opts *globalOptions, cl *k8s.DatabaseClusterInfo) error {
+	return printOutput(cmd, opts.output, cl, func(t *table) {
+		t.header("FIELD", "VALUE")
+		t.row("Name", cl.Name)
+		t.row("Namespace", cl.Namespace)
+		t.row("Engine", orDash(cl.Engine))
+		t.row("Status", cl.Status)
+		t.row("Detail", orDash(cl.DetailedStatus))
+		t.row("Replicas", fmt.Sprintf("%d/%d running", cl.RunningReplicas, cl.Replicas))
+		t.row("Credentials", readiness(cl.CredentialsReady))
+		t.row("Connection", readiness(cl.ConnectionReady))
+		t.row("Created", cl.CreatedAt)
+	})
+}
+
+func newDBDeleteCmd(opts *globalOptions) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a database and its data",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			if !yes && !confirm(cmd, fmt.Sprintf("Delete database %s of tenant %s? All data will be lost.", args[0], tenant)) {
+				return errors.New("aborted")
+			}
+			if err := c.DeleteDatabase(cmd.Context(), tenant, args[0]); err != nil {
+				return describeError(err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Database %s deleted.\n", args[0])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func newDBCredentialsCmd(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:     "credentials NAME",
+		Aliases: []string{"creds"},
+		Short:   "Show connection credentials",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			creds, err := c.GetDatabaseCredentials(cmd.Context(), tenant, args[0])
+			if err != nil {
+				return describeError(err)
+			}
+			return printOutput(cmd, opts.output, creds, func(t *table) {
+				t.header("FIELD", "VALUE")
+				t.row("Database", creds.DatabaseName)
+				t.row("Host", creds.Host)
+				t.row("Port", creds.Port)
+				t.row("Username", orDash(creds.PrimaryUser["username"]))
+				t.row("Password", orDash(creds.PrimaryUser["password"]))
+				t.row("URL", orDash(creds.ConnectionString))
+			})
+		},
+	}
+}
+
+func newDBLogsCmd(opts *globalOptions) *cobra.Command {
+	var pod string
+	var lines int
+	cmd := &cobra.Command{
+		Use:   "logs NAME",
+		Short: "Print the PostgreSQL log of the primary or a given pod",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			resp, err := c.GetDatabaseLogs(cmd.Context(), tenant, args[0], pod, lines)
+			if err != nil {
+				return describeError(err)
+			}
+			if opts.output != "table" {
+				return printOutput(cmd, opts.output, resp, nil)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "==> %s <==\n", resp.Pod)
+			_, err = fmt.Fprint(cmd.OutOrStdout(), resp.Logs)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&pod, "pod", "", "Pod to read from, defaults to the primary")
+	cmd.Flags().IntVar(&lines, "lines", 0, "Number of lines to show (server default 200)")
+	return cmd
+}
+
+func newDBBackupCmd(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "backup NAME",
+		Short: "Start an on-demand logical backup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			resp, err := c.CreateBackup(cmd.Context(), tenant, args[0])
+			if err != nil {
+				return describeError(err)
+			}
+			return printOutput(cmd, opts.output, resp, func(t *table) {
+				t.header("DATABASE", "JOB", "MESSAGE")
+				t.row(resp.DBName, orDash(resp.Job), resp.Message)
+			})
+		},
+	}
+}
+
+func newDBConnectCmd(opts *globalOptions) *cobra.Command {
+	var host string
+	var port, localPort int
+	var portForward bool
+
+	cmd := &cobra.Command{
+		Use:   "connect NAME [-- PSQL_ARGS...]",
+		Short: "Open psql with the database's credentials",
+		Long: `Fetch the database credentials and start psql.
+
+Database hosts are cluster-internal. From outside the cluster either pass
+--port-forward, which runs kubectl port-forward for the duration of the
+session, or point --host/--port at an existing tunnel.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, tenant, err := opts.clientForTenant()
+			if err != nil {
+				return err
+			}
+			dbName := args[0]
+			creds, err := c.GetDatabaseCredentials(cmd.Context(), tenant, dbName)
+			if err != nil {
+				return describeError(err)
+			}
+			if creds.Engine != "" && creds.Engine != k8s.EnginePostgreSQL {
+				return fmt.Errorf("%s is a %s database; connect with its client using 'cloudtrack db credentials %s'", dbName, creds.Engine, dbName)
+			}
+
+			psql, err := exec.LookPath("psql")
+			if err != nil {
+				return errors.New("psql not found in PATH")
+			}
+
+			connectHost, connectPort := creds.Host, creds.Port
+			if portForward {
+				namespace := k8s.TenantNamespace(tenant)
+				// The service name is the first label of the host, which differs between providers
+				service := strings.SplitN(creds.Host, ".", 2)[0]
+				stop, err := startPortForward(cmd, namespace, service, localPort, creds.Port)
+				if err != nil {
+					return err
+				}
+				defer stop()
+				connectHost, connectPort = "127.0.0.1", strconv.Itoa(localPort)
+			}
+			if host != "" {
+				connectHost = host
+			}
+			if port != 0 {
+				connectPort = strconv.Itoa(port)
+			}
+
+			psqlCmd := exec.CommandContext(cmd.Context(), psql, args[1:]...)
+			psqlCmd.Stdin, psqlCmd.Stdout, psqlCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			// Pass credentials through the environment so they don't show up in ps
+			psqlCmd.Env = append(os.Environ(),
+				"PGHOST="+connectHost,
+				"PGPORT="+connectPort,
+				"PGDATABASE="+creds.DatabaseName,
+				"PGUSER="+creds.PrimaryUser["username"],
+				"PGPASSWORD="+creds.PrimaryUser["password"],
+				"PGSSLMODE=prefer",
+			)
+			return psqlCmd.Run()
+		},
+	}
+	cmd.Flags().StringVar(&host, "host", "", "Override the database host")
+	cmd.Flags().IntVar(&port, "port", 0, "Override the database port")
+	cmd.Flags().BoolVar(&portForward, "port-forward", false, "Tunnel through kubectl port-forward")
+	cmd.Flags().IntVar(&localPort, "local-port", 15432, "Local port used by --port-forward")
+	return cmd
+}
+
+// startPortForward runs kubectl port-forward from localPort to remotePort of
+// the database service and waits until the local port accepts connections.
+func startPortForward(cmd *cobra.Command, namespace, service string, localPort int, remotePort string) (func(), error) {
+	pf := exec.Command("kubectl", "port-forward", "-n", namespace, "svc/"+service, fmt.Sprintf("%d:%s", localPort, remotePort))
+	pf.Stderr = cmd.ErrOrStderr()
+	if err := pf.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start kubectl port-forward: %w", err)
+	}
+	stop := func() {
+		_ = pf.Process.Kill()
+		_ = pf.Wait()
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort))
+	for i := 0; i < 50; i++ {
+		if conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond); err == nil {
+			conn.Close()
+			return stop, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	stop()
+	return nil, fmt.Errorf("port-forward to %s/%s did not become ready", namespace, service)
+}
+
+func (o *globalOptions) clientForTenant() (*client.Client, string, error) {
+	tenant, err := o.tenantName()
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := o.client()
+	if err != nil {
+		return nil, "", err
+	}
+	return c, tenant, nil
+}
+
+func readiness(ok bool) string {
+	if ok {
+		return "ready"
+	}
+	return "pending"
+}
+
+// describeError adds field-level validation messages to API errors.
+func describeError(err error) error {
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != api.CodeValidation {
+		return err
+	}
+	msg := apiErr.Message
+	for field, problems := range apiErr.FieldErrors() {
+		for _, p := range problems {
+			msg += fmt.Sprintf("\n  %s: %s", field, p)
+		}
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+type oidcEndpoints struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func newLoginCmd(opts *globalOptions) *cobra.Command {
+	var issuer, clientID, scope string
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in with the OIDC device flow",
+		Long: `Log in through your identity provider using the OAuth device authorization
+flow. The CLI prints a code and a URL to open in any browser.
+
+The client ID must belong to a native application with the device code grant
+enabled. Issuer and client ID are saved in the config file for next time.
+
+For CI, skip login and set CLOUDTRACK_TOKEN to an API token instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := opts.cfg
+			if issuer != "" {
+				cfg.Issuer = issuer
+			}
+			if clientID != "" {
+				cfg.ClientID = clientID
+			}
+			if cfg.ClientID == "" {
+				return errors.New("--client-id is required on first login")
+			}
+
+			tok, err := deviceLogin(cmd.Context(), cfg.Issuer, cfg.ClientID, scope, cmd)
+			if err != nil {
+				return err
+			}
+			if err := saveToken(tok); err != nil {
+				return fmt.Errorf("failed to save token: %w", err)
+			}
+			if err := saveConfig(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Logged in.")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&issuer, "issuer", "", "OIDC issuer URL")
+	cmd.Flags().StringVar(&clientID, "client-id", "", "OIDC client ID of the CLI application")
+	cmd.Flags().StringVar(&scope, "scope", "openid profile email offline_access", "Scopes to request")
+	return cmd
+}
+
+func newLogoutCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Remove the stored login token",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := removeFile("token.json"); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Logged out.")
+			return nil
+		},
+	}
+}
+
+func discoverEndpoints(ctx context.Context, issuer string) (*oidcEndpoints, error) {
+	wellKnown := strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", wellKnown, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", wellKnown, resp.Status)
+	}
+
+	var endpoints oidcEndpoints
+	if err := json.NewDecoder(resp.Body).Decode(&endpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC discovery document: %w", err)
+	}
+	if endpoints.DeviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("issuer %s does not support the device authorization flow", issuer)
+	}
+	return &endpoints, nil
+}
+
+func postForm(ctx context.Context, endpoint string, form url.Values, out interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("POST %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to parse response from %s: %w", endpoint, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func deviceLogin(ctx context.Context, issuer, clientID, scope string, cmd *cobra.Command) (*storedToken, error) {
+	endpoints, err := discoverEndpoints(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth deviceAuthorization
+	status, err := postForm(ctx, endpoints.DeviceAuthorizationEndpoint, url.Values{
+		"client_id": {clientID},
+		"scope":     {scope},
+	}, &auth)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || auth.DeviceCode == "" {
+		return nil, fmt.Errorf("device authorization failed with status %d", status)
+	}
+
+	out := cmd.ErrOrStderr()
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "Open %s in a browser to log in.\n", auth.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(out, "Open %s in a browser to log in.\n", auth.VerificationURI)
+	}
+	fmt.Fprintf(out, "Confirm the code: %s\n", auth.UserCode)
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if auth.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, errors.New("the device code expired, run login again")
+		}
+
+		var tok tokenResponse
+		if _, err := postForm(ctx, endpoints.TokenEndpoint, url.Values{
+			"grant_type":  {deviceCodeGrant},
+			"device_code": {auth.DeviceCode},
+			"client_id":   {clientID},
+		}, &tok); err != nil {
+			return nil, err
+		}
+
+		switch tok.Error {
+		case "":
+			return newStoredToken(tok, endpoints.TokenEndpoint, clientID), nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, errors.New("login was denied")
+		case "expired_token":
+			return nil, errors.New("the device code expired, run login again")
+		default:
+			return nil, fmt.Errorf("login failed: %s %s", tok.Error, tok.ErrorDescription)
+		}
+	}
+}
+
+func newStoredToken(tok tokenResponse, tokenEndpoint, clientID string) *storedToken {
+	return &storedToken{
+		AccessToken:   tok.AccessToken,
+		RefreshToken:  tok.RefreshToken,
+		Expiry:        time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second),
+		TokenEndpoint: tokenEndpoint,
+		ClientID:      clientID,
+	}
+}
+
+// accessToken returns a valid access token from the stored login, refreshing
+// it when it is about to expire.
+func accessToken(ctx context.Context) (string, error) {
+	stored, err := loadToken()
+	if err != nil {
+		return "", err
+	}
+	if stored == nil {
+		return "", errors.New("not logged in, run `cloudtrack login` or set CLOUDTRACK_TOKEN")
+	}
+	if time.Until(stored.Expiry) > 30*time.Second {
+		return stored.AccessToken, nil
+	}
+	if stored.RefreshToken == "" {
+		return "", errors.New("login expired, run `cloudtrack login` again")
+	}
+
+	var tok tokenResponse
+	if _, err := postForm(ctx, stored.TokenEndpoint, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {stored.RefreshToken},
+		"client_id":     {stored.ClientID},
+	}, &tok); err != nil {
+		return "", err
+	}
+	if tok.Error != "" || tok.AccessToken == "" {
+		return "", errors.New("login expired, run `cloudtrack login` again")
+	}
+
+	refreshed := newStoredToken(tok, stored.TokenEndpoint, stored.ClientID)
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = stored.RefreshToken
+	}
+	if err := saveToken(refreshed); err != nil {
+		return "", fmt.Errorf("failed to save refreshed token: %w", err)
+	}
+	return refreshed.AccessToken, nil
+}
@@ -0,0 +1,16 @@
+// Command cloudtrack is the command-line client for the PaaS API.
+//
+//	cloudtrack login --client-id <native app id>
+//	cloudtrack db create orders --tenant alice --wait
+//	cloudtrack db connect orders --port-forward
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
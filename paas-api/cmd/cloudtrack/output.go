@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+type table struct {
+	w *tabwriter.Writer
+}
+
+func (t *table) header(cols ...string) {
+	fmt.Fprintln(t.w, strings.Join(cols, "\t"))
+}
+
+func (t *table) row(cols ...interface{}) {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = fmt.Sprint(c)
+	}
+	fmt.Fprintln(t.w, strings.Join(parts, "\t"))
+}
+
+// printOutput writes v as JSON or YAML, or calls render for the table format.
+// YAML is produced from the JSON encoding so both use the API's field names.
+func printOutput(cmd *cobra.Command, format string, v interface{}, render func(*table)) error {
+	out := cmd.OutOrStdout()
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	default:
+		t := &table{w: tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)}
+		render(t)
+		return t.w.Flush()
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"paas-api/client"
+)
+
+type globalOptions struct {
+	apiURL string
+	tenant string
+	output string
+	token  string
+
+	cfg *config
+}
+
+func newRootCmd() *cobra.Command {
+	opts := &globalOptions{}
+
+	root := &cobra.Command{
+		Use:          "cloudtrack",
+		Short:        "Manage CloudTrack databases from the command line",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			opts.cfg = cfg
+
+			switch opts.output {
+			case "table", "json", "yaml":
+			default:
+				return fmt.Errorf("unknown output format %q, use table, json or yaml", opts.output)
+			}
+			return nil
+		},
+	}
+
+	root.PersistentFlags().StringVar(&opts.apiURL, "api-url", os.Getenv("CLOUDTRACK_API_URL"), "API base URL (env CLOUDTRACK_API_URL)")
+	root.PersistentFlags().StringVarP(&opts.tenant, "tenant", "t", os.Getenv("CLOUDTRACK_TENANT"), "Tenant that owns the databases (env CLOUDTRACK_TENANT)")
+	root.PersistentFlags().StringVarP(&opts.output, "output", "o", "table", "Output format: table, json or yaml")
+	root.PersistentFlags().StringVar(&opts.token, "token", "", "API token, overrides the stored login (env CLOUDTRACK_TOKEN)")
+
+	root.AddCommand(
+		newLoginCmd(opts),
+		newLogoutCmd(),
+		newConfigCmd(opts),
+		newDBCmd(opts),
+		newAdminCmd(opts),
+	)
+	return root
+}
+
+// client builds an API client. Authentication uses --token, then
+// CLOUDTRACK_TOKEN, then the stored login.
+func (o *globalOptions) client() (*client.Client, error) {
+	apiURL := o.apiURL
+	if apiURL == "" {
+		apiURL = o.cfg.APIURL
+	}
+	if apiURL == "" {
+		return nil, errors.New("no API URL configured, use --api-url or `cloudtrack config set api-url <url>`")
+	}
+
+	token := o.token
+	if token == "" {
+		token = os.Getenv("CLOUDTRACK_TOKEN")
+	}
+	if token != "" {
+		return client.New(apiURL, client.WithToken(token), client.WithUserAgent("cloudtrack-cli")), nil
+	}
+	return client.New(apiURL, client.WithTokenSource(accessToken), client.WithUserAgent("cloudtrack-cli")), nil
+}
+
+func (o *globalOptions) tenantName() (string, error) {
+	if o.tenant != "" {
+		return o.tenant, nil
+	}
+	if o.cfg.Tenant != "" {
+		return o.cfg.Tenant, nil
+	}
+	return "", errors.New("no tenant selected, use --tenant or `cloudtrack config set tenant <name>`")
+}
+
+func newConfigCmd(opts *globalOptions) *cobra.Command {
+	keys := make([]string, 0, len(configKeys))
+	for k := range configKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View or change CLI defaults",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Set a default (" + strings.Join(keys, ", ") + ")",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			field, ok := configKeys[args[0]]
+			if !ok {
+				return fmt.Errorf("unknown key %q, valid keys: %s", args[0], strings.Join(keys, ", "))
+			}
+			*field(opts.cfg) = args[1]
+			return saveConfig(opts.cfg)
+		},
+	}, &cobra.Command{
+		Use:   "view",
+		Short: "Show the current defaults",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printOutput(cmd, opts.output, opts.cfg, func(t *table) {
+				t.header("KEY", "VALUE")
+				for _, k := range keys {
+					t.row(k, *configKeys[k](opts.cfg))
+				}
+			})
+		},
+	})
+	return cmd
+}
+
+func confirm(cmd *cobra.Command, prompt string) bool {
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N]: ", prompt)
+	var answer string
+	fmt.Fscanln(cmd.InOrStdin(), &answer)
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
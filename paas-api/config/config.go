@@ -0,0 +1,421 @@
+// Package config holds the settings of the API server. They are read once at
+// startup from an optional YAML file (PAAS_CONFIG_FILE), overridden by
+// environment variables, and validated before anything else starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Redacted replaces secret values in the output of Config.Redacted.
+const Redacted = "REDACTED"
+
+type Config struct {
+	Server        ServerConfig        `json:"server"`
+	Auth          AuthConfig          `json:"auth"`
+	Kubernetes    KubernetesConfig    `json:"kubernetes"`
+	Databases     DatabasesConfig     `json:"databases"`
+	Audit         AuditConfig         `json:"audit"`
+	ObjectStorage ObjectStorageConfig `json:"object_storage"`
+	Ingress       IngressConfig       `json:"ingress"`
+}
+
+type ServerConfig struct {
+	Port        int      `json:"port"`         // PORT
+	CORSOrigins []string `json:"cors_origins"` // CORS_ALLOWED_ORIGINS, comma separated
+	// TrustedProxies may set the client address with X-Forwarded-For, e.g.
+	// the ingress controller's pod CIDR. By default no proxy is trusted and
+	// the address is the peer of the connection.
+	TrustedProxies []string `json:"trusted_proxies"` // TRUSTED_PROXIES, comma separated IPs or CIDRs
+}
+
+type AuthConfig struct {
+	IssuerURL string `json:"issuer_url"` // OIDC_ISSUER_URL
+	// JWKSURL skips OIDC discovery when set.
+	JWKSURL     string            `json:"jwks_url"`     // OIDC_JWKS_URL
+	Audience    string            `json:"audience"`     // OIDC_AUDIENCE
+	RolesClaim  string            `json:"roles_claim"`  // OIDC_ROLES_CLAIM
+	RoleMapping map[string]string `json:"role_mapping"` // OIDC_ROLE_MAPPING, "idp-devs=developer,idp-admins=platform-admin"
+	TokenUse    string            `json:"token_use"`    // OIDC_TOKEN_USE
+	RolesFile   string            `json:"roles_file"`   // RBAC_ROLES_FILE
+}
+
+type KubernetesConfig struct {
+	// Kubeconfig is used when set, by the API and by the kubectl commands it
+	// runs. Otherwise ~/.kube/config is tried before the in-cluster
+	// configuration.
+	Kubeconfig            string          `json:"kubeconfig"`              // KUBECONFIG
+	Timeout               metav1.Duration `json:"timeout"`                 // KUBE_CLIENT_TIMEOUT
+	TenantNamespacePrefix string          `json:"tenant_namespace_prefix"` // TENANT_NAMESPACE_PREFIX
+	SystemNamespace       string          `json:"system_namespace"`        // PAAS_SYSTEM_NAMESPACE
+	OperatorNamespace     string          `json:"operator_namespace"`      // POSTGRES_OPERATOR_NAMESPACE
+	TemplateDir           string          `json:"template_dir"`            // TEMPLATE_DIR
+	DefaultTeam           string          `json:"default_team"`            // DEFAULT_TEAM
+}
+
+type DatabasesConfig struct {
+	Mode                string            `json:"mode"`                  // DATABASE_MODE, api or crd
+	PlanProviders       map[string]string `json:"plan_providers"`        // DATABASE_PLAN_PROVIDERS, "medium=cloudnativepg"
+	SnapshotClass       string            `json:"snapshot_class"`        // CNPG_SNAPSHOT_CLASS
+	CredentialsWait     metav1.Duration   `json:"credentials_wait"`      // CREDENTIALS_WAIT
+	ResyncInterval      metav1.Duration   `json:"resync_interval"`       // CONTROLLER_RESYNC_INTERVAL
+	BindingSyncInterval metav1.Duration   `json:"binding_sync_interval"` // BINDING_SYNC_INTERVAL
+	// TenantStorageQuota caps the storage of each tenant namespace, "0" for none.
+	TenantStorageQuota string `json:"tenant_storage_quota"` // TENANT_STORAGE_QUOTA
+}
+
+type AuditConfig struct {
+	Sink   string `json:"sink"`   // AUDIT_SINK, stdout, file or postgres
+	Target string `json:"target"` // AUDIT_TARGET, file path or DSN
+}
+
+type ObjectStorageConfig struct {
+	Endpoint     string `json:"endpoint"`      // MINIO_ENDPOINT
+	RootUser     string `json:"root_user"`     // MINIO_ROOT_USER
+	RootPassword string `json:"root_password"` // MINIO_ROOT_PASSWORD
+}
+
+type IngressConfig struct {
+	Domain string `json:"domain"` // PLATFORM_DOMAIN
+	Class  string `json:"class"`  // INGRESS_CLASS
+	Issuer string `json:"issuer"` // CERT_MANAGER_ISSUER
+}
+
+// Defaults returns the settings used when neither the file nor the
+// environment sets a value.
+func Defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:        8080,
+			CORSOrigins: []string{"http://localhost:5173"},
+		},
+		Auth: AuthConfig{
+			RolesClaim:  "urn:zitadel:iam:org:project:roles",
+			RoleMapping: map[string]string{},
+		},
+		Kubernetes: KubernetesConfig{
+			Timeout:               metav1.Duration{Duration: 30 * time.Second},
+			TenantNamespacePrefix: "tenant-",
+			SystemNamespace:       "paas-system",
+			OperatorNamespace:     "default",
+			TemplateDir:           "templates",
+			DefaultTeam:           "paas-team",
+		},
+		Databases: DatabasesConfig{
+			Mode:                "api",
+			PlanProviders:       map[string]string{},
+			CredentialsWait:     metav1.Duration{Duration: 5 * time.Second},
+			ResyncInterval:      metav1.Duration{Duration: 30 * time.Second},
+			BindingSyncInterval: metav1.Duration{Duration: time.Minute},
+			TenantStorageQuota:  "200Gi",
+		},
+		Audit: AuditConfig{Sink: "stdout"},
+		Ingress: IngressConfig{
+			Issuer: "letsencrypt",
+		},
+	}
+}
+
+var (
+	current = Defaults()
+	source  string
+)
+
+// Current returns the configuration loaded at startup, or the defaults
+// before Load is called.
+func Current() Config {
+	return current
+}
+
+// Source is the file the configuration was read from, empty if there was none.
+func Source() string {
+	return source
+}
+
+// Load reads path, if not empty, on top of the defaults, applies the
+// environment and validates the result. It becomes the Current configuration.
+func Load(path string) (Config, error) {
+	cfg := Defaults()
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	current, source = cfg, path
+	return cfg, nil
+}
+
+// applyEnv overrides the settings whose environment variable is set.
+func applyEnv(cfg *Config) error {
+	values := map[string]*string{
+		"OIDC_ISSUER_URL":             &cfg.Auth.IssuerURL,
+		"OIDC_JWKS_URL":               &cfg.Auth.JWKSURL,
+		"OIDC_AUDIENCE":               &cfg.Auth.Audience,
+		"OIDC_ROLES_CLAIM":            &cfg.Auth.RolesClaim,
+		"OIDC_TOKEN_USE":              &cfg.Auth.TokenUse,
+		"RBAC_ROLES_FILE":             &cfg.Auth.RolesFile,
+		"KUBECONFIG":                  &cfg.Kubernetes.Kubeconfig,
+		"TENANT_NAMESPACE_PREFIX":     &cfg.Kubernetes.TenantNamespacePrefix,
+		"PAAS_SYSTEM_NAMESPACE":       &cfg.Kubernetes.SystemNamespace,
+		"POSTGRES_OPERATOR_NAMESPACE": &cfg.Kubernetes.OperatorNamespace,
+		"TEMPLATE_DIR":                &cfg.Kubernetes.TemplateDir,
+		"DEFAULT_TEAM":                &cfg.Kubernetes.DefaultTeam,
+		"DATABASE_MODE":               &cfg.Databases.Mode,
+		"CNPG_SNAPSHOT_CLASS":         &cfg.Databases.SnapshotClass,
+		"TENANT_STORAGE_QUOTA":        &cfg.Databases.TenantStorageQuota,
+		"AUDIT_SINK":                  &cfg.Audit.Sink,
+		"AUDIT_TARGET":                &cfg.Audit.Target,
+		"MINIO_ENDPOINT":              &cfg.ObjectStorage.Endpoint,
+		"MINIO_ROOT_USER":             &cfg.ObjectStorage.RootUser,
+		"MINIO_ROOT_PASSWORD":         &cfg.ObjectStorage.RootPassword,
+		"PLATFORM_DOMAIN":             &cfg.Ingress.Domain,
+		"INGRESS_CLASS":               &cfg.Ingress.Class,
+		"CERT_MANAGER_ISSUER":         &cfg.Ingress.Issuer,
+	}
+	for name, dst := range values {
+		if value := os.Getenv(name); value != "" {
+			*dst = value
+		}
+	}
+
+	durations := map[string]*metav1.Duration{
+		"KUBE_CLIENT_TIMEOUT":        &cfg.Kubernetes.Timeout,
+		"CREDENTIALS_WAIT":           &cfg.Databases.CredentialsWait,
+		"CONTROLLER_RESYNC_INTERVAL": &cfg.Databases.ResyncInterval,
+		"BINDING_SYNC_INTERVAL":      &cfg.Databases.BindingSyncInterval,
+	}
+	for name, dst := range durations {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+		dst.Duration = d
+	}
+
+	if value := os.Getenv("PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid PORT %q", value)
+		}
+		cfg.Server.Port = port
+	}
+	if value := os.Getenv("CORS_ALLOWED_ORIGINS"); value != "" {
+		cfg.Server.CORSOrigins = splitList(value)
+	}
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		cfg.Server.TrustedProxies = splitList(value)
+	}
+
+	pairs := map[string]*map[string]string{
+		"OIDC_ROLE_MAPPING":       &cfg.Auth.RoleMapping,
+		"DATABASE_PLAN_PROVIDERS": &cfg.Databases.PlanProviders,
+	}
+	for name, dst := range pairs {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parsePairs(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*dst = parsed
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePairs parses "from=to,from2=to2" into a map.
+func parsePairs(raw string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, item := range splitList(raw) {
+		key, value, ok := strings.Cut(item, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("%q is not key=value", item)
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var problems []string
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port", "must be between 1 and 65535")
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		add("server.cors_origins", "must list at least one origin")
+	}
+	for _, origin := range c.Server.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") || strings.TrimSuffix(u.Path, "/") != "" {
+			add("server.cors_origins", "%q must be * or an http(s) origin such as https://app.example.com", origin)
+		}
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("server.trusted_proxies", "%q must be an IP address or CIDR", proxy)
+		}
+	}
+
+	if c.Auth.IssuerURL == "" {
+		add("auth.issuer_url", "is required")
+	} else if !isHTTPURL(c.Auth.IssuerURL) {
+		add("auth.issuer_url", "must be an http(s) URL")
+	}
+	if c.Auth.JWKSURL != "" && !isHTTPURL(c.Auth.JWKSURL) {
+		add("auth.jwks_url", "must be an http(s) URL")
+	}
+	if c.Auth.RolesClaim == "" {
+		add("auth.roles_claim", "must not be empty")
+	}
+	if c.Auth.RolesFile != "" {
+		if _, err := os.Stat(c.Auth.RolesFile); err != nil {
+			add("auth.roles_file", "%v", err)
+		}
+	}
+
+	if c.Kubernetes.Kubeconfig != "" {
+		if _, err := os.Stat(c.Kubernetes.Kubeconfig); err != nil {
+			add("kubernetes.kubeconfig", "%v", err)
+		}
+	}
+	if c.Kubernetes.Timeout.Duration <= 0 {
+		add("kubernetes.timeout", "must be positive")
+	}
+	prefix := c.Kubernetes.TenantNamespacePrefix
+	if !strings.HasSuffix(prefix, "-") || len(validation.IsDNS1123Label(strings.TrimSuffix(prefix, "-"))) > 0 {
+		add("kubernetes.tenant_namespace_prefix", "must be a DNS label followed by '-', such as tenant-")
+	} else if len(prefix) > 20 {
+		add("kubernetes.tenant_namespace_prefix", "must be no more than 20 characters")
+	}
+	for field, namespace := range map[string]string{
+		"kubernetes.system_namespace":   c.Kubernetes.SystemNamespace,
+		"kubernetes.operator_namespace": c.Kubernetes.OperatorNamespace,
+	} {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			add(field, "%s", strings.Join(errs, ", "))
+		}
+	}
+	if info, err := os.Stat(c.Kubernetes.TemplateDir); err != nil {
+		add("kubernetes.template_dir", "%v", err)
+	} else if !info.IsDir() {
+		add("kubernetes.template_dir", "%s is not a directory", c.Kubernetes.TemplateDir)
+	}
+	if c.Kubernetes.DefaultTeam == "" {
+		add("kubernetes.default_team", "must not be empty")
+	}
+
+	switch c.Databases.Mode {
+	case "api", "crd":
+	default:
+		add("databases.mode", "must be api or crd")
+	}
+	for field, d := range map[string]metav1.Duration{
+		"databases.credentials_wait":      c.Databases.CredentialsWait,
+		"databases.resync_interval":       c.Databases.ResyncInterval,
+		"databases.binding_sync_interval": c.Databases.BindingSyncInterval,
+	} {
+		if d.Duration <= 0 {
+			add(field, "must be positive")
+		}
+	}
+	if q, err := resource.ParseQuantity(c.Databases.TenantStorageQuota); err != nil || q.Sign() < 0 {
+		add("databases.tenant_storage_quota", "must be a storage size such as 200Gi")
+	}
+
+	switch c.Audit.Sink {
+	case "stdout":
+	case "file", "postgres":
+		if c.Audit.Target == "" {
+			add("audit.target", "must be set for the %s sink", c.Audit.Sink)
+		}
+	default:
+		add("audit.sink", "must be stdout, file or postgres")
+	}
+
+	if c.ObjectStorage.Endpoint != "" {
+		if !isHTTPURL(c.ObjectStorage.Endpoint) {
+			add("object_storage.endpoint", "must be an http(s) URL")
+		}
+		if c.ObjectStorage.RootUser == "" || c.ObjectStorage.RootPassword == "" {
+			add("object_storage", "root_user and root_password are required with an endpoint")
+		}
+	}
+
+	if c.Ingress.Domain != "" && c.Ingress.Issuer == "" {
+		add("ingress.issuer", "must not be empty")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// Redacted returns a copy safe to show to operators: passwords and the audit
+// DSN, which may embed one, are replaced.
+func (c Config) Redacted() Config {
+	redact := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return Redacted
+	}
+	c.ObjectStorage.RootPassword = redact(c.ObjectStorage.RootPassword)
+	if c.Audit.Sink == "postgres" {
+		c.Audit.Target = redact(c.Audit.Target)
+	}
+	return c
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"paas-api/k8s"
+)
+
+// DefaultBindingSyncInterval is how often binding secrets are compared with
+// the credentials of their databases.
+const DefaultBindingSyncInterval = time.Minute
+
+// RunBindingSync keeps database binding secrets in step with rotated
+// credentials until ctx is cancelled.
+func RunBindingSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultBindingSyncInterval
+	}
+	fmt.Printf("Binding sync started (every %s)\n", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := k8s.SyncDatabaseBindings(); err != nil {
+			fmt.Printf("Binding sync: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package controller reconciles TenantDatabase resources into database
+// clusters, connection secrets, network policies and quotas, and keeps
+// database binding secrets up to date.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"paas-api/k8s"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultResyncInterval is how often every TenantDatabase is reconciled when
+// nothing triggers an earlier pass.
+const DefaultResyncInterval = 30 * time.Second
+
+// Condition types written to TenantDatabase status.
+const (
+	ConditionProvisioned      = "Provisioned"
+	ConditionCredentialsReady = "CredentialsReady"
+	ConditionReady            = "Ready"
+)
+
+var trigger = make(chan struct{}, 1)
+
+// clusterAPI is what reconciling needs from Kubernetes. Tests replace kube
+// with a fake.
+type clusterAPI interface {
+	ListTenantDatabases(namespace string) ([]k8s.TenantDatabase, error)
+	EnsureDatabaseQuota(namespace string) error
+	IsTenantSuspended(namespace string) (bool, error)
+	DatabaseExists(namespace, name string) (bool, error)
+	ApplyTenantDatabaseCluster(td *k8s.TenantDatabase) error
+	EnsureDatabaseNetworkPolicy(namespace, name, exposure string, owner metav1.OwnerReference) error
+	EnsureConnectionSecret(namespace, name string, owner metav1.OwnerReference) (bool, error)
+	GetDatabaseClusterInfo(namespace, name string) (*k8s.DatabaseClusterInfo, error)
+	UpdateTenantDatabaseStatus(namespace, name string, status k8s.TenantDatabaseStatus) error
+}
+
+type kubeCluster struct{}
+
+func (kubeCluster) ListTenantDatabases(namespace string) ([]k8s.TenantDatabase, error) {
+	return k8s.ListTenantDatabases(namespace)
+}
+
+func (kubeCluster) EnsureDatabaseQuota(namespace string) error {
+	return k8s.EnsureDatabaseQuota(namespace)
+}
+
+func (kubeCluster) IsTenantSuspended(namespace string) (bool, error) {
+	return k8s.IsTenantSuspended(namespace)
+}
+
+func (kubeCluster) DatabaseExists(namespace, name string) (bool, error) {
+	return k8s.DatabaseExists(namespace, name)
+}
+
+func (kubeCluster) ApplyTenantDatabaseCluster(td *k8s.TenantDatabase) error {
+	return k8s.ApplyTenantDatabaseCluster(td)
+}
+
+func (kubeCluster) EnsureDatabaseNetworkPolicy(namespace, name, exposure string, owner metav1.OwnerReference) error {
+	return k8s.EnsureDatabaseNetworkPolicy(namespace, name, exposure, owner)
+}
+
+func (kubeCluster) EnsureConnectionSecret(namespace, name string, owner metav1.OwnerReference) (bool, error) {
+	return k8s.EnsureConnectionSecret(namespace, name, owner)
+}
+
+func (kubeCluster) GetDatabaseClusterInfo(namespace, name string) (*k8s.DatabaseClusterInfo, error) {
+	return k8s.GetDatabaseClusterInfo(namespace, name)
+}
+
+func (kubeCluster) UpdateTenantDatabaseStatus(namespace, name string, status k8s.TenantDatabaseStatus) error {
+	return k8s.UpdateTenantDatabaseStatus(namespace, name, status)
+}
+
+var kube clusterAPI = kubeCluster{}
+
+// Enqueue requests a reconcile pass as soon as possible, e.g. after the API
+// created or changed a TenantDatabase. It never blocks.
+func Enqueue() {
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles all TenantDatabases every interval, or earlier when
+// Enqueue is called, until ctx is cancelled. Every pass is level-triggered,
+// so missed triggers only delay convergence.
+func Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultResyncInterval
+	}
+	fmt.Printf("TenantDatabase controller started (resync every %s)\n", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reconcileAll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-trigger:
+		}
+	}
+}
+
+func reconcileAll() {
+	databases, err := kube.ListTenantDatabases("")
+	if err != nil {
+		fmt.Printf("TenantDatabase controller: %v\n", err)
+		return
+	}
+
+	byNamespace := map[string][]k8s.TenantDatabase{}
+	for _, td := range databases {
+		byNamespace[td.Metadata.Namespace] = append(byNamespace[td.Metadata.Namespace], td)
+	}
+
+	for namespace, tds := range byNamespace {
+		// Outside tenant namespaces reconcile only reports the problem
+		if k8s.IsTenantNamespace(namespace) {
+			if err := kube.EnsureDatabaseQuota(namespace); err != nil {
+				fmt.Printf("TenantDatabase controller: %v\n", err)
+			}
+		}
+		for i := range tds {
+			reconcile(&tds[i])
+		}
+	}
+}
+
+// reconcile converges one TenantDatabase and writes its status back.
+func reconcile(td *k8s.TenantDatabase) {
+	if td.Metadata.DeletionTimestamp != nil {
+		return
+	}
+
+	namespace, name := td.Metadata.Namespace, td.Metadata.Name
+	status := td.Status
+	status.Conditions = append([]metav1.Condition(nil), td.Status.Conditions...)
+	generation := td.Metadata.Generation
+
+	setCondition := func(condType string, ok bool, reason, message string) {
+		s := metav1.ConditionFalse
+		if ok {
+			s = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               condType,
+			Status:             s,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: generation,
+		})
+	}
+	defer func() {
+		status.ObservedGeneration = generation
+		if reflect.DeepEqual(status, td.Status) {
+			return
+		}
+		if err := kube.UpdateTenantDatabaseStatus(namespace, name, status); err != nil {
+			fmt.Printf("TenantDatabase controller: %v\n", err)
+		}
+	}()
+
+	// The controller's ClusterRole reaches every namespace, tenants only own theirs
+	if !k8s.IsTenantNamespace(namespace) {
+		status.Phase = k8s.StatusFailed
+		status.Message = fmt.Sprintf("namespace %s is not a tenant namespace (%s<name>)", namespace, k8s.TenantNamespacePrefix)
+		setCondition(ConditionProvisioned, false, "NotTenantNamespace", status.Message)
+		setCondition(ConditionReady, false, "NotTenantNamespace", status.Message)
+		return
+	}
+
+	spec := td.Spec
+	spec.Default()
+	problems := spec.Validate()
+	if p := k8s.ValidateDBName(name); p != nil {
+		problems["metadata.name"] = p
+	}
+	if len(problems) > 0 {
+		status.Phase = k8s.StatusFailed
+		status.Message = describeProblems(problems)
+		setCondition(ConditionProvisioned, false, "InvalidSpec", status.Message)
+		setCondition(ConditionReady, false, "InvalidSpec", status.Message)
+		return
+	}
+
+	// Suspended tenants keep what they have but get nothing new. When that
+	// cannot be checked nothing is applied, so a suspension is never bypassed.
+	suspended, err := kube.IsTenantSuspended(namespace)
+	if err == nil && suspended {
+		var exists bool
+		exists, err = kube.DatabaseExists(namespace, name)
+		if err == nil && !exists {
+			status.Phase = k8s.StatusPaused
+			status.Message = "Tenant is suspended"
+			setCondition(ConditionProvisioned, false, "TenantSuspended", status.Message)
+			return
+		}
+	}
+	if err != nil {
+		status.Message = err.Error()
+		setCondition(ConditionProvisioned, false, "SuspensionCheckFailed", err.Error())
+		return
+	}
+
+	if err := kube.ApplyTenantDatabaseCluster(td); err != nil {
+		status.Message = err.Error()
+		setCondition(ConditionProvisioned, false, "ApplyFailed", err.Error())
+		return
+	}
+
+	owner := td.OwnerReference()
+	if err := kube.EnsureDatabaseNetworkPolicy(namespace, name, spec.Exposure, owner); err != nil {
+		status.Message = err.Error()
+		setCondition(ConditionProvisioned, false, "NetworkPolicyFailed", err.Error())
+		return
+	}
+	setCondition(ConditionProvisioned, true, "Applied", "Database cluster and network policy are up to date")
+
+	if ready, err := kube.EnsureConnectionSecret(namespace, name, owner); err != nil {
+		setCondition(ConditionCredentialsReady, false, "SecretFailed", err.Error())
+	} else if !ready {
+		setCondition(ConditionCredentialsReady, false, "WaitingForCredentials", "The credentials have not been created yet")
+	} else {
+		status.SecretName = k8s.ConnectionSecretName(name)
+		setCondition(ConditionCredentialsReady, true, "SecretCreated", "Connection details are in secret "+status.SecretName)
+	}
+
+	cluster, err := kube.GetDatabaseClusterInfo(namespace, name)
+	if err != nil {
+		status.Message = err.Error()
+		setCondition(ConditionReady, false, "StatusUnknown", err.Error())
+		return
+	}
+	status.Phase = cluster.Status
+	status.Message = cluster.DetailedStatus
+	status.Host = cluster.ConnectionInfo["host"]
+	status.Port, _ = strconv.Atoi(cluster.ConnectionInfo["port"])
+	status.Replicas = spec.Replicas
+	status.ReadyReplicas = cluster.RunningReplicas
+	setCondition(ConditionReady, cluster.ConnectionReady, strings.ReplaceAll(cluster.Status, " ", ""), cluster.DetailedStatus)
+}
+
+func describeProblems(problems map[string][]string) string {
+	fields := make([]string, 0, len(problems))
+	for field := range problems {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var parts []string
+	for _, field := range fields {
+		for _, p := range problems[field] {
+			parts = append(parts, fmt.Sprintf("%s %s", field, p))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,334 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"paas-api/k8s"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeCluster records what reconcile asks of Kubernetes.
+type fakeCluster struct {
+	databases   []k8s.TenantDatabase
+	suspended   bool
+	suspendErr  error
+	exists      bool
+	applyErr    error
+	secretReady bool
+	info        *k8s.DatabaseClusterInfo
+
+	applied  []string
+	quotas   []string
+	statuses map[string]k8s.TenantDatabaseStatus
+}
+
+func (f *fakeCluster) ListTenantDatabases(string) ([]k8s.TenantDatabase, error) {
+	return f.databases, nil
+}
+
+func (f *fakeCluster) EnsureDatabaseQuota(namespace string) error {
+	f.quotas = append(f.quotas, namespace)
+	return nil
+}
+
+func (f *fakeCluster) IsTenantSuspended(string) (bool, error) { return f.suspended, f.suspendErr }
+
+func (f *fakeCluster) DatabaseExists(string, string) (bool, error) { return f.exists, nil }
+
+func (f *fakeCluster) ApplyTenantDatabaseCluster(td *k8s.TenantDatabase) error {
+	if f.applyErr != nil {
+		return f.applyErr
+	}
+	f.applied = append(f.applied, td.Metadata.Namespace+"/"+td.Metadata.Name)
+	return nil
+}
+
+func (f *fakeCluster) EnsureDatabaseNetworkPolicy(string, string, string, metav1.OwnerReference) error {
+	return nil
+}
+
+func (f *fakeCluster) EnsureConnectionSecret(string, string, metav1.OwnerReference) (bool, error) {
+	return f.secretReady, nil
+}
+
+func (f *fakeCluster) GetDatabaseClusterInfo(namespace, name string) (*k8s.DatabaseClusterInfo, error) {
+	if f.info == nil {
+		return nil, errors.New("no cluster")
+	}
+	return f.info, nil
+}
+
+func (f *fakeCluster) UpdateTenantDatabaseStatus(namespace, name string, status k8s.TenantDatabaseStatus) error {
+	if f.statuses == nil {
+		f.statuses = map[string]k8s.TenantDatabaseStatus{}
+	}
+	f.statuses[namespace+"/"+name] = status
+	return nil
+}
+
+func useFake(t *testing.T, f *fakeCluster) {
+	t.Helper()
+	previous := kube
+	kube = f
+	t.Cleanup(func() { kube = previous })
+}
+
+func tenantDatabase(namespace, name string, spec k8s.TenantDatabaseSpec) k8s.TenantDatabase {
+	return k8s.TenantDatabase{
+		Metadata: metav1.ObjectMeta{Namespace: namespace, Name: name, Generation: 3},
+		Spec:     spec,
+	}
+}
+
+func readyCluster() *k8s.DatabaseClusterInfo {
+	return &k8s.DatabaseClusterInfo{
+		Status:          k8s.StatusReady,
+		DetailedStatus:  "All instances are ready",
+		ConnectionReady: true,
+		RunningReplicas: 1,
+		ConnectionInfo:  map[string]string{"host": "orders.tenant-alice.svc.cluster.local", "port": "5432"},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		spec      k8s.TenantDatabaseSpec
+		cluster   fakeCluster
+		applied   bool
+		phase     string
+		reason    string // of the Provisioned condition
+		ready     metav1.ConditionStatus
+	}{
+		{
+			name:      "ready database",
+			namespace: "tenant-alice",
+			cluster:   fakeCluster{secretReady: true, info: readyCluster()},
+			applied:   true,
+			phase:     k8s.StatusReady,
+			reason:    "Applied",
+			ready:     metav1.ConditionTrue,
+		},
+		{
+			name:      "invalid spec is not applied",
+			namespace: "tenant-alice",
+			spec:      k8s.TenantDatabaseSpec{Plan: "huge"},
+			cluster:   fakeCluster{info: readyCluster()},
+			phase:     k8s.StatusFailed,
+			reason:    "InvalidSpec",
+			ready:     metav1.ConditionFalse,
+		},
+		{
+			name:      "non-tenant namespace is not applied",
+			namespace: "kube-system",
+			cluster:   fakeCluster{info: readyCluster()},
+			phase:     k8s.StatusFailed,
+			reason:    "NotTenantNamespace",
+			ready:     metav1.ConditionFalse,
+		},
+		{
+			name:      "suspended tenant gets no new database",
+			namespace: "tenant-alice",
+			cluster:   fakeCluster{suspended: true, info: readyCluster()},
+			phase:     k8s.StatusPaused,
+			reason:    "TenantSuspended",
+		},
+		{
+			name:      "suspended tenant keeps an existing database",
+			namespace: "tenant-alice",
+			cluster:   fakeCluster{suspended: true, exists: true, secretReady: true, info: readyCluster()},
+			applied:   true,
+			phase:     k8s.StatusReady,
+			reason:    "Applied",
+			ready:     metav1.ConditionTrue,
+		},
+		{
+			name:      "suspension unknown is not applied",
+			namespace: "tenant-alice",
+			cluster:   fakeCluster{suspendErr: errors.New("apiserver unavailable"), info: readyCluster()},
+			reason:    "SuspensionCheckFailed",
+		},
+		{
+			name:      "apply failure",
+			namespace: "tenant-alice",
+			cluster:   fakeCluster{applyErr: errors.New("operator unavailable"), info: readyCluster()},
+			reason:    "ApplyFailed",
+		},
+		{
+			name:      "cluster status unknown",
+			namespace: "tenant-alice",
+			cluster:   fakeCluster{secretReady: true},
+			applied:   true,
+			reason:    "Applied",
+			ready:     metav1.ConditionFalse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.cluster
+			useFake(t, &f)
+
+			td := tenantDatabase(tt.namespace, "orders", tt.spec)
+			reconcile(&td)
+
+			if got := len(f.applied) > 0; got != tt.applied {
+				t.Errorf("applied = %v, want %v", got, tt.applied)
+			}
+			status, ok := f.statuses[tt.namespace+"/orders"]
+			if !ok {
+				t.Fatal("status was not written")
+			}
+			if status.Phase != tt.phase {
+				t.Errorf("phase = %q, want %q", status.Phase, tt.phase)
+			}
+			if status.ObservedGeneration != 3 {
+				t.Errorf("observedGeneration = %d, want 3", status.ObservedGeneration)
+			}
+			provisioned := meta.FindStatusCondition(status.Conditions, ConditionProvisioned)
+			if provisioned == nil || provisioned.Reason != tt.reason {
+				t.Errorf("Provisioned condition = %+v, want reason %s", provisioned, tt.reason)
+			}
+			if tt.ready != "" {
+				ready := meta.FindStatusCondition(status.Conditions, ConditionReady)
+				if ready == nil || ready.Status != tt.ready {
+					t.Errorf("Ready condition = %+v, want status %s", ready, tt.ready)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileReadyStatus(t *testing.T) {
+	f := &fakeCluster{secretReady: true, info: readyCluster()}
+	useFake(t, f)
+
+	td := tenantDatabase("tenant-alice", "orders", k8s.TenantDatabaseSpec{})
+	reconcile(&td)
+
+	status := f.statuses["tenant-alice/orders"]
+	if status.Host != "orders.tenant-alice.svc.cluster.local" || status.Port != 5432 {
+		t.Errorf("endpoint = %s:%d", status.Host, status.Port)
+	}
+	if status.SecretName != k8s.ConnectionSecretName("orders") {
+		t.Errorf("secretName = %q", status.SecretName)
+	}
+	if status.Replicas != 1 || status.ReadyReplicas != 1 {
+		t.Errorf("replicas = %d/%d, want 1/1", status.ReadyReplicas, status.Replicas)
+	}
+	credentials := meta.FindStatusCondition(status.Conditions, ConditionCredentialsReady)
+	if credentials == nil || credentials.Status != metav1.ConditionTrue {
+		t.Errorf("CredentialsReady condition = %+v", credentials)
+	}
+}
+
+func TestReconcileWaitingForCredentials(t *testing.T) {
+	f := &fakeCluster{info: readyCluster()}
+	useFake(t, f)
+
+	td := tenantDatabase("tenant-alice", "orders", k8s.TenantDatabaseSpec{})
+	reconcile(&td)
+
+	status := f.statuses["tenant-alice/orders"]
+	credentials := meta.FindStatusCondition(status.Conditions, ConditionCredentialsReady)
+	if credentials == nil || credentials.Reason != "WaitingForCredentials" {
+		t.Errorf("CredentialsReady condition = %+v", credentials)
+	}
+	if status.SecretName != "" {
+		t.Errorf("secretName = %q before the secret exists", status.SecretName)
+	}
+}
+
+func TestReconcileUnchangedStatusIsNotWritten(t *testing.T) {
+	f := &fakeCluster{secretReady: true, info: readyCluster()}
+	useFake(t, f)
+
+	td := tenantDatabase("tenant-alice", "orders", k8s.TenantDatabaseSpec{})
+	reconcile(&td)
+	td.Status = f.statuses["tenant-alice/orders"]
+	f.statuses = nil
+
+	reconcile(&td)
+	if len(f.statuses) != 0 {
+		t.Errorf("status written again without changes: %+v", f.statuses)
+	}
+}
+
+func TestReconcileSkipsDeletedDatabases(t *testing.T) {
+	f := &fakeCluster{secretReady: true, info: readyCluster()}
+	useFake(t, f)
+
+	td := tenantDatabase("tenant-alice", "orders", k8s.TenantDatabaseSpec{})
+	now := metav1.Now()
+	td.Metadata.DeletionTimestamp = &now
+	reconcile(&td)
+
+	if len(f.applied) != 0 || len(f.statuses) != 0 {
+		t.Errorf("deleted database was reconciled: applied %v, statuses %v", f.applied, f.statuses)
+	}
+}
+
+func TestReconcileAllQuotasOnlyTenantNamespaces(t *testing.T) {
+	f := &fakeCluster{
+		secretReady: true,
+		info:        readyCluster(),
+		databases: []k8s.TenantDatabase{
+			tenantDatabase("tenant-alice", "orders", k8s.TenantDatabaseSpec{}),
+			tenantDatabase("kube-system", "orders", k8s.TenantDatabaseSpec{}),
+		},
+	}
+	useFake(t, f)
+
+	reconcileAll()
+
+	if len(f.quotas) != 1 || f.quotas[0] != "tenant-alice" {
+		t.Errorf("quotas = %v, want [tenant-alice]", f.quotas)
+	}
+	if len(f.applied) != 1 || f.applied[0] != "tenant-alice/orders" {
+		t.Errorf("applied = %v, want [tenant-alice/orders]", f.applied)
+	}
+}
+
+func TestDescribeProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		problems map[string][]string
+		want     string
+	}{
+		{"none", map[string][]string{}, ""},
+		{"one", map[string][]string{"plan": {"must be one of dev, small"}}, "plan must be one of dev, small"},
+		{
+			"sorted by field",
+			map[string][]string{
+				"replicas":      {"must be at least 1", "must be at most 3"},
+				"engine":        {"is not supported"},
+				"metadata.name": {"must not be empty"},
+			},
+			"engine is not supported; metadata.name must not be empty; replicas must be at least 1; replicas must be at most 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeProblems(tt.problems); got != tt.want {
+				t.Errorf("describeProblems() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidSpecMessageNamesTheField(t *testing.T) {
+	f := &fakeCluster{}
+	useFake(t, f)
+
+	td := tenantDatabase("tenant-alice", "orders", k8s.TenantDatabaseSpec{Backups: k8s.BackupSpec{Schedule: "0 3 * *"}})
+	reconcile(&td)
+
+	if msg := f.statuses["tenant-alice/orders"].Message; !strings.HasPrefix(msg, "backups.schedule ") {
+		t.Errorf("message = %q, want it to name backups.schedule", msg)
+	}
+}
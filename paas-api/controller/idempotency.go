@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"paas-api/k8s"
+)
+
+// DefaultIdempotencySweepInterval is how often expired idempotency keys are
+// deleted.
+const DefaultIdempotencySweepInterval = time.Hour
+
+// RunIdempotencySweep deletes expired idempotency keys until ctx is cancelled.
+func RunIdempotencySweep(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultIdempotencySweepInterval
+	}
+	fmt.Printf("Idempotency key sweep started (every %s)\n", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := k8s.SweepIdempotencyKeys(); err != nil {
+			fmt.Printf("Idempotency key sweep: %v\n", err)
+		} else if deleted > 0 {
+			fmt.Printf("Idempotency key sweep deleted %d expired keys\n", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
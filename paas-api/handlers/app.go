@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// appError writes the response for a failed app operation.
+func appError(c *gin.Context, err error, name string, spec k8s.AppSpec) {
+	switch {
+	case errors.Is(err, k8s.ErrAppNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("app %s not found", name))
+	case errors.Is(err, k8s.ErrDatabaseNotFound):
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, fmt.Sprintf("database %s not found", spec.Database))
+	case errors.Is(err, k8s.ErrAppRevisionNotFound):
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, fmt.Sprintf("app %s has no such revision to roll back to", name))
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+// validateAppSpec adds the problems of a defaulted spec to errs.
+func validateAppSpec(errs fieldErrors, spec k8s.AppSpec) {
+	for field, problems := range k8s.ValidateAppSpec(spec) {
+		errs.add(field, problems...)
+	}
+}
+
+func CreateApp(c *gin.Context) {
+	var req api.CreateAppRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	spec := k8s.AppSpec{
+		Image:           req.Image,
+		Port:            req.Port,
+		Replicas:        req.Replicas,
+		Plan:            req.Plan,
+		Env:             req.Env,
+		HealthCheckPath: req.HealthCheckPath,
+		Database:        req.Database,
+	}
+	k8s.DefaultAppSpec(&spec)
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("name", k8s.ValidateAppName(req.Name)...)
+	validateAppSpec(errs, spec)
+	if errs.respond(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.Username)
+
+	app, err := k8s.DeployApp(namespace, req.Name, currentUser(c), spec)
+	audit.Record(c, "app.create", namespace, req.Name, err)
+	if errors.Is(err, k8s.ErrAppExists) {
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("app or service %s already exists in namespace %s", req.Name, namespace), map[string]interface{}{
+			"namespace": namespace,
+			"name":      req.Name,
+		})
+		return
+	}
+	if err != nil {
+		appError(c, err, req.Name, spec)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.CreateAppResponse{
+		Message:   "App is being deployed. Follow the rollout for progress.",
+		Namespace: namespace,
+		App:       app,
+	})
+}
+
+func ListApps(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	namespace := k8s.TenantNamespace(username)
+
+	apps, err := k8s.ListApps(namespace)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AppListResponse{
+		Username:  username,
+		Namespace: namespace,
+		Apps:      apps,
+		Total:     len(apps),
+	})
+}
+
+func GetApp(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	app, err := k8s.GetApp(namespace, c.Param("app_name"))
+	if err != nil {
+		appError(c, err, c.Param("app_name"), k8s.AppSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AppResponse{
+		Username: c.Param("username"),
+		App:      app,
+	})
+}
+
+func UpdateApp(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	var req api.UpdateAppRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	name := c.Param("app_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	current, err := k8s.GetApp(namespace, name)
+	if err != nil {
+		appError(c, err, name, k8s.AppSpec{})
+		return
+	}
+
+	spec := current.Spec
+	if req.Image != nil {
+		spec.Image = *req.Image
+	}
+	if req.Port != nil {
+		spec.Port = *req.Port
+	}
+	if req.Replicas != nil {
+		spec.Replicas = *req.Replicas
+	}
+	if req.Plan != nil {
+		spec.Plan = *req.Plan
+	}
+	if req.Env != nil {
+		spec.Env = *req.Env
+	}
+	if req.HealthCheckPath != nil {
+		spec.HealthCheckPath = *req.HealthCheckPath
+	}
+	if req.Database != nil {
+		spec.Database = *req.Database
+	}
+
+	errs := fieldErrors{}
+	validateAppSpec(errs, spec)
+	if errs.respond(c) {
+		return
+	}
+
+	app, err := k8s.UpdateApp(namespace, name, spec)
+	audit.Record(c, "app.update", namespace, name, err)
+	if err != nil {
+		appError(c, err, name, spec)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AppResponse{
+		Username: c.Param("username"),
+		App:      app,
+	})
+}
+
+func GetAppRollout(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	rollout, err := k8s.GetAppRollout(namespace, c.Param("app_name"))
+	if err != nil {
+		appError(c, err, c.Param("app_name"), k8s.AppSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AppRolloutResponse{
+		Username: c.Param("username"),
+		Rollout:  rollout,
+	})
+}
+
+func RollbackApp(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	// The body is optional, without it the previous revision is restored
+	var req api.RollbackAppRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	if req.Revision < 0 {
+		fieldErrors{"revision": {"must not be negative"}}.respond(c)
+		return
+	}
+
+	name := c.Param("app_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	app, err := k8s.RollbackApp(namespace, name, req.Revision)
+	audit.Record(c, "app.rollback", namespace, name, err)
+	if err != nil {
+		appError(c, err, name, k8s.AppSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AppResponse{
+		Username: c.Param("username"),
+		App:      app,
+	})
+}
+
+func DeleteApp(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	name := c.Param("app_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	err := k8s.DeleteApp(namespace, name)
+	audit.Record(c, "app.delete", namespace, name, err)
+	if err != nil {
+		appError(c, err, name, k8s.AppSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.DeleteAppResponse{
+		Message:   "App deleted successfully",
+		Namespace: namespace,
+		Name:      name,
+	})
+}
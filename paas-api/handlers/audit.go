@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEvents serves GET /admin/audit. Supported query parameters:
+// actor, action, namespace, database, result, since, until (RFC3339) and limit.
+func ListAuditEvents(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:     c.Query("actor"),
+		Action:    c.Query("action"),
+		Namespace: c.Query("namespace"),
+		Database:  c.Query("database"),
+		Result:    c.Query("result"),
+		Limit:     100,
+	}
+
+	for param, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			api.Error(c, http.StatusBadRequest, api.CodeBadRequest, param+" must be an RFC3339 timestamp")
+			return
+		}
+		*dst = t
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			api.Error(c, http.StatusBadRequest, api.CodeBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := audit.Query(filter)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AuditEventsResponse{
+		Events: events,
+		Total:  len(events),
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindingError writes the response for a failed binding operation.
+func bindingError(c *gin.Context, err error, dbName, name string) {
+	switch {
+	case errors.Is(err, k8s.ErrDatabaseNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("database %s not found", dbName))
+	case errors.Is(err, k8s.ErrBindingNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("binding %s of database %s not found", name, dbName))
+	case errors.Is(err, k8s.ErrBindingExists):
+		api.Error(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("a secret named %s already exists", name))
+	case errors.Is(err, k8s.ErrCredentialsPending):
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, "Credentials not yet available", map[string]interface{}{
+			"message": "Database may still be initializing",
+		})
+	case errors.Is(err, k8s.ErrNotSupported):
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, err.Error())
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+func CreateDatabaseBinding(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	var req api.CreateBindingRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	dbName := c.Param("db_name")
+	if req.Name == "" {
+		req.Name = k8s.DefaultBindingName(dbName, req.Format)
+	}
+
+	errs := fieldErrors{}
+	errs.add("format", k8s.ValidateBindingFormat(req.Format)...)
+	errs.add("name", k8s.ValidateBindingName(req.Name)...)
+	if errs.respond(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	binding, err := k8s.CreateDatabaseBinding(namespace, dbName, req.Name, req.Format)
+	audit.Record(c, "binding.create", namespace, dbName+"/"+req.Name, err)
+	if err != nil {
+		bindingError(c, err, dbName, req.Name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.CreateBindingResponse{
+		Message:   fmt.Sprintf("Binding secret %s created. It is updated when the credentials change.", binding.SecretName),
+		Namespace: namespace,
+		Binding:   binding,
+	})
+}
+
+func ListDatabaseBindings(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	dbName := c.Param("db_name")
+	namespace := k8s.TenantNamespace(username)
+
+	bindings, err := k8s.ListDatabaseBindings(namespace, dbName)
+	if err != nil {
+		bindingError(c, err, dbName, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, api.BindingListResponse{
+		Username: username,
+		DBName:   dbName,
+		Bindings: bindings,
+		Total:    len(bindings),
+	})
+}
+
+func DeleteDatabaseBinding(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	dbName := c.Param("db_name")
+	name := c.Param("binding_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	err := k8s.DeleteDatabaseBinding(namespace, dbName, name)
+	audit.Record(c, "binding.delete", namespace, dbName+"/"+name, err)
+	if err != nil {
+		bindingError(c, err, dbName, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.DeleteBindingResponse{
+		Message:   "Binding deleted successfully",
+		Namespace: namespace,
+		DBName:    dbName,
+		Name:      name,
+	})
+}
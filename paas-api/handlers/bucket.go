@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reservedBucketNames collide with the static /buckets/:username/... routes.
+var reservedBucketNames = map[string]bool{"credentials": true}
+
+// bucketError writes the response for a failed bucket operation.
+func bucketError(c *gin.Context, err error, name string) {
+	switch {
+	case errors.Is(err, k8s.ErrObjectStorageDisabled):
+		api.Error(c, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error())
+	case errors.Is(err, k8s.ErrBucketNotFound) && name == "":
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, "no bucket credentials yet, create a bucket first")
+	case errors.Is(err, k8s.ErrBucketNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("bucket %s not found", name))
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+func CreateBucket(c *gin.Context) {
+	var req api.CreateBucketRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.Username)
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("name", k8s.ValidateBucketName(namespace, req.Name)...)
+	if reservedBucketNames[req.Name] {
+		errs.add("name", "is reserved")
+	}
+	quota, problems := k8s.ParseBucketQuota(req.Quota)
+	errs.add("quota", problems...)
+	if errs.respond(c) {
+		return
+	}
+
+	bucket, err := k8s.CreateBucket(namespace, req.Name, currentUser(c), quota)
+	audit.Record(c, "bucket.create", namespace, req.Name, err)
+	if errors.Is(err, k8s.ErrBucketExists) {
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("bucket %s already exists in namespace %s", req.Name, namespace), map[string]interface{}{
+			"namespace": namespace,
+			"name":      req.Name,
+		})
+		return
+	}
+	if err != nil {
+		bucketError(c, err, req.Name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.CreateBucketResponse{
+		Message:    "Bucket created",
+		Namespace:  namespace,
+		Bucket:     bucket,
+		SecretName: k8s.BucketCredentialsSecret,
+	})
+}
+
+func ListBuckets(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	namespace := k8s.TenantNamespace(username)
+
+	buckets, err := k8s.ListBuckets(namespace)
+	if err != nil {
+		bucketError(c, err, "")
+		return
+	}
+
+	var total int64
+	for _, b := range buckets {
+		total += b.SizeBytes
+	}
+	c.JSON(http.StatusOK, api.BucketListResponse{
+		Username:   username,
+		Namespace:  namespace,
+		Buckets:    buckets,
+		Total:      len(buckets),
+		TotalBytes: total,
+	})
+}
+
+func GetBucket(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	name := c.Param("bucket_name")
+	namespace := k8s.TenantNamespace(username)
+
+	bucket, err := k8s.GetBucket(namespace, name)
+	if err != nil {
+		bucketError(c, err, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.BucketResponse{
+		Username: username,
+		Bucket:   bucket,
+	})
+}
+
+func UpdateBucket(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	var req api.UpdateBucketRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	quota, problems := k8s.ParseBucketQuota(*req.Quota)
+	errs := fieldErrors{}
+	errs.add("quota", problems...)
+	if errs.respond(c) {
+		return
+	}
+
+	username := c.Param("username")
+	name := c.Param("bucket_name")
+	namespace := k8s.TenantNamespace(username)
+
+	_, err := k8s.GetBucket(namespace, name)
+	if err == nil {
+		err = k8s.SetBucketQuota(namespace, name, quota)
+	}
+	audit.Record(c, "bucket.update", namespace, name, err)
+	if err != nil {
+		bucketError(c, err, name)
+		return
+	}
+
+	bucket, err := k8s.GetBucket(namespace, name)
+	if err != nil {
+		bucketError(c, err, name)
+		return
+	}
+	c.JSON(http.StatusOK, api.BucketResponse{
+		Username: username,
+		Bucket:   bucket,
+	})
+}
+
+func DeleteBucket(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	name := c.Param("bucket_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	err := k8s.DeleteBucket(namespace, name)
+	audit.Record(c, "bucket.delete", namespace, name, err)
+	if err != nil {
+		bucketError(c, err, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.DeleteBucketResponse{
+		Message:   "Bucket and its contents deleted",
+		Namespace: namespace,
+		Name:      name,
+	})
+}
+
+func GetBucketCredentials(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	namespace := k8s.TenantNamespace(username)
+
+	credentials, err := k8s.GetBucketCredentials(namespace)
+	audit.Record(c, "bucket.credentials.read", namespace, k8s.BucketCredentialsSecret, err)
+	if err != nil {
+		bucketError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, api.BucketCredentialsResponse{
+		Username:    username,
+		Credentials: credentials,
+	})
+}
+
+// RotateBucketCredentials replaces the tenant's access key. Workloads that
+// mount the credentials secret pick up the new key on restart.
+func RotateBucketCredentials(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	namespace := k8s.TenantNamespace(username)
+
+	credentials, err := k8s.RotateBucketCredentials(namespace)
+	audit.Record(c, "bucket.credentials.rotate", namespace, k8s.BucketCredentialsSecret, err)
+	if err != nil {
+		bucketError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, api.BucketCredentialsResponse{
+		Username:    username,
+		Credentials: credentials,
+	})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"paas-api/api"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache handlers, see statefulService.
+var (
+	CreateCache         = cacheService.handleCreate
+	ListCaches          = cacheService.handleList
+	GetCache            = cacheService.handleGet
+	GetCacheStatus      = cacheService.handleStatus
+	GetCacheCredentials = cacheService.handleCredentials
+	DeleteCache         = cacheService.handleDelete
+)
+
+var cacheService = statefulService{
+	kind:     "cache",
+	title:    "Cache",
+	param:    "cache_name",
+	notFound: k8s.ErrCacheNotFound,
+	exists:   k8s.ErrCacheExists,
+
+	bind: bindCreateCache,
+	list: func(username, namespace string) (interface{}, error) {
+		caches, err := k8s.ListCaches(namespace)
+		if err != nil {
+			return nil, err
+		}
+		return api.CacheListResponse{
+			Username:  username,
+			Namespace: namespace,
+			Caches:    caches,
+			Total:     len(caches),
+		}, nil
+	},
+	get: func(username, namespace, name string) (interface{}, error) {
+		cache, err := k8s.GetCache(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return api.CacheResponse{
+			Username: username,
+			Cache:    cache,
+		}, nil
+	},
+	status: func(username, namespace, name string) (interface{}, error) {
+		cache, err := k8s.GetCache(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return api.CacheStatusResponse{
+			Username:       username,
+			Name:           cache.Name,
+			Status:         cache.Status,
+			DetailedStatus: cache.DetailedStatus,
+		}, nil
+	},
+	credentials: func(username, namespace, name string) (interface{}, error) {
+		credentials, err := k8s.GetCacheCredentials(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return api.CacheCredentialsResponse{
+			Username:    username,
+			Name:        name,
+			Credentials: credentials,
+		}, nil
+	},
+	remove: k8s.DeleteCache,
+	deleted: func(message, namespace, name string) interface{} {
+		return api.DeleteCacheResponse{
+			Message:   message,
+			Namespace: namespace,
+			Name:      name,
+		}
+	},
+}
+
+func bindCreateCache(c *gin.Context) (createInstance, bool) {
+	var req api.CreateCacheRequest
+	if !bindJSON(c, &req) {
+		return createInstance{}, false
+	}
+
+	if req.Name == "" {
+		req.Name = defaultCacheName(req.Username)
+	}
+
+	spec := k8s.CacheSpec{Memory: req.Memory, Persistence: req.Persistence}
+	spec.Default()
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("name", k8s.ValidateCacheName(req.Name)...)
+	for field, problems := range spec.Validate() {
+		errs.add(field, problems...)
+	}
+	if errs.respond(c) {
+		return createInstance{}, false
+	}
+
+	return createInstance{
+		username: req.Username,
+		name:     req.Name,
+		provision: func(message, namespace, owner string) (interface{}, error) {
+			cache, err := k8s.ProvisionCache(namespace, req.Name, owner, spec)
+			if err != nil {
+				return nil, err
+			}
+			return api.CreateCacheResponse{
+				Message:   message,
+				Namespace: namespace,
+				Cache:     cache,
+			}, nil
+		},
+	}, true
+}
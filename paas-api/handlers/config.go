@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+	"paas-api/api"
+	"paas-api/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetConfig serves GET /admin/config, the configuration the server started
+// with. Passwords and DSNs are redacted.
+func GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, api.ConfigResponse{
+		Source: config.Source(),
+		Config: config.Current().Redacted(),
+	})
+}
@@ -1,104 +1,115 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"time"
 	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/config"
+	"paas-api/controller"
 	"paas-api/k8s"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-type DBRequest struct {
-	Username string `json:"username" binding:"required"`
-	DBName   string `json:"db_name"`   // Optional: will auto-generate if not provided
-	Replicas int    `json:"replicas"`  // Optional: defaults to 1
-}
-
-type DeleteDBRequest struct {
-	Username string `json:"username" binding:"required"`
-	DBName   string `json:"db_name"  binding:"required"`
-}
+// maxReplicas caps numberOfInstances on tenant clusters
+const maxReplicas = 5
 
 func ListAllTenantPodsHandler(c *gin.Context) {
 	podGroups, err := k8s.ListAllTenantPods()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, podGroups)
 }
 
 func ListDatabaseClusters(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
 	username := c.Param("username")
-	namespace := "tenant-" + username
+	namespace := k8s.TenantNamespace(username)
 
 	clusters, err := k8s.ListTenantDatabaseClusters(namespace)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"username": username,
-		"namespace": namespace,
-		"clusters": clusters,
-		"total_clusters": len(clusters),
-		"summary": generateClusterSummary(clusters),
+	c.JSON(http.StatusOK, api.DatabaseListResponse{
+		Username:      username,
+		Namespace:     namespace,
+		Clusters:      clusters,
+		TotalClusters: len(clusters),
+		Summary:       generateClusterSummary(clusters),
 	})
 }
 
 func GetDatabaseClusterDetails(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
 	username := c.Param("username")
 	dbName := c.Param("db_name")
-	namespace := "tenant-" + username
+	namespace := k8s.TenantNamespace(username)
 
-	cluster, err := k8s.GetDatabaseClusterInfo(namespace, dbName)
+	cluster, err := k8s.DescribeDatabaseCluster(namespace, dbName)
+	if errors.Is(err, k8s.ErrDatabaseNotFound) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("database %s not found", dbName))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"username": username,
-		"cluster": cluster,
+	c.JSON(http.StatusOK, api.DatabaseResponse{
+		Username: username,
+		Cluster:  cluster,
 	})
 }
 
-func generateClusterSummary(clusters []k8s.DatabaseClusterInfo) map[string]interface{} {
-	summary := map[string]interface{}{
-		"total": len(clusters),
-		"ready": 0,
-		"creating": 0,
-		"failed": 0,
-		"credentials_ready": 0,
-		"connection_ready": 0,
-		"manual_created": 0,
-		"zalando_created": 0,
-	}
+func generateClusterSummary(clusters []k8s.DatabaseClusterInfo) api.ClusterSummary {
+	summary := api.ClusterSummary{Total: len(clusters), Providers: map[string]int{}, Engines: map[string]int{}}
 
 	for _, cluster := range clusters {
 		switch cluster.Status {
-		case "Ready":
-			summary["ready"] = summary["ready"].(int) + 1
-		case "Creating", "Pending", "Pod Running", "Credentials Ready":
-			summary["creating"] = summary["creating"].(int) + 1
-		case "Failed", "Error":
-			summary["failed"] = summary["failed"].(int) + 1
+		case k8s.StatusReady:
+			summary.Ready++
+		case k8s.StatusProvisioning, k8s.StatusInitializing:
+			summary.Creating++
+		case k8s.StatusDegraded, k8s.StatusFailingOver, k8s.StatusUpdating:
+			summary.Degraded++
+		case k8s.StatusPaused:
+			summary.Paused++
+		case k8s.StatusFailed:
+			summary.Failed++
 		}
 
 		if cluster.CredentialsReady {
-			summary["credentials_ready"] = summary["credentials_ready"].(int) + 1
+			summary.CredentialsReady++
 		}
 
 		if cluster.ConnectionReady {
-			summary["connection_ready"] = summary["connection_ready"].(int) + 1
+			summary.ConnectionReady++
 		}
 
 		if cluster.CreationMethod == "manual" {
-			summary["manual_created"] = summary["manual_created"].(int) + 1
-		} else if cluster.CreationMethod == "zalando" {
-			summary["zalando_created"] = summary["zalando_created"].(int) + 1
+			summary.ManualCreated++
+		} else if cluster.CreationMethod == k8s.ProviderZalando {
+			summary.ZalandoCreated++
+		}
+		if cluster.CreationMethod != "" {
+			summary.Providers[cluster.CreationMethod]++
+		}
+		if cluster.Engine != "" {
+			summary.Engines[cluster.Engine]++
 		}
 	}
 
@@ -107,114 +118,288 @@ func generateClusterSummary(clusters []k8s.DatabaseClusterInfo) map[string]inter
 
 func ListTenantPodsHandler(c *gin.Context) {
 	namespace := c.Param("namespace")
+	if !strings.HasPrefix(namespace, k8s.TenantNamespacePrefix) || namespace == k8s.TenantNamespacePrefix {
+		fieldErrors{"namespace": {"must be a tenant namespace (" + k8s.TenantNamespacePrefix + "<name>)"}}.respond(c)
+		return
+	}
 
 	pods, err := k8s.ListTenantPodsJSON(namespace)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, pods)
 }
 
+// UpdateDatabase scales an existing database.
+func UpdateDatabase(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	var req api.UpdateDatabaseRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Replicas < 1 || req.Replicas > maxReplicas {
+		fieldErrors{"replicas": {fmt.Sprintf("must be between 1 and %d", maxReplicas)}}.respond(c)
+		return
+	}
+
+	username := c.Param("username")
+	dbName := c.Param("db_name")
+	namespace := k8s.TenantNamespace(username)
+
+	err := k8s.ScaleDatabase(namespace, dbName, req.Replicas)
+	audit.Record(c, "database.update", namespace, dbName, err)
+	controller.Enqueue()
+	if errors.Is(err, k8s.ErrDatabaseNotFound) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("database %s not found", dbName))
+		return
+	}
+	if errors.Is(err, k8s.ErrNotSupported) {
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, err.Error())
+		return
+	}
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.UpdateDatabaseResponse{
+		Message:   "Database scaled",
+		Namespace: namespace,
+		DBName:    dbName,
+		Replicas:  req.Replicas,
+	})
+}
+
 func DeleteDatabase(c *gin.Context) {
-	var req DeleteDBRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		fmt.Printf("Invalid delete request: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req api.DeleteDatabaseRequest
+	if !bindJSON(c, &req) {
+		fmt.Printf("Invalid delete request\n")
 		return
 	}
 
 	fmt.Printf("Delete request received - Username: %s, DBName: %s\n", req.Username, req.DBName)
 
-	namespace := "tenant-" + req.Username
-	err := k8s.DeleteTenantDB(namespace, req.DBName)
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("db_name", k8s.ValidateDBName(req.DBName)...)
+	if errs.respond(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.Username)
+
+	err := k8s.DeleteDatabase(namespace, req.DBName)
+	audit.Record(c, "database.delete", namespace, req.DBName, err)
+	if errors.Is(err, k8s.ErrDatabaseNotFound) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("database %s not found", req.DBName))
+		return
+	}
 	if err != nil {
 		fmt.Printf("Failed to delete database: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 
 	fmt.Printf("Database %s deleted successfully from namespace %s\n", req.DBName, namespace)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Database deleted successfully",
-		"namespace": namespace,
-		"db_name":   req.DBName,
+	c.JSON(http.StatusOK, api.DeleteDatabaseResponse{
+		Message:   "Database deleted successfully",
+		Namespace: namespace,
+		DBName:    req.DBName,
 	})
 }
 
 func GetDatabaseCredentials(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
 	username := c.Param("username")
 	dbName := c.Param("db_name")
-	namespace := "tenant-" + username
+	namespace := k8s.TenantNamespace(username)
 
 	// Try to get credentials without waiting
-	credentials, err := k8s.GetDatabaseCredentials(namespace, dbName, 5*time.Second)
+	credentials, err := k8s.GetDatabaseCredentials(namespace, dbName, config.Current().Databases.CredentialsWait.Duration)
+	audit.Record(c, "credentials.read", namespace, dbName, err)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Credentials not yet available",
+		api.ErrorDetails(c, http.StatusNotFound, api.CodeNotFound, "Credentials not yet available", map[string]interface{}{
 			"message": "Database may still be initializing",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"db_name":     dbName,
-		"username":    username,
-		"credentials": credentials,
+	c.JSON(http.StatusOK, api.CredentialsResponse{
+		DBName:      dbName,
+		Username:    username,
+		Credentials: credentials,
 	})
 }
 
 func GetDatabaseStatus(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
 	username := c.Param("username")
 	dbName := c.Param("db_name")
-	namespace := "tenant-" + username
+	namespace := k8s.TenantNamespace(username)
 
 	status, err := k8s.CheckTenantDBStatus(namespace, dbName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"db_name":  dbName,
-		"status":   status,
-		"username": username,
+	c.JSON(http.StatusOK, api.DatabaseStatusResponse{
+		DBName:   dbName,
+		Status:   status,
+		Username: username,
 	})
 }
 
 func CreateDatabase(c *gin.Context) {
-	var req DBRequest
+	var req api.CreateDatabaseRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Auto-generate database name if not provided
 	if req.DBName == "" {
-		req.DBName = fmt.Sprintf("%s-db", req.Username)
+		req.DBName = defaultDBName(req.Username)
+	}
+
+	spec := k8s.TenantDatabaseSpec{Engine: req.Engine, Plan: req.Plan, Version: req.Version, Replicas: req.Replicas}
+	spec.Default()
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("db_name", k8s.ValidateDBName(req.DBName)...)
+	if spec.Replicas > maxReplicas {
+		errs.add("replicas", fmt.Sprintf("must be between 1 and %d", maxReplicas))
+	}
+	for field, problems := range spec.Validate() {
+		errs.add(field, problems...)
+	}
+	if errs.respond(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.Username)
+
+	var credentials *k8s.ProvisionResult
+	var err error
+	if k8s.TenantDatabasesEnabled() {
+		credentials, err = k8s.CreateTenantDatabase(namespace, req.DBName, currentUser(c), spec)
+		controller.Enqueue()
+	} else {
+		credentials, err = k8s.ProvisionTenantDBWithCredentials(namespace, req.DBName, currentUser(c), spec)
+	}
+	audit.Record(c, "database.create", namespace, req.DBName, err)
+	if errors.Is(err, k8s.ErrDatabaseExists) {
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("database %s already exists in namespace %s", req.DBName, namespace), map[string]interface{}{
+			"namespace": namespace,
+			"db_name":   req.DBName,
+		})
+		return
+	}
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.CreateDatabaseResponse{
+		Message:     "Database provisioned successfully",
+		Namespace:   namespace,
+		DBName:      req.DBName,
+		Credentials: credentials,
+	})
+}
+
+// GetDatabaseLogs returns the tail of the postgres log. Query parameters:
+// pod (defaults to the primary) and lines.
+func GetDatabaseLogs(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	dbName := c.Param("db_name")
+	namespace := k8s.TenantNamespace(username)
+
+	lines := int64(k8s.DefaultLogLines)
+	if raw := c.Query("lines"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 || n > 10000 {
+			fieldErrors{"lines": {"must be an integer between 1 and 10000"}}.respond(c)
+			return
+		}
+		lines = n
+	}
+
+	pod, logs, err := k8s.GetDatabaseLogs(namespace, dbName, c.Query("pod"), lines)
+	if errors.Is(err, k8s.ErrDatabaseNotFound) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("database %s not found", dbName))
+		return
+	}
+	if err != nil {
+		api.InternalError(c, err)
+		return
 	}
 
-	// Set default replicas
-	if req.Replicas <= 0 {
-		req.Replicas = 1
+	c.JSON(http.StatusOK, api.DatabaseLogsResponse{
+		DBName: dbName,
+		Pod:    pod,
+		Logs:   logs,
+	})
+}
+
+// CreateDatabaseBackup starts an on-demand backup with the database's provider.
+func CreateDatabaseBackup(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
 	}
 
-	namespace := "tenant-" + req.Username
+	username := c.Param("username")
+	dbName := c.Param("db_name")
+	namespace := k8s.TenantNamespace(username)
 
-	// Use the simpler provision function that handles everything
-	credentials, err := k8s.ProvisionTenantDBWithCredentials(namespace, req.DBName, req.Replicas)
+	job, err := k8s.TriggerBackup(namespace, dbName)
+	pending := errors.Is(err, k8s.ErrBackupPending)
+	if pending {
+		err = nil
+	}
+	audit.Record(c, "database.backup", namespace, dbName, err)
+	if pending {
+		c.JSON(http.StatusAccepted, api.BackupResponse{
+			Message:   "Logical backups enabled. Retry shortly to start an on-demand backup.",
+			Namespace: namespace,
+			DBName:    dbName,
+		})
+		return
+	}
+	if errors.Is(err, k8s.ErrDatabaseNotFound) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("database %s not found", dbName))
+		return
+	}
+	if errors.Is(err, k8s.ErrNotSupported) {
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, err.Error())
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		api.InternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "Database provisioned successfully",
-		"namespace":   namespace,
-		"db_name":     req.DBName,
-		"credentials": credentials,
+	c.JSON(http.StatusAccepted, api.BackupResponse{
+		Message:   "Backup started",
+		Namespace: namespace,
+		DBName:    dbName,
+		Job:       job,
 	})
 }
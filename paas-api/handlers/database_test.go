@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"paas-api/auth"
+	"paas-api/k8s"
+	"paas-api/server/servertest"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestListTenantPods(t *testing.T) {
+	srv := servertest.New(t)
+	createTenant(t, srv, "alice", false)
+	createTenant(t, srv, "bob", false)
+	for _, namespace := range []string{"tenant-alice", "tenant-bob"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: namespace},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "web", Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				}},
+				{Name: "sidecar", Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+				}},
+			}},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "web", RestartCount: 2}, {Name: "sidecar", RestartCount: 1}},
+			},
+		}
+		if _, err := srv.Kube.CoreV1().Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	owner := srv.Token(t, auth.Grant{Role: "owner", Tenant: "alice"})
+
+	var pods []k8s.PodInfo
+	if code := call(t, srv, owner, http.MethodGet, "/pods/tenant-alice", nil, &pods); code != http.StatusOK {
+		t.Fatalf("GET /pods/tenant-alice = %d", code)
+	}
+	want := k8s.PodInfo{
+		Name: "web-0", Namespace: "tenant-alice", Status: "Running", Restarts: 3,
+		// The fake cluster has no metrics-server
+		CPU: k8s.MetricsUnavailable, Memory: k8s.MetricsUnavailable,
+		CPURequest: "150m", CPULimit: "N/A", MemoryRequest: "N/A", MemoryLimit: "256Mi",
+	}
+	if len(pods) != 1 {
+		t.Fatalf("pods = %+v, want one", pods)
+	}
+	pods[0].Age = ""
+	if pods[0] != want {
+		t.Errorf("pod = %+v\nwant  %+v", pods[0], want)
+	}
+
+	for path, wantCode := range map[string]int{
+		"/pods/tenant-bob": http.StatusForbidden,
+		"/pods/alice":      http.StatusBadRequest,
+		"/pods/tenant-":    http.StatusForbidden,
+	} {
+		if code, body := request(t, srv, owner, http.MethodGet, path, nil); code != wantCode {
+			t.Errorf("GET %s = %d %v, want %d", path, code, body, wantCode)
+		}
+	}
+
+	// Admins see every tenant's pods
+	admin := srv.Login(t, "1", "root", "platform-admin")
+	if code := call(t, srv, admin, http.MethodGet, "/admin/tenants/pods", nil, &pods); code != http.StatusOK || len(pods) != 2 {
+		t.Errorf("GET /admin/tenants/pods = %d %+v, want both pods", code, pods)
+	}
+}
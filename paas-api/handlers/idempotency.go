@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"paas-api/api"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	maxIdempotencyKey = 255
+)
+
+// capturingWriter keeps a copy of the response body for the idempotency store.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent makes a POST route safe to retry. When the client sends an
+// Idempotency-Key header, the first request runs normally and its response is
+// stored; retries with the same key and body replay that response instead of
+// starting a second operation. Keys are scoped to the caller and the route.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			api.Abort(c, http.StatusBadRequest, api.CodeBadRequest, fmt.Sprintf("%s must be at most %d characters", idempotencyHeader, maxIdempotencyKey))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			api.Abort(c, http.StatusBadRequest, api.CodeBadRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := sha256.Sum256([]byte(currentUser(c) + "\n" + c.Request.Method + " " + c.FullPath() + "\n" + key))
+		id := hex.EncodeToString(scope[:16])
+		bodyHash := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(bodyHash[:])
+
+		existing, claimed, err := k8s.ClaimIdempotencyKey(id, fingerprint)
+		if err != nil {
+			api.Abort(c, http.StatusInternalServerError, api.CodeInternal, err.Error())
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				api.Abort(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, idempotencyHeader+" was already used with a different request body")
+			case !existing.Completed:
+				api.Abort(c, http.StatusConflict, api.CodeConflict, "a request with this "+idempotencyHeader+" is still in progress")
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.Status, "application/json; charset=utf-8", []byte(existing.Body))
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// Server errors are not cached so the client can retry the operation
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := k8s.ReleaseIdempotencyKey(id); err != nil {
+				fmt.Printf("Failed to release idempotency key: %v\n", err)
+			}
+			return
+		}
+		if err := k8s.CompleteIdempotencyKey(id, writer.Status(), writer.body.String()); err != nil {
+			fmt.Printf("Failed to store idempotent response: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// jobError writes the response for a failed job operation.
+func jobError(c *gin.Context, err error, name string, spec k8s.JobSpec) {
+	switch {
+	case errors.Is(err, k8s.ErrJobNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("job %s not found", name))
+	case errors.Is(err, k8s.ErrJobRunNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("job %s has no such run", name))
+	case errors.Is(err, k8s.ErrJobRunning):
+		api.Error(c, http.StatusConflict, api.CodeConflict, err.Error())
+	case errors.Is(err, k8s.ErrDatabaseNotFound):
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, fmt.Sprintf("database %s not found", spec.Database))
+	case apierrors.IsInvalid(err):
+		// The API server has the final say on schedules
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, err.Error())
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+func CreateJob(c *gin.Context) {
+	var req api.CreateJobRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	spec := k8s.JobSpec{
+		Schedule: req.Schedule,
+		TimeZone: req.TimeZone,
+		Image:    req.Image,
+		Command:  req.Command,
+		Env:      req.Env,
+		Plan:     req.Plan,
+		Database: req.Database,
+	}
+	k8s.DefaultJobSpec(&spec)
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("name", k8s.ValidateJobName(req.Name)...)
+	for field, problems := range k8s.ValidateJobSpec(spec) {
+		errs.add(field, problems...)
+	}
+	if errs.respond(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.Username)
+
+	job, err := k8s.CreateJob(namespace, req.Name, currentUser(c), spec)
+	audit.Record(c, "job.create", namespace, req.Name, err)
+	if errors.Is(err, k8s.ErrJobExists) {
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("job %s already exists in namespace %s", req.Name, namespace), map[string]interface{}{
+			"namespace": namespace,
+			"name":      req.Name,
+		})
+		return
+	}
+	if err != nil {
+		jobError(c, err, req.Name, spec)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.CreateJobResponse{
+		Message:   fmt.Sprintf("Job scheduled on %q", spec.Schedule),
+		Namespace: namespace,
+		Job:       job,
+	})
+}
+
+func ListJobs(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	namespace := k8s.TenantNamespace(username)
+
+	jobs, err := k8s.ListJobs(namespace)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.JobListResponse{
+		Username:  username,
+		Namespace: namespace,
+		Jobs:      jobs,
+		Total:     len(jobs),
+	})
+}
+
+func GetJob(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	job, err := k8s.GetJob(namespace, c.Param("job_name"))
+	if err != nil {
+		jobError(c, err, c.Param("job_name"), k8s.JobSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.JobResponse{
+		Username: c.Param("username"),
+		Job:      job,
+	})
+}
+
+// TriggerJob starts a run now, independent of the schedule.
+func TriggerJob(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	name := c.Param("job_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	run, err := k8s.TriggerJob(namespace, name)
+	audit.Record(c, "job.run", namespace, name, err)
+	if err != nil {
+		jobError(c, err, name, k8s.JobSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TriggerJobResponse{
+		Message: fmt.Sprintf("Run %s started", run.Name),
+		Run:     run,
+	})
+}
+
+// GetJobLogs returns the output of a run. Query parameters: run (defaults to
+// the latest) and lines.
+func GetJobLogs(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	name := c.Param("job_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	lines := int64(k8s.DefaultLogLines)
+	if raw := c.Query("lines"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 || n > 10000 {
+			fieldErrors{"lines": {"must be an integer between 1 and 10000"}}.respond(c)
+			return
+		}
+		lines = n
+	}
+
+	run, logs, err := k8s.GetJobLogs(namespace, name, c.Query("run"), lines)
+	if err != nil {
+		jobError(c, err, name, k8s.JobSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.JobLogsResponse{
+		Name: name,
+		Run:  run,
+		Logs: logs,
+	})
+}
+
+func DeleteJob(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	name := c.Param("job_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	err := k8s.DeleteJob(namespace, name)
+	audit.Record(c, "job.delete", namespace, name, err)
+	if err != nil {
+		jobError(c, err, name, k8s.JobSpec{})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.DeleteJobResponse{
+		Message:   "Job deleted successfully",
+		Namespace: namespace,
+		Name:      name,
+	})
+}
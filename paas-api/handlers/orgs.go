@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/auth"
+	"paas-api/k8s"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const invitationLifetime = 7 * 24 * time.Hour
+
+var orgNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// loadOrg fetches the organization named in the route, writing a 404 if it is missing.
+func loadOrg(c *gin.Context) (*k8s.Organization, bool) {
+	org, err := k8s.GetOrg(c.Param("org"))
+	if err != nil {
+		api.InternalError(c, err)
+		return nil, false
+	}
+	if org == nil {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, "organization not found")
+		return nil, false
+	}
+	return org, true
+}
+
+func validOrgRole(role string) bool {
+	_, ok := auth.RolePermissions(role)
+	return ok && role != "platform-admin" && role != "admin"
+}
+
+func hashInvitationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func CreateOrg(c *gin.Context) {
+	var req api.CreateOrgRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if len(req.Name) > 40 || !orgNamePattern.MatchString(req.Name) {
+		fieldErrors{"name": {"must be lowercase letters, digits and '-' (max 40 characters)"}}.respond(c)
+		return
+	}
+	// The org is looked up by the tenant slug of its namespace
+	if !k8s.IsCanonicalTenantName(req.Name) {
+		fieldErrors{"name": {"must not be reserved or end in '-' followed by 8 hexadecimal characters"}}.respond(c)
+		return
+	}
+	if req.DisplayName == "" {
+		req.DisplayName = req.Name
+	}
+
+	now := time.Now().UTC()
+	org := &k8s.Organization{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Namespace:   k8s.TenantNamespace(req.Name),
+		CreatedAt:   now,
+		Members: []k8s.OrgMember{{
+			Subject:  currentUser(c),
+			Email:    c.GetString("email"),
+			Role:     "owner",
+			JoinedAt: now,
+		}},
+		Invitations: []k8s.OrgInvitation{},
+	}
+
+	err := k8s.CreateOrg(org)
+	audit.Record(c, "org.create", org.Namespace, "", err)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+func ListOrgs(c *gin.Context) {
+	orgs, err := k8s.ListOrgsForMember(currentUser(c))
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	// Invitations are managed per organization, don't leak them in the listing
+	for i := range orgs {
+		orgs[i].Invitations = nil
+	}
+
+	c.JSON(http.StatusOK, api.OrgListResponse{
+		Organizations: orgs,
+		Total:         len(orgs),
+	})
+}
+
+func GetOrg(c *gin.Context) {
+	org, ok := loadOrg(c)
+	if !ok {
+		return
+	}
+
+	// Only owners see pending invitations
+	if !auth.HasPermission(c, auth.PermOrgManage) {
+		org.Invitations = nil
+	}
+	for i := range org.Invitations {
+		org.Invitations[i].CodeHash = ""
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// ListOrgDatabases lists every database owned by the organization.
+func ListOrgDatabases(c *gin.Context) {
+	org, ok := loadOrg(c)
+	if !ok {
+		return
+	}
+
+	clusters, err := k8s.ListTenantDatabaseClusters(org.Namespace)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.OrgDatabasesResponse{
+		Organization:  org.Name,
+		Namespace:     org.Namespace,
+		Clusters:      clusters,
+		TotalClusters: len(clusters),
+		Summary:       generateClusterSummary(clusters),
+	})
+}
+
+var (
+	errInvitationNotFound = errors.New("invitation not found")
+	errInvitationExpired  = errors.New("invitation not found or expired")
+	errInviteeMismatch    = errors.New("invitation was issued to a different user")
+	errMemberNotFound     = errors.New("member not found")
+	errLastOwner          = errors.New("an organization needs at least one owner")
+)
+
+// orgUpdateError writes the response for an error returned by k8s.UpdateOrg.
+func orgUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, k8s.ErrOrgNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, "organization not found")
+	case errors.Is(err, errInvitationNotFound), errors.Is(err, errInvitationExpired), errors.Is(err, errMemberNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, err.Error())
+	case errors.Is(err, errInviteeMismatch):
+		api.Error(c, http.StatusForbidden, api.CodeForbidden, err.Error())
+	case errors.Is(err, errLastOwner):
+		api.Error(c, http.StatusConflict, api.CodeConflict, err.Error())
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+func CreateOrgInvitation(c *gin.Context) {
+	var req api.CreateInvitationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !validOrgRole(req.Role) {
+		fieldErrors{"role": {fmt.Sprintf("unknown role %q", req.Role)}}.respond(c)
+		return
+	}
+
+	org, ok := loadOrg(c)
+	if !ok {
+		return
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		api.InternalError(c, err)
+		return
+	}
+	id := hex.EncodeToString(secret[:4])
+	// The code carries the org name so it can be resolved without scanning all orgs
+	code := org.Name + "." + id + "." + hex.EncodeToString(secret[4:])
+
+	now := time.Now().UTC()
+	invitation := k8s.OrgInvitation{
+		ID:        id,
+		Invitee:   req.Invitee,
+		Role:      req.Role,
+		InvitedBy: currentUser(c),
+		CreatedAt: now,
+		ExpiresAt: now.Add(invitationLifetime),
+		CodeHash:  hashInvitationCode(code),
+	}
+
+	_, err := k8s.UpdateOrg(org.Name, func(org *k8s.Organization) error {
+		org.Invitations = append(org.Invitations, invitation)
+		return nil
+	})
+	audit.Record(c, "org.invite", org.Namespace, "", err)
+	if err != nil {
+		orgUpdateError(c, err)
+		return
+	}
+
+	invitation.CodeHash = ""
+	c.JSON(http.StatusCreated, api.CreateInvitationResponse{
+		Message:    "Invitation created. Share the code with the invitee, it will not be shown again.",
+		Code:       code,
+		Invitation: invitation,
+	})
+}
+
+func RevokeOrgInvitation(c *gin.Context) {
+	org, ok := loadOrg(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	_, err := k8s.UpdateOrg(org.Name, func(org *k8s.Organization) error {
+		kept := org.Invitations[:0]
+		found := false
+		for _, inv := range org.Invitations {
+			if inv.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, inv)
+		}
+		if !found {
+			return errInvitationNotFound
+		}
+		org.Invitations = kept
+		return nil
+	})
+	if errors.Is(err, errInvitationNotFound) {
+		orgUpdateError(c, err)
+		return
+	}
+	audit.Record(c, "org.invite.revoke", org.Namespace, "", err)
+	if err != nil {
+		orgUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.RevokeInvitationResponse{Message: "Invitation revoked", ID: id})
+}
+
+// AcceptOrgInvitation adds the caller to the organization named in the code.
+func AcceptOrgInvitation(c *gin.Context) {
+	code := c.Param("code")
+	orgName, rest, _ := strings.Cut(code, ".")
+	id, _, _ := strings.Cut(rest, ".")
+
+	subject := currentUser(c)
+	email := c.GetString("email")
+
+	var invitation *k8s.OrgInvitation
+	org, err := k8s.UpdateOrg(orgName, func(org *k8s.Organization) error {
+		invitation = nil
+		var remaining []k8s.OrgInvitation
+		for i := range org.Invitations {
+			inv := org.Invitations[i]
+			if inv.ID == id && subtle.ConstantTimeCompare([]byte(inv.CodeHash), []byte(hashInvitationCode(code))) == 1 {
+				invitation = &inv
+				continue
+			}
+			remaining = append(remaining, inv)
+		}
+
+		if invitation == nil || time.Now().After(invitation.ExpiresAt) {
+			return errInvitationExpired
+		}
+		if invitation.Invitee != "" && invitation.Invitee != subject && !strings.EqualFold(invitation.Invitee, email) {
+			return errInviteeMismatch
+		}
+
+		if member := org.Member(subject); member != nil {
+			member.Role = invitation.Role
+		} else {
+			org.Members = append(org.Members, k8s.OrgMember{
+				Subject:  subject,
+				Email:    email,
+				Role:     invitation.Role,
+				JoinedAt: time.Now().UTC(),
+			})
+		}
+		org.Invitations = remaining
+		return nil
+	})
+	switch {
+	case errors.Is(err, k8s.ErrOrgNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, "invitation not found")
+		return
+	case errors.Is(err, errInvitationExpired), errors.Is(err, errInviteeMismatch):
+		orgUpdateError(c, err)
+		return
+	}
+	audit.Record(c, "org.join", k8s.TenantNamespace(orgName), "", err)
+	if err != nil {
+		orgUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.AcceptInvitationResponse{
+		Message:      "Joined organization",
+		Organization: org.Name,
+		Namespace:    org.Namespace,
+		Role:         invitation.Role,
+	})
+}
+
+func UpdateOrgMember(c *gin.Context) {
+	var req api.UpdateMemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !validOrgRole(req.Role) {
+		fieldErrors{"role": {fmt.Sprintf("unknown role %q", req.Role)}}.respond(c)
+		return
+	}
+
+	org, ok := loadOrg(c)
+	if !ok {
+		return
+	}
+
+	var member k8s.OrgMember
+	_, err := k8s.UpdateOrg(org.Name, func(org *k8s.Organization) error {
+		m := org.Member(c.Param("member"))
+		if m == nil {
+			return errMemberNotFound
+		}
+		if m.Role == "owner" && req.Role != "owner" && countOwners(org) == 1 {
+			return errLastOwner
+		}
+		m.Role = req.Role
+		member = *m
+		return nil
+	})
+	if errors.Is(err, errMemberNotFound) || errors.Is(err, errLastOwner) {
+		orgUpdateError(c, err)
+		return
+	}
+	audit.Record(c, "org.member.update", org.Namespace, "", err)
+	if err != nil {
+		orgUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func RemoveOrgMember(c *gin.Context) {
+	org, ok := loadOrg(c)
+	if !ok {
+		return
+	}
+
+	subject := c.Param("member")
+	_, err := k8s.UpdateOrg(org.Name, func(org *k8s.Organization) error {
+		member := org.Member(subject)
+		if member == nil {
+			return errMemberNotFound
+		}
+		if member.Role == "owner" && countOwners(org) == 1 {
+			return errLastOwner
+		}
+
+		kept := org.Members[:0]
+		for _, m := range org.Members {
+			if m.Subject != subject {
+				kept = append(kept, m)
+			}
+		}
+		org.Members = kept
+		return nil
+	})
+	if errors.Is(err, errMemberNotFound) || errors.Is(err, errLastOwner) {
+		orgUpdateError(c, err)
+		return
+	}
+	audit.Record(c, "org.member.remove", org.Namespace, "", err)
+	if err != nil {
+		orgUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.RemoveMemberResponse{Message: "Member removed", Subject: subject})
+}
+
+func countOwners(org *k8s.Organization) int {
+	owners := 0
+	for _, m := range org.Members {
+		if m.Role == "owner" {
+			owners++
+		}
+	}
+	return owners
+}
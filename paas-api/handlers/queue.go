@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"paas-api/api"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Queue handlers, see statefulService.
+var (
+	CreateQueue         = queueService.handleCreate
+	ListQueues          = queueService.handleList
+	GetQueue            = queueService.handleGet
+	GetQueueStatus      = queueService.handleStatus
+	GetQueueCredentials = queueService.handleCredentials
+	DeleteQueue         = queueService.handleDelete
+)
+
+var queueService = statefulService{
+	kind:     "queue",
+	title:    "Queue",
+	param:    "queue_name",
+	notFound: k8s.ErrQueueNotFound,
+	exists:   k8s.ErrQueueExists,
+
+	bind: bindCreateQueue,
+	list: func(username, namespace string) (interface{}, error) {
+		queues, err := k8s.ListQueues(namespace)
+		if err != nil {
+			return nil, err
+		}
+		return api.QueueListResponse{
+			Username:  username,
+			Namespace: namespace,
+			Queues:    queues,
+			Total:     len(queues),
+		}, nil
+	},
+	get: func(username, namespace, name string) (interface{}, error) {
+		queue, err := k8s.GetQueue(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return api.QueueResponse{
+			Username: username,
+			Queue:    queue,
+		}, nil
+	},
+	status: func(username, namespace, name string) (interface{}, error) {
+		queue, err := k8s.GetQueue(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return api.QueueStatusResponse{
+			Username:       username,
+			Name:           queue.Name,
+			Status:         queue.Status,
+			DetailedStatus: queue.DetailedStatus,
+		}, nil
+	},
+	credentials: func(username, namespace, name string) (interface{}, error) {
+		credentials, err := k8s.GetQueueCredentials(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return api.QueueCredentialsResponse{
+			Username:    username,
+			Name:        name,
+			Credentials: credentials,
+		}, nil
+	},
+	remove: k8s.DeleteQueue,
+	deleted: func(message, namespace, name string) interface{} {
+		return api.DeleteQueueResponse{
+			Message:   message,
+			Namespace: namespace,
+			Name:      name,
+		}
+	},
+}
+
+func bindCreateQueue(c *gin.Context) (createInstance, bool) {
+	var req api.CreateQueueRequest
+	if !bindJSON(c, &req) {
+		return createInstance{}, false
+	}
+
+	if req.Name == "" {
+		req.Name = defaultQueueName(req.Username)
+	}
+
+	spec := k8s.QueueSpec{Engine: req.Engine, Memory: req.Memory, Storage: req.Storage}
+	spec.Default()
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("name", k8s.ValidateQueueName(req.Name)...)
+	for field, problems := range spec.Validate() {
+		errs.add(field, problems...)
+	}
+	if errs.respond(c) {
+		return createInstance{}, false
+	}
+
+	return createInstance{
+		username: req.Username,
+		name:     req.Name,
+		provision: func(message, namespace, owner string) (interface{}, error) {
+			queue, err := k8s.ProvisionQueue(namespace, req.Name, owner, spec)
+			if err != nil {
+				return nil, err
+			}
+			return api.CreateQueueResponse{
+				Message:   message,
+				Namespace: namespace,
+				Queue:     queue,
+			}, nil
+		},
+	}, true
+}
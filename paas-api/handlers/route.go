@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeError writes the response for a failed route operation.
+func routeError(c *gin.Context, err error, name string) {
+	switch {
+	case errors.Is(err, k8s.ErrIngressDisabled):
+		api.Error(c, http.StatusServiceUnavailable, api.CodeUnavailable, err.Error())
+	case errors.Is(err, k8s.ErrRouteNotFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("route %s not found", name))
+	case errors.Is(err, k8s.ErrHostnameTaken):
+		api.Error(c, http.StatusConflict, api.CodeConflict, err.Error())
+	case errors.Is(err, k8s.ErrNoCustomHostname), errors.Is(err, k8s.ErrDomainNotVerified):
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, err.Error())
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+func CreateRoute(c *gin.Context) {
+	var req api.CreateRouteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.Username)
+
+	spec := k8s.RouteSpec{Service: req.Service, Port: req.Port, Path: req.Path, Hostname: req.Hostname}
+	spec.Default()
+
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(req.Username)...)
+	errs.add("name", k8s.ValidateRouteName(namespace, req.Name)...)
+	for field, problems := range spec.Validate() {
+		errs.add(field, problems...)
+	}
+	if errs.respond(c) {
+		return
+	}
+
+	route, err := k8s.CreateRoute(namespace, req.Name, spec)
+	audit.Record(c, "route.create", namespace, req.Name, err)
+	if errors.Is(err, k8s.ErrRouteExists) {
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("route %s already exists in namespace %s", req.Name, namespace), map[string]interface{}{
+			"namespace": namespace,
+			"name":      req.Name,
+		})
+		return
+	}
+	if errors.Is(err, k8s.ErrServiceNotFound) {
+		api.Error(c, http.StatusUnprocessableEntity, api.CodeUnprocessable, fmt.Sprintf("service %s not found in namespace %s", spec.Service, namespace))
+		return
+	}
+	if err != nil {
+		routeError(c, err, req.Name)
+		return
+	}
+
+	message := fmt.Sprintf("Route created, serving %s", route.URL)
+	if route.Challenge != nil {
+		message += fmt.Sprintf(". Verify %s with the challenge before it is served", route.Hostname)
+	}
+	c.JSON(http.StatusOK, api.CreateRouteResponse{
+		Message:   message,
+		Namespace: namespace,
+		Route:     route,
+	})
+}
+
+func ListRoutes(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	username := c.Param("username")
+	namespace := k8s.TenantNamespace(username)
+
+	routes, err := k8s.ListRoutes(namespace)
+	if err != nil {
+		routeError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, api.RouteListResponse{
+		Username:  username,
+		Namespace: namespace,
+		Routes:    routes,
+		Total:     len(routes),
+	})
+}
+
+func GetRoute(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	route, err := k8s.GetRoute(namespace, c.Param("route_name"))
+	if err != nil {
+		routeError(c, err, c.Param("route_name"))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.RouteResponse{
+		Username: c.Param("username"),
+		Route:    route,
+	})
+}
+
+func VerifyRoute(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	var req api.VerifyRouteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Method != k8s.DomainChallengeTXT && req.Method != k8s.DomainChallengeHTTP {
+		fieldErrors{"method": {fmt.Sprintf("must be %s or %s", k8s.DomainChallengeTXT, k8s.DomainChallengeHTTP)}}.respond(c)
+		return
+	}
+
+	name := c.Param("route_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	route, err := k8s.VerifyRouteHostname(namespace, name, req.Method)
+	audit.Record(c, "route.verify", namespace, name, err)
+	if err != nil {
+		routeError(c, err, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.RouteResponse{
+		Username: c.Param("username"),
+		Route:    route,
+	})
+}
+
+func DeleteRoute(c *gin.Context) {
+	if !validateTenantParams(c) {
+		return
+	}
+
+	name := c.Param("route_name")
+	namespace := k8s.TenantNamespace(c.Param("username"))
+
+	err := k8s.DeleteRoute(namespace, name)
+	audit.Record(c, "route.delete", namespace, name, err)
+	if err != nil {
+		routeError(c, err, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.DeleteRouteResponse{
+		Message:   "Route deleted successfully",
+		Namespace: namespace,
+		Name:      name,
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statefulService implements the routes of a service that runs as a single
+// StatefulSet per instance, such as caches and queues. The handlers are the
+// same for every such kind; each kind supplies its k8s functions, wrapped to
+// return the kind's response bodies.
+type statefulService struct {
+	kind     string // as in messages and audit actions, e.g. "cache"
+	title    string // kind at the start of a sentence, e.g. "Cache"
+	param    string // route parameter holding the instance name
+	notFound error
+	exists   error
+
+	// bind reads and validates a create request, writing the error response
+	// if it is invalid.
+	bind func(c *gin.Context) (createInstance, bool)
+	// list returns the response listing the instances in namespace.
+	list func(username, namespace string) (interface{}, error)
+	// get returns the response describing an instance, and status the one
+	// reporting only its status.
+	get    func(username, namespace, name string) (interface{}, error)
+	status func(username, namespace, name string) (interface{}, error)
+	// credentials returns the response holding an instance's credentials.
+	credentials func(username, namespace, name string) (interface{}, error)
+	// remove deletes an instance, and deleted returns the response to it.
+	remove  func(namespace, name string) error
+	deleted func(message, namespace, name string) interface{}
+}
+
+// createInstance is a valid create request.
+type createInstance struct {
+	username string
+	name     string
+	// provision creates the instance and returns the response to the
+	// request, with message as its message.
+	provision func(message, namespace, owner string) (interface{}, error)
+}
+
+// namespace returns the namespace of the tenant in the route, writing an
+// error if the parameters are invalid.
+func (s statefulService) namespace(c *gin.Context) (string, bool) {
+	if !validateTenantParams(c) {
+		return "", false
+	}
+	return k8s.TenantNamespace(c.Param("username")), true
+}
+
+// respondError writes the response for an error of the k8s functions of the
+// service.
+func (s statefulService) respondError(c *gin.Context, err error, namespace, name string) {
+	switch {
+	case errors.Is(err, s.exists):
+		api.ErrorDetails(c, http.StatusConflict, api.CodeConflict, fmt.Sprintf("%s %s already exists in namespace %s", s.kind, name, namespace), map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		})
+	case errors.Is(err, s.notFound):
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("%s %s not found", s.kind, name))
+	case errors.Is(err, k8s.ErrCredentialsPending):
+		api.ErrorDetails(c, http.StatusNotFound, api.CodeNotFound, "Credentials not yet available", map[string]interface{}{
+			"message": s.title + " may still be initializing",
+		})
+	default:
+		api.InternalError(c, err)
+	}
+}
+
+func (s statefulService) handleCreate(c *gin.Context) {
+	req, ok := s.bind(c)
+	if !ok {
+		return
+	}
+
+	namespace := k8s.TenantNamespace(req.username)
+
+	resp, err := req.provision(s.title+" is being provisioned. Credentials are available immediately.", namespace, currentUser(c))
+	audit.Record(c, s.kind+".create", namespace, req.name, err)
+	if err != nil {
+		s.respondError(c, err, namespace, req.name)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s statefulService) handleList(c *gin.Context) {
+	namespace, ok := s.namespace(c)
+	if !ok {
+		return
+	}
+
+	resp, err := s.list(c.Param("username"), namespace)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s statefulService) handleGet(c *gin.Context) {
+	s.respondInstance(c, s.get)
+}
+
+func (s statefulService) handleStatus(c *gin.Context) {
+	s.respondInstance(c, s.status)
+}
+
+// respondInstance writes the response describe returns for the instance
+// named in the route.
+func (s statefulService) respondInstance(c *gin.Context, describe func(username, namespace, name string) (interface{}, error)) {
+	namespace, ok := s.namespace(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param(s.param)
+
+	resp, err := describe(c.Param("username"), namespace, name)
+	if err != nil {
+		s.respondError(c, err, namespace, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s statefulService) handleCredentials(c *gin.Context) {
+	namespace, ok := s.namespace(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param(s.param)
+
+	resp, err := s.credentials(c.Param("username"), namespace, name)
+	audit.Record(c, s.kind+".credentials.read", namespace, name, err)
+	if err != nil {
+		s.respondError(c, err, namespace, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s statefulService) handleDelete(c *gin.Context) {
+	namespace, ok := s.namespace(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param(s.param)
+
+	err := s.remove(namespace, name)
+	audit.Record(c, s.kind+".delete", namespace, name, err)
+	if err != nil {
+		s.respondError(c, err, namespace, name)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.deleted(s.title+" deleted successfully", namespace, name))
+}
@@ -0,0 +1,98 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"paas-api/audit"
+	"paas-api/auth"
+	"paas-api/server/servertest"
+)
+
+// request sends an API call with token and decodes the response into a map.
+func request(t *testing.T, srv *servertest.Server, token, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var out map[string]interface{}
+	code := call(t, srv, token, method, path, body, &out)
+	return code, out
+}
+
+// call sends an API call with token and decodes the response into out.
+func call(t *testing.T, srv *servertest.Server, token, method, path string, body, out interface{}) int {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, srv.URL+"/v1"+path, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	json.NewDecoder(resp.Body).Decode(out)
+	return resp.StatusCode
+}
+
+func TestStatefulServices(t *testing.T) {
+	tests := []struct {
+		kind   string // singular, as in audit actions and response fields
+		plural string // as in paths and list responses
+		body   map[string]interface{}
+	}{
+		{kind: "cache", plural: "caches", body: map[string]interface{}{"username": "alice", "name": "sessions"}},
+		{kind: "queue", plural: "queues", body: map[string]interface{}{"username": "alice", "name": "sessions", "engine": "nats"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			srv := servertest.New(t)
+			token := srv.Token(t, auth.Grant{Role: "owner", Tenant: "alice"})
+			instance := "/" + tt.plural + "/alice/sessions"
+
+			code, created := request(t, srv, token, http.MethodPost, "/"+tt.plural, tt.body)
+			if code != http.StatusOK || created["namespace"] != "tenant-alice" || created[tt.kind] == nil {
+				t.Fatalf("create = %d %v", code, created)
+			}
+			if code, _ := request(t, srv, token, http.MethodPost, "/"+tt.plural, tt.body); code != http.StatusConflict {
+				t.Errorf("second create = %d, want 409", code)
+			}
+
+			if code, got := request(t, srv, token, http.MethodGet, instance, nil); code != http.StatusOK || got[tt.kind] == nil {
+				t.Errorf("get = %d %v", code, got)
+			}
+			if code, got := request(t, srv, token, http.MethodGet, instance+"/status", nil); code != http.StatusOK || got["name"] != "sessions" {
+				t.Errorf("status = %d %v", code, got)
+			}
+			if code, got := request(t, srv, token, http.MethodGet, "/"+tt.plural+"/alice", nil); code != http.StatusOK || got["total"] != 1.0 {
+				t.Errorf("list = %d %v", code, got)
+			}
+			if code, got := request(t, srv, token, http.MethodGet, instance+"/credentials", nil); code != http.StatusOK || got["credentials"] == nil {
+				t.Errorf("credentials = %d %v", code, got)
+			}
+			events, _ := audit.Query(audit.Filter{Action: tt.kind + ".credentials.read", Namespace: "tenant-alice", Database: "sessions", Limit: 1})
+			if len(events) != 1 || events[0].Result != audit.ResultSuccess {
+				t.Errorf("credential read audit events = %+v", events)
+			}
+
+			if code, got := request(t, srv, token, http.MethodDelete, instance, nil); code != http.StatusOK || got["name"] != "sessions" {
+				t.Errorf("delete = %d %v", code, got)
+			}
+			if code, _ := request(t, srv, token, http.MethodGet, instance, nil); code != http.StatusNotFound {
+				t.Errorf("get after delete = %d, want 404", code)
+			}
+			if code, _ := request(t, srv, token, http.MethodDelete, instance, nil); code != http.StatusNotFound {
+				t.Errorf("second delete = %d, want 404", code)
+			}
+		})
+	}
+}
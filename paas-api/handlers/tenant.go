@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/auth"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser returns the subject of the caller, set by auth.RequirePermission
+// and auth.RequireAuthenticated.
+func currentUser(c *gin.Context) string {
+	if sub, ok := c.Get("user_id"); ok && sub != nil {
+		return fmt.Sprint(sub)
+	}
+	return ""
+}
+
+// RequireActiveTenant aborts requests to a tenant an admin has suspended with
+// 403. It runs after auth.RequirePermission, which finds the tenant; platform
+// admins pass so they can still inspect and repair suspended tenants.
+func RequireActiveTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := auth.Tenant(c)
+		if tenant == "" || auth.HasPermission(c, auth.PermAdminTenantsManage) {
+			c.Next()
+			return
+		}
+
+		suspended, err := k8s.IsTenantSuspended(k8s.TenantNamespacePrefix + tenant)
+		if err != nil {
+			api.Abort(c, http.StatusInternalServerError, api.CodeInternal, err.Error())
+			return
+		}
+		if suspended {
+			api.Abort(c, http.StatusForbidden, api.CodeTenantSuspended, "tenant is suspended, contact a platform administrator")
+			return
+		}
+		c.Next()
+	}
+}
+
+func ListTenantsHandler(c *gin.Context) {
+	tenants, err := k8s.ListTenants()
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TenantListResponse{
+		Tenants:      tenants,
+		TotalTenants: len(tenants),
+	})
+}
+
+func GetTenantHandler(c *gin.Context) {
+	namespace := k8s.TenantNamespace(c.Param("tenant"))
+
+	tenant, err := k8s.GetTenant(namespace)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+func SuspendTenantHandler(c *gin.Context) {
+	namespace := k8s.TenantNamespace(c.Param("tenant"))
+	actor := currentUser(c)
+
+	err := k8s.SuspendTenant(namespace, actor)
+	audit.Record(c, "admin.tenant.suspend", namespace, "", err)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TenantActionResponse{
+		Message:   "Tenant suspended",
+		Namespace: namespace,
+	})
+}
+
+func UnsuspendTenantHandler(c *gin.Context) {
+	namespace := k8s.TenantNamespace(c.Param("tenant"))
+
+	err := k8s.UnsuspendTenant(namespace)
+	audit.Record(c, "admin.tenant.unsuspend", namespace, "", err)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TenantActionResponse{
+		Message:   "Tenant unsuspended",
+		Namespace: namespace,
+	})
+}
+
+func DeprovisionTenantHandler(c *gin.Context) {
+	namespace := k8s.TenantNamespace(c.Param("tenant"))
+
+	err := k8s.DeprovisionTenant(namespace)
+	audit.Record(c, "admin.tenant.deprovision", namespace, "", err)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TenantActionResponse{
+		Message:   "Tenant deprovisioned",
+		Namespace: namespace,
+	})
+}
+
+// respondTenantError writes the response for an error of the k8s tenant
+// functions.
+func respondTenantError(c *gin.Context, err error) {
+	if errors.Is(err, k8s.ErrTenantNotFound) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, err.Error())
+		return
+	}
+	api.InternalError(c, err)
+}
@@ -0,0 +1,106 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"paas-api/api"
+	"paas-api/auth"
+	"paas-api/k8s"
+	"paas-api/server/servertest"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// createTenant adds a tenant namespace to the fake cluster.
+func createTenant(t *testing.T, srv *servertest.Server, name string, suspended bool) {
+	t.Helper()
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: k8s.TenantNamespace(name)}}
+	if suspended {
+		ns.Annotations = map[string]string{k8s.TenantSuspendedAnnotation: "true"}
+	}
+	if _, err := srv.Kube.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuspendedTenant(t *testing.T) {
+	srv := servertest.New(t)
+	createTenant(t, srv, "alice", true)
+	owner := srv.Token(t, auth.Grant{Role: "owner", Tenant: "alice"})
+	admin := srv.Login(t, "1", "root", "platform-admin")
+	user := srv.Login(t, "2", "alice", "tenant")
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{name: "owner reads", token: owner, method: http.MethodGet, path: "/caches/alice", want: http.StatusForbidden},
+		{name: "owner creates", token: owner, method: http.MethodPost, path: "/caches", body: map[string]string{"username": "alice"}, want: http.StatusForbidden},
+		{name: "owner lists pods", token: owner, method: http.MethodGet, path: "/pods/tenant-alice", want: http.StatusForbidden},
+		{name: "user manages tokens", token: user, method: http.MethodGet, path: "/tokens", want: http.StatusOK},
+		{name: "admin reads", token: admin, method: http.MethodGet, path: "/caches/alice", want: http.StatusOK},
+		{name: "admin lists pods", token: admin, method: http.MethodGet, path: "/pods/tenant-alice", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := request(t, srv, tt.token, tt.method, tt.path, tt.body)
+			if code != tt.want {
+				t.Fatalf("%s %s = %d %v, want %d", tt.method, tt.path, code, body, tt.want)
+			}
+			if code == http.StatusForbidden {
+				if e, _ := body["error"].(map[string]interface{}); e["code"] != api.CodeTenantSuspended {
+					t.Errorf("error = %v, want %s", body["error"], api.CodeTenantSuspended)
+				}
+			}
+		})
+	}
+
+	// Unsuspending reopens the tenant
+	if code, body := request(t, srv, admin, http.MethodPost, "/admin/tenants/alice/unsuspend", nil); code != http.StatusOK {
+		t.Fatalf("unsuspend = %d %v", code, body)
+	}
+	if code, _ := request(t, srv, owner, http.MethodGet, "/caches/alice", nil); code != http.StatusOK {
+		t.Errorf("owner reads after unsuspend = %d, want 200", code)
+	}
+}
+
+func TestAdminTenantErrors(t *testing.T) {
+	srv := servertest.New(t)
+	createTenant(t, srv, "alice", false)
+	admin := srv.Login(t, "1", "root", "platform-admin")
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/admin/tenants/alice", http.StatusOK},
+		{http.MethodGet, "/admin/tenants/nobody", http.StatusNotFound},
+		{http.MethodPost, "/admin/tenants/nobody/suspend", http.StatusNotFound},
+		{http.MethodPost, "/admin/tenants/nobody/unsuspend", http.StatusNotFound},
+		{http.MethodDelete, "/admin/tenants/nobody", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code, body := request(t, srv, admin, tt.method, tt.path, nil); code != tt.want {
+			t.Errorf("%s %s = %d %v, want %d", tt.method, tt.path, code, body, tt.want)
+		}
+	}
+
+	// Failing to reach the cluster is not a missing tenant
+	srv.Kube.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	if code, body := request(t, srv, admin, http.MethodGet, "/admin/tenants/alice", nil); code != http.StatusInternalServerError {
+		t.Errorf("GET with the cluster down = %d %v, want 500", code, body)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/audit"
+	"paas-api/auth"
+	"paas-api/k8s"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxTokenLifetimeDays = 365
+
+func CreateAPIToken(c *gin.Context) {
+	var req api.CreateTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		api.ErrorDetails(c, http.StatusBadRequest, api.CodeValidation, "at least one scope is required",
+			map[string]interface{}{"valid_scopes": auth.ValidScopes})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !auth.IsValidScope(scope) {
+			api.ErrorDetails(c, http.StatusBadRequest, api.CodeValidation, fmt.Sprintf("unknown scope %q", scope),
+				map[string]interface{}{"valid_scopes": auth.ValidScopes})
+			return
+		}
+	}
+
+	grants := auth.Grants(c)
+	if missing := auth.UngrantedScopes(grants, req.Scopes); len(missing) > 0 {
+		api.ErrorDetails(c, http.StatusForbidden, api.CodeForbidden, "your roles do not allow every requested scope",
+			map[string]interface{}{"missing_scopes": missing})
+		return
+	}
+
+	if req.ExpiresInDays <= 0 {
+		req.ExpiresInDays = 90
+	}
+	if req.ExpiresInDays > maxTokenLifetimeDays {
+		api.Error(c, http.StatusBadRequest, api.CodeBadRequest, fmt.Sprintf("expires_in_days cannot exceed %d", maxTokenLifetimeDays))
+		return
+	}
+
+	owner := currentUser(c)
+	plaintext, token, err := auth.GenerateAPIToken(req.Name, owner, req.ServiceAccount, grants, req.Scopes,
+		time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err == nil {
+		err = k8s.SaveAPIToken(token)
+	}
+	audit.Record(c, "token.create", "", "", err)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, api.CreateTokenResponse{
+		Message: "Token created. Store it now, it will not be shown again.",
+		Token:   plaintext,
+		Details: token,
+	})
+}
+
+func ListAPITokens(c *gin.Context) {
+	tokens, err := k8s.ListAPITokens(currentUser(c))
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.TokenListResponse{
+		Tokens: tokens,
+		Total:  len(tokens),
+	})
+}
+
+func RevokeAPIToken(c *gin.Context) {
+	id := c.Param("id")
+
+	token, err := k8s.GetAPIToken(id)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+	if token == nil || token.Owner != currentUser(c) {
+		api.Error(c, http.StatusNotFound, api.CodeNotFound, "token not found")
+		return
+	}
+
+	err = k8s.DeleteAPIToken(id)
+	audit.Record(c, "token.revoke", "", "", err)
+	if err != nil {
+		api.InternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.RevokeTokenResponse{
+		Message: "Token revoked",
+		ID:      id,
+	})
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"paas-api/api"
+	"paas-api/k8s"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const maxUsernameLength = 128
+
+// fieldErrors collects validation problems keyed by request field.
+type fieldErrors map[string][]string
+
+func (f fieldErrors) add(field string, problems ...string) {
+	if len(problems) > 0 {
+		f[field] = append(f[field], problems...)
+	}
+}
+
+// respond writes a 400 with field-level messages and returns true if there
+// were any problems.
+func (f fieldErrors) respond(c *gin.Context) bool {
+	if len(f) == 0 {
+		return false
+	}
+	api.ErrorDetails(c, http.StatusBadRequest, api.CodeValidation, "validation failed", map[string]interface{}{
+		"fields": f,
+	})
+	return true
+}
+
+// validateUsername accepts any printable identity; it is mapped to a
+// DNS-safe tenant name by k8s.TenantSlug.
+func validateUsername(username string) []string {
+	if username == "" {
+		return []string{"must not be empty"}
+	}
+	if len(username) > maxUsernameLength {
+		return []string{fmt.Sprintf("must be no more than %d characters", maxUsernameLength)}
+	}
+	for _, r := range username {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return []string{"must not contain whitespace or control characters"}
+		}
+	}
+	return nil
+}
+
+// defaultDBName derives a valid database name from the tenant when the
+// request does not name one.
+func defaultDBName(username string) string {
+	base := k8s.TenantSlug(username)
+	if len(base) > k8s.MaxDBNameLength-3 {
+		base = strings.TrimRight(base[:k8s.MaxDBNameLength-3], "-")
+	}
+	return base + "-db"
+}
+
+// defaultCacheName is defaultDBName for caches.
+func defaultCacheName(username string) string {
+	base := k8s.TenantSlug(username)
+	if len(base) > k8s.MaxCacheNameLength-6 {
+		base = strings.TrimRight(base[:k8s.MaxCacheNameLength-6], "-")
+	}
+	return base + "-cache"
+}
+
+// defaultQueueName is defaultDBName for queues.
+func defaultQueueName(username string) string {
+	base := k8s.TenantSlug(username)
+	if len(base) > k8s.MaxQueueNameLength-3 {
+		base = strings.TrimRight(base[:k8s.MaxQueueNameLength-3], "-")
+	}
+	return base + "-mq"
+}
+
+// validateTenantParams checks the :username, :db_name, :cache_name,
+// :queue_name, :bucket_name, :app_name, :binding_name, :route_name and
+// :job_name route parameters.
+func validateTenantParams(c *gin.Context) bool {
+	errs := fieldErrors{}
+	errs.add("username", validateUsername(c.Param("username"))...)
+	if dbName := c.Param("db_name"); dbName != "" {
+		errs.add("db_name", k8s.ValidateDBName(dbName)...)
+	}
+	if cacheName := c.Param("cache_name"); cacheName != "" {
+		errs.add("cache_name", k8s.ValidateCacheName(cacheName)...)
+	}
+	if queueName := c.Param("queue_name"); queueName != "" {
+		errs.add("queue_name", k8s.ValidateQueueName(queueName)...)
+	}
+	if bucketName := c.Param("bucket_name"); bucketName != "" {
+		errs.add("bucket_name", k8s.ValidateBucketName(k8s.TenantNamespace(c.Param("username")), bucketName)...)
+	}
+	if appName := c.Param("app_name"); appName != "" {
+		errs.add("app_name", k8s.ValidateAppName(appName)...)
+	}
+	if bindingName := c.Param("binding_name"); bindingName != "" {
+		errs.add("binding_name", k8s.ValidateBindingName(bindingName)...)
+	}
+	if routeName := c.Param("route_name"); routeName != "" {
+		errs.add("route_name", k8s.ValidateRouteName(k8s.TenantNamespace(c.Param("username")), routeName)...)
+	}
+	if jobName := c.Param("job_name"); jobName != "" {
+		errs.add("job_name", k8s.ValidateJobName(jobName)...)
+	}
+	return !errs.respond(c)
+}
+
+// bindJSON binds the request body, turning binding tag failures into
+// field-level 400 responses. It returns false if the request was rejected.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		api.Error(c, http.StatusBadRequest, api.CodeBadRequest, err.Error())
+		return false
+	}
+
+	errs := fieldErrors{}
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, fe := range verrs {
+		name := fe.Field()
+		if f, ok := t.FieldByName(fe.StructField()); ok {
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" {
+				name = tag
+			}
+		}
+		if fe.Tag() == "required" {
+			errs.add(name, "is required")
+		} else {
+			errs.add(name, fmt.Sprintf("failed %q validation", fe.Tag()))
+		}
+	}
+	errs.respond(c)
+	return false
+}
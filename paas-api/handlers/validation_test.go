@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"paas-api/k8s"
+)
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     []string
+	}{
+		{"alice", nil},
+		{"Alice@Example.com", nil},
+		{"Ünïcode_user", nil},
+		{strings.Repeat("a", maxUsernameLength), nil},
+		{"", []string{"must not be empty"}},
+		{strings.Repeat("a", maxUsernameLength+1), []string{"must be no more than 128 characters"}},
+		{"alice smith", []string{"must not contain whitespace or control characters"}},
+		{"alice\tsmith", []string{"must not contain whitespace or control characters"}},
+		{"alice\x00", []string{"must not contain whitespace or control characters"}},
+	}
+	for _, tt := range tests {
+		if got := validateUsername(tt.username); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("validateUsername(%q) = %q, want %q", tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultNames(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+
+	tests := []struct {
+		name      string
+		username  string
+		wantDB    string
+		wantCache string
+		wantQueue string
+	}{
+		{"canonical", "alice", "alice-db", "alice-cache", "alice-mq"},
+		{"hashed", "Alice", "alice-3bc51062-db", "alice-3bc51062-cache", "alice-3bc51062-mq"},
+		{"longest tenant", a(k8s.MaxTenantNameLength), a(49) + "-db", a(40) + "-cache", a(43) + "-mq"},
+		// A cut that ends in a dash loses it
+		{"cache name cut at a dash", a(39) + "-bb", a(39) + "-bb-db", a(39) + "-cache", a(39) + "-bb-mq"},
+		{"truncated into the hash", a(k8s.MaxTenantNameLength + 1), a(47) + "-f-db", a(40) + "-cache", a(43) + "-mq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, cache, queue := defaultDBName(tt.username), defaultCacheName(tt.username), defaultQueueName(tt.username)
+			if db != tt.wantDB || cache != tt.wantCache || queue != tt.wantQueue {
+				t.Errorf("default names = %q, %q, %q; want %q, %q, %q", db, cache, queue, tt.wantDB, tt.wantCache, tt.wantQueue)
+			}
+			if problems := k8s.ValidateDBName(db); problems != nil {
+				t.Errorf("database name %q is invalid: %v", db, problems)
+			}
+			if problems := k8s.ValidateCacheName(cache); problems != nil {
+				t.Errorf("cache name %q is invalid: %v", cache, problems)
+			}
+			if problems := k8s.ValidateQueueName(queue); problems != nil {
+				t.Errorf("queue name %q is invalid: %v", queue, problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,685 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	DefaultAppPort     = 8080
+	DefaultAppReplicas = 1
+	MaxAppReplicas     = 10
+
+	// MaxAppNameLength matches databases, whose services share the namespace.
+	MaxAppNameLength = MaxDBNameLength
+
+	// appSpecAnnotation holds the AppSpec on the pod template, so a rollback
+	// restores the spec along with the template it produced.
+	appSpecAnnotation  = "paas.cloudtrack.io/app-spec"
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+
+	// databaseEnvPrefix is reserved for the variables of a bound database.
+	databaseEnvPrefix = "DATABASE_"
+)
+
+var (
+	ErrAppNotFound         = errors.New("app not found")
+	ErrAppExists           = errors.New("app already exists")
+	ErrAppRevisionNotFound = errors.New("app revision not found")
+)
+
+// DefaultAppSpec fills unset fields with the platform defaults.
+func DefaultAppSpec(s *AppSpec) {
+	if s.Port == 0 {
+		s.Port = DefaultAppPort
+	}
+	if s.Replicas == 0 {
+		s.Replicas = DefaultAppReplicas
+	}
+	if s.Plan == "" {
+		s.Plan = DefaultPlan
+	}
+}
+
+// ValidateAppSpec returns problems keyed by field. It expects a defaulted spec.
+func ValidateAppSpec(s AppSpec) map[string][]string {
+	problems := map[string][]string{}
+	if p := validateImage(s.Image); len(p) > 0 {
+		problems["image"] = p
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		problems["port"] = []string{"must be between 1 and 65535"}
+	}
+	if s.Replicas < 1 || s.Replicas > MaxAppReplicas {
+		problems["replicas"] = []string{fmt.Sprintf("must be between 1 and %d", MaxAppReplicas)}
+	}
+	if p := ValidatePlan(s.Plan); len(p) > 0 {
+		problems["plan"] = p
+	}
+	if s.HealthCheckPath != "" && !strings.HasPrefix(s.HealthCheckPath, "/") {
+		problems["health_check_path"] = []string{"must start with /"}
+	}
+	validateWorkloadEnv(problems, s.Env, s.Database)
+	return problems
+}
+
+// ValidateAppName checks an app name against DNS-1123 label rules, as it
+// names both the Deployment and its Service.
+func ValidateAppName(name string) []string {
+	if name == "" {
+		return []string{"must not be empty"}
+	}
+	problems := validation.IsDNS1123Label(name)
+	if len(name) > MaxAppNameLength {
+		problems = append(problems, fmt.Sprintf("must be no more than %d characters", MaxAppNameLength))
+	}
+	return problems
+}
+
+func appHost(namespace, name string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", name, namespace)
+}
+
+func appPodLabels(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/component": "app",
+		"app.kubernetes.io/instance":  name,
+	}
+}
+
+func appLabels(name string) map[string]string {
+	l := appPodLabels(name)
+	l["app.kubernetes.io/managed-by"] = "paas-api"
+	return l
+}
+
+func isAppObject(meta metav1.ObjectMeta) bool {
+	return meta.Labels["app.kubernetes.io/component"] == "app" && meta.Labels["app.kubernetes.io/managed-by"] == "paas-api"
+}
+
+func getAppDeployment(namespace, name string) (*appsv1.Deployment, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && !isAppObject(deployment.ObjectMeta)) {
+		return nil, ErrAppNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app %s: %w", name, err)
+	}
+	return deployment, nil
+}
+
+// DeployApp creates the Deployment and Service of an app and returns without
+// waiting for the rollout. A bound database must exist in the namespace.
+func DeployApp(namespace, name, owner string, spec AppSpec) (*AppInfo, error) {
+	DefaultAppSpec(&spec)
+
+	_, err := getAppDeployment(namespace, name)
+	if err == nil {
+		return nil, ErrAppExists
+	}
+	if !errors.Is(err, ErrAppNotFound) {
+		return nil, err
+	}
+
+	if err := EnsureTenantNamespace(namespace, owner); err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	// The service name may already be taken by a database
+	service, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil && !isAppObject(service.ObjectMeta) {
+		return nil, ErrAppExists
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get service %s: %w", name, err)
+	}
+	serviceExists := err == nil
+
+	template, err := appPodTemplate(namespace, name, spec)
+	if err != nil {
+		return nil, err
+	}
+	replicas := int32(spec.Replicas)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: appLabels(name)},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:                &replicas,
+			Selector:                &metav1.LabelSelector{MatchLabels: appPodLabels(name)},
+			Template:                *template,
+			RevisionHistoryLimit:    int32Ptr(10),
+			ProgressDeadlineSeconds: int32Ptr(300),
+		},
+	}
+	deployment, err = clientset.AppsV1().Deployments(namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil, ErrAppExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create app %s: %w", name, err)
+	}
+
+	if !serviceExists {
+		service = &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: appLabels(name)},
+			Spec: corev1.ServiceSpec{
+				Selector: appPodLabels(name),
+				Ports: []corev1.ServicePort{{
+					Name:       "http",
+					Port:       80,
+					TargetPort: intstr.FromString("http"),
+				}},
+			},
+		}
+		_, err = clientset.CoreV1().Services(namespace).Create(context.TODO(), service, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create service %s: %w", name, err)
+		}
+	}
+	fmt.Printf("App deployment initiated for %s in namespace %s\n", name, namespace)
+
+	return &AppInfo{
+		Name:           name,
+		Namespace:      namespace,
+		Status:         StatusProvisioning,
+		DetailedStatus: "App is being deployed",
+		Spec:           spec,
+		Host:           appHost(namespace, name),
+		CreatedAt:      deployment.CreationTimestamp.Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+
+// appPodTemplate renders the pod template of spec, with PORT set to the
+// container port unless the spec's env overrides it.
+func appPodTemplate(namespace, name string, spec AppSpec) (*corev1.PodTemplateSpec, error) {
+	resources, err := planResources(spec.Plan)
+	if err != nil {
+		return nil, err
+	}
+	env, err := workloadEnv(namespace, spec.Env, spec.Database)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := spec.Env["PORT"]; !ok {
+		env = append([]corev1.EnvVar{{Name: "PORT", Value: strconv.Itoa(spec.Port)}}, env...)
+	}
+
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spec of app %s: %w", name, err)
+	}
+
+	container := corev1.Container{
+		Name:      "app",
+		Image:     spec.Image,
+		Ports:     []corev1.ContainerPort{{Name: "http", ContainerPort: int32(spec.Port)}},
+		Env:       env,
+		Resources: resources,
+	}
+	if spec.HealthCheckPath != "" {
+		handler := corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: spec.HealthCheckPath, Port: intstr.FromString("http")},
+		}
+		container.ReadinessProbe = &corev1.Probe{ProbeHandler: handler, PeriodSeconds: 10}
+		// Liveness waits longer so a slow start is not mistaken for a hang
+		container.LivenessProbe = &corev1.Probe{ProbeHandler: handler, InitialDelaySeconds: 30, PeriodSeconds: 20, FailureThreshold: 3}
+	}
+
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      appPodLabels(name),
+			Annotations: map[string]string{appSpecAnnotation: string(encoded)},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{container}},
+	}, nil
+}
+
+// planResources sizes a workload container like a database instance of plan.
+func planResources(planName string) (corev1.ResourceRequirements, error) {
+	plan, ok := Plans[planName]
+	if !ok {
+		return corev1.ResourceRequirements{}, fmt.Errorf("unknown plan %q", planName)
+	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(plan.CPURequest),
+			corev1.ResourceMemory: resource.MustParse(plan.MemoryRequest),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(plan.CPULimit),
+			corev1.ResourceMemory: resource.MustParse(plan.MemoryLimit),
+		},
+	}, nil
+}
+
+// workloadEnv returns env sorted by name, followed by the connection
+// settings of database as DATABASE_* variables if one is bound.
+func workloadEnv(namespace string, env map[string]string, database string) ([]corev1.EnvVar, error) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vars := []corev1.EnvVar{}
+	for _, name := range names {
+		vars = append(vars, corev1.EnvVar{Name: name, Value: env[name]})
+	}
+	if database == "" {
+		return vars, nil
+	}
+	dbEnv, err := databaseEnv(namespace, database)
+	if err != nil {
+		return nil, err
+	}
+	return append(vars, dbEnv...), nil
+}
+
+func validateImage(image string) []string {
+	if strings.TrimSpace(image) == "" {
+		return []string{"must not be empty"}
+	}
+	if strings.ContainsAny(image, " \t\n") {
+		return []string{"must not contain whitespace"}
+	}
+	return nil
+}
+
+// validateWorkloadEnv adds the problems of env and database to problems.
+// DATABASE_* names are reserved while a database is bound.
+func validateWorkloadEnv(problems map[string][]string, env map[string]string, database string) {
+	if database != "" {
+		if p := ValidateDBName(database); len(p) > 0 {
+			problems["database"] = p
+		}
+	}
+	for name := range env {
+		field := "env." + name
+		if p := validation.IsEnvVarName(name); len(p) > 0 {
+			problems[field] = p
+		} else if database != "" && strings.HasPrefix(name, databaseEnvPrefix) {
+			problems[field] = []string{fmt.Sprintf("%s variables are set from the bound database", databaseEnvPrefix)}
+		}
+	}
+}
+
+// databaseEnv returns the connection settings of a tenant database. The
+// credentials stay in the provider's secret and are referenced, not copied,
+// so a password rotation only needs a restart.
+func databaseEnv(namespace, dbName string) ([]corev1.EnvVar, error) {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+	fromSecret := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: provider.SecretName(dbName)},
+			Key:                  key,
+		}}
+	}
+
+	host, port := provider.Host(namespace, dbName), strconv.Itoa(provider.Port())
+	uri := connectionURI(provider.Engine(), "$(DATABASE_USER)", "$(DATABASE_PASSWORD)", host, port, dbName)
+	uri.RawQuery = sslQuery(provider.Engine())
+
+	return []corev1.EnvVar{
+		{Name: "DATABASE_ENGINE", Value: provider.Engine()},
+		{Name: "DATABASE_HOST", Value: host},
+		{Name: "DATABASE_PORT", Value: port},
+		{Name: "DATABASE_NAME", Value: dbName},
+		{Name: "DATABASE_USER", ValueFrom: fromSecret("username")},
+		{Name: "DATABASE_PASSWORD", ValueFrom: fromSecret("password")},
+		// Kubernetes expands $(VAR) references to variables defined earlier in the list
+		{Name: "DATABASE_URL", Value: unescapeVarRefs(uri.String())},
+	}, nil
+}
+
+// unescapeVarRefs undoes the percent-encoding url.URL applies to the $(VAR)
+// placeholders in the user info.
+func unescapeVarRefs(uri string) string {
+	return strings.NewReplacer("%24", "$", "%28", "(", "%29", ")").Replace(uri)
+}
+
+// specFromDeployment reads the spec recorded on the pod template. Replicas
+// come from the Deployment, or from before a pause.
+func specFromDeployment(deployment *appsv1.Deployment) AppSpec {
+	var spec AppSpec
+	_ = json.Unmarshal([]byte(deployment.Spec.Template.Annotations[appSpecAnnotation]), &spec)
+	if deployment.Spec.Replicas != nil {
+		spec.Replicas = int(*deployment.Spec.Replicas)
+	}
+	if n, err := strconv.Atoi(deployment.Annotations[pausedInstancesAnnotation]); err == nil {
+		spec.Replicas = n
+	}
+	return spec
+}
+
+// observeDeployment derives the app state from the Deployment status. It
+// returns whether the latest rollout is complete.
+func observeDeployment(deployment *appsv1.Deployment) (string, string, bool) {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	complete := status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == desired && status.Replicas == desired && status.AvailableReplicas == desired
+
+	var progressing *appsv1.DeploymentCondition
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == appsv1.DeploymentProgressing {
+			progressing = &status.Conditions[i]
+		}
+	}
+
+	switch {
+	case deployment.DeletionTimestamp != nil:
+		return StatusDeleting, "App is being deleted", false
+	case deployment.Annotations[PausedAnnotation] == "true" || desired == 0:
+		return StatusPaused, "App is paused", complete
+	case progressing != nil && progressing.Reason == "ProgressDeadlineExceeded":
+		return StatusFailed, fmt.Sprintf("Rollout failed: %s", progressing.Message), false
+	case status.ObservedGeneration < deployment.Generation || status.UpdatedReplicas < desired || status.Replicas > status.UpdatedReplicas:
+		if status.AvailableReplicas == 0 && revisionOf(deployment.ObjectMeta) <= 1 {
+			return StatusInitializing, "App pods are starting", false
+		}
+		return StatusUpdating, fmt.Sprintf("Rollout in progress: %d of %d replicas updated", status.UpdatedReplicas, desired), false
+	case status.AvailableReplicas == 0:
+		return StatusInitializing, "App pods are starting", false
+	case status.AvailableReplicas < desired:
+		return StatusDegraded, fmt.Sprintf("%d of %d replicas available", status.AvailableReplicas, desired), false
+	}
+	return StatusReady, "App is ready", complete
+}
+
+func revisionOf(meta metav1.ObjectMeta) int64 {
+	n, _ := strconv.ParseInt(meta.Annotations[revisionAnnotation], 10, 64)
+	return n
+}
+
+func describeApp(deployment *appsv1.Deployment) *AppInfo {
+	app := &AppInfo{
+		Name:          deployment.Name,
+		Namespace:     deployment.Namespace,
+		Spec:          specFromDeployment(deployment),
+		ReadyReplicas: int(deployment.Status.ReadyReplicas),
+		Revision:      revisionOf(deployment.ObjectMeta),
+		Host:          appHost(deployment.Namespace, deployment.Name),
+		CreatedAt:     deployment.CreationTimestamp.Format("2006-01-02 15:04:05"),
+	}
+	app.Status, app.DetailedStatus, _ = observeDeployment(deployment)
+	return app
+}
+
+// GetApp returns ErrAppNotFound if there is no such app.
+func GetApp(namespace, name string) (*AppInfo, error) {
+	deployment, err := getAppDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return describeApp(deployment), nil
+}
+
+func listAppDeployments(namespace string) ([]appsv1.Deployment, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/component=app,app.kubernetes.io/managed-by=paas-api",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+func ListApps(namespace string) ([]AppInfo, error) {
+	deployments, err := listAppDeployments(namespace)
+	if err != nil {
+		return nil, err
+	}
+	apps := []AppInfo{}
+	for i := range deployments {
+		apps = append(apps, *describeApp(&deployments[i]))
+	}
+	return apps, nil
+}
+
+// UpdateApp replaces the spec of an app, which rolls out a new revision when
+// anything but the replica count changes. A paused app stays paused with the
+// new replica count remembered.
+func UpdateApp(namespace, name string, spec AppSpec) (*AppInfo, error) {
+	DefaultAppSpec(&spec)
+
+	deployment, err := getAppDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	template, err := appPodTemplate(namespace, name, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	replicas := int32(spec.Replicas)
+	if deployment.Annotations[PausedAnnotation] == "true" {
+		deployment.Annotations[pausedInstancesAnnotation] = strconv.Itoa(spec.Replicas)
+	} else {
+		deployment.Spec.Replicas = &replicas
+	}
+	deployment.Spec.Template = *template
+	deployment, err = clientset.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update app %s: %w", name, err)
+	}
+	fmt.Printf("Updated app %s/%s\n", namespace, name)
+	return describeApp(deployment), nil
+}
+
+// appRevisions returns the ReplicaSets of a Deployment, newest first.
+func appRevisions(deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.AppsV1().ReplicaSets(deployment.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions of app %s: %w", deployment.Name, err)
+	}
+
+	var owned []appsv1.ReplicaSet
+	for _, rs := range list.Items {
+		if ref := metav1.GetControllerOf(&rs); ref != nil && ref.UID == deployment.UID {
+			owned = append(owned, rs)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return revisionOf(owned[i].ObjectMeta) > revisionOf(owned[j].ObjectMeta) })
+	return owned, nil
+}
+
+// GetAppRollout reports the state of the latest rollout and the revisions
+// available to roll back to.
+func GetAppRollout(namespace, name string) (*AppRollout, error) {
+	deployment, err := getAppDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := appRevisions(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	current := revisionOf(deployment.ObjectMeta)
+	rollout := &AppRollout{
+		Name:              name,
+		Revision:          current,
+		UpdatedReplicas:   int(deployment.Status.UpdatedReplicas),
+		ReadyReplicas:     int(deployment.Status.ReadyReplicas),
+		AvailableReplicas: int(deployment.Status.AvailableReplicas),
+		Revisions:         []AppRevision{},
+	}
+	if deployment.Spec.Replicas != nil {
+		rollout.DesiredReplicas = int(*deployment.Spec.Replicas)
+	}
+	rollout.Status, rollout.DetailedStatus, rollout.Complete = observeDeployment(deployment)
+
+	for _, rs := range replicaSets {
+		revision := AppRevision{
+			Revision:  revisionOf(rs.ObjectMeta),
+			Replicas:  int(rs.Status.Replicas),
+			Current:   revisionOf(rs.ObjectMeta) == current,
+			CreatedAt: rs.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		}
+		if containers := rs.Spec.Template.Spec.Containers; len(containers) > 0 {
+			revision.Image = containers[0].Image
+		}
+		rollout.Revisions = append(rollout.Revisions, revision)
+	}
+	return rollout, nil
+}
+
+// RollbackApp restores the pod template of an earlier revision, which the
+// Deployment rolls out as a new revision. Revision 0 means the one before
+// the current revision.
+func RollbackApp(namespace, name string, revision int64) (*AppInfo, error) {
+	deployment, err := getAppDeployment(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := appRevisions(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	current := revisionOf(deployment.ObjectMeta)
+	var target *appsv1.ReplicaSet
+	for i := range replicaSets {
+		r := revisionOf(replicaSets[i].ObjectMeta)
+		if r == current {
+			continue
+		}
+		if revision == 0 || r == revision {
+			target = &replicaSets[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, ErrAppRevisionNotFound
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	deployment.Spec.Template = *template
+	deployment, err = clientset.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back app %s: %w", name, err)
+	}
+	fmt.Printf("Rolled back app %s/%s to revision %d\n", namespace, name, revisionOf(target.ObjectMeta))
+	return describeApp(deployment), nil
+}
+
+// DeleteApp removes the Deployment, whose ReplicaSets and pods are garbage
+// collected, and the Service.
+func DeleteApp(namespace, name string) error {
+	if _, err := getAppDeployment(namespace, name); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	if err := clientset.AppsV1().Deployments(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete app %s: %w", name, err)
+	}
+	service, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil && isAppObject(service.ObjectMeta) {
+		if err := clientset.CoreV1().Services(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete service of app %s: %w", name, err)
+		}
+	}
+	fmt.Printf("Deleted app %s/%s\n", namespace, name)
+	return nil
+}
+
+// setAppsPaused scales every app in the namespace to zero as part of a tenant
+// suspension, remembering the replica count to restore.
+func setAppsPaused(namespace string, paused bool) (int, error) {
+	deployments, err := listAppDeployments(namespace)
+	if err != nil {
+		return 0, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	for i := range deployments {
+		deployment := &deployments[i]
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		if paused {
+			if deployment.Annotations[PausedAnnotation] == "true" {
+				continue
+			}
+			replicas := int32(DefaultAppReplicas)
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
+			}
+			deployment.Annotations[PausedAnnotation] = "true"
+			deployment.Annotations[pausedInstancesAnnotation] = strconv.Itoa(int(replicas))
+			deployment.Spec.Replicas = int32Ptr(0)
+		} else {
+			if deployment.Annotations[PausedAnnotation] != "true" {
+				continue
+			}
+			replicas := DefaultAppReplicas
+			if n, err := strconv.Atoi(deployment.Annotations[pausedInstancesAnnotation]); err == nil && n > 0 {
+				replicas = n
+			}
+			delete(deployment.Annotations, PausedAnnotation)
+			delete(deployment.Annotations, pausedInstancesAnnotation)
+			deployment.Spec.Replicas = int32Ptr(int32(replicas))
+		}
+		if _, err := clientset.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{}); err != nil {
+			return 0, fmt.Errorf("failed to update app %s: %w", deployment.Name, err)
+		}
+	}
+	return len(deployments), nil
+}
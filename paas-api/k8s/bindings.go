@@ -0,0 +1,317 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Binding secret layouts.
+const (
+	BindingFormatURL            = "url"            // a single DATABASE_URL key
+	BindingFormatServiceBinding = "servicebinding" // the servicebinding.io projection keys
+	BindingFormatPG             = "pg"             // PGHOST, PGUSER and the other libpq variables
+)
+
+var BindingFormats = []string{BindingFormatURL, BindingFormatServiceBinding, BindingFormatPG}
+
+const (
+	bindingForLabel           = "paas.cloudtrack.io/binding-for"
+	bindingFormatAnnotation   = "paas.cloudtrack.io/binding-format"
+	bindingSyncedAtAnnotation = "paas.cloudtrack.io/binding-synced-at"
+)
+
+var (
+	ErrBindingNotFound = errors.New("binding not found")
+	ErrBindingExists   = errors.New("binding already exists")
+)
+
+// DefaultBindingName names the binding of dbName in format when the caller
+// does not choose a name.
+func DefaultBindingName(dbName, format string) string {
+	base := dbName
+	if limit := validation.DNS1123LabelMaxLength - len(format) - 1; len(base) > limit {
+		base = strings.TrimRight(base[:limit], "-")
+	}
+	return base + "-" + format
+}
+
+// ValidateBindingName checks a binding name, which is also the secret name.
+func ValidateBindingName(name string) []string {
+	if name == "" {
+		return []string{"must not be empty"}
+	}
+	return validation.IsDNS1123Label(name)
+}
+
+func ValidateBindingFormat(format string) []string {
+	for _, f := range BindingFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("must be one of %s", strings.Join(BindingFormats, ", "))}
+}
+
+// bindingData renders credentials in format. The pg layout only exists for
+// PostgreSQL.
+func bindingData(provider DatabaseProvider, credentials *DatabaseCredentials, dbName, format string) (map[string]string, corev1.SecretType, error) {
+	engine := provider.Engine()
+	host, port := credentials.Host, credentials.Port
+	username, password := credentials.PrimaryUser["username"], credentials.PrimaryUser["password"]
+	uri := connectionURI(engine, username, password, host, port, dbName)
+	uri.RawQuery = sslQuery(engine)
+
+	switch format {
+	case BindingFormatURL:
+		return map[string]string{"DATABASE_URL": uri.String()}, corev1.SecretTypeOpaque, nil
+	case BindingFormatServiceBinding:
+		// servicebinding.io knows MariaDB as mysql
+		bindingType := engine
+		if engine == EngineMariaDB {
+			bindingType = EngineMySQL
+		}
+		return map[string]string{
+			"type":     bindingType,
+			"provider": provider.Name(),
+			"host":     host,
+			"port":     port,
+			"database": dbName,
+			"username": username,
+			"password": password,
+			"uri":      uri.String(),
+		}, corev1.SecretType("servicebinding.io/" + bindingType), nil
+	case BindingFormatPG:
+		if engine != EnginePostgreSQL {
+			return nil, "", fmt.Errorf("%w: the %s binding format of a %s database", ErrNotSupported, format, engine)
+		}
+		return map[string]string{
+			"PGHOST":     host,
+			"PGPORT":     port,
+			"PGDATABASE": dbName,
+			"PGUSER":     username,
+			"PGPASSWORD": password,
+			"PGSSLMODE":  "prefer",
+		}, corev1.SecretTypeOpaque, nil
+	}
+	return nil, "", fmt.Errorf("unknown binding format %q", format)
+}
+
+func bindingFromSecret(secret *corev1.Secret) DatabaseBinding {
+	return DatabaseBinding{
+		Name:       secret.Name,
+		Database:   secret.Labels[bindingForLabel],
+		Format:     secret.Annotations[bindingFormatAnnotation],
+		SecretName: secret.Name,
+		CreatedAt:  secret.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		SyncedAt:   secret.Annotations[bindingSyncedAtAnnotation],
+	}
+}
+
+func getBindingSecret(namespace, dbName, name string) (*corev1.Secret, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && secret.Labels[bindingForLabel] != dbName) {
+		return nil, ErrBindingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get binding %s: %w", name, err)
+	}
+	return secret, nil
+}
+
+// CreateDatabaseBinding writes the credentials of dbName into a new secret
+// called name. It returns ErrCredentialsPending while the database has no
+// credentials yet.
+func CreateDatabaseBinding(namespace, dbName, name, format string) (*DatabaseBinding, error) {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := provider.Credentials(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+	data, secretType, err := bindingData(provider, credentials, dbName, format)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				bindingForLabel:                dbName,
+				"app.kubernetes.io/managed-by": "paas-api",
+			},
+			Annotations: map[string]string{
+				bindingFormatAnnotation:   format,
+				bindingSyncedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Type:       secretType,
+		StringData: data,
+	}
+	secret, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil, ErrBindingExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create binding %s: %w", name, err)
+	}
+	fmt.Printf("Created %s binding %s/%s for database %s\n", format, namespace, name, dbName)
+
+	binding := bindingFromSecret(secret)
+	return &binding, nil
+}
+
+func listBindingSecrets(namespace, selector string) ([]corev1.Secret, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bindings: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+// ListDatabaseBindings returns the bindings of one database.
+func ListDatabaseBindings(namespace, dbName string) ([]DatabaseBinding, error) {
+	if _, err := providerFor(namespace, dbName); err != nil {
+		return nil, err
+	}
+	secrets, err := listBindingSecrets(namespace, bindingForLabel+"="+dbName)
+	if err != nil {
+		return nil, err
+	}
+	bindings := []DatabaseBinding{}
+	for i := range secrets {
+		bindings = append(bindings, bindingFromSecret(&secrets[i]))
+	}
+	return bindings, nil
+}
+
+func DeleteDatabaseBinding(namespace, dbName, name string) error {
+	if _, err := getBindingSecret(namespace, dbName, name); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	if err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete binding %s: %w", name, err)
+	}
+	fmt.Printf("Deleted binding %s/%s of database %s\n", namespace, name, dbName)
+	return nil
+}
+
+// deleteDatabaseBindings removes the bindings left behind by a deleted database.
+func deleteDatabaseBindings(namespace, dbName string) error {
+	secrets, err := listBindingSecrets(namespace, bindingForLabel+"="+dbName)
+	if err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	for _, secret := range secrets {
+		if err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), secret.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete binding %s: %w", secret.Name, err)
+		}
+	}
+	return nil
+}
+
+// SyncDatabaseBindings rewrites every binding secret in the cluster whose
+// contents no longer match its database's credentials, e.g. after a password
+// rotation. Bindings of databases that are gone or still without credentials
+// are left alone, and a binding that cannot be synced is logged and skipped
+// so it does not hold up the others. It returns the number of secrets
+// updated.
+func SyncDatabaseBindings() (int, error) {
+	secrets, err := listBindingSecrets(metav1.NamespaceAll, bindingForLabel)
+	if err != nil {
+		return 0, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	updated := 0
+	for i := range secrets {
+		secret := &secrets[i]
+		namespace, dbName := secret.Namespace, secret.Labels[bindingForLabel]
+		provider, err := providerFor(namespace, dbName)
+		if errors.Is(err, ErrDatabaseNotFound) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Skipping binding %s/%s: %v\n", namespace, secret.Name, err)
+			continue
+		}
+		credentials, err := provider.Credentials(namespace, dbName)
+		if errors.Is(err, ErrCredentialsPending) || errors.Is(err, ErrDatabaseNotFound) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Skipping binding %s/%s: %v\n", namespace, secret.Name, err)
+			continue
+		}
+		data, _, err := bindingData(provider, credentials, dbName, secret.Annotations[bindingFormatAnnotation])
+		if err != nil {
+			fmt.Printf("Skipping binding %s/%s: %v\n", namespace, secret.Name, err)
+			continue
+		}
+		if secretDataEqual(secret.Data, data) {
+			continue
+		}
+
+		secret.Data = nil
+		secret.StringData = data
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[bindingSyncedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		if _, err := clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			fmt.Printf("Failed to update binding %s/%s: %v\n", namespace, secret.Name, err)
+			continue
+		}
+		fmt.Printf("Synced binding %s/%s with the credentials of %s\n", namespace, secret.Name, dbName)
+		updated++
+	}
+	return updated, nil
+}
+
+func secretDataEqual(current map[string][]byte, desired map[string]string) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+	for key, value := range desired {
+		if string(current[key]) != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,576 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Buckets live on an S3-compatible MinIO deployment administered with the
+// MinIO client (mc), the same way CRDs are handled through kubectl. A bucket
+// is named "<tenant slug>.<name>": the dot cannot occur in a slug, so the
+// per-tenant policy granting "<slug>.*" never matches another tenant's buckets.
+
+const (
+	// BucketCredentialsSecret holds the tenant's access key in its namespace,
+	// ready to be mounted by workloads.
+	BucketCredentialsSecret = "bucket-credentials"
+
+	// mcAlias is the name mc knows the backend by, configured through MC_HOST_<alias>.
+	mcAlias = "paas"
+
+	bucketAccessKeyLength = 20
+	bucketSecretKeyLength = 40
+)
+
+var (
+	minBucketQuota = resource.MustParse("1Mi")
+	maxBucketQuota = resource.MustParse("10Ti")
+
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrBucketExists   = errors.New("bucket already exists")
+
+	// ErrObjectStorageDisabled is returned by every bucket operation when no
+	// backend has been configured.
+	ErrObjectStorageDisabled = errors.New("object storage is not configured")
+)
+
+// objectStorage is the MinIO backend; nil until ConfigureObjectStorage is called.
+var objectStorage *objectStorageConfig
+
+type objectStorageConfig struct {
+	endpoint  *url.URL
+	mcHostEnv string // MC_HOST_<alias>=<endpoint with root credentials>
+}
+
+// ConfigureObjectStorage points bucket operations at a MinIO endpoint such as
+// http://minio.minio.svc.cluster.local:9000, administered with its root
+// credentials. An empty endpoint leaves object storage disabled.
+func ConfigureObjectStorage(endpoint, rootUser, rootPassword string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid object storage endpoint %q, expected http(s)://host:port", endpoint)
+	}
+	if rootUser == "" || rootPassword == "" {
+		return errors.New("object storage needs MINIO_ROOT_USER and MINIO_ROOT_PASSWORD")
+	}
+	withCredentials := *u
+	withCredentials.User = url.UserPassword(rootUser, rootPassword)
+	objectStorage = &objectStorageConfig{
+		endpoint:  u,
+		mcHostEnv: "MC_HOST_" + mcAlias + "=" + withCredentials.String(),
+	}
+	return nil
+}
+
+func ObjectStorageEnabled() bool {
+	return objectStorage != nil
+}
+
+// bucketPrefix is what every bucket of the tenant in namespace starts with.
+func bucketPrefix(namespace string) string {
+	return tenantName(namespace) + "."
+}
+
+func bucketFullName(namespace, name string) string {
+	return bucketPrefix(namespace) + name
+}
+
+// ValidateBucketName checks a bucket name against DNS-1123 label rules and
+// the 63 character S3 limit of the full "<slug>.<name>" bucket name.
+func ValidateBucketName(namespace, name string) []string {
+	if name == "" {
+		return []string{"must not be empty"}
+	}
+	problems := validation.IsDNS1123Label(name)
+	if max := 63 - len(bucketPrefix(namespace)); len(name) > max {
+		problems = append(problems, fmt.Sprintf("must be no more than %d characters for this tenant", max))
+	}
+	if len(name) < 3 {
+		problems = append(problems, "must be at least 3 characters")
+	}
+	return problems
+}
+
+// ParseBucketQuota parses a quota like 10Gi. Empty means unlimited.
+func ParseBucketQuota(quota string) (int64, []string) {
+	if quota == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(quota)
+	if err != nil {
+		return 0, []string{"must be a quantity such as 500Mi or 10Gi"}
+	}
+	if q.Cmp(minBucketQuota) < 0 || q.Cmp(maxBucketQuota) > 0 {
+		return 0, []string{fmt.Sprintf("must be between %s and %s", minBucketQuota.String(), maxBucketQuota.String())}
+	}
+	return q.Value(), nil
+}
+
+func formatBucketQuota(bytes int64) string {
+	if bytes <= 0 {
+		return ""
+	}
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
+
+// mcError is the error record mc prints with --json.
+type mcError struct {
+	Status string `json:"status"`
+	Error  struct {
+		Message string `json:"message"`
+		Cause   struct {
+			Message string `json:"message"`
+		} `json:"cause"`
+	} `json:"error"`
+}
+
+// runMC runs mc with --json against the configured backend and returns one
+// raw JSON record per line of output.
+func runMC(args ...string) ([]json.RawMessage, error) {
+	if objectStorage == nil {
+		return nil, ErrObjectStorageDisabled
+	}
+	return runMCWithInput(nil, args...)
+}
+
+func runMCWithInput(stdin []byte, args ...string) ([]json.RawMessage, error) {
+	cmd := exec.Command("mc", append([]string{"--json", "--quiet"}, args...)...)
+	cmd.Env = append(os.Environ(), objectStorage.mcHostEnv)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	output, runErr := cmd.Output()
+
+	var records []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e mcError
+		if json.Unmarshal(line, &e) == nil && e.Status == "error" {
+			message := e.Error.Message
+			if e.Error.Cause.Message != "" {
+				message += ": " + e.Error.Cause.Message
+			}
+			return nil, fmt.Errorf("mc %s: %s", args[0], message)
+		}
+		records = append(records, json.RawMessage(append([]byte(nil), line...)))
+	}
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("mc %s: %w: %s", args[0], runErr, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("mc %s: %w", args[0], runErr)
+	}
+	return records, nil
+}
+
+func mcNotFound(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "NoSuchBucket"))
+}
+
+// listBucketNames returns the full names of the tenant's buckets with their
+// creation times.
+func listBucketNames(namespace string) (map[string]string, error) {
+	records, err := runMC("ls", mcAlias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	buckets := map[string]string{}
+	prefix := bucketPrefix(namespace)
+	for _, record := range records {
+		var entry struct {
+			Key          string `json:"key"`
+			LastModified string `json:"lastModified"`
+		}
+		if err := json.Unmarshal(record, &entry); err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Key, "/")
+		if strings.HasPrefix(name, prefix) {
+			buckets[name] = formatCreationTime(entry.LastModified)
+		}
+	}
+	return buckets, nil
+}
+
+func describeBucket(namespace, name, createdAt string) (*BucketInfo, error) {
+	full := bucketFullName(namespace, name)
+	bucket := &BucketInfo{
+		Name:      name,
+		Bucket:    full,
+		Namespace: namespace,
+		Endpoint:  objectStorage.endpoint.String(),
+		CreatedAt: createdAt,
+	}
+
+	records, err := runMC("du", mcAlias+"/"+full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage of bucket %s: %w", full, err)
+	}
+	for _, record := range records {
+		var usage struct {
+			Size    int64 `json:"size"`
+			Objects int64 `json:"objects"`
+		}
+		if json.Unmarshal(record, &usage) == nil {
+			bucket.SizeBytes, bucket.Objects = usage.Size, usage.Objects
+		}
+	}
+
+	records, err = runMC("quota", "info", mcAlias+"/"+full)
+	if err != nil && !strings.Contains(err.Error(), "quota") {
+		return nil, fmt.Errorf("failed to get quota of bucket %s: %w", full, err)
+	}
+	for _, record := range records {
+		// Older mc releases report the limit as quota, newer ones as size
+		var quota struct {
+			Quota int64 `json:"quota"`
+			Size  int64 `json:"size"`
+		}
+		if json.Unmarshal(record, &quota) == nil {
+			bucket.QuotaBytes = quota.Quota
+			if quota.Size > 0 {
+				bucket.QuotaBytes = quota.Size
+			}
+		}
+	}
+	bucket.Quota = formatBucketQuota(bucket.QuotaBytes)
+	return bucket, nil
+}
+
+// ListBuckets returns the tenant's buckets with their usage, sorted by name.
+func ListBuckets(namespace string) ([]BucketInfo, error) {
+	names, err := listBucketNames(namespace)
+	if err != nil {
+		return nil, err
+	}
+	buckets := []BucketInfo{}
+	for full, createdAt := range names {
+		bucket, err := describeBucket(namespace, strings.TrimPrefix(full, bucketPrefix(namespace)), createdAt)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, *bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	return buckets, nil
+}
+
+// GetBucket returns ErrBucketNotFound if the tenant has no such bucket.
+func GetBucket(namespace, name string) (*BucketInfo, error) {
+	names, err := listBucketNames(namespace)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, ok := names[bucketFullName(namespace, name)]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+	return describeBucket(namespace, name, createdAt)
+}
+
+// CreateBucket creates the bucket with an optional hard quota in bytes and
+// makes sure the tenant has an access key for it.
+func CreateBucket(namespace, name, owner string, quotaBytes int64) (*BucketInfo, error) {
+	if objectStorage == nil {
+		return nil, ErrObjectStorageDisabled
+	}
+	full := bucketFullName(namespace, name)
+
+	if err := EnsureTenantNamespace(namespace, owner); err != nil {
+		return nil, err
+	}
+	if _, err := ensureBucketCredentials(namespace); err != nil {
+		return nil, err
+	}
+
+	if _, err := runMC("mb", mcAlias+"/"+full); err != nil {
+		if strings.Contains(err.Error(), "already own it") || strings.Contains(err.Error(), "already exists") {
+			return nil, ErrBucketExists
+		}
+		return nil, fmt.Errorf("failed to create bucket %s: %w", full, err)
+	}
+	if quotaBytes > 0 {
+		if err := SetBucketQuota(namespace, name, quotaBytes); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("Created bucket %s for namespace %s\n", full, namespace)
+	return GetBucket(namespace, name)
+}
+
+// SetBucketQuota sets a hard quota in bytes; zero removes the quota.
+func SetBucketQuota(namespace, name string, quotaBytes int64) error {
+	full := bucketFullName(namespace, name)
+	var err error
+	if quotaBytes > 0 {
+		_, err = runMC("quota", "set", mcAlias+"/"+full, "--size", fmt.Sprint(quotaBytes))
+	} else {
+		_, err = runMC("quota", "clear", mcAlias+"/"+full)
+	}
+	if mcNotFound(err) {
+		return ErrBucketNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set quota of bucket %s: %w", full, err)
+	}
+	return nil
+}
+
+// DeleteBucket removes the bucket and everything in it.
+func DeleteBucket(namespace, name string) error {
+	if _, err := GetBucket(namespace, name); err != nil {
+		return err
+	}
+	full := bucketFullName(namespace, name)
+	if _, err := runMC("rb", "--force", mcAlias+"/"+full); err != nil {
+		if mcNotFound(err) {
+			return ErrBucketNotFound
+		}
+		return fmt.Errorf("failed to delete bucket %s: %w", full, err)
+	}
+	fmt.Printf("Deleted bucket %s with its contents\n", full)
+	return nil
+}
+
+func bucketPolicyName(namespace string) string {
+	return TenantNamespacePrefix + tenantName(namespace)
+}
+
+// bucketPolicy grants full access to the tenant's buckets and nothing else.
+func bucketPolicy(namespace string) []byte {
+	prefix := "arn:aws:s3:::" + bucketPrefix(namespace) + "*"
+	policy, _ := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"s3:*"},
+			"Resource": []string{prefix, prefix + "/*"},
+		}},
+	})
+	return policy
+}
+
+func randomKey(length int, charset string) (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = charset[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// createBucketUser adds a MinIO user with a fresh key pair and attaches the
+// tenant policy to it.
+func createBucketUser(namespace string) (string, string, error) {
+	accessKey, err := randomKey(bucketAccessKeyLength, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access key: %w", err)
+	}
+	secretKey, err := randomKey(bucketSecretKeyLength, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate secret key: %w", err)
+	}
+
+	// mc reads the policy document from a file; /dev/stdin keeps it off disk
+	if _, err := runMCWithInput(bucketPolicy(namespace), "admin", "policy", "create", mcAlias, bucketPolicyName(namespace), "/dev/stdin"); err != nil {
+		return "", "", fmt.Errorf("failed to create bucket policy: %w", err)
+	}
+	if _, err := runMC("admin", "user", "add", mcAlias, accessKey, secretKey); err != nil {
+		return "", "", fmt.Errorf("failed to create bucket user: %w", err)
+	}
+	if _, err := runMC("admin", "policy", "attach", mcAlias, bucketPolicyName(namespace), "--user", accessKey); err != nil {
+		return "", "", fmt.Errorf("failed to attach bucket policy: %w", err)
+	}
+	return accessKey, secretKey, nil
+}
+
+func bucketCredentialsFromSecret(namespace string, secret *corev1.Secret) *BucketCredentials {
+	return &BucketCredentials{
+		Namespace:  namespace,
+		Endpoint:   string(secret.Data["endpoint"]),
+		AccessKey:  string(secret.Data["access_key"]),
+		SecretKey:  string(secret.Data["secret_key"]),
+		SecretName: secret.Name,
+	}
+}
+
+func bucketCredentialsSecret(namespace, accessKey, secretKey string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BucketCredentialsSecret,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "paas-api"},
+		},
+		StringData: map[string]string{
+			"endpoint":   objectStorage.endpoint.String(),
+			"access_key": accessKey,
+			"secret_key": secretKey,
+			// Names the AWS SDKs pick up from the environment
+			"AWS_ACCESS_KEY_ID":     accessKey,
+			"AWS_SECRET_ACCESS_KEY": secretKey,
+			"AWS_ENDPOINT_URL":      objectStorage.endpoint.String(),
+		},
+	}
+}
+
+// ensureBucketCredentials creates the tenant's MinIO user and credentials
+// secret on first use.
+func ensureBucketCredentials(namespace string) (*BucketCredentials, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	secrets := clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(context.TODO(), BucketCredentialsSecret, metav1.GetOptions{})
+	if err == nil {
+		return bucketCredentialsFromSecret(namespace, secret), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get secret %s: %w", BucketCredentialsSecret, err)
+	}
+
+	accessKey, secretKey, err := createBucketUser(namespace)
+	if err != nil {
+		return nil, err
+	}
+	secret, err = secrets.Create(context.TODO(), bucketCredentialsSecret(namespace, accessKey, secretKey), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret %s: %w", BucketCredentialsSecret, err)
+	}
+	return bucketCredentialsFromSecret(namespace, secret), nil
+}
+
+// GetBucketCredentials returns ErrBucketNotFound until the tenant has created a bucket.
+func GetBucketCredentials(namespace string) (*BucketCredentials, error) {
+	if objectStorage == nil {
+		return nil, ErrObjectStorageDisabled
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), BucketCredentialsSecret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrBucketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", BucketCredentialsSecret, err)
+	}
+	return bucketCredentialsFromSecret(namespace, secret), nil
+}
+
+// RotateBucketCredentials replaces the tenant's key pair. The new user is
+// created and stored before the old one is removed, so a failure part way
+// leaves a working key in the secret.
+func RotateBucketCredentials(namespace string) (*BucketCredentials, error) {
+	current, err := GetBucketCredentials(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey, secretKey, err := createBucketUser(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	secrets := clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(context.TODO(), BucketCredentialsSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", BucketCredentialsSecret, err)
+	}
+	secret.Data = nil
+	secret.StringData = bucketCredentialsSecret(namespace, accessKey, secretKey).StringData
+	secret, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update secret %s: %w", BucketCredentialsSecret, err)
+	}
+
+	if _, err := runMC("admin", "user", "remove", mcAlias, current.AccessKey); err != nil {
+		fmt.Printf("Could not remove rotated bucket user %s: %v\n", current.AccessKey, err)
+	}
+	fmt.Printf("Rotated bucket credentials of namespace %s\n", namespace)
+	return bucketCredentialsFromSecret(namespace, secret), nil
+}
+
+// setBucketUserEnabled disables the tenant's key while it is suspended.
+func setBucketUserEnabled(namespace string, enabled bool) error {
+	if objectStorage == nil {
+		return nil
+	}
+	creds, err := GetBucketCredentials(namespace)
+	if errors.Is(err, ErrBucketNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	if _, err := runMC("admin", "user", action, mcAlias, creds.AccessKey); err != nil {
+		return fmt.Errorf("failed to %s bucket user of %s: %w", action, namespace, err)
+	}
+	return nil
+}
+
+// deleteTenantBuckets removes every bucket and the MinIO user of a tenant
+// that is being deprovisioned.
+func deleteTenantBuckets(namespace string) (int, error) {
+	if objectStorage == nil {
+		return 0, nil
+	}
+	names, err := listBucketNames(namespace)
+	if err != nil {
+		return 0, err
+	}
+	for full := range names {
+		if _, err := runMC("rb", "--force", mcAlias+"/"+full); err != nil && !mcNotFound(err) {
+			return 0, fmt.Errorf("failed to delete bucket %s: %w", full, err)
+		}
+	}
+
+	creds, err := GetBucketCredentials(namespace)
+	if err == nil {
+		if _, err := runMC("admin", "user", "remove", mcAlias, creds.AccessKey); err != nil {
+			fmt.Printf("Could not remove bucket user %s: %v\n", creds.AccessKey, err)
+		}
+		if _, err := runMC("admin", "policy", "remove", mcAlias, bucketPolicyName(namespace)); err != nil {
+			fmt.Printf("Could not remove bucket policy %s: %v\n", bucketPolicyName(namespace), err)
+		}
+	}
+	return len(names), nil
+}
@@ -0,0 +1,293 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Redis persistence modes.
+const (
+	CachePersistenceNone = "none" // in memory only, lost on restart
+	CachePersistenceRDB  = "rdb"  // periodic snapshots
+	CachePersistenceAOF  = "aof"  // append-only file, fsync every second
+)
+
+const (
+	DefaultCacheMemory      = "256Mi"
+	DefaultCachePersistence = CachePersistenceNone
+
+	// MaxCacheNameLength leaves room for the "-redis" suffix within MaxDBNameLength.
+	MaxCacheNameLength = MaxDBNameLength - len(cacheSuffix)
+
+	cacheSuffix = "-redis"
+	cacheImage  = "redis:7.2-alpine"
+	cachePort   = 6379
+
+	cacheMemoryAnnotation      = "paas.cloudtrack.io/cache-memory"
+	cachePersistenceAnnotation = "paas.cloudtrack.io/cache-persistence"
+)
+
+var (
+	minCacheMemory = resource.MustParse("64Mi")
+	maxCacheMemory = resource.MustParse("4Gi")
+
+	ErrCacheNotFound = errors.New("cache not found")
+	ErrCacheExists   = errors.New("cache already exists")
+)
+
+// CacheSpec is the desired state of a Redis instance.
+type CacheSpec struct {
+	Memory      string `json:"memory,omitempty"`
+	Persistence string `json:"persistence,omitempty"`
+}
+
+// Default fills unset fields with the platform defaults.
+func (s *CacheSpec) Default() {
+	if s.Memory == "" {
+		s.Memory = DefaultCacheMemory
+	}
+	if s.Persistence == "" {
+		s.Persistence = DefaultCachePersistence
+	}
+}
+
+// Validate returns problems keyed by field. It expects a defaulted spec.
+func (s CacheSpec) Validate() map[string][]string {
+	problems := map[string][]string{}
+	memory, err := resource.ParseQuantity(s.Memory)
+	if err != nil {
+		problems["memory"] = []string{"must be a quantity such as 256Mi or 1Gi"}
+	} else if memory.Cmp(minCacheMemory) < 0 || memory.Cmp(maxCacheMemory) > 0 {
+		problems["memory"] = []string{fmt.Sprintf("must be between %s and %s", minCacheMemory.String(), maxCacheMemory.String())}
+	}
+	switch s.Persistence {
+	case CachePersistenceNone, CachePersistenceRDB, CachePersistenceAOF:
+	default:
+		problems["persistence"] = []string{fmt.Sprintf("must be one of %s, %s, %s", CachePersistenceNone, CachePersistenceRDB, CachePersistenceAOF)}
+	}
+	return problems
+}
+
+// caches are Redis instances.
+var caches = statefulService{
+	kind:     "cache",
+	suffix:   cacheSuffix,
+	selector: map[string]string{"app.kubernetes.io/name": "redis"},
+	notFound: ErrCacheNotFound,
+	exists:   ErrCacheExists,
+}
+
+// ValidateCacheName checks a cache name against DNS-1123 label rules and the
+// length limit of the objects derived from it.
+func ValidateCacheName(name string) []string {
+	return caches.validateName(name)
+}
+
+// ProvisionCache creates the AUTH secret, service and StatefulSet of a Redis
+// instance and returns without waiting for it to start.
+func ProvisionCache(namespace, name, owner string, spec CacheSpec) (*CacheInfo, error) {
+	spec.Default()
+
+	password, err := randomPassword(32)
+	if err != nil {
+		return nil, err
+	}
+	err = caches.provision(namespace, name, owner, caches.labels(name),
+		map[string]string{"password": password},
+		[]corev1.ServicePort{{Name: "redis", Port: cachePort, TargetPort: intstr.FromString("redis")}},
+		cacheStatefulSet(name, spec))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Cache creation initiated for %s in namespace %s\n", name, namespace)
+
+	return &CacheInfo{
+		Name:           name,
+		Namespace:      namespace,
+		Status:         StatusProvisioning,
+		DetailedStatus: "Cache is being provisioned",
+		Memory:         spec.Memory,
+		Persistence:    spec.Persistence,
+		Host:           caches.host(namespace, name),
+		Port:           cachePort,
+	}, nil
+}
+
+// cacheStatefulSet runs redis-server with maxmemory at 80% of the container
+// limit, leaving headroom for fragmentation and the persistence fork.
+func cacheStatefulSet(name string, spec CacheSpec) *appsv1.StatefulSet {
+	memory := resource.MustParse(spec.Memory)
+	maxMemory := memory.Value() * 8 / 10
+
+	args := []string{
+		"--requirepass", "$(REDIS_PASSWORD)",
+		"--maxmemory", fmt.Sprint(maxMemory),
+		"--maxmemory-policy", "allkeys-lru",
+		"--dir", "/data",
+	}
+	switch spec.Persistence {
+	case CachePersistenceRDB:
+		args = append(args, "--save", "300 10 60 10000", "--appendonly", "no")
+	case CachePersistenceAOF:
+		args = append(args, "--save", "", "--appendonly", "yes", "--appendfsync", "everysec")
+	default:
+		args = append(args, "--save", "", "--appendonly", "no")
+	}
+
+	password := &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: caches.secretName(name)},
+		Key:                  "password",
+	}}
+
+	sts := caches.statefulSet(name, map[string]string{
+		cacheMemoryAnnotation:      spec.Memory,
+		cachePersistenceAnnotation: spec.Persistence,
+	}, corev1.Container{
+		Name:    "redis",
+		Image:   cacheImage,
+		Command: []string{"redis-server"},
+		Args:    args,
+		Ports:   []corev1.ContainerPort{{Name: "redis", ContainerPort: cachePort}},
+		Env: []corev1.EnvVar{
+			{Name: "REDIS_PASSWORD", ValueFrom: password},
+			// Used by redis-cli in the probe
+			{Name: "REDISCLI_AUTH", ValueFrom: password},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{Command: []string{"sh", "-c", "redis-cli ping | grep -q PONG"}},
+			},
+			PeriodSeconds: 10,
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("50m"),
+				corev1.ResourceMemory: memory,
+			},
+			Limits: corev1.ResourceList{corev1.ResourceMemory: memory},
+		},
+	})
+
+	if spec.Persistence == CachePersistenceNone {
+		sts.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}}
+		return sts
+	}
+	sts.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{caches.dataVolumeClaim(name, cacheVolumeSize(memory))}
+	return sts
+}
+
+// cacheVolumeSize is twice the memory size, room for a snapshot being
+// written next to the previous one or for an AOF rewrite.
+func cacheVolumeSize(memory resource.Quantity) resource.Quantity {
+	size := memory.DeepCopy()
+	size.Add(memory)
+	return size
+}
+
+func describeCache(clientset kubernetes.Interface, sts *appsv1.StatefulSet, namespace, name string) (*CacheInfo, error) {
+	state, err := caches.observe(clientset, sts, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return &CacheInfo{
+		Name:             name,
+		Namespace:        namespace,
+		Status:           state.Status,
+		DetailedStatus:   state.DetailedStatus,
+		CredentialsReady: state.CredentialsReady,
+		Memory:           sts.Annotations[cacheMemoryAnnotation],
+		Persistence:      sts.Annotations[cachePersistenceAnnotation],
+		Host:             caches.host(namespace, name),
+		Port:             cachePort,
+		CreatedAt:        state.CreatedAt,
+	}, nil
+}
+
+// GetCache returns ErrCacheNotFound if there is no such cache.
+func GetCache(namespace, name string) (*CacheInfo, error) {
+	sts, err := caches.get(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	return describeCache(clientset, sts, namespace, name)
+}
+
+func ListCaches(namespace string) ([]CacheInfo, error) {
+	statefulSets, err := caches.list(namespace)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	list := []CacheInfo{}
+	for i := range statefulSets {
+		sts := &statefulSets[i]
+		name := caches.instanceName(sts)
+		cache, err := describeCache(clientset, sts, namespace, name)
+		if err != nil {
+			list = append(list, CacheInfo{
+				Name:           name,
+				Namespace:      namespace,
+				Status:         StatusFailed,
+				DetailedStatus: fmt.Sprintf("Failed to get info: %v", err),
+			})
+			continue
+		}
+		list = append(list, *cache)
+	}
+	return list, nil
+}
+
+// GetCacheCredentials returns ErrCredentialsPending until the AUTH secret exists.
+func GetCacheCredentials(namespace, name string) (*CacheCredentials, error) {
+	_, secret, err := caches.credentials(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	host := caches.host(namespace, name)
+	password := string(secret.Data["password"])
+	uri := url.URL{
+		Scheme: "redis",
+		User:   url.UserPassword("default", password),
+		Host:   fmt.Sprintf("%s:%d", host, cachePort),
+		Path:   "/0",
+	}
+	return &CacheCredentials{
+		Name:     name,
+		Host:     host,
+		Port:     cachePort,
+		Password: password,
+		URI:      uri.String(),
+	}, nil
+}
+
+// DeleteCache removes the StatefulSet, whose retention policy deletes the
+// volume, and the service and secret created with it.
+func DeleteCache(namespace, name string) error {
+	return caches.delete(namespace, name)
+}
+
+// setCachesPaused scales every cache in the namespace to zero, or back to
+// one, as part of a tenant suspension.
+func setCachesPaused(namespace string, paused bool) (int, error) {
+	return caches.setPaused(namespace, paused)
+}
@@ -3,16 +3,17 @@ package k8s
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"text/template"
+	"strconv"
 	"strings"
 	"time"
 
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -23,6 +24,66 @@ type TemplateData struct {
 	DBName    string
 	Team      string
 	Replicas  int
+	Plan      DatabasePlan
+	Version   string
+	Engine    string
+
+	LogicalBackup         bool
+	LogicalBackupSchedule string
+	LoadBalancer          bool
+
+	// SnapshotClass is the VolumeSnapshotClass CloudNativePG backs up with
+	SnapshotClass string
+
+	// Owner is set for clusters managed through a TenantDatabase
+	Owner *metav1.OwnerReference
+}
+
+// newTemplateData renders a cluster from a defaulted spec.
+func newTemplateData(namespace, dbName string, spec TenantDatabaseSpec) TemplateData {
+	spec.Default()
+	return TemplateData{
+		Namespace:             namespace,
+		DBName:                strings.ToLower(dbName),
+		Team:                  NamespaceTeam(namespace),
+		Replicas:              spec.Replicas,
+		Plan:                  Plans[spec.Plan],
+		Version:               spec.Version,
+		Engine:                spec.Engine,
+		LogicalBackup:         spec.Backups.Enabled,
+		LogicalBackupSchedule: spec.Backups.Schedule,
+		LoadBalancer:          spec.Exposure == ExposurePublic,
+	}
+}
+
+// templateFuncs are available to the manifest templates. Values come from
+// tenant-controlled specs, so templates render every string through quote
+// instead of interpolating it into the YAML.
+var templateFuncs = template.FuncMap{
+	"quote": func(v interface{}) (string, error) {
+		quoted, err := json.Marshal(fmt.Sprint(v))
+		return string(quoted), err
+	},
+}
+
+// renderTemplate executes one of the manifest templates in the template directory.
+func renderTemplate(name string, data TemplateData) (*bytes.Buffer, error) {
+	tmplPath := filepath.Join(settings.TemplateDir, name)
+	tmplBytes, err := os.ReadFile(tmplPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(tmplBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return &buf, nil
 }
 
 
@@ -33,37 +94,42 @@ type PodInfo struct {
 	Age       string `json:"age"`
 	Node      string `json:"node"`
 	Restarts  int32  `json:"restarts"`
-	CPU       string `json:"cpu"`
-	Memory    string `json:"memory"`
+	CPU       string `json:"cpu"`    // actual usage from metrics-server, summed across containers
+	Memory    string `json:"memory"` // actual usage from metrics-server, summed across containers
+
+	CPURequest    string `json:"cpu_request"`
+	CPULimit      string `json:"cpu_limit"`
+	MemoryRequest string `json:"memory_request"`
+	MemoryLimit   string `json:"memory_limit"`
 }
 
 func ListTenantDatabaseClusters(namespace string) ([]DatabaseClusterInfo, error) {
-	// Get all PostgreSQL CRDs in the namespace
-	cmd := exec.Command("kubectl", "get", "postgresql", "-n", namespace, "-o", "jsonpath={.items[*].metadata.name}")
-	output, err := cmd.Output()
-	
+	databases, err := listDatabases(namespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list PostgreSQL clusters: %w", err)
+		return nil, err
 	}
 
-	dbNames := strings.Fields(strings.TrimSpace(string(output)))
 	var clusters []DatabaseClusterInfo
 
-	for _, dbName := range dbNames {
-		cluster, err := GetDatabaseClusterInfo(namespace, dbName)
+	for _, db := range databases {
+		cluster, err := describeDatabase(db.Provider, namespace, db.Name)
 		if err != nil {
 			// If we can't get info for one cluster, still include it with error status
 			clusters = append(clusters, DatabaseClusterInfo{
-				Name:           dbName,
+				Name:           db.Name,
 				Namespace:      namespace,
-				Status:         "Error",
+				Status:         StatusFailed,
 				DetailedStatus: fmt.Sprintf("Failed to get info: %v", err),
+				Engine:         db.Provider.Engine(),
+				CreationMethod: db.Provider.Name(),
 			})
 		} else {
 			clusters = append(clusters, *cluster)
 		}
 	}
 
+	// Group by engine, by name within an engine
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Engine < clusters[j].Engine })
 	return clusters, nil
 }
 
@@ -78,10 +144,12 @@ func ListAllTenantPods() ([]PodInfo, error) {
 		return nil, fmt.Errorf("failed to list namespaces: %w", err)
 	}
 
+	usage := getPodUsage(clientset, "")
+
 	var pods []PodInfo
 
 	for _, ns := range nsList.Items {
-		if !isTenantNamespace(ns.Name) {
+		if !IsTenantNamespace(ns.Name) {
 			continue
 		}
 
@@ -91,47 +159,13 @@ func ListAllTenantPods() ([]PodInfo, error) {
 		}
 
 		for _, pod := range podList.Items {
-			age := time.Since(pod.CreationTimestamp.Time).Round(time.Second).String()
-
-			var restarts int32
-			for _, cs := range pod.Status.ContainerStatuses {
-				restarts += cs.RestartCount
-			}
-
-			cpu := "N/A"
-			mem := "N/A"
-			if len(pod.Spec.Containers) > 0 {
-				req := pod.Spec.Containers[0].Resources.Requests
-				if cpuQty, ok := req[corev1.ResourceCPU]; ok {
-					cpu = cpuQty.String()
-				}
-				if memQty, ok := req[corev1.ResourceMemory]; ok {
-					mem = memQty.String()
-				}
-			}
-
-			pods = append(pods, PodInfo{
-				Name:      pod.Name,
-				Namespace: ns.Name,
-				Status:    string(pod.Status.Phase),
-				Age:       age,
-				Node:      pod.Spec.NodeName,
-				Restarts:  restarts,
-				CPU:       cpu,
-				Memory:    mem,
-			})
+			pods = append(pods, newPodInfo(pod, usage))
 		}
 	}
 
 	return pods, nil
 }
 
-
-// Helper to identify tenant namespaces (adjust prefix as needed)
-func isTenantNamespace(ns string) bool {
-	return len(ns) > 7 && ns[:7] == "tenant-"
-}
-
 func ListTenantPodsJSON(namespace string) ([]PodInfo, error) {
 	clientset, err := getKubeClient()
 	if err != nil {
@@ -143,75 +177,37 @@ func ListTenantPodsJSON(namespace string) ([]PodInfo, error) {
 		return nil, fmt.Errorf("failed to list pods: %w", err)
 	}
 
+	usage := getPodUsage(clientset, namespace)
+
 	var result []PodInfo
 	for _, pod := range pods.Items {
-		age := time.Since(pod.CreationTimestamp.Time).Round(time.Second).String()
-		result = append(result, PodInfo{
-			Name:      pod.Name,
-			Status:    string(pod.Status.Phase),
-			Namespace: pod.Namespace,
-			Age:       age,
-		})
+		result = append(result, newPodInfo(pod, usage))
 	}
 	return result, nil
 }
 
 
 
+// DeleteTenantDB deletes a database through the provider that manages it.
 func DeleteTenantDB(namespace, dbName string) error {
-	fmt.Printf("Deleting database %s in namespace %s\n", dbName, namespace)
-
-	// Delete the PostgreSQL cluster using kubectl (since it's a CRD)
-	cmd := exec.Command("kubectl", "delete", "postgresql", dbName, "-n", namespace)
-	output, err := cmd.CombinedOutput()
+	provider, err := providerFor(namespace, dbName)
 	if err != nil {
-		fmt.Printf("Failed to delete PostgreSQL cluster: %v, output: %s\n", err, string(output))
-		return fmt.Errorf("failed to delete PostgreSQL cluster %s: %w", dbName, err)
+		return err
 	}
+	return provider.Delete(namespace, dbName)
+}
 
-	fmt.Printf("Successfully deleted PostgreSQL cluster %s\n", dbName)
-
-	// Wait a moment for the operator to clean up
-	time.Sleep(2 * time.Second)
 
-	// The Zalando operator should automatically clean up associated secrets
-	// But we can also manually delete them if needed
-	clientset, err := getKubeClient()
-	if err != nil {
-		return fmt.Errorf("failed to get k8s client: %w", err)
-	}
+// ErrDatabaseExists is returned when provisioning a database name that is already taken.
+var ErrDatabaseExists = errors.New("database already exists")
 
-	// Delete associated secrets (optional, operator usually handles this)
-	secretNames := []string{
-		fmt.Sprintf("%s.%s.credentials.postgresql.acid.zalan.do", dbName, dbName),
-		fmt.Sprintf("postgres.%s.credentials.postgresql.acid.zalan.do", dbName),
-	}
-
-	for _, secretName := range secretNames {
-		err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), secretName, metav1.DeleteOptions{})
-		if err != nil {
-			fmt.Printf("Could not delete secret %s (may not exist): %v\n", secretName, err)
-		} else {
-			fmt.Printf("Deleted secret %s\n", secretName)
-		}
+// DatabaseExists reports whether any provider has a cluster with this name in the namespace.
+func DatabaseExists(namespace, dbName string) (bool, error) {
+	_, err := providerFor(namespace, dbName)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
 	}
-
-	return nil
-}
-
-
-type DatabaseClusterInfo struct {
-	Name              string            `json:"name"`
-	Namespace         string            `json:"namespace"`
-	Status            string            `json:"status"`
-	DetailedStatus    string            `json:"detailed_status"`
-	CredentialsReady  bool              `json:"credentials_ready"`
-	ConnectionReady   bool              `json:"connection_ready"`
-	CreatedAt         string            `json:"created_at"`
-	Replicas          int               `json:"replicas"`
-	RunningReplicas   int               `json:"running_replicas"`
-	ConnectionInfo    map[string]string `json:"connection_info,omitempty"`
-	CreationMethod    string            `json:"creation_method"` // "zalando" or "manual"
+	return err == nil, err
 }
 
 func CheckTenantDBStatus(namespace, dbName string) (string, error) {
@@ -223,188 +219,62 @@ func CheckTenantDBStatus(namespace, dbName string) (string, error) {
 }
 
 func GetDatabaseClusterInfo(namespace, dbName string) (*DatabaseClusterInfo, error) {
-	clientset, err := getKubeClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get k8s client: %w", err)
-	}
-
-	cluster := &DatabaseClusterInfo{
-		Name:      dbName,
-		Namespace: namespace,
-		Status:    "Unknown",
-	}
-
-	// 1. Get PostgreSQL CRD information
-	cmd := exec.Command("kubectl", "get", "postgresql", dbName, "-n", namespace, "-o", "json")
-	output, err := cmd.Output()
-	
-	if err != nil {
-		cluster.Status = "Not Found"
-		cluster.DetailedStatus = "PostgreSQL cluster not found"
-		return cluster, nil
-	}
-
-	// Parse basic info from CRD
-	outputStr := string(output)
-	if strings.Contains(outputStr, `"PostgresClusterStatus":"Running"`) {
-		cluster.Status = "Running"
-		cluster.DetailedStatus = "Database cluster is running"
-	} else if strings.Contains(outputStr, `"PostgresClusterStatus":"Creating"`) {
-		cluster.Status = "Creating"
-		cluster.DetailedStatus = "Database cluster is being created"
-	} else if strings.Contains(outputStr, `"PostgresClusterStatus":"CreateFailed"`) {
-		cluster.Status = "Failed"
-		cluster.DetailedStatus = "Database creation failed"
-	} else {
-		cluster.Status = "Pending"
-		cluster.DetailedStatus = "Database cluster is pending"
-	}
-
-	// 2. Get pod information
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("cluster-name=%s", dbName),
-	})
-	
-	if err == nil && len(pods.Items) > 0 {
-		runningCount := 0
-		for _, pod := range pods.Items {
-			if pod.Status.Phase == corev1.PodRunning {
-				runningCount++
-			}
-			// Get creation time from first pod only
-			if cluster.CreatedAt == "" {
-				cluster.CreatedAt = pod.CreationTimestamp.Format("2006-01-02 15:04:05")
-			}
-		}
-		cluster.Replicas = len(pods.Items)
-		cluster.RunningReplicas = runningCount
-		
-		// Status update based on pods and credentials
-		if runningCount > 0 {
-			cluster.Status = "Ready"
-			cluster.DetailedStatus = "Database is ready"
-		}
-	}
-
-	// 3. Check for Zalando credentials
-	ownerSecretName := fmt.Sprintf("%s.%s.credentials.postgresql.acid.zalan.do", dbName, dbName)
-	_, err = clientset.CoreV1().Secrets(namespace).Get(context.TODO(), ownerSecretName, metav1.GetOptions{})
-	if err == nil {
-		cluster.CredentialsReady = true
-		cluster.CreationMethod = "zalando"
-		cluster.ConnectionReady = true
-		cluster.Status = "Ready"
-		cluster.DetailedStatus = "Database is ready with credentials"
-	}
-
-	return cluster, nil
+	cluster, err := DescribeDatabaseCluster(namespace, dbName)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return &DatabaseClusterInfo{
+			Name:           dbName,
+			Namespace:      namespace,
+			Status:         "Not Found",
+			DetailedStatus: "Database cluster not found",
+		}, nil
+	}
+	return cluster, err
 }
 
-
-func ProvisionTenantDB(namespace, dbName string, replicas int) error {
-	clientset, err := getKubeClient()
+// DescribeDatabaseCluster is GetDatabaseClusterInfo for callers that must
+// tell a missing cluster apart: it returns ErrDatabaseNotFound instead of a
+// "Not Found" placeholder.
+func DescribeDatabaseCluster(namespace, dbName string) (*DatabaseClusterInfo, error) {
+	provider, err := providerFor(namespace, dbName)
 	if err != nil {
-		return fmt.Errorf("failed to get k8s client: %w", err)
+		return nil, err
 	}
+	return describeDatabase(provider, namespace, dbName)
+}
 
-	// 1. Create Namespace if not exists
-	_, err = clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+// describeDatabase fills in what is common to every provider.
+func describeDatabase(provider DatabaseProvider, namespace, dbName string) (*DatabaseClusterInfo, error) {
+	cluster, err := provider.Describe(namespace, dbName)
 	if err != nil {
-		_, err = clientset.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{Name: namespace},
-		}, metav1.CreateOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create namespace: %w", err)
-		}
+		return nil, err
 	}
-
-	// 2. Render and apply manifest
-	data := TemplateData{
-		Namespace: namespace,
-		DBName:    strings.ToLower(dbName),
-		Team:      "paas-team",
-		Replicas:  replicas,
+	cluster.CreationMethod = provider.Name()
+	cluster.Engine = provider.Engine()
+	cluster.ConnectionReady = cluster.CredentialsReady &&
+		(cluster.Status == StatusReady || cluster.Status == StatusDegraded || cluster.Status == StatusUpdating)
+	cluster.ConnectionInfo = map[string]string{
+		"host":     provider.Host(namespace, dbName),
+		"port":     strconv.Itoa(provider.Port()),
+		"database": dbName,
 	}
+	return cluster, nil
+}
 
-	tmplPath := filepath.Join("templates", "postgres-cluster.yaml.tmpl")
-	tmplBytes, err := os.ReadFile(tmplPath)
+// ProvisionTenantDBWithCredentials creates a database with the provider of its
+// engine and plan and returns as soon as the cluster resource exists; credentials follow.
+func ProvisionTenantDBWithCredentials(namespace, dbName, owner string, spec TenantDatabaseSpec) (*ProvisionResult, error) {
+	spec.Default()
+	provider, err := ProviderForSpec(spec)
 	if err != nil {
-		return fmt.Errorf("failed to read template: %w", err)
+		return nil, err
 	}
 
-	tmpl, err := template.New("postgres").Parse(string(tmplBytes))
+	exists, err := DatabaseExists(namespace, dbName)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, err
 	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
-	}
-
-	cmd := exec.Command("kubectl", "apply", "-f", "-")
-	cmd.Stdin = &buf
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to apply manifest: %w", err)
-	}
-
-	// 3. Wait for pod to be ready
-	fmt.Printf("Waiting for PostgreSQL pod to be ready...\n")
-	
-	// Wait up to 2 minutes for pod to be running
-	podReady := false
-	for attempts := 0; attempts < 60; attempts++ {
-		pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("cluster-name=%s", dbName),
-		})
-		
-		if err == nil && len(pods.Items) > 0 {
-			for _, pod := range pods.Items {
-				if pod.Status.Phase == corev1.PodRunning {
-					podReady = true
-					break
-				}
-			}
-		}
-		
-		if podReady {
-			break
-		}
-		
-		// Show progress every 15 seconds
-		if attempts > 0 && attempts%8 == 0 {
-			fmt.Printf("Still waiting for pod... (%d seconds elapsed)\n", attempts*2)
-		}
-		
-		time.Sleep(2 * time.Second)
-	}
-
-	if !podReady {
-		return fmt.Errorf("pod not ready after 2 minutes")
-	}
-
-	fmt.Printf("Pod is ready, checking Zalando credentials...\n")
-
-	// 4. Wait for Zalando credentials
-	credentials, err := GetDatabaseCredentials(namespace, dbName, 60*time.Second)
-	if err != nil {
-		return fmt.Errorf("failed to get database credentials: %v", err)
-	}
-
-	fmt.Printf("Database creation successful!\n")
-	fmt.Printf("Database: %s\n", credentials["database_name"])
-	fmt.Printf("Connection string: %s\n", credentials["connection_string"])
-	return nil
-}
-
-// ProvisionTenantDBWithCredentials provisions a database and returns credentials immediately
-func ProvisionTenantDBWithCredentials(namespace, dbName string, replicas int) (map[string]interface{}, error) {
-	clientset, err := getKubeClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	if exists {
+		return nil, ErrDatabaseExists
 	}
 
 	// 1. Create Namespace if not exists (with retry)
@@ -412,19 +282,8 @@ func ProvisionTenantDBWithCredentials(namespace, dbName string, replicas int) (m
 	
 	var nsErr error
 	for attempts := 0; attempts < 3; attempts++ {
-		_, err = clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
-		if err == nil {
-			fmt.Printf("Namespace %s already exists\n", namespace)
-			break
-		}
-		
-		// Try to create namespace
-		_, nsErr = clientset.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{Name: namespace},
-		}, metav1.CreateOptions{})
-		
+		nsErr = EnsureTenantNamespace(namespace, owner)
 		if nsErr == nil {
-			fmt.Printf("Created namespace %s\n", namespace)
 			break
 		}
 		
@@ -434,149 +293,66 @@ func ProvisionTenantDBWithCredentials(namespace, dbName string, replicas int) (m
 		}
 	}
 	
-	if nsErr != nil && err != nil {
+	if nsErr != nil {
 		return nil, fmt.Errorf("failed to create namespace after 3 attempts: %w", nsErr)
 	}
 
-	// 2. Render and apply manifest
-	data := TemplateData{
-		Namespace: namespace,
-		DBName:    strings.ToLower(dbName),
-		Team:      "paas-team",
-		Replicas:  replicas,
-	}
-
-	tmplPath := filepath.Join("templates", "postgres-cluster.yaml.tmpl")
-	tmplBytes, err := os.ReadFile(tmplPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read template: %w", err)
-	}
-
-	tmpl, err := template.New("postgres").Parse(string(tmplBytes))
+	// 2. Create the cluster; the provider's operator or controller creates the
+	// credentials in the background
+	result, err := provider.Provision(ProvisionRequest{Namespace: namespace, DBName: dbName, Spec: spec})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse template: %w", err)
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
-	}
-
-	cmd := exec.Command("kubectl", "apply", "-f", "-")
-	cmd.Stdin = &buf
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to apply manifest: %w", err)
-	}
-
-	fmt.Printf("Database manifest applied successfully!\n")
-
-	// 3. Return immediate credentials based on Zalando naming conventions
-	// The actual credentials will be created by Zalando operator in the background
-	result := map[string]interface{}{
-		"database_name": dbName,
-		"host": fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace),
-		"port": "5432",
-		"status": "provisioning",
-		"message": "Database is being created. Credentials will be available shortly.",
-		"secret_name": fmt.Sprintf("%s.%s.credentials.postgresql.acid.zalan.do", dbName, dbName),
-		"connection_info": map[string]string{
-			"host": fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace),
-			"port": "5432",
-			"database": dbName,
-			"ssl_mode": "prefer",
-			"note": "Username and password will be available in the secret once ready",
-		},
-		"instructions": map[string]string{
-			"check_status": fmt.Sprintf("kubectl get postgresql %s -n %s", dbName, namespace),
-			"get_credentials": fmt.Sprintf("kubectl get secret %s.%s.credentials.postgresql.acid.zalan.do -n %s -o yaml", dbName, dbName, namespace),
-		},
-	}
-
-	fmt.Printf("Database creation initiated for %s in namespace %s\n", dbName, namespace)
+	fmt.Printf("Database creation initiated for %s in namespace %s (%s)\n", dbName, namespace, provider.Name())
 	return result, nil
 }
 
 
 
 
-func GetDatabaseCredentials(namespace, dbName string, timeout time.Duration) (map[string]interface{}, error) {
-    clientset, err := getKubeClient()
+// GetDatabaseCredentials waits up to timeout for the database's credentials secret.
+func GetDatabaseCredentials(namespace, dbName string, timeout time.Duration) (*DatabaseCredentials, error) {
+    provider, err := providerFor(namespace, dbName)
     if err != nil {
-        return nil, fmt.Errorf("failed to get k8s client: %w", err)
+        return nil, err
     }
 
-    // The main database owner credentials
-    ownerSecretName := fmt.Sprintf("%s.%s.credentials.postgresql.acid.zalan.do", dbName, dbName)
-    
-    fmt.Printf("Looking for secret: %s in namespace: %s\n", ownerSecretName, namespace)
-    
     deadline := time.Now().Add(timeout)
-    var ownerCreds map[string]string
-
-    // Wait for owner credentials
-    attempt := 0
     for {
-        attempt++
-        
-        secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), ownerSecretName, metav1.GetOptions{})
-        if err == nil {
-            fmt.Printf("Found owner secret: %s after %d attempts\n", ownerSecretName, attempt)
-            ownerCreds = make(map[string]string)
-            for key, val := range secret.Data {
-                ownerCreds[key] = string(val)
-            }
-            break
+        credentials, err := provider.Credentials(namespace, dbName)
+        if !errors.Is(err, ErrCredentialsPending) || time.Now().After(deadline) {
+            return credentials, err
         }
-        
-        if time.Now().After(deadline) {
-            return nil, fmt.Errorf("owner secret %s not found after %v: %w", ownerSecretName, timeout, err)
-        }
-        
         time.Sleep(2 * time.Second)
     }
+}
 
-    // Prepare response with connection information
-    result := map[string]interface{}{
-        "database_name": dbName,
-        "host": fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace),
-        "port": "5432",
-        "primary_user": ownerCreds,
-    }
 
-    // Add connection string for convenience
-    if username, ok := ownerCreds["username"]; ok {
-        if password, ok := ownerCreds["password"]; ok {
-            connectionString := fmt.Sprintf("postgresql://%s:%s@%s.%s.svc.cluster.local:5432/%s", 
-                username, password, dbName, namespace, dbName)
-            result["connection_string"] = connectionString
-            result["connection_string_ssl"] = connectionString + "?sslmode=prefer"
-            
-            // Add individual components for easier use
-            result["connection_info"] = map[string]string{
-                "host": fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace),
-                "port": "5432",
-                "database": dbName,
-                "username": username,
-                "password": password,
-                "ssl_mode": "prefer",
-            }
-        }
-    }
 
-    return result, nil
-}
+// kubeClient, when set, is used instead of connecting to a cluster.
+var kubeClient kubernetes.Interface
 
+// SetKubeClient makes the package use client for every Kubernetes API call,
+// e.g. a fake clientset in tests. Nil restores connecting with the
+// kubeconfig or in-cluster configuration.
+func SetKubeClient(client kubernetes.Interface) {
+	kubeClient = client
+}
 
+func getKubeClient() (kubernetes.Interface, error) {
+	if kubeClient != nil {
+		return kubeClient, nil
+	}
 
-func getKubeClient() (*kubernetes.Clientset, error) {
 	var config *rest.Config
 	var err error
 
 	// For local development, always use kubeconfig file first
-	kubeconfig := filepath.Join(homeDir(), ".kube", "config")
+	kubeconfig := settings.Kubeconfig
+	if kubeconfig == "" {
+		kubeconfig = filepath.Join(homeDir(), ".kube", "config")
+	}
 	if _, err := os.Stat(kubeconfig); err == nil {
 		fmt.Printf("Using kubeconfig file: %s\n", kubeconfig)
 		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
@@ -594,8 +370,7 @@ func getKubeClient() (*kubernetes.Clientset, error) {
 		}
 	}
 
-	// Set a reasonable timeout
-	config.Timeout = 30 * time.Second
+	config.Timeout = settings.ClientTimeout
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -618,12 +393,11 @@ func homeDir() string {
 	return os.Getenv("USERPROFILE") // Windows support
 }
 
-// Helper function to generate random password
-func generateRandomPassword(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+// randomPassword returns an alphanumeric password from crypto/rand.
+func randomPassword(length int) (string, error) {
+	password, err := randomKey(length, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	if err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
 	}
-	return string(b)
+	return password, nil
 }
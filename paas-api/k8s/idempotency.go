@@ -0,0 +1,204 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	idempotencyLabel  = "paas.cloudtrack.io/idempotency-key"
+	idempotencyPrefix = "idem-"
+
+	// IdempotencyTTL is how long a completed request is replayed for its key.
+	IdempotencyTTL = 24 * time.Hour
+
+	// IdempotencyLease is how long a request may hold its key without
+	// completing. A request still in progress after that is assumed to have
+	// died with its server, and a retry may claim the key again.
+	IdempotencyLease = 10 * time.Minute
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key.
+type IdempotencyRecord struct {
+	ID          string
+	Fingerprint string // hash of the request body
+	Completed   bool
+	Status      int
+	Body        string
+	CreatedAt   time.Time
+	CompletedAt time.Time
+}
+
+func idempotencyFromConfigMap(cm *corev1.ConfigMap) *IdempotencyRecord {
+	rec := &IdempotencyRecord{
+		ID:          cm.Name[len(idempotencyPrefix):],
+		Fingerprint: cm.Data["fingerprint"],
+		Completed:   cm.Data["completed"] == "true",
+		Body:        cm.Data["body"],
+		CreatedAt:   cm.CreationTimestamp.Time,
+	}
+	rec.Status, _ = strconv.Atoi(cm.Data["status"])
+	if t, err := time.Parse(time.RFC3339, cm.Data["claimed_at"]); err == nil {
+		rec.CreatedAt = t
+	}
+	rec.CompletedAt = rec.CreatedAt
+	if t, err := time.Parse(time.RFC3339, cm.Data["completed_at"]); err == nil {
+		rec.CompletedAt = t
+	}
+	return rec
+}
+
+// Expired reports whether the key may be claimed again: the response of a
+// completed request is kept for IdempotencyTTL, an unfinished claim for
+// IdempotencyLease.
+func (r *IdempotencyRecord) Expired(now time.Time) bool {
+	if r.Completed {
+		return now.Sub(r.CompletedAt) > IdempotencyTTL
+	}
+	return now.Sub(r.CreatedAt) > IdempotencyLease
+}
+
+// deleteIdempotencyKey deletes cm unless it has been replaced since it was read.
+func deleteIdempotencyKey(configMaps corev1client.ConfigMapInterface, cm *corev1.ConfigMap) error {
+	err := configMaps.Delete(context.TODO(), cm.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &cm.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return fmt.Errorf("failed to delete idempotency key %s: %w", cm.Name, err)
+	}
+	return nil
+}
+
+// ClaimIdempotencyKey atomically reserves id for a new request. If the key is
+// already taken it returns the existing record and claimed=false. Expired
+// records, including claims abandoned by a crashed request, are discarded and
+// the key is claimed again.
+func ClaimIdempotencyKey(id, fingerprint string) (*IdempotencyRecord, bool, error) {
+	if err := ensureSystemNamespace(); err != nil {
+		return nil, false, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(SystemNamespace())
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      idempotencyPrefix + id,
+			Namespace: SystemNamespace(),
+			Labels:    map[string]string{idempotencyLabel: "true"},
+		},
+		Data: map[string]string{
+			"fingerprint": fingerprint,
+			"completed":   "false",
+			"claimed_at":  time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+		if err == nil {
+			return nil, true, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, false, fmt.Errorf("failed to store idempotency key: %w", err)
+		}
+
+		existing, err := configMaps.Get(context.TODO(), cm.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read idempotency key: %w", err)
+		}
+
+		rec := idempotencyFromConfigMap(existing)
+		if rec.Expired(time.Now()) {
+			if err := deleteIdempotencyKey(configMaps, existing); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		return rec, false, nil
+	}
+	return nil, false, fmt.Errorf("failed to claim idempotency key %s", id)
+}
+
+// CompleteIdempotencyKey stores the response so retries can replay it.
+func CompleteIdempotencyKey(id string, status int, body string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(SystemNamespace())
+
+	cm, err := configMaps.Get(context.TODO(), idempotencyPrefix+id, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	cm.Data["completed"] = "true"
+	cm.Data["completed_at"] = time.Now().UTC().Format(time.RFC3339)
+	cm.Data["status"] = strconv.Itoa(status)
+	cm.Data["body"] = body
+
+	_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key, e.g. after a server error, so the
+// client's retry runs the operation again.
+func ReleaseIdempotencyKey(id string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	err = clientset.CoreV1().ConfigMaps(SystemNamespace()).Delete(context.TODO(), idempotencyPrefix+id, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// SweepIdempotencyKeys deletes expired keys, which are otherwise only
+// discarded when a request reuses them. A key that cannot be deleted is
+// skipped until the next sweep. It returns how many were deleted.
+func SweepIdempotencyKeys() (int, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(SystemNamespace())
+
+	list, err := configMaps.List(context.TODO(), metav1.ListOptions{LabelSelector: idempotencyLabel + "=true"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list idempotency keys: %w", err)
+	}
+
+	deleted := 0
+	now := time.Now()
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if !idempotencyFromConfigMap(cm).Expired(now) {
+			continue
+		}
+		if err := deleteIdempotencyKey(configMaps, cm); err != nil {
+			fmt.Printf("Idempotency sweep: %v\n", err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// backdateIdempotencyKey moves a stored timestamp of key into the past.
+func backdateIdempotencyKey(t *testing.T, kube *fake.Clientset, id, field string, age time.Duration) {
+	t.Helper()
+	configMaps := kube.CoreV1().ConfigMaps(SystemNamespace())
+	cm, err := configMaps.Get(context.Background(), idempotencyPrefix+id, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Data[field] = time.Now().Add(-age).UTC().Format(time.RFC3339)
+	if _, err := configMaps.Update(context.Background(), cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func useFakeKube(t *testing.T) *fake.Clientset {
+	t.Helper()
+	kube := fake.NewSimpleClientset()
+	SetKubeClient(kube)
+	t.Cleanup(func() { SetKubeClient(nil) })
+	return kube
+}
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	kube := useFakeKube(t)
+
+	if _, claimed, err := ClaimIdempotencyKey("k1", "body"); err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v", claimed, err)
+	}
+	existing, claimed, err := ClaimIdempotencyKey("k1", "body")
+	if err != nil || claimed || existing.Completed {
+		t.Fatalf("claim while in progress = %+v, %v, %v", existing, claimed, err)
+	}
+
+	// The first request died without completing
+	backdateIdempotencyKey(t, kube, "k1", "claimed_at", IdempotencyLease+time.Minute)
+	if _, claimed, err := ClaimIdempotencyKey("k1", "body"); err != nil || !claimed {
+		t.Fatalf("claim after the lease expired = %v, %v", claimed, err)
+	}
+
+	if err := CompleteIdempotencyKey("k1", 201, `{"ok":true}`); err != nil {
+		t.Fatal(err)
+	}
+	backdateIdempotencyKey(t, kube, "k1", "claimed_at", IdempotencyLease+time.Minute)
+	existing, claimed, err = ClaimIdempotencyKey("k1", "body")
+	if err != nil || claimed || !existing.Completed || existing.Status != 201 {
+		t.Fatalf("claim of a completed key = %+v, %v, %v", existing, claimed, err)
+	}
+
+	backdateIdempotencyKey(t, kube, "k1", "completed_at", IdempotencyTTL+time.Minute)
+	if _, claimed, err := ClaimIdempotencyKey("k1", "body"); err != nil || !claimed {
+		t.Fatalf("claim after the TTL = %v, %v", claimed, err)
+	}
+}
+
+func TestSweepIdempotencyKeys(t *testing.T) {
+	kube := useFakeKube(t)
+
+	for _, id := range []string{"running", "abandoned", "completed", "expired"} {
+		if _, _, err := ClaimIdempotencyKey(id, "body"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"completed", "expired"} {
+		if err := CompleteIdempotencyKey(id, 200, "{}"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backdateIdempotencyKey(t, kube, "abandoned", "claimed_at", IdempotencyLease+time.Minute)
+	backdateIdempotencyKey(t, kube, "expired", "completed_at", IdempotencyTTL+time.Minute)
+
+	deleted, err := SweepIdempotencyKeys()
+	if err != nil || deleted != 2 {
+		t.Fatalf("SweepIdempotencyKeys() = %d, %v, want 2", deleted, err)
+	}
+
+	list, err := kube.CoreV1().ConfigMaps(SystemNamespace()).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, cm := range list.Items {
+		left = append(left, cm.Name)
+	}
+	if len(left) != 2 || left[0] != idempotencyPrefix+"completed" || left[1] != idempotencyPrefix+"running" {
+		t.Errorf("keys left = %v, want the running and completed ones", left)
+	}
+}
@@ -0,0 +1,678 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Ways to prove control of a custom hostname.
+const (
+	DomainChallengeTXT  = "txt"  // TXT record at _paas-challenge.<hostname>
+	DomainChallengeHTTP = "http" // token served at http://<hostname>/.well-known/paas-challenge/<token>
+)
+
+const (
+	DefaultRoutePath        = "/"
+	DefaultRouteServicePort = 80
+
+	// DefaultCertificateIssuer is the cert-manager ClusterIssuer used when none is configured.
+	DefaultCertificateIssuer = "letsencrypt"
+
+	// DomainChallengePrefix is the DNS label the TXT challenge record sits under.
+	DomainChallengePrefix = "_paas-challenge"
+	// DomainChallengePath is where the HTTP challenge expects the token.
+	DomainChallengePath = "/.well-known/paas-challenge/"
+
+	routeHostnameAnnotation = "paas.cloudtrack.io/custom-hostname"
+	routeTokenAnnotation    = "paas.cloudtrack.io/domain-token"
+	routeVerifiedAnnotation = "paas.cloudtrack.io/domain-verified-at"
+	certManagerIssuerKey    = "cert-manager.io/cluster-issuer"
+)
+
+var (
+	ErrRouteNotFound     = errors.New("route not found")
+	ErrRouteExists       = errors.New("route already exists")
+	ErrHostnameTaken     = errors.New("hostname is used by another route")
+	ErrNoCustomHostname  = errors.New("route has no custom hostname")
+	ErrServiceNotFound   = errors.New("service not found")
+	ErrIngressDisabled   = errors.New("ingress is not configured")
+	ErrDomainNotVerified = errors.New("domain ownership could not be verified")
+)
+
+const domainChallengeTimeout = 10 * time.Second
+
+const (
+	hostnameClaimPrefix = "hostname-"
+
+	// hostnameClaimLease is how long the claim of a route that is not marked
+	// verified yet holds its hostname, which covers the update marking it.
+	hostnameClaimLease = time.Minute
+)
+
+// ingress holds the platform settings for tenant routes; nil until
+// ConfigureIngress is called with a domain.
+var ingress *ingressConfig
+
+type ingressConfig struct {
+	domain string // wildcard domain, routes get <route>--<tenant>.<domain>
+	class  string // IngressClass, the cluster default when empty
+	issuer string // cert-manager ClusterIssuer for TLS certificates
+}
+
+// ConfigureIngress enables routes under domain, whose wildcard DNS record
+// points at the ingress controller. An empty domain leaves routes disabled.
+func ConfigureIngress(domain, class, issuer string) error {
+	if domain == "" {
+		return nil
+	}
+	domain = strings.TrimPrefix(strings.ToLower(domain), "*.")
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 || !strings.Contains(domain, ".") {
+		return fmt.Errorf("invalid platform domain %q", domain)
+	}
+	if issuer == "" {
+		issuer = DefaultCertificateIssuer
+	}
+	ingress = &ingressConfig{domain: domain, class: class, issuer: issuer}
+	return nil
+}
+
+func IngressEnabled() bool {
+	return ingress != nil
+}
+
+// RouteSpec is the desired state of an HTTP route to a tenant service.
+type RouteSpec struct {
+	Service  string `json:"service"`
+	Port     int    `json:"port"`
+	Path     string `json:"path"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// Default fills unset fields with the platform defaults.
+func (s *RouteSpec) Default() {
+	if s.Port == 0 {
+		s.Port = DefaultRouteServicePort
+	}
+	if s.Path == "" {
+		s.Path = DefaultRoutePath
+	}
+	s.Hostname = strings.ToLower(strings.TrimSuffix(s.Hostname, "."))
+}
+
+// Validate returns problems keyed by field. It expects a defaulted spec.
+func (s RouteSpec) Validate() map[string][]string {
+	problems := map[string][]string{}
+	if s.Service == "" {
+		problems["service"] = []string{"must not be empty"}
+	} else if p := validation.IsDNS1035Label(s.Service); len(p) > 0 {
+		problems["service"] = p
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		problems["port"] = []string{"must be between 1 and 65535"}
+	}
+	if !strings.HasPrefix(s.Path, "/") {
+		problems["path"] = []string{"must start with /"}
+	}
+	if s.Hostname != "" {
+		if p := validation.IsDNS1123Subdomain(s.Hostname); len(p) > 0 {
+			problems["hostname"] = p
+		} else if !strings.Contains(s.Hostname, ".") || net.ParseIP(s.Hostname) != nil {
+			problems["hostname"] = []string{"must be a fully qualified domain name"}
+		} else if isInternalHostname(s.Hostname) {
+			problems["hostname"] = []string{"must be a public domain name"}
+		} else if ingress != nil && (s.Hostname == ingress.domain || strings.HasSuffix(s.Hostname, "."+ingress.domain)) {
+			problems["hostname"] = []string{fmt.Sprintf("must not be under the platform domain %s", ingress.domain)}
+		}
+	}
+	return problems
+}
+
+// ValidateRouteName checks a route name, which also becomes part of the
+// platform hostname of the route.
+func ValidateRouteName(namespace, name string) []string {
+	if name == "" {
+		return []string{"must not be empty"}
+	}
+	problems := validation.IsDNS1123Label(name)
+	if strings.Contains(name, platformHostSeparator) {
+		problems = append(problems, fmt.Sprintf("must not contain %q", platformHostSeparator))
+	}
+	if label := platformHostLabel(namespace, name); len(label) > validation.DNS1123LabelMaxLength {
+		problems = append(problems, fmt.Sprintf("must be no more than %d characters for this tenant",
+			validation.DNS1123LabelMaxLength-len(label)+len(name)))
+	}
+	return problems
+}
+
+// platformHostSeparator joins route and tenant in the platform hostname.
+// Route names cannot contain it, so every label belongs to one route only.
+const platformHostSeparator = "--"
+
+func platformHostLabel(namespace, name string) string {
+	return name + platformHostSeparator + tenantName(namespace)
+}
+
+func platformHost(namespace, name string) string {
+	return platformHostLabel(namespace, name) + "." + ingress.domain
+}
+
+func routeLabels(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/component":  "route",
+		"app.kubernetes.io/instance":   name,
+		"app.kubernetes.io/managed-by": "paas-api",
+	}
+}
+
+func isRouteObject(meta metav1.ObjectMeta) bool {
+	return meta.Labels["app.kubernetes.io/component"] == "route" && meta.Labels["app.kubernetes.io/managed-by"] == "paas-api"
+}
+
+// certManagerInstalled reports whether the cert-manager CRDs are served.
+func certManagerInstalled() bool {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return false
+	}
+	_, err = clientset.Discovery().ServerResourcesForGroupVersion("cert-manager.io/v1")
+	return err == nil
+}
+
+func getRouteIngress(namespace, name string) (*networkingv1.Ingress, error) {
+	if ingress == nil {
+		return nil, ErrIngressDisabled
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	ing, err := clientset.NetworkingV1().Ingresses(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && !isRouteObject(ing.ObjectMeta)) {
+		return nil, ErrRouteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route %s: %w", name, err)
+	}
+	return ing, nil
+}
+
+// hostnameInUse reports whether another route in the cluster has verified
+// hostname. Unverified claims do not block anyone.
+func hostnameInUse(namespace, name, hostname string) (bool, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return false, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.NetworkingV1().Ingresses(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/component=route,app.kubernetes.io/managed-by=paas-api",
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list routes: %w", err)
+	}
+	for _, ing := range list.Items {
+		if ing.Namespace == namespace && ing.Name == name {
+			continue
+		}
+		if ing.Annotations[routeHostnameAnnotation] == hostname && ing.Annotations[routeVerifiedAnnotation] != "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hostnameClaimName is the ConfigMap in the system namespace that reserves a
+// verified hostname for a single route. Hostnames are hashed to fit.
+func hostnameClaimName(hostname string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(hostname)))
+	return hostnameClaimPrefix + hex.EncodeToString(sum[:16])
+}
+
+// claimHostname reserves hostname for the route cluster wide. Only one
+// caller can create the claim, so two tenants verifying the same hostname at
+// once cannot both succeed. A claim left behind by a route that is gone, or
+// that did not finish verifying within hostnameClaimLease, is taken over.
+func claimHostname(namespace, name, hostname string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(SystemNamespace())
+	claim := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: hostnameClaimName(hostname),
+			Labels: map[string]string{
+				"app.kubernetes.io/component":  "hostname-claim",
+				"app.kubernetes.io/managed-by": "paas-api",
+			},
+		},
+		Data: map[string]string{
+			"hostname":   hostname,
+			"namespace":  namespace,
+			"route":      name,
+			"claimed_at": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	// One retry covers taking over a stale claim
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := configMaps.Create(context.TODO(), claim, metav1.CreateOptions{})
+		if err == nil {
+			return nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to claim hostname %s: %w", hostname, err)
+		}
+
+		existing, err := configMaps.Get(context.TODO(), claim.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get claim of hostname %s: %w", hostname, err)
+		}
+		if existing.Data["namespace"] == namespace && existing.Data["route"] == name {
+			return nil
+		}
+		held, err := hostnameClaimHeld(existing)
+		if err != nil {
+			return err
+		}
+		if held {
+			return ErrHostnameTaken
+		}
+		err = configMaps.Delete(context.TODO(), existing.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &existing.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+			return fmt.Errorf("failed to delete stale claim of hostname %s: %w", hostname, err)
+		}
+	}
+	return ErrHostnameTaken
+}
+
+// hostnameClaimHeld reports whether the route that made a claim still holds
+// its hostname.
+func hostnameClaimHeld(claim *corev1.ConfigMap) (bool, error) {
+	ing, err := getRouteIngress(claim.Data["namespace"], claim.Data["route"])
+	if errors.Is(err, ErrRouteNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ing.Annotations[routeHostnameAnnotation] != claim.Data["hostname"] {
+		return false, nil
+	}
+	if ing.Annotations[routeVerifiedAnnotation] != "" {
+		return true, nil
+	}
+	claimedAt, err := time.Parse(time.RFC3339, claim.Data["claimed_at"])
+	return err == nil && time.Since(claimedAt) < hostnameClaimLease, nil
+}
+
+// releaseHostname deletes the route's claim on hostname, if it holds one.
+func releaseHostname(namespace, name, hostname string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(SystemNamespace())
+	claim, err := configMaps.Get(context.TODO(), hostnameClaimName(hostname), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get claim of hostname %s: %w", hostname, err)
+	}
+	if claim.Data["namespace"] != namespace || claim.Data["route"] != name {
+		return nil
+	}
+	err = configMaps.Delete(context.TODO(), claim.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &claim.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return fmt.Errorf("failed to release hostname %s: %w", hostname, err)
+	}
+	return nil
+}
+
+// CreateRoute exposes a service of the tenant on its platform hostname. A
+// custom hostname is only served once VerifyRouteHostname succeeds.
+func CreateRoute(namespace, name string, spec RouteSpec) (*RouteInfo, error) {
+	if ingress == nil {
+		return nil, ErrIngressDisabled
+	}
+	spec.Default()
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	if _, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), spec.Service, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrServiceNotFound
+		}
+		return nil, fmt.Errorf("failed to get service %s: %w", spec.Service, err)
+	}
+	if spec.Hostname != "" {
+		taken, err := hostnameInUse(namespace, name, spec.Hostname)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, ErrHostnameTaken
+		}
+	}
+
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      routeLabels(name),
+			Annotations: map[string]string{},
+		},
+	}
+	if spec.Hostname != "" {
+		token, err := randomPassword(32)
+		if err != nil {
+			return nil, err
+		}
+		ing.Annotations[routeHostnameAnnotation] = spec.Hostname
+		ing.Annotations[routeTokenAnnotation] = token
+	}
+	setRouteRules(ing, spec, certManagerInstalled())
+
+	ing, err = clientset.NetworkingV1().Ingresses(namespace).Create(context.TODO(), ing, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil, ErrRouteExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create route %s: %w", name, err)
+	}
+	fmt.Printf("Created route %s/%s to service %s\n", namespace, name, spec.Service)
+	return describeRoute(ing), nil
+}
+
+// setRouteRules writes the rules of spec: the platform hostname always, the
+// custom hostname once verified. With cert-manager every host gets TLS from
+// the configured issuer.
+func setRouteRules(ing *networkingv1.Ingress, spec RouteSpec, tls bool) {
+	pathType := networkingv1.PathTypePrefix
+	value := networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+		Paths: []networkingv1.HTTPIngressPath{{
+			Path:     spec.Path,
+			PathType: &pathType,
+			Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+				Name: spec.Service,
+				Port: networkingv1.ServiceBackendPort{Number: int32(spec.Port)},
+			}},
+		}},
+	}}
+
+	hosts := []string{platformHost(ing.Namespace, ing.Name)}
+	if spec.Hostname != "" && ing.Annotations[routeVerifiedAnnotation] != "" {
+		hosts = append(hosts, spec.Hostname)
+	}
+	ing.Spec.Rules = nil
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: host, IngressRuleValue: value})
+	}
+	if ingress.class != "" {
+		class := ingress.class
+		ing.Spec.IngressClassName = &class
+	}
+
+	ing.Spec.TLS = nil
+	delete(ing.Annotations, certManagerIssuerKey)
+	if tls {
+		ing.Annotations[certManagerIssuerKey] = ingress.issuer
+		ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: hosts, SecretName: ing.Name + "-tls"}}
+	}
+}
+
+func specFromIngress(ing *networkingv1.Ingress) RouteSpec {
+	spec := RouteSpec{Hostname: ing.Annotations[routeHostnameAnnotation]}
+	if len(ing.Spec.Rules) == 0 || ing.Spec.Rules[0].HTTP == nil || len(ing.Spec.Rules[0].HTTP.Paths) == 0 {
+		return spec
+	}
+	path := ing.Spec.Rules[0].HTTP.Paths[0]
+	spec.Path = path.Path
+	if path.Backend.Service != nil {
+		spec.Service = path.Backend.Service.Name
+		spec.Port = int(path.Backend.Service.Port.Number)
+	}
+	return spec
+}
+
+func describeRoute(ing *networkingv1.Ingress) *RouteInfo {
+	spec := specFromIngress(ing)
+	route := &RouteInfo{
+		Name:      ing.Name,
+		Namespace: ing.Namespace,
+		Service:   spec.Service,
+		Port:      spec.Port,
+		Path:      spec.Path,
+		TLS:       len(ing.Spec.TLS) > 0,
+		CreatedAt: ing.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		Hostname:  spec.Hostname,
+	}
+	scheme := "http"
+	if route.TLS {
+		scheme = "https"
+	}
+	route.URL = fmt.Sprintf("%s://%s%s", scheme, platformHost(ing.Namespace, ing.Name), spec.Path)
+
+	if spec.Hostname != "" {
+		token := ing.Annotations[routeTokenAnnotation]
+		route.HostnameVerified = ing.Annotations[routeVerifiedAnnotation] != ""
+		route.Challenge = &DomainChallenge{
+			Token:      token,
+			TXTRecord:  DomainChallengePrefix + "." + spec.Hostname,
+			HTTPURL:    "http://" + spec.Hostname + DomainChallengePath + token,
+			VerifiedAt: ing.Annotations[routeVerifiedAnnotation],
+		}
+	}
+	return route
+}
+
+// GetRoute returns ErrRouteNotFound if there is no such route.
+func GetRoute(namespace, name string) (*RouteInfo, error) {
+	ing, err := getRouteIngress(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return describeRoute(ing), nil
+}
+
+func ListRoutes(namespace string) ([]RouteInfo, error) {
+	if ingress == nil {
+		return nil, ErrIngressDisabled
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.NetworkingV1().Ingresses(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/component=route,app.kubernetes.io/managed-by=paas-api",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+
+	routes := []RouteInfo{}
+	for i := range list.Items {
+		routes = append(routes, *describeRoute(&list.Items[i]))
+	}
+	return routes, nil
+}
+
+// VerifyRouteHostname checks the ownership challenge of the route's custom
+// hostname with method and, once it passes, adds the hostname to the Ingress.
+// A verified hostname stays verified.
+func VerifyRouteHostname(namespace, name, method string) (*RouteInfo, error) {
+	ing, err := getRouteIngress(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	spec := specFromIngress(ing)
+	if spec.Hostname == "" {
+		return nil, ErrNoCustomHostname
+	}
+	if ing.Annotations[routeVerifiedAnnotation] != "" {
+		return describeRoute(ing), nil
+	}
+
+	token := ing.Annotations[routeTokenAnnotation]
+	switch method {
+	case DomainChallengeTXT:
+		err = checkTXTChallenge(spec.Hostname, token)
+	case DomainChallengeHTTP:
+		err = checkHTTPChallenge(spec.Hostname, token)
+	default:
+		return nil, fmt.Errorf("unknown challenge method %q", method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Another tenant may be verifying the same hostname right now, or have
+	// verified it before hostnames were claimed
+	if err := claimHostname(namespace, name, spec.Hostname); err != nil {
+		return nil, err
+	}
+	taken, err := hostnameInUse(namespace, name, spec.Hostname)
+	if err == nil && taken {
+		err = ErrHostnameTaken
+	}
+	if err != nil {
+		return nil, errors.Join(err, releaseHostname(namespace, name, spec.Hostname))
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	ing.Annotations[routeVerifiedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	setRouteRules(ing, spec, certManagerInstalled())
+	ing, err = clientset.NetworkingV1().Ingresses(namespace).Update(context.TODO(), ing, metav1.UpdateOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to update route %s: %w", name, err)
+		return nil, errors.Join(err, releaseHostname(namespace, name, spec.Hostname))
+	}
+	fmt.Printf("Verified hostname %s of route %s/%s with the %s challenge\n", spec.Hostname, namespace, name, method)
+	return describeRoute(ing), nil
+}
+
+func checkTXTChallenge(hostname, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), domainChallengeTimeout)
+	defer cancel()
+	records, err := net.DefaultResolver.LookupTXT(ctx, DomainChallengePrefix+"."+hostname)
+	if err != nil {
+		return fmt.Errorf("%w: TXT lookup of %s.%s failed: %v", ErrDomainNotVerified, DomainChallengePrefix, hostname, err)
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == token {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no TXT record at %s.%s holds the token", ErrDomainNotVerified, DomainChallengePrefix, hostname)
+}
+
+// internalSuffixes are name suffixes that never resolve on the public internet.
+var internalSuffixes = []string{".local", ".localhost", ".internal", ".svc", ".cluster.local", ".home.arpa", ".in-addr.arpa", ".ip6.arpa"}
+
+func isInternalHostname(hostname string) bool {
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(hostname, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicAddressOnly refuses connections to addresses inside the cluster or
+// the node's networks. It runs after name resolution, so a hostname that
+// resolves to a private address is refused too.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("address %s is not public", host)
+	}
+	return nil
+}
+
+// checkHTTPChallenge fetches the token from the hostname. Only public
+// addresses are contacted and redirects are not followed, so a tenant cannot
+// make the API call services inside the cluster.
+func checkHTTPChallenge(hostname, token string) error {
+	if net.ParseIP(hostname) != nil || isInternalHostname(hostname) {
+		return fmt.Errorf("%w: %s is not a public domain name", ErrDomainNotVerified, hostname)
+	}
+	dialer := &net.Dialer{Timeout: domainChallengeTimeout, Control: publicAddressOnly}
+	client := &http.Client{
+		Timeout:   domainChallengeTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	url := "http://" + hostname + DomainChallengePath + token
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("%w: GET %s failed: %v", ErrDomainNotVerified, url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return fmt.Errorf("%w: reading %s failed: %v", ErrDomainNotVerified, url, err)
+	}
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != token {
+		return fmt.Errorf("%w: %s did not return the token", ErrDomainNotVerified, url)
+	}
+	return nil
+}
+
+// DeleteRoute removes the Ingress. The TLS secret issued for it is left for
+// cert-manager to reuse if the route is created again.
+func DeleteRoute(namespace, name string) error {
+	ing, err := getRouteIngress(namespace, name)
+	if err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	if err := clientset.NetworkingV1().Ingresses(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete route %s: %w", name, err)
+	}
+	if hostname := ing.Annotations[routeHostnameAnnotation]; hostname != "" {
+		if err := releaseHostname(namespace, name, hostname); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Deleted route %s/%s\n", namespace, name)
+	return nil
+}
@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// useRoutes enables routes and returns a function creating a route with a
+// custom hostname, as CreateRoute leaves it before verification.
+func useRoutes(t *testing.T, kube *fake.Clientset) func(namespace, name, hostname string) {
+	t.Helper()
+	if err := ConfigureIngress("apps.example.com", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ingress = nil })
+
+	return func(namespace, name, hostname string) {
+		t.Helper()
+		ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      routeLabels(name),
+			Annotations: map[string]string{routeHostnameAnnotation: hostname},
+		}}
+		if _, err := kube.NetworkingV1().Ingresses(namespace).Create(context.Background(), ing, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClaimHostnameConcurrently(t *testing.T) {
+	kube := useFakeKube(t)
+	createRoute := useRoutes(t, kube)
+
+	const tenants = 8
+	for i := 0; i < tenants; i++ {
+		createRoute(fmt.Sprintf("tenant-%d", i), "web", "shop.example.org")
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, tenants)
+	for i := 0; i < tenants; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = claimHostname(fmt.Sprintf("tenant-%d", i), "web", "shop.example.org")
+		}(i)
+	}
+	wg.Wait()
+
+	claimed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			claimed++
+		case !errors.Is(err, ErrHostnameTaken):
+			t.Errorf("claimHostname() error = %v", err)
+		}
+	}
+	if claimed != 1 {
+		t.Errorf("%d tenants claimed the hostname, want 1", claimed)
+	}
+}
+
+func TestClaimHostnameTakesOverStaleClaims(t *testing.T) {
+	kube := useFakeKube(t)
+	createRoute := useRoutes(t, kube)
+	createRoute("tenant-a", "web", "shop.example.org")
+	createRoute("tenant-b", "web", "shop.example.org")
+
+	if err := claimHostname("tenant-a", "web", "shop.example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := claimHostname("tenant-a", "web", "shop.example.org"); err != nil {
+		t.Errorf("claiming again = %v, want the holder to keep its claim", err)
+	}
+	if err := claimHostname("tenant-b", "web", "shop.example.org"); !errors.Is(err, ErrHostnameTaken) {
+		t.Errorf("claim during the lease = %v, want ErrHostnameTaken", err)
+	}
+
+	// tenant-a never finished verifying
+	configMaps := kube.CoreV1().ConfigMaps(SystemNamespace())
+	claim, err := configMaps.Get(context.Background(), hostnameClaimName("shop.example.org"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim.Data["claimed_at"] = time.Now().Add(-hostnameClaimLease - time.Second).UTC().Format(time.RFC3339)
+	if _, err := configMaps.Update(context.Background(), claim, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := claimHostname("tenant-b", "web", "shop.example.org"); err != nil {
+		t.Fatalf("claim after the lease = %v", err)
+	}
+
+	// Deleting tenant-b's route releases the hostname
+	if err := DeleteRoute("tenant-b", "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := claimHostname("tenant-a", "web", "shop.example.org"); err != nil {
+		t.Errorf("claim after the holder was deleted = %v", err)
+	}
+}
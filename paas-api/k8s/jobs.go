@@ -0,0 +1,505 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// MaxJobNameLength leaves room for the suffix the CronJob controller adds to job names.
+	MaxJobNameLength = 52
+
+	DefaultJobPlan = "dev"
+	// DefaultJobTimeout stops runs that hang, e.g. on a lock.
+	DefaultJobTimeout = time.Hour
+
+	// Finished jobs are kept this long so the last run's status and logs can be read.
+	jobHistoryLimit = 3
+	jobTTLSeconds   = 7 * 24 * 60 * 60
+
+	jobSpecAnnotation   = "paas.cloudtrack.io/job-spec"
+	manualRunAnnotation = "cronjob.kubernetes.io/instantiate"
+	jobNameLabel        = "paas.cloudtrack.io/job"
+)
+
+// Run states reported in JobRun.Status.
+const (
+	JobRunRunning   = "Running"
+	JobRunSucceeded = "Succeeded"
+	JobRunFailed    = "Failed"
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobExists      = errors.New("job already exists")
+	ErrJobRunNotFound = errors.New("job run not found")
+	ErrJobRunning     = errors.New("job is still running")
+
+	cronMacros = map[string]bool{
+		"@yearly": true, "@annually": true, "@monthly": true, "@weekly": true,
+		"@daily": true, "@midnight": true, "@hourly": true,
+	}
+	cronField = regexp.MustCompile(`^(\*|\?|[0-9A-Za-z]+(-[0-9A-Za-z]+)?)(/[0-9]+)?(,(\*|[0-9A-Za-z]+(-[0-9A-Za-z]+)?)(/[0-9]+)?)*$`)
+)
+
+// DefaultJobSpec fills unset fields with the platform defaults.
+func DefaultJobSpec(s *JobSpec) {
+	if s.Plan == "" {
+		s.Plan = DefaultJobPlan
+	}
+	s.Schedule = strings.Join(strings.Fields(s.Schedule), " ")
+}
+
+// ValidateJobSpec returns problems keyed by field. It expects a defaulted spec.
+func ValidateJobSpec(s JobSpec) map[string][]string {
+	problems := map[string][]string{}
+	if p := validateSchedule(s.Schedule); len(p) > 0 {
+		problems["schedule"] = p
+	}
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			problems["time_zone"] = []string{"must be an IANA time zone such as Europe/Berlin"}
+		}
+	}
+	if p := validateImage(s.Image); len(p) > 0 {
+		problems["image"] = p
+	}
+	if len(s.Command) == 0 {
+		problems["command"] = []string{"must not be empty"}
+	}
+	if p := ValidatePlan(s.Plan); len(p) > 0 {
+		problems["plan"] = p
+	}
+	validateWorkloadEnv(problems, s.Env, s.Database)
+	return problems
+}
+
+// validateSchedule accepts a five-field cron expression or a macro such as
+// @daily. The API server checks the values of each field.
+func validateSchedule(schedule string) []string {
+	if schedule == "" {
+		return []string{"must not be empty"}
+	}
+	if strings.HasPrefix(schedule, "@") {
+		if cronMacros[schedule] {
+			return nil
+		}
+		return []string{fmt.Sprintf("unknown macro %s", schedule)}
+	}
+	if strings.Contains(schedule, "TZ=") {
+		return []string{"must not set a time zone, use time_zone"}
+	}
+	return validateCronFields(schedule)
+}
+
+// validateCronFields accepts exactly five cron fields.
+func validateCronFields(schedule string) []string {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return []string{"must have five fields: minute hour day-of-month month day-of-week"}
+	}
+	for _, field := range fields {
+		if !cronField.MatchString(field) {
+			return []string{fmt.Sprintf("invalid field %q", field)}
+		}
+	}
+	return nil
+}
+
+// ValidateJobName checks a job name against DNS-1123 label rules and the
+// CronJob name limit.
+func ValidateJobName(name string) []string {
+	if name == "" {
+		return []string{"must not be empty"}
+	}
+	problems := validation.IsDNS1123Label(name)
+	if len(name) > MaxJobNameLength {
+		problems = append(problems, fmt.Sprintf("must be no more than %d characters", MaxJobNameLength))
+	}
+	return problems
+}
+
+func jobLabels(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/component":  "job",
+		"app.kubernetes.io/managed-by": "paas-api",
+		jobNameLabel:                   name,
+	}
+}
+
+func isJobObject(meta metav1.ObjectMeta) bool {
+	return meta.Labels["app.kubernetes.io/component"] == "job" && meta.Labels["app.kubernetes.io/managed-by"] == "paas-api"
+}
+
+func getCronJob(namespace, name string) (*batchv1.CronJob, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	cronJob, err := clientset.BatchV1().CronJobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && !isJobObject(cronJob.ObjectMeta)) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job %s: %w", name, err)
+	}
+	return cronJob, nil
+}
+
+// jobTemplate renders the Job every run of spec is created from. A run that
+// fails is not retried, so a maintenance script does not run twice.
+func jobTemplate(namespace, name string, spec JobSpec) (*batchv1.JobTemplateSpec, error) {
+	resources, err := planResources(spec.Plan)
+	if err != nil {
+		return nil, err
+	}
+	env, err := workloadEnv(namespace, spec.Env, spec.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	backoffLimit := int32(0)
+	deadline := int64(DefaultJobTimeout.Seconds())
+	ttl := int32(jobTTLSeconds)
+	return &batchv1.JobTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: jobLabels(name)},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: jobLabels(name)},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:      "job",
+						Image:     spec.Image,
+						Command:   spec.Command,
+						Env:       env,
+						Resources: resources,
+					}},
+				},
+			},
+		},
+	}, nil
+}
+
+// CreateJob creates the CronJob of a scheduled job. A bound database must
+// exist in the namespace.
+func CreateJob(namespace, name, owner string, spec JobSpec) (*JobInfo, error) {
+	DefaultJobSpec(&spec)
+
+	_, err := getCronJob(namespace, name)
+	if err == nil {
+		return nil, ErrJobExists
+	}
+	if !errors.Is(err, ErrJobNotFound) {
+		return nil, err
+	}
+	if err := EnsureTenantNamespace(namespace, owner); err != nil {
+		return nil, err
+	}
+
+	template, err := jobTemplate(namespace, name, spec)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spec of job %s: %w", name, err)
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	history := int32(jobHistoryLimit)
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      jobLabels(name),
+			Annotations: map[string]string{jobSpecAnnotation: string(encoded)},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   spec.Schedule,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &history,
+			FailedJobsHistoryLimit:     &history,
+			JobTemplate:                *template,
+		},
+	}
+	if spec.TimeZone != "" {
+		cronJob.Spec.TimeZone = &spec.TimeZone
+	}
+
+	cronJob, err = clientset.BatchV1().CronJobs(namespace).Create(context.TODO(), cronJob, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil, ErrJobExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job %s: %w", name, err)
+	}
+	fmt.Printf("Created job %s/%s on schedule %q\n", namespace, name, spec.Schedule)
+	return describeJob(cronJob, nil), nil
+}
+
+// listJobRuns returns the runs of a job, newest first.
+func listJobRuns(namespace, name string) ([]batchv1.Job, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(jobLabels(name)).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs of job %s: %w", name, err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[j].CreationTimestamp.Before(&list.Items[i].CreationTimestamp)
+	})
+	return list.Items, nil
+}
+
+func describeJobRun(job *batchv1.Job) *JobRun {
+	run := &JobRun{
+		Name:   job.Name,
+		Status: JobRunRunning,
+		Manual: job.Annotations[manualRunAnnotation] == "manual",
+	}
+	if job.Status.StartTime != nil {
+		run.StartedAt = job.Status.StartTime.Format("2006-01-02 15:04:05")
+	}
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			run.Status = JobRunSucceeded
+			run.CompletedAt = cond.LastTransitionTime.Format("2006-01-02 15:04:05")
+		case batchv1.JobFailed:
+			run.Status = JobRunFailed
+			run.Message = cond.Message
+			run.CompletedAt = cond.LastTransitionTime.Format("2006-01-02 15:04:05")
+		}
+	}
+	return run
+}
+
+func describeJob(cronJob *batchv1.CronJob, runs []batchv1.Job) *JobInfo {
+	var spec JobSpec
+	_ = json.Unmarshal([]byte(cronJob.Annotations[jobSpecAnnotation]), &spec)
+	job := &JobInfo{
+		Name:      cronJob.Name,
+		Namespace: cronJob.Namespace,
+		Spec:      spec,
+		Suspended: cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend,
+		CreatedAt: cronJob.CreationTimestamp.Format("2006-01-02 15:04:05"),
+	}
+	if t := cronJob.Status.LastScheduleTime; t != nil {
+		job.LastScheduleTime = t.Format("2006-01-02 15:04:05")
+	}
+	if t := cronJob.Status.LastSuccessfulTime; t != nil {
+		job.LastSuccessfulTime = t.Format("2006-01-02 15:04:05")
+	}
+	if len(runs) > 0 {
+		job.LastRun = describeJobRun(&runs[0])
+	}
+	return job
+}
+
+// GetJob returns ErrJobNotFound if there is no such job.
+func GetJob(namespace, name string) (*JobInfo, error) {
+	cronJob, err := getCronJob(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := listJobRuns(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return describeJob(cronJob, runs), nil
+}
+
+func listCronJobs(namespace string) ([]batchv1.CronJob, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.BatchV1().CronJobs(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/component=job,app.kubernetes.io/managed-by=paas-api",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+func ListJobs(namespace string) ([]JobInfo, error) {
+	cronJobs, err := listCronJobs(namespace)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []JobInfo{}
+	for i := range cronJobs {
+		runs, err := listJobRuns(namespace, cronJobs[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *describeJob(&cronJobs[i], runs))
+	}
+	return jobs, nil
+}
+
+// TriggerJob starts a run of the job now, outside its schedule. Like the
+// schedule it refuses to start while a run is still going.
+func TriggerJob(namespace, name string) (*JobRun, error) {
+	cronJob, err := getCronJob(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := listJobRuns(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > 0 && describeJobRun(&runs[0]).Status == JobRunRunning {
+		return nil, fmt.Errorf("%w: run %s has not finished", ErrJobRunning, runs[0].Name)
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	runName := fmt.Sprintf("%s-%d", name, time.Now().Unix())
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        runName,
+			Namespace:   namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: map[string]string{manualRunAnnotation: "manual"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+	job, err = clientset.BatchV1().Jobs(namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start job %s: %w", name, err)
+	}
+	fmt.Printf("Triggered run %s of job %s/%s\n", runName, namespace, name)
+	return describeJobRun(job), nil
+}
+
+// GetJobLogs returns the run and the last tailLines of its output. An empty
+// run means the latest one.
+func GetJobLogs(namespace, name, run string, tailLines int64) (*JobRun, string, error) {
+	if _, err := getCronJob(namespace, name); err != nil {
+		return nil, "", err
+	}
+	runs, err := listJobRuns(namespace, name)
+	if err != nil {
+		return nil, "", err
+	}
+	var target *batchv1.Job
+	for i := range runs {
+		if run == "" || runs[i].Name == run {
+			target = &runs[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, "", ErrJobRunNotFound
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "job-name=" + target.Name,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list pods of run %s: %w", target.Name, err)
+	}
+	if len(pods.Items) == 0 {
+		// Not scheduled yet, or the pod was cleaned up
+		return describeJobRun(target), "", nil
+	}
+
+	if tailLines <= 0 {
+		tailLines = DefaultLogLines
+	}
+	raw, err := clientset.CoreV1().Pods(namespace).GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{
+		Container: "job",
+		TailLines: &tailLines,
+	}).Do(context.TODO()).Raw()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get logs of run %s: %w", target.Name, err)
+	}
+	return describeJobRun(target), string(raw), nil
+}
+
+// DeleteJob removes the CronJob; its runs and their pods are garbage collected.
+func DeleteJob(namespace, name string) error {
+	if _, err := getCronJob(namespace, name); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = clientset.BatchV1().CronJobs(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete job %s: %w", name, err)
+	}
+	fmt.Printf("Deleted job %s/%s\n", namespace, name)
+	return nil
+}
+
+// setJobsPaused suspends every job schedule in the namespace as part of a
+// tenant suspension. Runs already started finish.
+func setJobsPaused(namespace string, paused bool) (int, error) {
+	cronJobs, err := listCronJobs(namespace)
+	if err != nil {
+		return 0, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	for i := range cronJobs {
+		cronJob := &cronJobs[i]
+		if cronJob.Annotations == nil {
+			cronJob.Annotations = map[string]string{}
+		}
+		if paused {
+			cronJob.Annotations[PausedAnnotation] = "true"
+		} else {
+			delete(cronJob.Annotations, PausedAnnotation)
+		}
+		cronJob.Spec.Suspend = &paused
+		if _, err := clientset.BatchV1().CronJobs(namespace).Update(context.TODO(), cronJob, metav1.UpdateOptions{}); err != nil {
+			return 0, fmt.Errorf("failed to update job %s: %w", cronJob.Name, err)
+		}
+	}
+	return len(cronJobs), nil
+}
@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// MetricsUnavailable is reported for usage fields when metrics-server is not installed
+// or has no sample for a pod yet.
+const MetricsUnavailable = "unavailable"
+
+// podUsage is the summed CPU and memory usage of all containers in a pod.
+type podUsage struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+// podMetricsList mirrors metrics.k8s.io/v1beta1 PodMetricsList. We only read the
+// usage, so the API is queried raw instead of pulling in k8s.io/metrics.
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Containers []struct {
+			Name  string                       `json:"name"`
+			Usage map[string]resource.Quantity `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// getPodUsage returns usage keyed by "namespace/name". An empty namespace queries
+// all namespaces. If metrics-server is absent, it returns a nil map and no error,
+// so callers can report MetricsUnavailable instead of failing the request.
+func getPodUsage(clientset kubernetes.Interface, namespace string) map[string]podUsage {
+	path := "/apis/metrics.k8s.io/v1beta1/pods"
+	if namespace != "" {
+		path = fmt.Sprintf("/apis/metrics.k8s.io/v1beta1/namespaces/%s/pods", namespace)
+	}
+
+	// Fake clientsets have no REST client to query the metrics API with
+	client := clientset.Discovery().RESTClient()
+	if client == nil {
+		return nil
+	}
+
+	raw, err := client.Get().AbsPath(path).DoRaw(context.TODO())
+	if err != nil {
+		fmt.Printf("Pod metrics not available (is metrics-server installed?): %v\n", err)
+		return nil
+	}
+
+	var list podMetricsList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		fmt.Printf("Failed to parse pod metrics: %v\n", err)
+		return nil
+	}
+
+	usage := make(map[string]podUsage, len(list.Items))
+	for _, item := range list.Items {
+		var u podUsage
+		for _, c := range item.Containers {
+			if q, ok := c.Usage[string(corev1.ResourceCPU)]; ok {
+				u.CPU.Add(q)
+			}
+			if q, ok := c.Usage[string(corev1.ResourceMemory)]; ok {
+				u.Memory.Add(q)
+			}
+		}
+		usage[item.Metadata.Namespace+"/"+item.Metadata.Name] = u
+	}
+	return usage
+}
+
+// sumContainerResources adds up a resource list across all containers of a pod.
+func sumContainerResources(pod corev1.Pod, pick func(corev1.ResourceRequirements) corev1.ResourceList) (cpu, mem string) {
+	var cpuTotal, memTotal resource.Quantity
+	var hasCPU, hasMem bool
+	for _, c := range pod.Spec.Containers {
+		list := pick(c.Resources)
+		if q, ok := list[corev1.ResourceCPU]; ok {
+			cpuTotal.Add(q)
+			hasCPU = true
+		}
+		if q, ok := list[corev1.ResourceMemory]; ok {
+			memTotal.Add(q)
+			hasMem = true
+		}
+	}
+
+	cpu, mem = "N/A", "N/A"
+	if hasCPU {
+		cpu = cpuTotal.String()
+	}
+	if hasMem {
+		mem = memTotal.String()
+	}
+	return cpu, mem
+}
+
+// newPodInfo builds the PodInfo returned by both the tenant and admin pod views.
+func newPodInfo(pod corev1.Pod, usage map[string]podUsage) PodInfo {
+	var restarts int32
+	for _, cs := range pod.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+	}
+
+	info := PodInfo{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Status:    string(pod.Status.Phase),
+		Age:       time.Since(pod.CreationTimestamp.Time).Round(time.Second).String(),
+		Node:      pod.Spec.NodeName,
+		Restarts:  restarts,
+		CPU:       MetricsUnavailable,
+		Memory:    MetricsUnavailable,
+	}
+
+	if u, ok := usage[pod.Namespace+"/"+pod.Name]; ok {
+		info.CPU = u.CPU.String()
+		info.Memory = u.Memory.String()
+	}
+
+	info.CPURequest, info.MemoryRequest = sumContainerResources(pod, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Requests })
+	info.CPULimit, info.MemoryLimit = sumContainerResources(pod, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Limits })
+
+	return info
+}
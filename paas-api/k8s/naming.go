@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	// TenantNamespacePrefix is prepended to every tenant identity. It is set
+	// once at startup by Configure.
+	TenantNamespacePrefix = "tenant-"
+
+	// MaxTenantNameLength keeps "<prefix><name>" within a 63 character DNS label.
+	MaxTenantNameLength = validation.DNS1123LabelMaxLength - len(TenantNamespacePrefix)
+)
+
+const (
+	// MaxDBNameLength leaves room for the suffixes the operator adds to the
+	// cluster name on StatefulSet pods, services and controller-revision-hash labels.
+	MaxDBNameLength = 52
+
+	zalandoSecretSuffix = ".credentials.postgresql.acid.zalan.do"
+	slugHashLength      = 8
+)
+
+var (
+	invalidSlugChars = regexp.MustCompile(`[^a-z0-9-]+`)
+	// hashedSlug matches the output of TenantSlug for non-canonical identities.
+	hashedSlug = regexp.MustCompile(`(^|-)[0-9a-f]{8}$`)
+
+	// reservedTenantNames collide with the static /admin/tenants/... routes.
+	reservedTenantNames = map[string]bool{"pods": true}
+)
+
+// TenantSlug maps any identity (username, email, IdP subject) to a DNS-1123
+// label of at most MaxTenantNameLength characters.
+//
+// Identities that already are valid labels are returned unchanged, unless
+// they are reserved or end in something that looks like a slug hash.
+// Anything else is lowercased, has invalid characters replaced by '-', and
+// gets a short hash of the original identity appended, so "My_User" and
+// "my-user" never map to the same tenant, and no identity can claim the slug
+// of another. The mapping is deterministic.
+func TenantSlug(identity string) string {
+	if IsCanonicalTenantName(identity) {
+		return identity
+	}
+
+	sum := sha256.Sum256([]byte(identity))
+	suffix := hex.EncodeToString(sum[:])[:slugHashLength]
+
+	slug := invalidSlugChars.ReplaceAllString(strings.ToLower(identity), "-")
+	slug = strings.Trim(slug, "-")
+
+	maxBase := MaxTenantNameLength - slugHashLength - 1
+	if len(slug) > maxBase {
+		slug = strings.TrimRight(slug[:maxBase], "-")
+	}
+	if slug == "" {
+		return suffix
+	}
+	return slug + "-" + suffix
+}
+
+// IsCanonicalTenantName reports whether TenantSlug returns name unchanged.
+func IsCanonicalTenantName(name string) bool {
+	return len(name) <= MaxTenantNameLength && len(validation.IsDNS1123Label(name)) == 0 &&
+		!hashedSlug.MatchString(name) && !reservedTenantNames[name]
+}
+
+// TenantNamespace returns the namespace holding a tenant's resources.
+func TenantNamespace(identity string) string {
+	return TenantNamespacePrefix + TenantSlug(identity)
+}
+
+// ValidateDBName checks a database name against DNS-1123 label rules and the
+// length limits of every object name derived from it. It returns one message
+// per problem, or nil when the name is valid.
+func ValidateDBName(dbName string) []string {
+	var problems []string
+	if dbName == "" {
+		return []string{"must not be empty"}
+	}
+
+	problems = append(problems, validation.IsDNS1123Label(dbName)...)
+	if len(dbName) > MaxDBNameLength {
+		problems = append(problems, fmt.Sprintf("must be no more than %d characters", MaxDBNameLength))
+	}
+
+	secretName := dbName + "." + dbName + zalandoSecretSuffix
+	if errs := validation.IsDNS1123Subdomain(secretName); len(errs) > 0 {
+		problems = append(problems, fmt.Sprintf("generated secret name %q is invalid: %s", secretName, strings.Join(errs, "; ")))
+	}
+	return problems
+}
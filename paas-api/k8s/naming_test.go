@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The slugs name existing namespaces, so every case here pins a mapping that
+// must never change.
+func TestTenantSlug(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+
+	tests := []struct {
+		name     string
+		identity string
+		want     string
+	}{
+		{"valid label unchanged", "alice", "alice"},
+		{"dashes unchanged", "my-user", "my-user"},
+		{"uppercase hashed", "Alice", "alice-3bc51062"},
+		{"underscore hashed apart from dash", "my_user", "my-user-acf56419"},
+		{"email", "alice@example.com", "alice-example-com-ff8d9819"},
+		{"dots and case", "Bob.Smith@Example.com", "bob-smith-example-com-a1a3f1c1"},
+		{"non ascii", "Ünïcode", "n-code-00b24be8"},
+		{"leading and trailing dashes trimmed", "-alice-", "alice-8bbe7cdb"},
+		{"nothing valid left", "___", "bda25155"},
+		{"reserved route name", "pods", "pods-049c287e"},
+		{"looks like a hashed slug", "alice-deadbeef", "alice-deadbeef-c0cd9e4d"},
+		{"looks like a bare hash", "deadbeef", "deadbeef-2baf1f40"},
+		{"longest valid label", a(MaxTenantNameLength), a(MaxTenantNameLength)},
+		{"too long", a(MaxTenantNameLength + 1), a(47) + "-f13b2d72"},
+		{"truncated before a dash", a(46) + "-bbbb_", a(46) + "-d360f429"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TenantSlug(tt.identity)
+			if got != tt.want {
+				t.Errorf("TenantSlug(%q) = %q, want %q", tt.identity, got, tt.want)
+			}
+			if len(got) > MaxTenantNameLength || len(validation.IsDNS1123Label(got)) > 0 {
+				t.Errorf("TenantSlug(%q) = %q is not a valid tenant name", tt.identity, got)
+			}
+			if ns := TenantNamespace(tt.identity); ns != TenantNamespacePrefix+got || len(validation.IsDNS1123Label(ns)) > 0 {
+				t.Errorf("TenantNamespace(%q) = %q", tt.identity, ns)
+			}
+		})
+	}
+}
+
+func TestIsCanonicalTenantName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"alice", true},
+		{"alice-2", true},
+		{"a1b2c3d4e", true},
+		{"", false},
+		{"Alice", false},
+		{"-alice", false},
+		{"pods", false},
+		{"0badf00d", false},
+		{"alice-0badf00d", false},
+		{strings.Repeat("a", MaxTenantNameLength), true},
+		{strings.Repeat("a", MaxTenantNameLength+1), false},
+	}
+	for _, tt := range tests {
+		if got := IsCanonicalTenantName(tt.name); got != tt.want {
+			t.Errorf("IsCanonicalTenantName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDBName(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbName string
+		want   []string
+	}{
+		{name: "valid", dbName: "orders"},
+		{name: "longest", dbName: strings.Repeat("a", MaxDBNameLength)},
+		{name: "empty", dbName: "", want: []string{"must not be empty"}},
+		{name: "too long", dbName: strings.Repeat("a", MaxDBNameLength+1), want: []string{"must be no more than 52 characters"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateDBName(tt.dbName); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateDBName(%q) = %q, want %q", tt.dbName, got, tt.want)
+			}
+		})
+	}
+
+	// Names that are not DNS labels fail on the label rules alone
+	for _, dbName := range []string{"Orders", "my_db", "-orders", "orders-"} {
+		if got := ValidateDBName(dbName); len(got) == 0 {
+			t.Errorf("ValidateDBName(%q) accepted an invalid label", dbName)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DefaultLogLines is how much of a pod log GetDatabaseLogs returns by default.
+const DefaultLogLines = 200
+
+var (
+	// ErrDatabaseNotFound is returned when no provider has a cluster with the requested name.
+	ErrDatabaseNotFound = errors.New("database not found")
+
+	// ErrBackupPending is returned by TriggerBackup right after logical backups
+	// were enabled, before the operator has created the backup CronJob.
+	ErrBackupPending = errors.New("logical backups are being enabled, retry in a minute")
+)
+
+// GetDatabaseLogs returns the last tailLines lines of the postgres container log
+// of one of the cluster's pods. With pod empty the current primary is used.
+func GetDatabaseLogs(namespace, dbName, pod string, tailLines int64) (string, string, error) {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return "", "", err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(provider.PodLabels(dbName)).String(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return "", "", ErrDatabaseNotFound
+	}
+
+	target := ""
+	for _, p := range pods.Items {
+		if (pod != "" && p.Name == pod) || (pod == "" && provider.IsPrimary(p)) {
+			target = p.Name
+			break
+		}
+	}
+	if target == "" {
+		if pod != "" {
+			return "", "", fmt.Errorf("pod %s is not part of database %s", pod, dbName)
+		}
+		// No leader elected yet, fall back to any member
+		target = pods.Items[0].Name
+	}
+
+	if tailLines <= 0 {
+		tailLines = DefaultLogLines
+	}
+	raw, err := clientset.CoreV1().Pods(namespace).GetLogs(target, &corev1.PodLogOptions{
+		Container: "postgres",
+		TailLines: &tailLines,
+	}).DoRaw(context.TODO())
+	if err != nil {
+		return target, "", fmt.Errorf("failed to read logs of pod %s: %w", target, err)
+	}
+	return target, string(raw), nil
+}
+
+// TriggerBackup starts an on-demand backup and returns the name of the Job or
+// backup resource. Zalando clusters without logical backups get them switched
+// on first and ErrBackupPending is returned.
+func TriggerBackup(namespace, dbName string) (string, error) {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return "", err
+	}
+	return provider.Backup(namespace, dbName)
+}
+
+// ScaleDatabase sets the number of instances of a cluster. Clusters
+// managed by a TenantDatabase are scaled through its spec so the controller
+// does not revert the change.
+func ScaleDatabase(namespace, dbName string, replicas int) error {
+	if tenantDatabasesEnabled {
+		td, err := GetTenantDatabase(namespace, dbName)
+		if err == nil {
+			spec := td.Spec
+			spec.Default()
+			spec.Replicas = replicas
+			if p := ValidateReplicas(spec); p != nil {
+				return fmt.Errorf("%w: replicas %s", ErrNotSupported, p[0])
+			}
+			return ScaleTenantDatabase(namespace, dbName, replicas)
+		}
+		if !errors.Is(err, ErrDatabaseNotFound) {
+			return err
+		}
+	}
+
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	return provider.Scale(namespace, dbName, replicas)
+}
@@ -0,0 +1,212 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	orgLabel           = "paas.cloudtrack.io/org"
+	orgConfigMapPrefix = "org-"
+)
+
+// ErrOrgNotFound is returned by UpdateOrg when the organization does not exist.
+var ErrOrgNotFound = errors.New("organization not found")
+
+// DefaultTeam is the Zalando teamId used for namespaces without an organization.
+var DefaultTeam = "paas-team"
+
+func orgConfigMap(org *Organization) (*corev1.ConfigMap, error) {
+	data, err := json.Marshal(org)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      orgConfigMapPrefix + org.Name,
+			Namespace: SystemNamespace(),
+			Labels:    map[string]string{orgLabel: org.Name},
+		},
+		Data: map[string]string{"organization.json": string(data)},
+	}, nil
+}
+
+func orgFromConfigMap(cm corev1.ConfigMap) (*Organization, error) {
+	var org Organization
+	if err := json.Unmarshal([]byte(cm.Data["organization.json"]), &org); err != nil {
+		return nil, fmt.Errorf("failed to parse organization %s: %w", cm.Name, err)
+	}
+	return &org, nil
+}
+
+// CreateOrg stores the organization and creates its tenant namespace,
+// labelled so databases in it are provisioned with the org as Zalando team.
+func CreateOrg(org *Organization) error {
+	if err := ensureSystemNamespace(); err != nil {
+		return err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	owner := ""
+	if len(org.Members) > 0 {
+		owner = org.Members[0].Subject
+	}
+
+	// Never adopt a namespace that already belongs to someone else
+	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), org.Namespace, metav1.GetOptions{})
+	if err == nil && ns.Annotations[TenantOwnerAnnotation] != owner {
+		return fmt.Errorf("namespace %s is already owned by another tenant", org.Namespace)
+	}
+
+	cm, err := orgConfigMap(org)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().ConfigMaps(SystemNamespace()).Create(context.TODO(), cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("organization %s already exists", org.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store organization: %w", err)
+	}
+
+	if err := EnsureTenantNamespace(org.Namespace, owner); err != nil {
+		return err
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, orgLabel, org.Name)
+	_, err = clientset.CoreV1().Namespaces().Patch(context.TODO(), org.Namespace, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to label namespace %s with organization: %w", org.Namespace, err)
+	}
+	return nil
+}
+
+// GetOrg returns nil and no error when the organization does not exist.
+func GetOrg(name string) (*Organization, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps(SystemNamespace()).Get(context.TODO(), orgConfigMapPrefix+name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization %s: %w", name, err)
+	}
+	return orgFromConfigMap(*cm)
+}
+
+// UpdateOrg applies mutate to the stored organization and saves it. When the
+// organization changed in between, mutate is applied again to a fresh copy,
+// so concurrent edits are not lost. Errors returned by mutate are passed
+// through unchanged.
+func UpdateOrg(name string, mutate func(*Organization) error) (*Organization, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(SystemNamespace())
+
+	var org *Organization
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := configMaps.Get(context.TODO(), orgConfigMapPrefix+name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return ErrOrgNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if org, err = orgFromConfigMap(*current); err != nil {
+			return err
+		}
+		if err := mutate(org); err != nil {
+			return err
+		}
+
+		cm, err := orgConfigMap(org)
+		if err != nil {
+			return err
+		}
+		cm.ResourceVersion = current.ResourceVersion
+		_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return nil, fmt.Errorf("failed to update organization %s: %w", name, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListOrgsForMember returns every organization the subject belongs to.
+func ListOrgsForMember(subject string) ([]Organization, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	cms, err := clientset.CoreV1().ConfigMaps(SystemNamespace()).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: orgLabel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	orgs := []Organization{}
+	for _, cm := range cms.Items {
+		org, err := orgFromConfigMap(cm)
+		if err != nil {
+			fmt.Printf("Skipping organization: %v\n", err)
+			continue
+		}
+		if org.Member(subject) != nil {
+			orgs = append(orgs, *org)
+		}
+	}
+	return orgs, nil
+}
+
+// OrgMemberRole returns the role the subject holds in the organization owning
+// the tenant, or "" if the tenant is not an organization or the subject is not a member.
+func OrgMemberRole(tenant, subject string) (string, error) {
+	org, err := GetOrg(tenant)
+	if err != nil || org == nil {
+		return "", err
+	}
+	if m := org.Member(subject); m != nil {
+		return m.Role, nil
+	}
+	return "", nil
+}
+
+// NamespaceTeam returns the Zalando teamId for databases in a namespace:
+// the owning organization, or DefaultTeam.
+func NamespaceTeam(namespace string) string {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return DefaultTeam
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err != nil || ns.Labels[orgLabel] == "" {
+		return DefaultTeam
+	}
+	return ns.Labels[orgLabel]
+}
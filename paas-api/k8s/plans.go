@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DatabasePlan sizes the pods and volume of every instance of a cluster and
+// picks the provider that runs it.
+type DatabasePlan struct {
+	Name          string `json:"name"`
+	Provider      string `json:"provider"`
+	CPURequest    string `json:"cpu_request"`
+	CPULimit      string `json:"cpu_limit"`
+	MemoryRequest string `json:"memory_request"`
+	MemoryLimit   string `json:"memory_limit"`
+	VolumeSize    string `json:"volume_size"`
+}
+
+const (
+	DefaultPlan            = "small"
+	DefaultPostgresVersion = "15"
+)
+
+// Database engines. Plans size every engine; the engine picks the image.
+const (
+	EnginePostgreSQL = "postgresql"
+	EngineMySQL      = "mysql"
+	EngineMariaDB    = "mariadb"
+)
+
+// Plans are the sizes tenants can choose from. "small" matches what every
+// database got before plans existed; "dev" is a single unreplicated instance.
+var Plans = map[string]DatabasePlan{
+	"dev": {
+		Name: "dev", Provider: ProviderStatefulSet,
+		CPURequest: "100m", CPULimit: "250m",
+		MemoryRequest: "128Mi", MemoryLimit: "256Mi",
+		VolumeSize: "1Gi",
+	},
+	"small": {
+		Name: "small", Provider: ProviderZalando,
+		CPURequest: "200m", CPULimit: "500m",
+		MemoryRequest: "256Mi", MemoryLimit: "512Mi",
+		VolumeSize: "5Gi",
+	},
+	"medium": {
+		Name: "medium", Provider: ProviderZalando,
+		CPURequest: "500m", CPULimit: "1",
+		MemoryRequest: "1Gi", MemoryLimit: "2Gi",
+		VolumeSize: "20Gi",
+	},
+	"large": {
+		Name: "large", Provider: ProviderZalando,
+		CPURequest: "1", CPULimit: "2",
+		MemoryRequest: "4Gi", MemoryLimit: "8Gi",
+		VolumeSize: "100Gi",
+	},
+}
+
+// PostgresVersions are the major versions the operator image supports.
+var PostgresVersions = []string{"14", "15", "16", "17"}
+
+// EngineVersions lists the versions of each engine, default first for MySQL
+// and MariaDB.
+var EngineVersions = map[string][]string{
+	EnginePostgreSQL: PostgresVersions,
+	EngineMySQL:      {"8.4", "8.0"},
+	EngineMariaDB:    {"11.4", "10.11"},
+}
+
+// DefaultVersion returns the version a database of engine gets when none is requested.
+func DefaultVersion(engine string) string {
+	if engine == EnginePostgreSQL || engine == "" {
+		return DefaultPostgresVersion
+	}
+	if versions := EngineVersions[engine]; len(versions) > 0 {
+		return versions[0]
+	}
+	return ""
+}
+
+func ValidateEngine(engine string) []string {
+	if _, ok := EngineVersions[engine]; ok {
+		return nil
+	}
+	return []string{fmt.Sprintf("must be one of %s, %s, %s", EnginePostgreSQL, EngineMySQL, EngineMariaDB)}
+}
+
+func ValidatePlan(plan string) []string {
+	if _, ok := Plans[plan]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(Plans))
+	for name := range Plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return []string{fmt.Sprintf("must be one of %s", strings.Join(names, ", "))}
+}
+
+func ValidatePostgresVersion(version string) []string {
+	return ValidateVersion(EnginePostgreSQL, version)
+}
+
+// ValidateVersion checks a version against those of an engine. Unknown
+// engines are reported by ValidateEngine.
+func ValidateVersion(engine, version string) []string {
+	versions, ok := EngineVersions[engine]
+	if !ok {
+		return nil
+	}
+	for _, v := range versions {
+		if v == version {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("must be one of %s", strings.Join(versions, ", "))}
+}
+
+// ValidateReplicas checks the instance count of a defaulted spec against what
+// its provider supports.
+func ValidateReplicas(spec TenantDatabaseSpec) []string {
+	provider, err := ProviderForSpec(spec)
+	if err != nil {
+		return nil
+	}
+	if max := provider.Capabilities().MaxReplicas; spec.Replicas > max {
+		if spec.Engine != EnginePostgreSQL {
+			return []string{fmt.Sprintf("must be at most %d for engine %s", max, spec.Engine)}
+		}
+		return []string{fmt.Sprintf("must be at most %d for plan %s", max, spec.Plan)}
+	}
+	return nil
+}
+
+// SetPlanProviders overrides which provider runs each plan, e.g.
+// {"medium": "cloudnativepg", "large": "cloudnativepg"}.
+func SetPlanProviders(overrides map[string]string) error {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		provider := overrides[name]
+		plan, ok := Plans[name]
+		if !ok {
+			return fmt.Errorf("unknown plan %q", name)
+		}
+		if _, ok := GetProvider(provider); !ok {
+			return fmt.Errorf("unknown provider %q for plan %s, expected one of %s", provider, name, strings.Join(providerNames(), ", "))
+		}
+		plan.Provider = provider
+		Plans[name] = plan
+	}
+	return nil
+}
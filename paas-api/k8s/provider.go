@@ -0,0 +1,297 @@
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Provider names, as used in plans and reported as DatabaseClusterInfo.CreationMethod.
+// MySQL and MariaDB providers are named after their engine.
+const (
+	ProviderZalando       = "zalando"
+	ProviderCloudNativePG = "cloudnativepg"
+	ProviderStatefulSet   = "statefulset"
+	ProviderMySQL         = EngineMySQL
+	ProviderMariaDB       = EngineMariaDB
+)
+
+var (
+	// ErrNotSupported is returned for operations a provider cannot perform,
+	// e.g. replication on a single StatefulSet.
+	ErrNotSupported = errors.New("not supported by the database provider")
+
+	// ErrCredentialsPending is returned by DatabaseProvider.Credentials while
+	// the database exists but its credentials secret has not been created yet.
+	ErrCredentialsPending = errors.New("credentials are not available yet")
+)
+
+// ProvisionRequest describes the cluster a provider should create or update.
+type ProvisionRequest struct {
+	Namespace string
+	DBName    string
+	Spec      TenantDatabaseSpec // defaulted
+
+	// Owner is set for clusters managed through a TenantDatabase
+	Owner *metav1.OwnerReference
+
+	// Update applies the spec to an existing cluster instead of failing with
+	// ErrDatabaseExists.
+	Update bool
+}
+
+// ProviderCapabilities limits what specs a provider accepts.
+type ProviderCapabilities struct {
+	MaxReplicas int
+	Backups     bool
+}
+
+// DatabaseProvider runs database clusters of one engine on Kubernetes. Every
+// method returns ErrDatabaseNotFound when the provider has no cluster of that name.
+type DatabaseProvider interface {
+	Name() string
+	Engine() string
+	Capabilities() ProviderCapabilities
+
+	Provision(req ProvisionRequest) (*ProvisionResult, error)
+	Describe(namespace, dbName string) (*DatabaseClusterInfo, error)
+	Delete(namespace, dbName string) error
+	Scale(namespace, dbName string, replicas int) error
+	Credentials(namespace, dbName string) (*DatabaseCredentials, error)
+	// Backup starts an on-demand backup and returns the name of the object tracking it.
+	Backup(namespace, dbName string) (string, error)
+
+	// Pause stops every instance but keeps the volumes; Resume undoes it.
+	Pause(namespace, dbName string) error
+	Resume(namespace, dbName string) error
+
+	Exists(namespace, dbName string) (bool, error)
+	List(namespace string) ([]string, error)
+
+	// Host is the in-cluster DNS name of the read-write service, Port its port.
+	Host(namespace, dbName string) string
+	Port() int
+	// SecretName is the secret holding the username and password of the database owner.
+	SecretName(dbName string) string
+	// PodLabels select the cluster's pods; IsPrimary picks the writable one.
+	PodLabels(dbName string) map[string]string
+	IsPrimary(pod corev1.Pod) bool
+}
+
+// providers in lookup order. Clusters created before providers existed are Zalando's.
+var providers = []DatabaseProvider{
+	zalandoProvider{},
+	cloudNativePGProvider{},
+	statefulSetProvider{},
+	mysqlProvider{engine: EngineMySQL},
+	mysqlProvider{engine: EngineMariaDB},
+}
+
+// GetProvider returns the provider registered under name.
+func GetProvider(name string) (DatabaseProvider, bool) {
+	for _, p := range providers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// ProviderForSpec returns the provider that creates a database: the engine's
+// own for MySQL and MariaDB, the plan's for PostgreSQL.
+func ProviderForSpec(spec TenantDatabaseSpec) (DatabaseProvider, error) {
+	if spec.Engine == "" || spec.Engine == EnginePostgreSQL {
+		return ProviderForPlan(spec.Plan)
+	}
+	if _, ok := Plans[spec.Plan]; !ok {
+		return nil, fmt.Errorf("unknown plan %q", spec.Plan)
+	}
+	provider, ok := GetProvider(spec.Engine)
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %q", spec.Engine)
+	}
+	return provider, nil
+}
+
+// ProviderForPlan returns the provider that creates PostgreSQL databases of a plan.
+func ProviderForPlan(plan string) (DatabaseProvider, error) {
+	p, ok := Plans[plan]
+	if !ok {
+		return nil, fmt.Errorf("unknown plan %q", plan)
+	}
+	name := p.Provider
+	if name == "" {
+		name = ProviderZalando
+	}
+	provider, ok := GetProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("plan %s uses unknown provider %q", plan, name)
+	}
+	return provider, nil
+}
+
+// providerFor finds the provider that manages an existing database.
+func providerFor(namespace, dbName string) (DatabaseProvider, error) {
+	for _, p := range providers {
+		exists, err := p.Exists(namespace, dbName)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return p, nil
+		}
+	}
+	return nil, ErrDatabaseNotFound
+}
+
+type providerDatabase struct {
+	Name     string
+	Provider DatabaseProvider
+}
+
+// listDatabases returns the databases of every provider in a namespace, sorted by name.
+func listDatabases(namespace string) ([]providerDatabase, error) {
+	var databases []providerDatabase
+	for _, p := range providers {
+		names, err := p.List(namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			databases = append(databases, providerDatabase{Name: name, Provider: p})
+		}
+	}
+	sort.Slice(databases, func(i, j int) bool { return databases[i].Name < databases[j].Name })
+	return databases, nil
+}
+
+// kubectlGet runs kubectl get on a custom resource of the API groupVersion.
+// A resource type the cluster does not know, i.e. an operator that is not
+// installed, yields empty output rather than an error.
+func kubectlGet(groupVersion string, args ...string) ([]byte, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	if _, err := clientset.Discovery().ServerResourcesForGroupVersion(groupVersion); apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("kubectl", append([]string{"get"}, args...)...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "doesn't have a resource type") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// newProvisionResult describes a database whose credentials are still being created.
+func newProvisionResult(p DatabaseProvider, namespace, dbName, secretName, checkStatus string) *ProvisionResult {
+	host := p.Host(namespace, dbName)
+	port := strconv.Itoa(p.Port())
+	return &ProvisionResult{
+		DatabaseName: dbName,
+		Engine:       p.Engine(),
+		Host:         host,
+		Port:         port,
+		Status:       "provisioning",
+		Message:      "Database is being created. Credentials will be available shortly.",
+		SecretName:   secretName,
+		ConnectionInfo: map[string]string{
+			"host":     host,
+			"port":     port,
+			"database": dbName,
+			"ssl_mode": "prefer",
+			"note":     "Username and password will be available in the secret once ready",
+		},
+		Instructions: map[string]string{
+			"check_status":    checkStatus,
+			"get_credentials": fmt.Sprintf("kubectl get secret %s -n %s -o yaml", secretName, namespace),
+		},
+	}
+}
+
+// newDatabaseCredentials builds the credentials response from a secret's data,
+// which must contain username and password.
+func newDatabaseCredentials(p DatabaseProvider, namespace, dbName string, data map[string][]byte) *DatabaseCredentials {
+	user := map[string]string{}
+	for key, val := range data {
+		user[key] = string(val)
+	}
+	username, password := user["username"], user["password"]
+	host, port := p.Host(namespace, dbName), strconv.Itoa(p.Port())
+
+	uri := connectionURI(p.Engine(), username, password, host, port, dbName)
+	connectionString := uri.String()
+	return &DatabaseCredentials{
+		DatabaseName:        dbName,
+		Engine:              p.Engine(),
+		Host:                host,
+		Port:                port,
+		PrimaryUser:         user,
+		ConnectionString:    connectionString,
+		ConnectionStringSSL: connectionString + "?" + sslQuery(p.Engine()),
+		ConnectionInfo: map[string]string{
+			"host":     host,
+			"port":     port,
+			"database": dbName,
+			"username": username,
+			"password": password,
+			"ssl_mode": "prefer",
+		},
+	}
+}
+
+// connectionURI is the URI clients of engine connect with, without TLS options.
+func connectionURI(engine, username, password, host, port, dbName string) url.URL {
+	scheme := "postgresql"
+	if engine == EngineMySQL || engine == EngineMariaDB {
+		scheme = "mysql"
+	}
+	return url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(username, password),
+		Host:   host + ":" + port,
+		Path:   "/" + dbName,
+	}
+}
+
+// sslQuery asks for TLS when the server offers it.
+func sslQuery(engine string) string {
+	if engine == EngineMySQL || engine == EngineMariaDB {
+		return "ssl-mode=PREFERRED"
+	}
+	return "sslmode=prefer"
+}
+
+// formatCreationTime renders an RFC 3339 timestamp the way DatabaseClusterInfo.CreatedAt expects.
+func formatCreationTime(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,311 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	cnpgGroupVersion            = "postgresql.cnpg.io/v1"
+	cnpgClusterResource         = "clusters.postgresql.cnpg.io"
+	cnpgBackupResource          = "backups.postgresql.cnpg.io"
+	cnpgScheduledBackupResource = "scheduledbackups.postgresql.cnpg.io"
+
+	cnpgHibernationAnnotation = "cnpg.io/hibernation"
+
+	// cnpgDefaultBackupSchedule runs daily, like the Zalando logical backup
+	cnpgDefaultBackupSchedule = "0 30 0 * * *"
+)
+
+// cloudNativePGProvider runs clusters through the CloudNativePG operator.
+// Backups are volume snapshots and need a snapshot class (CNPG_SNAPSHOT_CLASS)
+// to name a VolumeSnapshotClass.
+type cloudNativePGProvider struct{}
+
+func (cloudNativePGProvider) Name() string { return ProviderCloudNativePG }
+
+func (cloudNativePGProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{MaxReplicas: 5, Backups: cnpgSnapshotClass() != ""}
+}
+
+func cnpgSnapshotClass() string {
+	return settings.SnapshotClass
+}
+
+// Host is the operator's read-write service, which always points at the primary.
+func (cloudNativePGProvider) Engine() string { return EnginePostgreSQL }
+
+func (cloudNativePGProvider) Port() int { return 5432 }
+
+func (cloudNativePGProvider) Host(namespace, dbName string) string {
+	return fmt.Sprintf("%s-rw.%s.svc.cluster.local", dbName, namespace)
+}
+
+func (cloudNativePGProvider) PodLabels(dbName string) map[string]string {
+	return map[string]string{"cnpg.io/cluster": dbName}
+}
+
+func (cloudNativePGProvider) IsPrimary(pod corev1.Pod) bool {
+	return pod.Labels["cnpg.io/instanceRole"] == "primary"
+}
+
+// cnpgSecretName is the secret the operator creates for the application user.
+func (cloudNativePGProvider) SecretName(dbName string) string { return cnpgSecretName(dbName) }
+
+func cnpgSecretName(dbName string) string {
+	return dbName + "-app"
+}
+
+// cnpgCluster is the subset of the CloudNativePG Cluster resource we read.
+type cnpgCluster struct {
+	Metadata struct {
+		Annotations       map[string]string `json:"annotations"`
+		CreationTimestamp string            `json:"creationTimestamp"`
+		DeletionTimestamp string            `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		Instances int `json:"instances"`
+	} `json:"spec"`
+	Status struct {
+		Phase          string `json:"phase"`
+		Instances      int    `json:"instances"`
+		ReadyInstances int    `json:"readyInstances"`
+	} `json:"status"`
+}
+
+func (cloudNativePGProvider) get(namespace, dbName string) (*cnpgCluster, error) {
+	output, err := kubectlGet(cnpgGroupVersion, cnpgClusterResource, dbName, "-n", namespace, "--ignore-not-found", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CloudNativePG cluster %s: %w", dbName, err)
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, ErrDatabaseNotFound
+	}
+	var cluster cnpgCluster
+	if err := json.Unmarshal(output, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse CloudNativePG cluster %s: %w", dbName, err)
+	}
+	return &cluster, nil
+}
+
+func (cloudNativePGProvider) Exists(namespace, dbName string) (bool, error) {
+	output, err := kubectlGet(cnpgGroupVersion, cnpgClusterResource, dbName, "-n", namespace, "--ignore-not-found", "-o", "name")
+	if err != nil {
+		return false, fmt.Errorf("failed to check for CloudNativePG cluster %s: %w", dbName, err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+func (cloudNativePGProvider) List(namespace string) ([]string, error) {
+	output, err := kubectlGet(cnpgGroupVersion, cnpgClusterResource, "-n", namespace, "-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CloudNativePG clusters: %w", err)
+	}
+	return strings.Fields(strings.TrimSpace(string(output))), nil
+}
+
+// Provision renders the Cluster and, with backups enabled, a ScheduledBackup.
+// A hibernated cluster stays hibernated, the annotation survives kubectl apply.
+func (p cloudNativePGProvider) Provision(req ProvisionRequest) (*ProvisionResult, error) {
+	data := newTemplateData(req.Namespace, req.DBName, req.Spec)
+	data.Owner = req.Owner
+	data.SnapshotClass = cnpgSnapshotClass()
+	if data.LogicalBackup {
+		if data.SnapshotClass == "" {
+			return nil, fmt.Errorf("%w: backups need CNPG_SNAPSHOT_CLASS", ErrNotSupported)
+		}
+		// CloudNativePG schedules have a leading seconds field
+		switch schedule := data.LogicalBackupSchedule; {
+		case schedule == "":
+			data.LogicalBackupSchedule = cnpgDefaultBackupSchedule
+		case len(strings.Fields(schedule)) == 5:
+			data.LogicalBackupSchedule = "0 " + schedule
+		}
+	}
+
+	buf, err := renderTemplate("cnpg-cluster.yaml.tmpl", data)
+	if err != nil {
+		return nil, err
+	}
+
+	verb := "create"
+	if req.Update {
+		verb = "apply"
+	}
+	cmd := exec.Command("kubectl", verb, "-f", "-")
+	cmd.Stdin = buf
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(output), "AlreadyExists") {
+			return nil, ErrDatabaseExists
+		}
+		return nil, fmt.Errorf("failed to apply CloudNativePG cluster %s: %w, output: %s", req.DBName, err, string(output))
+	}
+
+	if req.Update && !data.LogicalBackup {
+		output, err := exec.Command("kubectl", "delete", cnpgScheduledBackupResource, req.DBName+"-scheduled", "-n", req.Namespace, "--ignore-not-found").CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete scheduled backup of %s: %w, output: %s", req.DBName, err, string(output))
+		}
+	}
+
+	return newProvisionResult(p, req.Namespace, req.DBName, cnpgSecretName(req.DBName),
+		fmt.Sprintf("kubectl get %s %s -n %s", cnpgClusterResource, req.DBName, req.Namespace)), nil
+}
+
+func (p cloudNativePGProvider) Describe(namespace, dbName string) (*DatabaseClusterInfo, error) {
+	cr, err := p.get(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &DatabaseClusterInfo{
+		Name:            dbName,
+		Namespace:       namespace,
+		CreatedAt:       formatCreationTime(cr.Metadata.CreationTimestamp),
+		Replicas:        cr.Status.Instances,
+		DesiredReplicas: cr.Spec.Instances,
+		RunningReplicas: cr.Status.ReadyInstances,
+	}
+	cluster.Status, cluster.DetailedStatus = deriveCNPGStatus(cr)
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	_, err = clientset.CoreV1().Secrets(namespace).Get(context.TODO(), cnpgSecretName(dbName), metav1.GetOptions{})
+	if err == nil {
+		cluster.CredentialsReady = true
+	}
+	return cluster, nil
+}
+
+// deriveCNPGStatus maps the operator's free-form phase onto the platform's
+// cluster states; instance counts decide between Ready and Degraded.
+func deriveCNPGStatus(cr *cnpgCluster) (string, string) {
+	phase := cr.Status.Phase
+	switch {
+	case cr.Metadata.DeletionTimestamp != "":
+		return StatusDeleting, "Database cluster is being deleted"
+	case cr.Metadata.Annotations[cnpgHibernationAnnotation] == "on":
+		return StatusPaused, "Database cluster is hibernated"
+	case strings.Contains(phase, "Failing over"), strings.Contains(phase, "Switchover"):
+		return StatusFailingOver, phase
+	case strings.Contains(phase, "cannot"), strings.Contains(phase, "Unable"):
+		return StatusFailed, phase
+	}
+
+	if cr.Status.Instances == 0 {
+		return StatusProvisioning, "Database cluster is being provisioned"
+	}
+	if cr.Status.ReadyInstances == 0 {
+		return StatusInitializing, "Database pods are starting"
+	}
+	if phase != "Cluster in healthy state" {
+		return StatusUpdating, phase
+	}
+	if cr.Status.ReadyInstances < cr.Spec.Instances {
+		return StatusDegraded, fmt.Sprintf("%d of %d instances ready", cr.Status.ReadyInstances, cr.Spec.Instances)
+	}
+	return StatusReady, "Database is ready"
+}
+
+// Delete removes the Cluster; the operator's secrets and services are owned by it.
+func (cloudNativePGProvider) Delete(namespace, dbName string) error {
+	output, err := exec.Command("kubectl", "delete", cnpgClusterResource, dbName, "-n", namespace).CombinedOutput()
+	if err != nil {
+		if kubectlNotFound(output) {
+			return ErrDatabaseNotFound
+		}
+		return fmt.Errorf("failed to delete CloudNativePG cluster %s: %w, output: %s", dbName, err, string(output))
+	}
+	output, err = exec.Command("kubectl", "delete", cnpgScheduledBackupResource, dbName+"-scheduled", "-n", namespace, "--ignore-not-found").CombinedOutput()
+	if err != nil {
+		fmt.Printf("Could not delete scheduled backup of %s: %v, output: %s\n", dbName, err, string(output))
+	}
+	fmt.Printf("Deleted CloudNativePG cluster %s/%s\n", namespace, dbName)
+	return nil
+}
+
+func (cloudNativePGProvider) patch(namespace, dbName, patch string) error {
+	output, err := exec.Command("kubectl", "patch", cnpgClusterResource, dbName, "-n", namespace, "--type", "merge", "-p", patch).CombinedOutput()
+	if err != nil {
+		if kubectlNotFound(output) {
+			return ErrDatabaseNotFound
+		}
+		return fmt.Errorf("failed to patch CloudNativePG cluster %s: %w, output: %s", dbName, err, string(output))
+	}
+	return nil
+}
+
+func (p cloudNativePGProvider) Scale(namespace, dbName string, replicas int) error {
+	return p.patch(namespace, dbName, fmt.Sprintf(`{"spec":{"instances":%d}}`, replicas))
+}
+
+func (p cloudNativePGProvider) Credentials(namespace, dbName string) (*DatabaseCredentials, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), cnpgSecretName(dbName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrCredentialsPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials of %s: %w", dbName, err)
+	}
+	return newDatabaseCredentials(p, namespace, dbName, secret.Data), nil
+}
+
+// Backup creates a Backup resource taking a volume snapshot of the cluster.
+func (p cloudNativePGProvider) Backup(namespace, dbName string) (string, error) {
+	exists, err := p.Exists(namespace, dbName)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrDatabaseNotFound
+	}
+	if cnpgSnapshotClass() == "" {
+		return "", fmt.Errorf("%w: backups need CNPG_SNAPSHOT_CLASS", ErrNotSupported)
+	}
+
+	name := fmt.Sprintf("%s-manual-%d", dbName, time.Now().Unix())
+	backup, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "postgresql.cnpg.io/v1",
+		"kind":       "Backup",
+		"metadata":   map[string]string{"name": name, "namespace": namespace},
+		"spec": map[string]interface{}{
+			"method":  "volumeSnapshot",
+			"cluster": map[string]string{"name": dbName},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("kubectl", "create", "-f", "-")
+	cmd.Stdin = bytes.NewReader(backup)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create %s %s: %w, output: %s", cnpgBackupResource, name, err, string(output))
+	}
+	return name, nil
+}
+
+// Pause hibernates the cluster: the operator removes the pods and keeps the volumes.
+func (p cloudNativePGProvider) Pause(namespace, dbName string) error {
+	return p.patch(namespace, dbName, fmt.Sprintf(`{"metadata":{"annotations":{%q:"on"}}}`, cnpgHibernationAnnotation))
+}
+
+func (p cloudNativePGProvider) Resume(namespace, dbName string) error {
+	return p.patch(namespace, dbName, fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, cnpgHibernationAnnotation))
+}
@@ -0,0 +1,317 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// mysqlProvider runs a single MySQL or MariaDB server in a StatefulSet
+// rendered from templates/mysql.yaml.tmpl. Like the postgres StatefulSet
+// provider there is no replication or backup.
+type mysqlProvider struct {
+	engine string // EngineMySQL or EngineMariaDB, also the image name
+}
+
+func (p mysqlProvider) Name() string { return p.engine }
+
+func (p mysqlProvider) Engine() string { return p.engine }
+
+func (mysqlProvider) Port() int { return 3306 }
+
+func (mysqlProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{MaxReplicas: 1}
+}
+
+func (mysqlProvider) Host(namespace, dbName string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace)
+}
+
+func (p mysqlProvider) PodLabels(dbName string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     p.engine,
+		"app.kubernetes.io/instance": dbName,
+	}
+}
+
+// IsPrimary is true for the only instance there is.
+func (mysqlProvider) IsPrimary(pod corev1.Pod) bool { return true }
+
+func (mysqlProvider) SecretName(dbName string) string { return mysqlSecretName(dbName) }
+
+func mysqlSecretName(dbName string) string {
+	return dbName + "-credentials"
+}
+
+func (p mysqlProvider) get(namespace, dbName string) (*appsv1.StatefulSet, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	sts, err := clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), dbName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && !isProviderStatefulSet(sts, p.engine)) {
+		return nil, ErrDatabaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get StatefulSet %s: %w", dbName, err)
+	}
+	return sts, nil
+}
+
+func (p mysqlProvider) Exists(namespace, dbName string) (bool, error) {
+	_, err := p.get(namespace, dbName)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (p mysqlProvider) List(namespace string) ([]string, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/name=%s,app.kubernetes.io/managed-by=paas-api", p.engine),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StatefulSets: %w", err)
+	}
+	var names []string
+	for _, sts := range list.Items {
+		names = append(names, sts.Name)
+	}
+	return names, nil
+}
+
+// Provision creates the credentials secret and applies the service and
+// StatefulSet. The generated passwords are kept across updates, and so is a
+// paused instance.
+func (p mysqlProvider) Provision(req ProvisionRequest) (*ProvisionResult, error) {
+	if req.Spec.Replicas > 1 {
+		return nil, fmt.Errorf("%w: a %s database has a single instance", ErrNotSupported, p.Name())
+	}
+	if req.Spec.Backups.Enabled {
+		return nil, fmt.Errorf("%w: backups of a %s database", ErrNotSupported, p.Name())
+	}
+	namespace, dbName := req.Namespace, req.DBName
+
+	existing, err := p.get(namespace, dbName)
+	if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
+		return nil, err
+	}
+	if existing != nil && !req.Update {
+		return nil, ErrDatabaseExists
+	}
+	if err := ensureNamesFree(namespace, dbName, p.Engine(), mysqlSecretName(dbName)); err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	secrets := clientset.CoreV1().Secrets(namespace)
+	_, err = secrets.Get(context.TODO(), mysqlSecretName(dbName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		meta := metav1.ObjectMeta{Name: mysqlSecretName(dbName), Namespace: namespace, Labels: p.PodLabels(dbName)}
+		meta.Labels["app.kubernetes.io/managed-by"] = "paas-api"
+		if req.Owner != nil {
+			meta.OwnerReferences = []metav1.OwnerReference{*req.Owner}
+		}
+		var password, rootPassword string
+		if password, err = randomPassword(24); err != nil {
+			return nil, err
+		}
+		if rootPassword, err = randomPassword(24); err != nil {
+			return nil, err
+		}
+		_, err = secrets.Create(context.TODO(), &corev1.Secret{
+			ObjectMeta: meta,
+			StringData: map[string]string{
+				// MySQL user names are limited to 32 characters
+				"username":      truncate(dbName, 32),
+				"password":      password,
+				"root-password": rootPassword,
+			},
+		}, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret %s: %w", mysqlSecretName(dbName), err)
+	}
+
+	data := newTemplateData(namespace, dbName, req.Spec)
+	data.Owner = req.Owner
+	verb := "create"
+	if existing != nil {
+		verb = "apply"
+		if existing.Annotations[PausedAnnotation] == "true" {
+			data.Replicas = 0
+		}
+	}
+
+	buf, err := renderTemplate("mysql.yaml.tmpl", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("kubectl", verb, "-f", "-")
+	cmd.Stdin = buf
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "AlreadyExists") {
+			return nil, ErrDatabaseExists
+		}
+		fmt.Fprint(os.Stderr, stderr.String())
+		return nil, fmt.Errorf("failed to apply %s database %s: %w", p.engine, dbName, err)
+	}
+
+	result := newProvisionResult(p, namespace, dbName, mysqlSecretName(dbName),
+		fmt.Sprintf("kubectl get statefulset %s -n %s", dbName, namespace))
+	result.Message = "Database is being created. Credentials are available now; the server accepts connections once ready."
+	return result, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func (p mysqlProvider) Describe(namespace, dbName string) (*DatabaseClusterInfo, error) {
+	sts, err := p.get(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(p.PodLabels(dbName)).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for %s: %w", dbName, err)
+	}
+
+	cluster := &DatabaseClusterInfo{
+		Name:            dbName,
+		Namespace:       namespace,
+		CreatedAt:       sts.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		Replicas:        len(pods.Items),
+		DesiredReplicas: statefulSetReplicas(sts),
+	}
+	cluster.Status, cluster.DetailedStatus, cluster.RunningReplicas = observeStatefulSet(sts, pods.Items, "Database")
+
+	_, err = clientset.CoreV1().Secrets(namespace).Get(context.TODO(), mysqlSecretName(dbName), metav1.GetOptions{})
+	if err == nil {
+		cluster.CredentialsReady = true
+	}
+	return cluster, nil
+}
+
+// Delete removes the StatefulSet, whose retention policy deletes the volume,
+// and the service and secret created with it.
+func (p mysqlProvider) Delete(namespace, dbName string) error {
+	if _, err := p.get(namespace, dbName); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	if err := clientset.AppsV1().StatefulSets(namespace).Delete(context.TODO(), dbName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete StatefulSet %s: %w", dbName, err)
+	}
+	if err := clientset.CoreV1().Services(namespace).Delete(context.TODO(), dbName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete service %s: %w", dbName, err)
+	}
+	if err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), mysqlSecretName(dbName), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret %s: %w", mysqlSecretName(dbName), err)
+	}
+	fmt.Printf("Deleted %s database %s/%s\n", p.engine, namespace, dbName)
+	return nil
+}
+
+func (p mysqlProvider) setReplicas(namespace, dbName string, replicas int32, paused bool) error {
+	sts, err := p.get(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	if sts.Annotations == nil {
+		sts.Annotations = map[string]string{}
+	}
+	if paused {
+		sts.Annotations[PausedAnnotation] = "true"
+	} else {
+		delete(sts.Annotations, PausedAnnotation)
+	}
+	sts.Spec.Replicas = &replicas
+	if _, err := clientset.AppsV1().StatefulSets(namespace).Update(context.TODO(), sts, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update StatefulSet %s: %w", dbName, err)
+	}
+	return nil
+}
+
+func (p mysqlProvider) Scale(namespace, dbName string, replicas int) error {
+	if replicas > 1 {
+		return fmt.Errorf("%w: a %s database has a single instance", ErrNotSupported, p.Name())
+	}
+	return p.setReplicas(namespace, dbName, int32(replicas), false)
+}
+
+func (p mysqlProvider) Pause(namespace, dbName string) error {
+	return p.setReplicas(namespace, dbName, 0, true)
+}
+
+func (p mysqlProvider) Resume(namespace, dbName string) error {
+	return p.setReplicas(namespace, dbName, 1, false)
+}
+
+// Credentials returns the application user; the root password stays in the secret.
+func (p mysqlProvider) Credentials(namespace, dbName string) (*DatabaseCredentials, error) {
+	if _, err := p.get(namespace, dbName); err != nil {
+		return nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), mysqlSecretName(dbName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrCredentialsPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials of %s: %w", dbName, err)
+	}
+	data := map[string][]byte{"username": secret.Data["username"], "password": secret.Data["password"]}
+	return newDatabaseCredentials(p, namespace, dbName, data), nil
+}
+
+func (p mysqlProvider) Backup(namespace, dbName string) (string, error) {
+	if _, err := p.get(namespace, dbName); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%w: backups of a %s database", ErrNotSupported, p.Name())
+}
@@ -0,0 +1,484 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// statefulSetProvider runs a single postgres container in a StatefulSet, with
+// no operator involved. It suits development databases: there is no
+// replication, failover or backup.
+type statefulSetProvider struct{}
+
+func (statefulSetProvider) Name() string { return ProviderStatefulSet }
+
+func (statefulSetProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{MaxReplicas: 1}
+}
+
+func (statefulSetProvider) Engine() string { return EnginePostgreSQL }
+
+func (statefulSetProvider) Port() int { return 5432 }
+
+func (statefulSetProvider) Host(namespace, dbName string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace)
+}
+
+func (statefulSetProvider) PodLabels(dbName string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "postgres",
+		"app.kubernetes.io/instance": dbName,
+	}
+}
+
+// IsPrimary is true for the only instance there is.
+func (statefulSetProvider) IsPrimary(pod corev1.Pod) bool { return true }
+
+func (statefulSetProvider) SecretName(dbName string) string { return statefulSetSecretName(dbName) }
+
+func statefulSetSecretName(dbName string) string {
+	return dbName + "-credentials"
+}
+
+// labels mark the objects of the provider so List can find them.
+func (p statefulSetProvider) labels(dbName string) map[string]string {
+	l := p.PodLabels(dbName)
+	l["app.kubernetes.io/managed-by"] = "paas-api"
+	return l
+}
+
+func (p statefulSetProvider) get(namespace, dbName string) (*appsv1.StatefulSet, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	sts, err := clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), dbName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && !isProviderStatefulSet(sts, "postgres")) {
+		return nil, ErrDatabaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get StatefulSet %s: %w", dbName, err)
+	}
+	return sts, nil
+}
+
+func (p statefulSetProvider) Exists(namespace, dbName string) (bool, error) {
+	_, err := p.get(namespace, dbName)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (statefulSetProvider) List(namespace string) ([]string, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	list, err := clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/name=postgres,app.kubernetes.io/managed-by=paas-api",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StatefulSets: %w", err)
+	}
+	var names []string
+	for _, sts := range list.Items {
+		names = append(names, sts.Name)
+	}
+	return names, nil
+}
+
+// Provision creates the credentials secret, the service and the StatefulSet.
+// The generated password is kept across updates, and so is a paused instance.
+func (p statefulSetProvider) Provision(req ProvisionRequest) (*ProvisionResult, error) {
+	if req.Spec.Replicas > 1 {
+		return nil, fmt.Errorf("%w: a %s database has a single instance", ErrNotSupported, p.Name())
+	}
+	if req.Spec.Backups.Enabled {
+		return nil, fmt.Errorf("%w: backups of a %s database", ErrNotSupported, p.Name())
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	namespace, dbName := req.Namespace, req.DBName
+
+	objectMeta := func(name string) metav1.ObjectMeta {
+		meta := metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: p.labels(dbName)}
+		if req.Owner != nil {
+			meta.OwnerReferences = []metav1.OwnerReference{*req.Owner}
+		}
+		return meta
+	}
+
+	existing, err := p.get(namespace, dbName)
+	if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
+		return nil, err
+	}
+	if existing != nil && !req.Update {
+		return nil, ErrDatabaseExists
+	}
+	if err := ensureNamesFree(namespace, dbName, "postgres", statefulSetSecretName(dbName)); err != nil {
+		return nil, err
+	}
+
+	// Credentials
+	secrets := clientset.CoreV1().Secrets(namespace)
+	_, err = secrets.Get(context.TODO(), statefulSetSecretName(dbName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		var password string
+		if password, err = randomPassword(24); err != nil {
+			return nil, err
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: objectMeta(statefulSetSecretName(dbName)),
+			StringData: map[string]string{
+				"username": dbName,
+				"password": password,
+			},
+		}
+		_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret %s: %w", statefulSetSecretName(dbName), err)
+	}
+
+	// Service
+	serviceType := corev1.ServiceTypeClusterIP
+	if req.Spec.Exposure == ExposurePublic {
+		serviceType = corev1.ServiceTypeLoadBalancer
+	}
+	service := &corev1.Service{
+		ObjectMeta: objectMeta(dbName),
+		Spec: corev1.ServiceSpec{
+			Type:     serviceType,
+			Selector: p.PodLabels(dbName),
+			Ports: []corev1.ServicePort{{
+				Name:       "postgres",
+				Port:       5432,
+				TargetPort: intstr.FromString("postgres"),
+			}},
+		},
+	}
+	services := clientset.CoreV1().Services(namespace)
+	current, err := services.Get(context.TODO(), dbName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = services.Create(context.TODO(), service, metav1.CreateOptions{})
+	} else if err == nil {
+		current.Spec.Type = service.Spec.Type
+		current.Spec.Selector = service.Spec.Selector
+		current.Spec.Ports = service.Spec.Ports
+		_, err = services.Update(context.TODO(), current, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply service %s: %w", dbName, err)
+	}
+
+	// StatefulSet
+	sts := p.statefulSet(req, objectMeta(dbName))
+	statefulSets := clientset.AppsV1().StatefulSets(namespace)
+	if existing == nil {
+		if _, err := statefulSets.Create(context.TODO(), sts, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return nil, ErrDatabaseExists
+			}
+			return nil, fmt.Errorf("failed to create StatefulSet %s: %w", dbName, err)
+		}
+	} else {
+		// volumeClaimTemplates cannot change, only the pod template and size
+		if existing.Annotations[PausedAnnotation] == "true" {
+			sts.Spec.Replicas = existing.Spec.Replicas
+		}
+		existing.Spec.Replicas = sts.Spec.Replicas
+		existing.Spec.Template = sts.Spec.Template
+		if _, err := statefulSets.Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to update StatefulSet %s: %w", dbName, err)
+		}
+	}
+
+	return newProvisionResult(p, namespace, dbName, statefulSetSecretName(dbName),
+		fmt.Sprintf("kubectl get statefulset %s -n %s", dbName, namespace)), nil
+}
+
+func (p statefulSetProvider) statefulSet(req ProvisionRequest, meta metav1.ObjectMeta) *appsv1.StatefulSet {
+	dbName := req.DBName
+	plan := Plans[req.Spec.Plan]
+	replicas := int32(req.Spec.Replicas)
+
+	secretEnv := func(name, key string) corev1.EnvVar {
+		return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: statefulSetSecretName(dbName)},
+				Key:                  key,
+			},
+		}}
+	}
+
+	return &appsv1.StatefulSet{
+		ObjectMeta: meta,
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: dbName,
+			Selector:    &metav1.LabelSelector{MatchLabels: p.PodLabels(dbName)},
+			PersistentVolumeClaimRetentionPolicy: &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: appsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+				WhenScaled:  appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: p.PodLabels(dbName)},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "postgres",
+						Image: "postgres:" + req.Spec.Version,
+						Ports: []corev1.ContainerPort{{Name: "postgres", ContainerPort: 5432}},
+						Env: []corev1.EnvVar{
+							{Name: "POSTGRES_DB", Value: dbName},
+							secretEnv("POSTGRES_USER", "username"),
+							secretEnv("POSTGRES_PASSWORD", "password"),
+							{Name: "PGDATA", Value: "/var/lib/postgresql/data/pgdata"},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/var/lib/postgresql/data"}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{Command: []string{"pg_isready", "-U", dbName, "-d", dbName}},
+							},
+							PeriodSeconds: 10,
+						},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(plan.CPURequest),
+								corev1.ResourceMemory: resource.MustParse(plan.MemoryRequest),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(plan.CPULimit),
+								corev1.ResourceMemory: resource.MustParse(plan.MemoryLimit),
+							},
+						},
+					}},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Labels: p.PodLabels(dbName)},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(plan.VolumeSize)},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func (p statefulSetProvider) Describe(namespace, dbName string) (*DatabaseClusterInfo, error) {
+	sts, err := p.get(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(p.PodLabels(dbName)).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for %s: %w", dbName, err)
+	}
+
+	cluster := &DatabaseClusterInfo{
+		Name:            dbName,
+		Namespace:       namespace,
+		CreatedAt:       sts.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		Replicas:        len(pods.Items),
+		DesiredReplicas: statefulSetReplicas(sts),
+	}
+
+	cluster.Status, cluster.DetailedStatus, cluster.RunningReplicas = observeStatefulSet(sts, pods.Items, "Database")
+
+	_, err = clientset.CoreV1().Secrets(namespace).Get(context.TODO(), statefulSetSecretName(dbName), metav1.GetOptions{})
+	if err == nil {
+		cluster.CredentialsReady = true
+	}
+	return cluster, nil
+}
+
+// isProviderStatefulSet tells the StatefulSets of a provider apart from
+// other paas-api StatefulSets in the namespace by their name label.
+func isProviderStatefulSet(sts *appsv1.StatefulSet, name string) bool {
+	return sts.Labels["app.kubernetes.io/managed-by"] == "paas-api" && sts.Labels["app.kubernetes.io/name"] == name
+}
+
+// ensureNamesFree returns ErrDatabaseExists when the Service or the secret a
+// single-instance database needs already exists without its labels, e.g. the
+// Service of an app with the same name. They would otherwise be taken over.
+func ensureNamesFree(namespace, dbName, name, secretName string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	owned := func(meta metav1.ObjectMeta) bool {
+		return meta.Labels["app.kubernetes.io/managed-by"] == "paas-api" &&
+			meta.Labels["app.kubernetes.io/name"] == name &&
+			meta.Labels["app.kubernetes.io/instance"] == dbName
+	}
+
+	service, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), dbName, metav1.GetOptions{})
+	if err == nil && !owned(service.ObjectMeta) {
+		return ErrDatabaseExists
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get service %s: %w", dbName, err)
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err == nil && !owned(secret.ObjectMeta) {
+		return ErrDatabaseExists
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s: %w", secretName, err)
+	}
+	return nil
+}
+
+// statefulSetReplicas is the number of pods the StatefulSet asks for; an
+// unset spec.replicas means one.
+func statefulSetReplicas(sts *appsv1.StatefulSet) int {
+	if sts.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*sts.Spec.Replicas)
+}
+
+// observeStatefulSet derives the state of a single-instance StatefulSet from
+// its pods and returns it with an explanation and the number of running pods.
+func observeStatefulSet(sts *appsv1.StatefulSet, pods []corev1.Pod, kind string) (string, string, int) {
+	desired := statefulSetReplicas(sts)
+	ready, running, crashLooping := 0, 0, 0
+	for _, pod := range pods {
+		m := podMemberState(pod)
+		if m.Ready {
+			ready++
+		}
+		if m.CrashLoop {
+			crashLooping++
+		}
+		if pod.Status.Phase == corev1.PodRunning {
+			running++
+		}
+	}
+
+	switch {
+	case sts.DeletionTimestamp != nil:
+		return StatusDeleting, kind + " is being deleted", running
+	case desired == 0 || sts.Annotations[PausedAnnotation] == "true":
+		return StatusPaused, kind + " is paused", running
+	case len(pods) == 0:
+		return StatusProvisioning, kind + " is being provisioned", running
+	case crashLooping > 0:
+		return StatusFailed, kind + " container is crash-looping", running
+	case ready < desired:
+		return StatusInitializing, kind + " pod is starting", running
+	}
+	return StatusReady, kind + " is ready", running
+}
+
+// Delete removes the StatefulSet, whose retention policy deletes the volume,
+// and the service and secret created with it.
+func (p statefulSetProvider) Delete(namespace, dbName string) error {
+	if _, err := p.get(namespace, dbName); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	if err := clientset.AppsV1().StatefulSets(namespace).Delete(context.TODO(), dbName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete StatefulSet %s: %w", dbName, err)
+	}
+	if err := clientset.CoreV1().Services(namespace).Delete(context.TODO(), dbName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete service %s: %w", dbName, err)
+	}
+	if err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), statefulSetSecretName(dbName), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret %s: %w", statefulSetSecretName(dbName), err)
+	}
+	fmt.Printf("Deleted StatefulSet database %s/%s\n", namespace, dbName)
+	return nil
+}
+
+func (p statefulSetProvider) setReplicas(namespace, dbName string, replicas int32, paused bool) error {
+	sts, err := p.get(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	if sts.Annotations == nil {
+		sts.Annotations = map[string]string{}
+	}
+	if paused {
+		sts.Annotations[PausedAnnotation] = "true"
+	} else {
+		delete(sts.Annotations, PausedAnnotation)
+	}
+	sts.Spec.Replicas = &replicas
+	if _, err := clientset.AppsV1().StatefulSets(namespace).Update(context.TODO(), sts, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update StatefulSet %s: %w", dbName, err)
+	}
+	return nil
+}
+
+func (p statefulSetProvider) Scale(namespace, dbName string, replicas int) error {
+	if replicas > 1 {
+		return fmt.Errorf("%w: a %s database has a single instance", ErrNotSupported, p.Name())
+	}
+	return p.setReplicas(namespace, dbName, int32(replicas), false)
+}
+
+func (p statefulSetProvider) Pause(namespace, dbName string) error {
+	return p.setReplicas(namespace, dbName, 0, true)
+}
+
+func (p statefulSetProvider) Resume(namespace, dbName string) error {
+	return p.setReplicas(namespace, dbName, 1, false)
+}
+
+func (p statefulSetProvider) Credentials(namespace, dbName string) (*DatabaseCredentials, error) {
+	if _, err := p.get(namespace, dbName); err != nil {
+		return nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), statefulSetSecretName(dbName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrCredentialsPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials of %s: %w", dbName, err)
+	}
+	return newDatabaseCredentials(p, namespace, dbName, secret.Data), nil
+}
+
+func (p statefulSetProvider) Backup(namespace, dbName string) (string, error) {
+	if _, err := p.get(namespace, dbName); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%w: backups of a %s database", ErrNotSupported, p.Name())
+}
@@ -0,0 +1,346 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// zalandoGroupVersion serves the operator's postgresql resource.
+const zalandoGroupVersion = "acid.zalan.do/v1"
+
+// zalandoProvider runs clusters through the Zalando postgres-operator's
+// postgresql resource. Its status model is documented in status.go.
+type zalandoProvider struct{}
+
+func (zalandoProvider) Name() string { return ProviderZalando }
+
+func (zalandoProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{MaxReplicas: 5, Backups: true}
+}
+
+func (zalandoProvider) Engine() string { return EnginePostgreSQL }
+
+func (zalandoProvider) Port() int { return 5432 }
+
+func (zalandoProvider) Host(namespace, dbName string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", dbName, namespace)
+}
+
+func (zalandoProvider) PodLabels(dbName string) map[string]string {
+	return map[string]string{"cluster-name": dbName}
+}
+
+func (zalandoProvider) IsPrimary(pod corev1.Pod) bool {
+	return pod.Labels["spilo-role"] == "master"
+}
+
+func (zalandoProvider) SecretName(dbName string) string { return zalandoSecretName(dbName) }
+
+func zalandoSecretName(dbName string) string {
+	return dbName + "." + dbName + zalandoSecretSuffix
+}
+
+func (zalandoProvider) get(namespace, dbName string) (*postgresqlCR, error) {
+	output, err := kubectlGet(zalandoGroupVersion, "postgresql", dbName, "-n", namespace, "--ignore-not-found", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PostgreSQL cluster %s: %w", dbName, err)
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, ErrDatabaseNotFound
+	}
+	return parsePostgresqlCR(output)
+}
+
+func (zalandoProvider) Exists(namespace, dbName string) (bool, error) {
+	output, err := kubectlGet(zalandoGroupVersion, "postgresql", dbName, "-n", namespace, "--ignore-not-found", "-o", "name")
+	if err != nil {
+		return false, fmt.Errorf("failed to check for PostgreSQL cluster %s: %w", dbName, err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+func (zalandoProvider) List(namespace string) ([]string, error) {
+	output, err := kubectlGet(zalandoGroupVersion, "postgresql", "-n", namespace, "-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PostgreSQL clusters: %w", err)
+	}
+	return strings.Fields(strings.TrimSpace(string(output))), nil
+}
+
+// Provision renders the postgresql manifest. New clusters are created (not
+// applied) so a concurrent request for the same name fails instead of
+// silently re-applying the manifest on a live cluster. Updates keep clusters
+// paused by a tenant suspension at zero instances.
+func (p zalandoProvider) Provision(req ProvisionRequest) (*ProvisionResult, error) {
+	data := newTemplateData(req.Namespace, req.DBName, req.Spec)
+	data.Owner = req.Owner
+
+	verb := "create"
+	if req.Update {
+		verb = "apply"
+		cr, err := p.get(req.Namespace, req.DBName)
+		if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
+			return nil, err
+		}
+		if cr != nil && cr.Metadata.Annotations[PausedAnnotation] == "true" {
+			data.Replicas = 0
+		}
+	}
+
+	buf, err := renderTemplate("postgres-cluster.yaml.tmpl", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("kubectl", verb, "-f", "-")
+	cmd.Stdin = buf
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "AlreadyExists") {
+			return nil, ErrDatabaseExists
+		}
+		fmt.Fprint(os.Stderr, stderr.String())
+		return nil, fmt.Errorf("failed to apply PostgreSQL cluster %s: %w", req.DBName, err)
+	}
+
+	return newProvisionResult(p, req.Namespace, req.DBName, zalandoSecretName(req.DBName),
+		fmt.Sprintf("kubectl get postgresql %s -n %s", req.DBName, req.Namespace)), nil
+}
+
+func (p zalandoProvider) Describe(namespace, dbName string) (*DatabaseClusterInfo, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	cr, err := p.get(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &DatabaseClusterInfo{
+		Name:      dbName,
+		Namespace: namespace,
+	}
+
+	var members []memberState
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("cluster-name=%s", dbName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for cluster %s: %w", dbName, err)
+	}
+
+	runningCount := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			runningCount++
+		}
+		// Get creation time from first pod only
+		if cluster.CreatedAt == "" {
+			cluster.CreatedAt = pod.CreationTimestamp.Format("2006-01-02 15:04:05")
+		}
+		members = append(members, podMemberState(pod))
+	}
+	cluster.Replicas = len(pods.Items)
+	cluster.RunningReplicas = runningCount
+
+	obs := observeCluster(cr, members)
+	cluster.DesiredReplicas = obs.ExpectedMembers
+	cluster.Status, cluster.DetailedStatus = deriveClusterStatus(obs)
+
+	_, err = clientset.CoreV1().Secrets(namespace).Get(context.TODO(), zalandoSecretName(dbName), metav1.GetOptions{})
+	if err == nil {
+		cluster.CredentialsReady = true
+	}
+	return cluster, nil
+}
+
+func (p zalandoProvider) Delete(namespace, dbName string) error {
+	fmt.Printf("Deleting database %s in namespace %s\n", dbName, namespace)
+
+	// Delete the PostgreSQL cluster using kubectl (since it's a CRD)
+	output, err := exec.Command("kubectl", "delete", "postgresql", dbName, "-n", namespace).CombinedOutput()
+	if err != nil {
+		if kubectlNotFound(output) {
+			return ErrDatabaseNotFound
+		}
+		fmt.Printf("Failed to delete PostgreSQL cluster: %v, output: %s\n", err, string(output))
+		return fmt.Errorf("failed to delete PostgreSQL cluster %s: %w", dbName, err)
+	}
+
+	fmt.Printf("Successfully deleted PostgreSQL cluster %s\n", dbName)
+
+	// Wait a moment for the operator to clean up
+	time.Sleep(2 * time.Second)
+
+	// The Zalando operator should automatically clean up associated secrets
+	// But we can also manually delete them if needed
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	// Delete associated secrets (optional, operator usually handles this)
+	secretNames := []string{
+		zalandoSecretName(dbName),
+		"postgres." + dbName + zalandoSecretSuffix,
+	}
+
+	for _, secretName := range secretNames {
+		err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), secretName, metav1.DeleteOptions{})
+		if err != nil {
+			fmt.Printf("Could not delete secret %s (may not exist): %v\n", secretName, err)
+		} else {
+			fmt.Printf("Deleted secret %s\n", secretName)
+		}
+	}
+
+	return nil
+}
+
+func (p zalandoProvider) Scale(namespace, dbName string, replicas int) error {
+	exists, err := p.Exists(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrDatabaseNotFound
+	}
+	return patchPostgresql(namespace, dbName, fmt.Sprintf(`{"spec":{"numberOfInstances":%d}}`, replicas))
+}
+
+func (p zalandoProvider) Credentials(namespace, dbName string) (*DatabaseCredentials, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), zalandoSecretName(dbName), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, ErrCredentialsPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials of %s: %w", dbName, err)
+	}
+	return newDatabaseCredentials(p, namespace, dbName, secret.Data), nil
+}
+
+// Backup creates a Job from the operator's logical-backup CronJob. If logical
+// backups are not enabled on the cluster yet they are switched on and
+// ErrBackupPending is returned.
+func (p zalandoProvider) Backup(namespace, dbName string) (string, error) {
+	exists, err := p.Exists(namespace, dbName)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrDatabaseNotFound
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return "", fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	cronJob, err := clientset.BatchV1().CronJobs(namespace).Get(context.TODO(), "logical-backup-"+dbName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if err := patchPostgresql(namespace, dbName, `{"spec":{"enableLogicalBackup":true}}`); err != nil {
+			return "", err
+		}
+		return "", ErrBackupPending
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get backup CronJob: %w", err)
+	}
+
+	// Shorten the CronJob name rather than the timestamp, so the Job name
+	// stays unique and still starts with an alphanumeric
+	suffix := fmt.Sprintf("-manual-%d", time.Now().Unix())
+	prefix := cronJob.Name
+	if len(prefix)+len(suffix) > 63 {
+		prefix = strings.TrimRight(prefix[:63-len(suffix)], "-")
+	}
+	name := prefix + suffix
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: map[string]string{"cronjob.kubernetes.io/instantiate": "manual"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+
+	if _, err := clientset.BatchV1().Jobs(namespace).Create(context.TODO(), job, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to create backup job: %w", err)
+	}
+	return name, nil
+}
+
+// Pause scales the cluster to zero instances, remembering the previous
+// instance count so Resume can restore it.
+func (p zalandoProvider) Pause(namespace, dbName string) error {
+	cr, err := p.get(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	if cr.Metadata.Annotations[PausedAnnotation] == "true" {
+		return nil
+	}
+
+	instances := 1
+	if cr.Spec.NumberOfInstances != nil {
+		instances = *cr.Spec.NumberOfInstances
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true",%q:"%d"}},"spec":{"numberOfInstances":0}}`,
+		PausedAnnotation, pausedInstancesAnnotation, instances)
+	return patchPostgresql(namespace, dbName, patch)
+}
+
+func (p zalandoProvider) Resume(namespace, dbName string) error {
+	cr, err := p.get(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	if cr.Metadata.Annotations[PausedAnnotation] != "true" {
+		return nil
+	}
+
+	instances, err := strconv.Atoi(cr.Metadata.Annotations[pausedInstancesAnnotation])
+	if err != nil || instances <= 0 {
+		instances = 1
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null,%q:null}},"spec":{"numberOfInstances":%d}}`,
+		PausedAnnotation, pausedInstancesAnnotation, instances)
+	return patchPostgresql(namespace, dbName, patch)
+}
+
+func patchPostgresql(namespace, dbName, patch string) error {
+	cmd := exec.Command("kubectl", "patch", "postgresql", dbName, "-n", namespace, "--type", "merge", "-p", patch)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to patch PostgreSQL cluster %s: %w, output: %s", dbName, err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Message brokers a queue can run.
+const (
+	QueueEngineRabbitMQ = "rabbitmq"
+	QueueEngineNATS     = "nats" // with JetStream enabled
+)
+
+const (
+	DefaultQueueEngine  = QueueEngineRabbitMQ
+	DefaultQueueMemory  = "256Mi"
+	DefaultQueueStorage = "1Gi"
+
+	// MaxQueueNameLength leaves room for the "-queue" suffix within MaxDBNameLength.
+	MaxQueueNameLength = MaxDBNameLength - len(queueSuffix)
+
+	queueSuffix = "-queue"
+
+	rabbitMQImage          = "rabbitmq:3.13-management-alpine"
+	rabbitMQPort           = 5672
+	rabbitMQManagementPort = 15672
+	natsImage              = "nats:2.10-alpine"
+	natsPort               = 4222
+	natsMonitorPort        = 8222
+
+	queueMemoryAnnotation  = "paas.cloudtrack.io/queue-memory"
+	queueStorageAnnotation = "paas.cloudtrack.io/queue-storage"
+)
+
+var (
+	minQueueMemory  = resource.MustParse("128Mi")
+	maxQueueMemory  = resource.MustParse("4Gi")
+	minQueueStorage = resource.MustParse("1Gi")
+	maxQueueStorage = resource.MustParse("50Gi")
+
+	ErrQueueNotFound = errors.New("queue not found")
+	ErrQueueExists   = errors.New("queue already exists")
+)
+
+// QueueSpec is the desired state of a message broker.
+type QueueSpec struct {
+	Engine  string `json:"engine,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+	Storage string `json:"storage,omitempty"`
+}
+
+// Default fills unset fields with the platform defaults.
+func (s *QueueSpec) Default() {
+	if s.Engine == "" {
+		s.Engine = DefaultQueueEngine
+	}
+	if s.Memory == "" {
+		s.Memory = DefaultQueueMemory
+	}
+	if s.Storage == "" {
+		s.Storage = DefaultQueueStorage
+	}
+}
+
+// Validate returns problems keyed by field. It expects a defaulted spec.
+func (s QueueSpec) Validate() map[string][]string {
+	problems := map[string][]string{}
+	switch s.Engine {
+	case QueueEngineRabbitMQ, QueueEngineNATS:
+	default:
+		problems["engine"] = []string{fmt.Sprintf("must be %s or %s", QueueEngineRabbitMQ, QueueEngineNATS)}
+	}
+	memory, err := resource.ParseQuantity(s.Memory)
+	if err != nil {
+		problems["memory"] = []string{"must be a quantity such as 256Mi or 1Gi"}
+	} else if memory.Cmp(minQueueMemory) < 0 || memory.Cmp(maxQueueMemory) > 0 {
+		problems["memory"] = []string{fmt.Sprintf("must be between %s and %s", minQueueMemory.String(), maxQueueMemory.String())}
+	}
+	storage, err := resource.ParseQuantity(s.Storage)
+	if err != nil {
+		problems["storage"] = []string{"must be a quantity such as 1Gi or 10Gi"}
+	} else if storage.Cmp(minQueueStorage) < 0 || storage.Cmp(maxQueueStorage) > 0 {
+		problems["storage"] = []string{fmt.Sprintf("must be between %s and %s", minQueueStorage.String(), maxQueueStorage.String())}
+	}
+	return problems
+}
+
+// queues are message brokers of either engine.
+var queues = statefulService{
+	kind:     "queue",
+	suffix:   queueSuffix,
+	selector: map[string]string{"app.kubernetes.io/component": "queue"},
+	notFound: ErrQueueNotFound,
+	exists:   ErrQueueExists,
+}
+
+// ValidateQueueName checks a queue name against DNS-1123 label rules and the
+// length limit of the objects derived from it.
+func ValidateQueueName(name string) []string {
+	return queues.validateName(name)
+}
+
+func queuePort(engine string) int {
+	if engine == QueueEngineNATS {
+		return natsPort
+	}
+	return rabbitMQPort
+}
+
+// ProvisionQueue creates the credentials secret, service and StatefulSet of a
+// broker and returns without waiting for it to start.
+func ProvisionQueue(namespace, name, owner string, spec QueueSpec) (*QueueInfo, error) {
+	spec.Default()
+
+	password, err := randomPassword(32)
+	if err != nil {
+		return nil, err
+	}
+	secretData := map[string]string{
+		"username": name,
+		"password": password,
+	}
+	ports := []corev1.ServicePort{{Name: "nats", Port: natsPort, TargetPort: intstr.FromString("nats")}}
+	if spec.Engine == QueueEngineRabbitMQ {
+		secretData["vhost"] = name
+		ports = []corev1.ServicePort{
+			{Name: "amqp", Port: rabbitMQPort, TargetPort: intstr.FromString("amqp")},
+			{Name: "management", Port: rabbitMQManagementPort, TargetPort: intstr.FromString("management")},
+		}
+	}
+	objectLabels := queues.labels(name)
+	objectLabels["app.kubernetes.io/name"] = spec.Engine
+
+	err = queues.provision(namespace, name, owner, objectLabels, secretData, ports, queueStatefulSet(name, spec))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Queue creation initiated for %s (%s) in namespace %s\n", name, spec.Engine, namespace)
+
+	return &QueueInfo{
+		Name:           name,
+		Namespace:      namespace,
+		Engine:         spec.Engine,
+		Status:         StatusProvisioning,
+		DetailedStatus: "Queue is being provisioned",
+		Memory:         spec.Memory,
+		Storage:        spec.Storage,
+		Host:           queues.host(namespace, name),
+		Port:           queuePort(spec.Engine),
+	}, nil
+}
+
+// queueContainer runs the broker with the user from the queue's secret.
+func queueContainer(name string, spec QueueSpec, memory resource.Quantity) corev1.Container {
+	secretKey := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: queues.secretName(name)},
+			Key:                  key,
+		}}
+	}
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: memory,
+		},
+		Limits: corev1.ResourceList{corev1.ResourceMemory: memory},
+	}
+
+	if spec.Engine == QueueEngineNATS {
+		return corev1.Container{
+			Name:  "nats",
+			Image: natsImage,
+			Args: []string{
+				"--user", "$(NATS_USER)",
+				"--pass", "$(NATS_PASSWORD)",
+				"--jetstream",
+				"--store_dir", "/data",
+				"--http_port", fmt.Sprint(natsMonitorPort),
+			},
+			Ports: []corev1.ContainerPort{
+				{Name: "nats", ContainerPort: natsPort},
+				{Name: "monitor", ContainerPort: natsMonitorPort},
+			},
+			Env: []corev1.EnvVar{
+				{Name: "NATS_USER", ValueFrom: secretKey("username")},
+				{Name: "NATS_PASSWORD", ValueFrom: secretKey("password")},
+				// Keeps the Go runtime below the container limit
+				{Name: "GOMEMLIMIT", Value: fmt.Sprint(memory.Value() * 8 / 10)},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+			ReadinessProbe: &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("monitor")},
+				},
+				PeriodSeconds: 10,
+			},
+			Resources: resources,
+		}
+	}
+
+	// The default user and vhost are only created on first boot; the data
+	// volume keeps them afterwards.
+	return corev1.Container{
+		Name:  "rabbitmq",
+		Image: rabbitMQImage,
+		Ports: []corev1.ContainerPort{
+			{Name: "amqp", ContainerPort: rabbitMQPort},
+			{Name: "management", ContainerPort: rabbitMQManagementPort},
+		},
+		Env: []corev1.EnvVar{
+			{Name: "RABBITMQ_DEFAULT_USER", ValueFrom: secretKey("username")},
+			{Name: "RABBITMQ_DEFAULT_PASS", ValueFrom: secretKey("password")},
+			{Name: "RABBITMQ_DEFAULT_VHOST", ValueFrom: secretKey("vhost")},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/var/lib/rabbitmq"}},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{Command: []string{"rabbitmq-diagnostics", "-q", "check_port_connectivity"}},
+			},
+			InitialDelaySeconds: 20,
+			PeriodSeconds:       15,
+			TimeoutSeconds:      10,
+		},
+		Resources: resources,
+	}
+}
+
+func queueStatefulSet(name string, spec QueueSpec) *appsv1.StatefulSet {
+	sts := queues.statefulSet(name, map[string]string{
+		queueMemoryAnnotation:  spec.Memory,
+		queueStorageAnnotation: spec.Storage,
+	}, queueContainer(name, spec, resource.MustParse(spec.Memory)))
+	sts.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{queues.dataVolumeClaim(name, resource.MustParse(spec.Storage))}
+	return sts
+}
+
+func describeQueue(clientset kubernetes.Interface, sts *appsv1.StatefulSet, namespace, name string) (*QueueInfo, error) {
+	state, err := queues.observe(clientset, sts, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	engine := sts.Labels["app.kubernetes.io/name"]
+	return &QueueInfo{
+		Name:             name,
+		Namespace:        namespace,
+		Engine:           engine,
+		Status:           state.Status,
+		DetailedStatus:   state.DetailedStatus,
+		CredentialsReady: state.CredentialsReady,
+		Memory:           sts.Annotations[queueMemoryAnnotation],
+		Storage:          sts.Annotations[queueStorageAnnotation],
+		Host:             queues.host(namespace, name),
+		Port:             queuePort(engine),
+		CreatedAt:        state.CreatedAt,
+	}, nil
+}
+
+// GetQueue returns ErrQueueNotFound if there is no such queue.
+func GetQueue(namespace, name string) (*QueueInfo, error) {
+	sts, err := queues.get(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	return describeQueue(clientset, sts, namespace, name)
+}
+
+func ListQueues(namespace string) ([]QueueInfo, error) {
+	statefulSets, err := queues.list(namespace)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	list := []QueueInfo{}
+	for i := range statefulSets {
+		sts := &statefulSets[i]
+		name := queues.instanceName(sts)
+		queue, err := describeQueue(clientset, sts, namespace, name)
+		if err != nil {
+			list = append(list, QueueInfo{
+				Name:           name,
+				Namespace:      namespace,
+				Engine:         sts.Labels["app.kubernetes.io/name"],
+				Status:         StatusFailed,
+				DetailedStatus: fmt.Sprintf("Failed to get info: %v", err),
+			})
+			continue
+		}
+		list = append(list, *queue)
+	}
+	return list, nil
+}
+
+// GetQueueCredentials returns ErrCredentialsPending until the credentials
+// secret exists.
+func GetQueueCredentials(namespace, name string) (*QueueCredentials, error) {
+	sts, secret, err := queues.credentials(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := sts.Labels["app.kubernetes.io/name"]
+	host := queues.host(namespace, name)
+	port := queuePort(engine)
+	credentials := &QueueCredentials{
+		Name:     name,
+		Engine:   engine,
+		Host:     host,
+		Port:     port,
+		Username: string(secret.Data["username"]),
+		Password: string(secret.Data["password"]),
+		VHost:    string(secret.Data["vhost"]),
+	}
+	uri := url.URL{
+		Scheme: engine,
+		User:   url.UserPassword(credentials.Username, credentials.Password),
+		Host:   fmt.Sprintf("%s:%d", host, port),
+	}
+	if engine == QueueEngineRabbitMQ {
+		uri.Scheme = "amqp"
+		uri.Path = "/" + credentials.VHost
+		credentials.ManagementURL = fmt.Sprintf("http://%s:%d", host, rabbitMQManagementPort)
+	}
+	credentials.URI = uri.String()
+	return credentials, nil
+}
+
+// DeleteQueue removes the StatefulSet, whose retention policy deletes the
+// volume, and the service and secret created with it.
+func DeleteQueue(namespace, name string) error {
+	return queues.delete(namespace, name)
+}
+
+// setQueuesPaused scales every queue in the namespace to zero, or back to
+// one, as part of a tenant suspension.
+func setQueuesPaused(namespace string, paused bool) (int, error) {
+	return queues.setPaused(namespace, paused)
+}
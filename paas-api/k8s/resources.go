@@ -0,0 +1,42 @@
+package k8s
+
+import "paas-api/api"
+
+// The resources this package reports are defined in package api, so that
+// API clients can decode them without depending on client-go.
+type (
+	DatabaseClusterInfo = api.DatabaseClusterInfo
+	ProvisionResult     = api.ProvisionResult
+	DatabaseCredentials = api.DatabaseCredentials
+	DatabaseBinding     = api.DatabaseBinding
+
+	CacheInfo        = api.CacheInfo
+	CacheCredentials = api.CacheCredentials
+
+	QueueInfo        = api.QueueInfo
+	QueueCredentials = api.QueueCredentials
+
+	BucketInfo        = api.BucketInfo
+	BucketCredentials = api.BucketCredentials
+
+	AppSpec     = api.AppSpec
+	AppInfo     = api.AppInfo
+	AppRevision = api.AppRevision
+	AppRollout  = api.AppRollout
+
+	JobSpec = api.JobSpec
+	JobInfo = api.JobInfo
+	JobRun  = api.JobRun
+
+	RouteInfo       = api.RouteInfo
+	DomainChallenge = api.DomainChallenge
+
+	TenantQuota = api.TenantQuota
+	TenantInfo  = api.TenantInfo
+
+	OrgMember     = api.OrgMember
+	OrgInvitation = api.OrgInvitation
+	Organization  = api.Organization
+
+	APIToken = api.APIToken
+)
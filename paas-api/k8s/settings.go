@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Settings are the cluster-side options of the API, applied once at startup
+// by Configure.
+type Settings struct {
+	// Kubeconfig is tried before the in-cluster configuration, defaulting to
+	// ~/.kube/config. kubectl runs with it too.
+	Kubeconfig    string
+	ClientTimeout time.Duration
+
+	TenantNamespacePrefix string
+	SystemNamespace       string
+	OperatorNamespace     string // where the postgres operators run
+	TemplateDir           string
+	DefaultTeam           string
+	SnapshotClass         string // VolumeSnapshotClass for CloudNativePG backups
+
+	// TenantStorageQuota caps the storage requests of every tenant namespace
+	// with databases; zero leaves them uncapped.
+	TenantStorageQuota resource.Quantity
+}
+
+var settings = Settings{
+	ClientTimeout:         30 * time.Second,
+	TenantNamespacePrefix: TenantNamespacePrefix,
+	SystemNamespace:       "paas-system",
+	OperatorNamespace:     "default",
+	TemplateDir:           "templates",
+	DefaultTeam:           DefaultTeam,
+	TenantStorageQuota:    resource.MustParse("200Gi"),
+}
+
+// Configure replaces the settings. It must run before any request is served;
+// changing the tenant namespace prefix orphans existing tenants.
+func Configure(s Settings) {
+	settings = s
+	TenantNamespacePrefix = s.TenantNamespacePrefix
+	MaxTenantNameLength = validation.DNS1123LabelMaxLength - len(TenantNamespacePrefix)
+	DefaultTeam = s.DefaultTeam
+
+	// The kubectl subprocesses inherit the environment
+	if s.Kubeconfig != "" {
+		os.Setenv("KUBECONFIG", s.Kubeconfig)
+	}
+}
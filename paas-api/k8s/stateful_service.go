@@ -0,0 +1,307 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// statefulService is a kind of tenant service that runs as a single-replica
+// StatefulSet next to a Service and a credentials secret, such as a cache or
+// a queue. It does what every such kind does alike; each kind supplies its
+// container, ports and credentials.
+type statefulService struct {
+	kind     string            // e.g. "cache", in messages
+	suffix   string            // appended to the instance name to name its objects
+	selector map[string]string // labels every instance of the kind carries
+	notFound error
+	exists   error
+}
+
+// instanceState is what observe reports about an instance.
+type instanceState struct {
+	Status           string
+	DetailedStatus   string
+	CreatedAt        string
+	CredentialsReady bool
+}
+
+// validateName checks an instance name against DNS-1123 label rules and the
+// length limit of the objects derived from it.
+func (s statefulService) validateName(name string) []string {
+	if name == "" {
+		return []string{"must not be empty"}
+	}
+	problems := validation.IsDNS1123Label(name)
+	if max := MaxDBNameLength - len(s.suffix); len(name) > max {
+		problems = append(problems, fmt.Sprintf("must be no more than %d characters", max))
+	}
+	return problems
+}
+
+func (s statefulService) objectName(name string) string {
+	return name + s.suffix
+}
+
+func (s statefulService) secretName(name string) string {
+	return name + s.suffix + "-auth"
+}
+
+func (s statefulService) host(namespace, name string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", s.objectName(name), namespace)
+}
+
+func (s statefulService) podLabels(name string) map[string]string {
+	l := map[string]string{"app.kubernetes.io/instance": name}
+	for k, v := range s.selector {
+		l[k] = v
+	}
+	return l
+}
+
+// labels are set on every object of the instance.
+func (s statefulService) labels(name string) map[string]string {
+	l := s.podLabels(name)
+	l["app.kubernetes.io/managed-by"] = "paas-api"
+	return l
+}
+
+// instanceName is the inverse of objectName.
+func (s statefulService) instanceName(sts *appsv1.StatefulSet) string {
+	return strings.TrimSuffix(sts.Name, s.suffix)
+}
+
+// get returns the notFound error of the kind if there is no such instance,
+// including when a StatefulSet of that name belongs to something else.
+func (s statefulService) get(namespace, name string) (*appsv1.StatefulSet, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	sts, err := clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), s.objectName(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, s.notFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", s.kind, name, err)
+	}
+	for k, v := range s.selector {
+		if sts.Labels[k] != v {
+			return nil, s.notFound
+		}
+	}
+	return sts, nil
+}
+
+// statefulSet returns the StatefulSet running container as a single pod.
+// provision names it; callers add the volumes the container mounts.
+func (s statefulService) statefulSet(name string, annotations map[string]string, container corev1.Container) *appsv1.StatefulSet {
+	replicas := int32(1)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: s.podLabels(name)},
+			PersistentVolumeClaimRetentionPolicy: &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: appsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+				WhenScaled:  appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: s.podLabels(name)},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container}},
+			},
+		},
+	}
+}
+
+// dataVolumeClaim is the claim template of the "data" volume.
+func (s statefulService) dataVolumeClaim(name string, size resource.Quantity) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Labels: s.podLabels(name)},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+}
+
+// provision creates the credentials secret, the Service and the StatefulSet
+// of a new instance, all labelled with objectLabels, and returns without
+// waiting for it to start.
+func (s statefulService) provision(namespace, name, owner string, objectLabels, secretData map[string]string, ports []corev1.ServicePort, sts *appsv1.StatefulSet) error {
+	_, err := s.get(namespace, name)
+	if err == nil {
+		return s.exists
+	}
+	if !errors.Is(err, s.notFound) {
+		return err
+	}
+
+	if err := EnsureTenantNamespace(namespace, owner); err != nil {
+		return err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	objectMeta := func(objectName string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: objectName, Namespace: namespace, Labels: objectLabels}
+	}
+
+	secret := &corev1.Secret{ObjectMeta: objectMeta(s.secretName(name)), StringData: secretData}
+	_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create secret %s: %w", secret.Name, err)
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: objectMeta(s.objectName(name)),
+		Spec:       corev1.ServiceSpec{Selector: s.podLabels(name), Ports: ports},
+	}
+	_, err = clientset.CoreV1().Services(namespace).Create(context.TODO(), service, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create service %s: %w", service.Name, err)
+	}
+
+	meta := objectMeta(s.objectName(name))
+	meta.Annotations = sts.Annotations
+	sts.ObjectMeta = meta
+	sts.Spec.ServiceName = meta.Name
+	if _, err := clientset.AppsV1().StatefulSets(namespace).Create(context.TODO(), sts, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return s.exists
+		}
+		return fmt.Errorf("failed to create %s %s: %w", s.kind, name, err)
+	}
+	return nil
+}
+
+// observe derives the state of an instance from its pods and secret.
+func (s statefulService) observe(clientset kubernetes.Interface, sts *appsv1.StatefulSet, namespace, name string) (instanceState, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(s.podLabels(name)).String(),
+	})
+	if err != nil {
+		return instanceState{}, fmt.Errorf("failed to list pods for %s %s: %w", s.kind, name, err)
+	}
+
+	state := instanceState{CreatedAt: sts.CreationTimestamp.Format("2006-01-02 15:04:05")}
+	kind := strings.ToUpper(s.kind[:1]) + s.kind[1:]
+	state.Status, state.DetailedStatus, _ = observeStatefulSet(sts, pods.Items, kind)
+
+	_, err = clientset.CoreV1().Secrets(namespace).Get(context.TODO(), s.secretName(name), metav1.GetOptions{})
+	state.CredentialsReady = err == nil
+	return state, nil
+}
+
+// list returns the StatefulSets of every instance in the namespace, by name.
+func (s statefulService) list(namespace string) ([]appsv1.StatefulSet, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+	selector := labels.Set{"app.kubernetes.io/managed-by": "paas-api"}
+	for k, v := range s.selector {
+		selector[k] = v
+	}
+	list, err := clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %ss: %w", s.kind, err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+// credentials returns the StatefulSet and credentials secret of an instance,
+// or ErrCredentialsPending if the secret does not exist yet.
+func (s statefulService) credentials(namespace, name string) (*appsv1.StatefulSet, *corev1.Secret, error) {
+	sts, err := s.get(namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), s.secretName(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil, ErrCredentialsPending
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get credentials of %s %s: %w", s.kind, name, err)
+	}
+	return sts, secret, nil
+}
+
+// delete removes the StatefulSet, whose retention policy deletes the volume,
+// and the Service and secret created with it.
+func (s statefulService) delete(namespace, name string) error {
+	if _, err := s.get(namespace, name); err != nil {
+		return err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	if err := clientset.AppsV1().StatefulSets(namespace).Delete(context.TODO(), s.objectName(name), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s %s: %w", s.kind, name, err)
+	}
+	if err := clientset.CoreV1().Services(namespace).Delete(context.TODO(), s.objectName(name), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete service of %s %s: %w", s.kind, name, err)
+	}
+	if err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), s.secretName(name), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret of %s %s: %w", s.kind, name, err)
+	}
+	fmt.Printf("Deleted %s %s/%s\n", s.kind, namespace, name)
+	return nil
+}
+
+// setPaused scales every instance in the namespace to zero, or back to one,
+// as part of a tenant suspension.
+func (s statefulService) setPaused(namespace string, paused bool) (int, error) {
+	statefulSets, err := s.list(namespace)
+	if err != nil {
+		return 0, err
+	}
+	clientset, err := getKubeClient()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	for i := range statefulSets {
+		sts := &statefulSets[i]
+		replicas := int32(1)
+		if sts.Annotations == nil {
+			sts.Annotations = map[string]string{}
+		}
+		if paused {
+			replicas = 0
+			sts.Annotations[PausedAnnotation] = "true"
+		} else {
+			delete(sts.Annotations, PausedAnnotation)
+		}
+		sts.Spec.Replicas = &replicas
+		if _, err := clientset.AppsV1().StatefulSets(namespace).Update(context.TODO(), sts, metav1.UpdateOptions{}); err != nil {
+			return 0, fmt.Errorf("failed to update %s %s: %w", s.kind, sts.Name, err)
+		}
+	}
+	return len(statefulSets), nil
+}
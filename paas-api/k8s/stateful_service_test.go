@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStatefulServicesAreSeparate(t *testing.T) {
+	useFakeKube(t)
+	const namespace = "tenant-alice"
+
+	if _, err := ProvisionCache(namespace, "shared", "alice", CacheSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProvisionQueue(namespace, "shared", "alice", QueueSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProvisionCache(namespace, "shared", "alice", CacheSpec{}); !errors.Is(err, ErrCacheExists) {
+		t.Errorf("second ProvisionCache() error = %v, want ErrCacheExists", err)
+	}
+
+	for _, s := range []statefulService{caches, queues} {
+		list, err := s.list(namespace)
+		if err != nil || len(list) != 1 || s.instanceName(&list[0]) != "shared" {
+			t.Errorf("%s list = %d items, %v", s.kind, len(list), err)
+		}
+	}
+
+	if n, err := setCachesPaused(namespace, true); err != nil || n != 1 {
+		t.Fatalf("setCachesPaused() = %d, %v", n, err)
+	}
+	cache, err := caches.get(namespace, "shared")
+	if err != nil || *cache.Spec.Replicas != 0 || cache.Annotations[PausedAnnotation] != "true" {
+		t.Errorf("paused cache: %v", err)
+	}
+	queue, err := queues.get(namespace, "shared")
+	if err != nil || *queue.Spec.Replicas != 1 {
+		t.Errorf("pausing caches changed the queue: %v", err)
+	}
+
+	if err := DeleteCache(namespace, "shared"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCache(namespace, "shared"); !errors.Is(err, ErrCacheNotFound) {
+		t.Errorf("GetCache() after delete error = %v, want ErrCacheNotFound", err)
+	}
+	if _, err := GetQueue(namespace, "shared"); err != nil {
+		t.Errorf("deleting the cache deleted the queue: %v", err)
+	}
+}
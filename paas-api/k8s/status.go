@@ -0,0 +1,214 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"paas-api/api"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Cluster lifecycle states reported in DatabaseClusterInfo.Status.
+//
+// The state is derived from three sources, in this order of precedence:
+//
+//  1. The postgresql CR itself (deletionTimestamp, paused flag, PostgresClusterStatus)
+//  2. Patroni leader labels on the pods (spilo-role=master / spilo-role=replica)
+//  3. Container readiness of those pods
+//
+// Transitions:
+//
+//	(CR created)           -> Provisioning   no pods scheduled yet
+//	Provisioning           -> Initializing   pods exist, no ready Patroni leader yet,
+//	                                         or the leader is ready and replicas are still starting
+//	Initializing           -> Ready          one ready leader, all expected members ready
+//	Initializing           -> Failed         operator reports CreateFailed/AddFailed/Invalid,
+//	                                         or containers crash-loop before a leader appears
+//	Ready                  -> Degraded       leader ready but some members not ready,
+//	                                         or operator reports SyncFailed/UpdateFailed
+//	Ready|Degraded         -> Failing over   cluster was running but has no ready leader,
+//	                                         or more than one pod claims leadership
+//	Failing over           -> Ready|Degraded a single ready leader is elected again
+//	Ready|Degraded         -> Updating       operator reports Updating (spec change/rolling restart)
+//	Updating               -> Ready|Degraded operator finishes the sync
+//	any                    -> Paused         numberOfInstances is 0 or the paused annotation is set
+//	Paused                 -> Initializing   instances are scaled back up
+//	any                    -> Deleting       deletionTimestamp is set on the CR
+const (
+	StatusProvisioning = api.StatusProvisioning
+	StatusInitializing = api.StatusInitializing
+	StatusReady        = api.StatusReady
+	StatusDegraded     = api.StatusDegraded
+	StatusFailingOver  = api.StatusFailingOver
+	StatusUpdating     = api.StatusUpdating
+	StatusPaused       = api.StatusPaused
+	StatusFailed       = api.StatusFailed
+	StatusDeleting     = api.StatusDeleting
+)
+
+// PausedAnnotation marks a postgresql CR as intentionally stopped by the platform.
+const PausedAnnotation = "paas.cloudtrack.io/paused"
+
+// postgresqlCR is the subset of the Zalando postgresql resource we read.
+type postgresqlCR struct {
+	Metadata struct {
+		Name              string            `json:"name"`
+		Annotations       map[string]string `json:"annotations"`
+		CreationTimestamp string            `json:"creationTimestamp"`
+		DeletionTimestamp string            `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		NumberOfInstances *int `json:"numberOfInstances"`
+		Volume            struct {
+			Size string `json:"size"`
+		} `json:"volume"`
+	} `json:"spec"`
+	Status struct {
+		PostgresClusterStatus string `json:"PostgresClusterStatus"`
+	} `json:"status"`
+}
+
+func parsePostgresqlCR(raw []byte) (*postgresqlCR, error) {
+	var cr postgresqlCR
+	if err := json.Unmarshal(raw, &cr); err != nil {
+		return nil, fmt.Errorf("failed to parse postgresql resource: %w", err)
+	}
+	return &cr, nil
+}
+
+// clusterObservation is everything deriveClusterStatus needs, gathered from the API.
+type clusterObservation struct {
+	Deleting         bool
+	Paused           bool
+	OperatorStatus   string // PostgresClusterStatus from the CR
+	ExpectedMembers  int    // spec.numberOfInstances
+	Pods             int
+	ReadyPods        int
+	Leaders          int // pods labelled spilo-role=master
+	ReadyLeaders     int
+	CrashLoopingPods int
+}
+
+// memberState is the per-pod view used to build a clusterObservation.
+type memberState struct {
+	Role        string
+	Ready       bool
+	CrashLoop   bool
+	Terminating bool
+}
+
+func podMemberState(pod corev1.Pod) memberState {
+	m := memberState{
+		Role:        pod.Labels["spilo-role"],
+		Terminating: pod.DeletionTimestamp != nil,
+	}
+
+	if pod.Status.Phase == corev1.PodRunning && len(pod.Status.ContainerStatuses) > 0 {
+		m.Ready = true
+		for _, cs := range pod.Status.ContainerStatuses {
+			if !cs.Ready {
+				m.Ready = false
+			}
+		}
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+			m.CrashLoop = true
+		}
+	}
+	return m
+}
+
+func observeCluster(cr *postgresqlCR, members []memberState) clusterObservation {
+	obs := clusterObservation{
+		Deleting:        cr.Metadata.DeletionTimestamp != "",
+		OperatorStatus:  cr.Status.PostgresClusterStatus,
+		ExpectedMembers: 1,
+	}
+	if cr.Spec.NumberOfInstances != nil {
+		obs.ExpectedMembers = *cr.Spec.NumberOfInstances
+	}
+	obs.Paused = obs.ExpectedMembers == 0 || cr.Metadata.Annotations[PausedAnnotation] == "true"
+
+	for _, m := range members {
+		if m.Terminating {
+			continue
+		}
+		obs.Pods++
+		if m.Ready {
+			obs.ReadyPods++
+		}
+		if m.Role == "master" {
+			obs.Leaders++
+			if m.Ready {
+				obs.ReadyLeaders++
+			}
+		}
+		if m.CrashLoop {
+			obs.CrashLoopingPods++
+		}
+	}
+	return obs
+}
+
+// deriveClusterStatus implements the state machine documented above.
+// It returns the state and a human readable explanation.
+func deriveClusterStatus(obs clusterObservation) (string, string) {
+	if obs.Deleting {
+		return StatusDeleting, "Database cluster is being deleted"
+	}
+
+	if obs.Paused {
+		if obs.Pods > 0 {
+			return StatusPaused, fmt.Sprintf("Database cluster is pausing (%d pods still running)", obs.Pods)
+		}
+		return StatusPaused, "Database cluster is paused"
+	}
+
+	switch obs.OperatorStatus {
+	case "CreateFailed", "AddFailed", "Invalid":
+		return StatusFailed, fmt.Sprintf("Operator reported %s", obs.OperatorStatus)
+	}
+
+	wasRunning := obs.OperatorStatus == "Running" || obs.OperatorStatus == "Updating" ||
+		obs.OperatorStatus == "UpdateFailed" || obs.OperatorStatus == "SyncFailed"
+
+	if obs.Pods == 0 {
+		if wasRunning {
+			return StatusFailingOver, "No database pods are running, waiting for them to be rescheduled"
+		}
+		return StatusProvisioning, "Database cluster is being provisioned"
+	}
+
+	if obs.Leaders > 1 {
+		return StatusFailingOver, fmt.Sprintf("%d pods claim the leader role", obs.Leaders)
+	}
+
+	if obs.ReadyLeaders == 0 {
+		if !wasRunning {
+			if obs.CrashLoopingPods > 0 {
+				return StatusFailed, fmt.Sprintf("%d pods are crash-looping before a leader was elected", obs.CrashLoopingPods)
+			}
+			return StatusInitializing, "Database pods are starting, waiting for leader election"
+		}
+		return StatusFailingOver, "No ready leader, Patroni is electing a new primary"
+	}
+
+	if obs.OperatorStatus == "Updating" {
+		return StatusUpdating, "Operator is applying changes to the cluster"
+	}
+
+	if obs.OperatorStatus == "UpdateFailed" || obs.OperatorStatus == "SyncFailed" {
+		return StatusDegraded, fmt.Sprintf("Leader is ready but operator reported %s", obs.OperatorStatus)
+	}
+
+	if obs.ReadyPods < obs.ExpectedMembers {
+		if !wasRunning {
+			return StatusInitializing, fmt.Sprintf("%d of %d members ready, replicas are starting", obs.ReadyPods, obs.ExpectedMembers)
+		}
+		return StatusDegraded, fmt.Sprintf("%d of %d members ready", obs.ReadyPods, obs.ExpectedMembers)
+	}
+
+	return StatusReady, "Database is ready"
+}
@@ -0,0 +1,205 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeriveClusterStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		obs  clusterObservation
+		want string
+	}{
+		{
+			name: "deleting wins over everything",
+			obs:  clusterObservation{Deleting: true, Paused: true, OperatorStatus: "CreateFailed"},
+			want: StatusDeleting,
+		},
+		{
+			name: "paused",
+			obs:  clusterObservation{Paused: true, OperatorStatus: "Running"},
+			want: StatusPaused,
+		},
+		{
+			name: "pausing with pods left",
+			obs:  clusterObservation{Paused: true, OperatorStatus: "Running", Pods: 1},
+			want: StatusPaused,
+		},
+		{
+			name: "operator failed to create",
+			obs:  clusterObservation{OperatorStatus: "CreateFailed", ExpectedMembers: 1, Pods: 1},
+			want: StatusFailed,
+		},
+		{
+			name: "invalid spec",
+			obs:  clusterObservation{OperatorStatus: "Invalid", ExpectedMembers: 1},
+			want: StatusFailed,
+		},
+		{
+			name: "no pods yet",
+			obs:  clusterObservation{OperatorStatus: "Creating", ExpectedMembers: 1},
+			want: StatusProvisioning,
+		},
+		{
+			name: "pods starting before leader election",
+			obs:  clusterObservation{OperatorStatus: "Creating", ExpectedMembers: 2, Pods: 2},
+			want: StatusInitializing,
+		},
+		{
+			name: "crash loop before a leader",
+			obs:  clusterObservation{OperatorStatus: "Creating", ExpectedMembers: 1, Pods: 1, CrashLoopingPods: 1},
+			want: StatusFailed,
+		},
+		{
+			name: "new cluster with ready leader and starting replicas",
+			obs: clusterObservation{
+				OperatorStatus: "Creating", ExpectedMembers: 3,
+				Pods: 3, ReadyPods: 1, Leaders: 1, ReadyLeaders: 1,
+			},
+			want: StatusInitializing,
+		},
+		{
+			name: "ready single instance",
+			obs: clusterObservation{
+				OperatorStatus: "Running", ExpectedMembers: 1,
+				Pods: 1, ReadyPods: 1, Leaders: 1, ReadyLeaders: 1,
+			},
+			want: StatusReady,
+		},
+		{
+			name: "ready before the operator reports running",
+			obs: clusterObservation{
+				OperatorStatus: "Creating", ExpectedMembers: 2,
+				Pods: 2, ReadyPods: 2, Leaders: 1, ReadyLeaders: 1,
+			},
+			want: StatusReady,
+		},
+		{
+			name: "running cluster lost a replica",
+			obs: clusterObservation{
+				OperatorStatus: "Running", ExpectedMembers: 3,
+				Pods: 3, ReadyPods: 2, Leaders: 1, ReadyLeaders: 1,
+			},
+			want: StatusDegraded,
+		},
+		{
+			name: "operator sync failed",
+			obs: clusterObservation{
+				OperatorStatus: "SyncFailed", ExpectedMembers: 1,
+				Pods: 1, ReadyPods: 1, Leaders: 1, ReadyLeaders: 1,
+			},
+			want: StatusDegraded,
+		},
+		{
+			name: "operator updating",
+			obs: clusterObservation{
+				OperatorStatus: "Updating", ExpectedMembers: 2,
+				Pods: 2, ReadyPods: 1, Leaders: 1, ReadyLeaders: 1,
+			},
+			want: StatusUpdating,
+		},
+		{
+			name: "running cluster without a ready leader",
+			obs: clusterObservation{
+				OperatorStatus: "Running", ExpectedMembers: 2,
+				Pods: 2, ReadyPods: 1, Leaders: 1,
+			},
+			want: StatusFailingOver,
+		},
+		{
+			name: "running cluster with no pods",
+			obs:  clusterObservation{OperatorStatus: "Running", ExpectedMembers: 1},
+			want: StatusFailingOver,
+		},
+		{
+			name: "split brain",
+			obs: clusterObservation{
+				OperatorStatus: "Running", ExpectedMembers: 2,
+				Pods: 2, ReadyPods: 2, Leaders: 2, ReadyLeaders: 2,
+			},
+			want: StatusFailingOver,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detail := deriveClusterStatus(tt.obs)
+			if got != tt.want {
+				t.Errorf("deriveClusterStatus() = %q (%s), want %q", got, detail, tt.want)
+			}
+			if detail == "" {
+				t.Error("deriveClusterStatus() returned no explanation")
+			}
+		})
+	}
+}
+
+func TestObserveCluster(t *testing.T) {
+	three := 3
+	cr := &postgresqlCR{}
+	cr.Spec.NumberOfInstances = &three
+	cr.Status.PostgresClusterStatus = "Running"
+
+	obs := observeCluster(cr, []memberState{
+		{Role: "master", Ready: true},
+		{Role: "replica", Ready: true},
+		{Role: "replica", CrashLoop: true},
+		{Role: "replica", Ready: true, Terminating: true},
+	})
+
+	want := clusterObservation{
+		OperatorStatus:   "Running",
+		ExpectedMembers:  3,
+		Pods:             3,
+		ReadyPods:        2,
+		Leaders:          1,
+		ReadyLeaders:     1,
+		CrashLoopingPods: 1,
+	}
+	if obs != want {
+		t.Errorf("observeCluster() = %+v, want %+v", obs, want)
+	}
+}
+
+func TestObserveClusterPaused(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name string
+		cr   func(*postgresqlCR)
+	}{
+		{"no instances", func(cr *postgresqlCR) { cr.Spec.NumberOfInstances = &zero }},
+		{"paused annotation", func(cr *postgresqlCR) {
+			cr.Metadata.Annotations = map[string]string{PausedAnnotation: "true"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &postgresqlCR{}
+			tt.cr(cr)
+			if !observeCluster(cr, nil).Paused {
+				t.Error("cluster is not paused")
+			}
+		})
+	}
+}
+
+func TestPodMemberState(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"spilo-role": "master"}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Ready: true},
+				{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			},
+		},
+	}
+
+	m := podMemberState(pod)
+	if m.Role != "master" || m.Ready || !m.CrashLoop || m.Terminating {
+		t.Errorf("podMemberState() = %+v", m)
+	}
+}
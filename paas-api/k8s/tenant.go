@@ -0,0 +1,393 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Namespace metadata keys used to track tenant ownership and lifecycle.
+const (
+	TenantOwnerAnnotation       = "paas.cloudtrack.io/owner"
+	TenantSuspendedAnnotation   = "paas.cloudtrack.io/suspended"
+	TenantSuspendedByAnnotation = "paas.cloudtrack.io/suspended-by"
+	TenantSuspendedAtAnnotation = "paas.cloudtrack.io/suspended-at"
+
+	// pausedInstancesAnnotation remembers the instance count of a paused cluster
+	pausedInstancesAnnotation = "paas.cloudtrack.io/paused-instances"
+)
+
+// ErrTenantNotFound is returned for a tenant whose namespace does not exist.
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// tenantName strips the namespace prefix, e.g. "tenant-alice" -> "alice".
+func tenantName(namespace string) string {
+	return strings.TrimPrefix(namespace, TenantNamespacePrefix)
+}
+
+// IsTenantNamespace reports whether namespace holds a tenant's resources.
+func IsTenantNamespace(namespace string) bool {
+	return strings.HasPrefix(namespace, TenantNamespacePrefix) && namespace != TenantNamespacePrefix
+}
+
+// EnsureTenantNamespace creates the tenant namespace if needed and records its owner.
+func EnsureTenantNamespace(namespace, owner string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err == nil {
+		if ns.Annotations[TenantOwnerAnnotation] == "" && owner != "" {
+			patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, TenantOwnerAnnotation, owner)
+			_, err = clientset.CoreV1().Namespaces().Patch(context.TODO(), namespace, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+			if err != nil {
+				fmt.Printf("Could not record owner on namespace %s: %v\n", namespace, err)
+			}
+		}
+		return nil
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namespace,
+			Annotations: map[string]string{TenantOwnerAnnotation: owner},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create namespace: %w", err)
+	}
+	fmt.Printf("Created namespace %s\n", namespace)
+	return nil
+}
+
+func ListTenants() ([]TenantInfo, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	nsList, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	usage := getPodUsage(clientset, "")
+
+	var tenants []TenantInfo
+	for _, ns := range nsList.Items {
+		if !IsTenantNamespace(ns.Name) {
+			continue
+		}
+		tenant, err := describeTenant(ns, usage)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, *tenant)
+	}
+	return tenants, nil
+}
+
+func GetTenant(namespace string) (*TenantInfo, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("tenant namespace %s: %w", namespace, ErrTenantNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant namespace %s: %w", namespace, err)
+	}
+
+	tenant, err := describeTenant(*ns, getPodUsage(clientset, namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	tenant.Databases, err = ListTenantDatabaseClusters(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func describeTenant(ns corev1.Namespace, usage map[string]podUsage) (*TenantInfo, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	tenant := &TenantInfo{
+		Name:        tenantName(ns.Name),
+		Namespace:   ns.Name,
+		Owner:       ns.Annotations[TenantOwnerAnnotation],
+		CreatedAt:   ns.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		Suspended:   ns.Annotations[TenantSuspendedAnnotation] == "true",
+		SuspendedBy: ns.Annotations[TenantSuspendedByAnnotation],
+		SuspendedAt: ns.Annotations[TenantSuspendedAtAnnotation],
+		CPUUsage:    MetricsUnavailable,
+		MemoryUsage: MetricsUnavailable,
+		Quotas:      []TenantQuota{},
+	}
+	if tenant.Owner == "" {
+		tenant.Owner = tenantName(ns.Name)
+	}
+
+	databases, err := listDatabases(ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	tenant.DatabaseCount = len(databases)
+
+	cacheSets, err := caches.list(ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	tenant.CacheCount = len(cacheSets)
+
+	queueSets, err := queues.list(ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	tenant.QueueCount = len(queueSets)
+
+	apps, err := listAppDeployments(ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	tenant.AppCount = len(apps)
+
+	jobs, err := listCronJobs(ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	tenant.JobCount = len(jobs)
+
+	pods, err := clientset.CoreV1().Pods(ns.Name).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", ns.Name, err)
+	}
+	tenant.PodCount = len(pods.Items)
+
+	if usage != nil {
+		var cpu, mem resource.Quantity
+		for _, pod := range pods.Items {
+			if u, ok := usage[pod.Namespace+"/"+pod.Name]; ok {
+				cpu.Add(u.CPU)
+				mem.Add(u.Memory)
+			}
+		}
+		tenant.CPUUsage = cpu.String()
+		tenant.MemoryUsage = mem.String()
+	}
+
+	quotas, err := clientset.CoreV1().ResourceQuotas(ns.Name).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource quotas in namespace %s: %w", ns.Name, err)
+	}
+	for _, q := range quotas.Items {
+		quota := TenantQuota{Name: q.Name, Hard: map[string]string{}, Used: map[string]string{}}
+		for name, qty := range q.Status.Hard {
+			quota.Hard[string(name)] = qty.String()
+		}
+		for name, qty := range q.Status.Used {
+			quota.Used[string(name)] = qty.String()
+		}
+		tenant.Quotas = append(tenant.Quotas, quota)
+	}
+
+	return tenant, nil
+}
+
+// IsTenantSuspended reports whether an admin has suspended the tenant namespace.
+// A namespace that does not exist yet is not suspended. Any other failure is
+// returned, so that callers refuse the request rather than skip the check.
+func IsTenantSuspended(namespace string) (bool, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return false, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check whether tenant %s is suspended: %w", namespace, err)
+	}
+	return ns.Annotations[TenantSuspendedAnnotation] == "true", nil
+}
+
+// SuspendTenant pauses every database, cache, queue, app and job in the namespace and marks the tenant as
+// suspended, which blocks the tenant's API calls, except a platform admin's, until UnsuspendTenant is
+// called. Both return ErrTenantNotFound if the namespace does not exist.
+func SuspendTenant(namespace, actor string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				TenantSuspendedAnnotation:   "true",
+				TenantSuspendedByAnnotation: actor,
+				TenantSuspendedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	_, err = clientset.CoreV1().Namespaces().Patch(context.TODO(), namespace, types.MergePatchType, patch, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("tenant namespace %s: %w", namespace, ErrTenantNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark namespace %s as suspended: %w", namespace, err)
+	}
+
+	databases, err := listDatabases(namespace)
+	if err != nil {
+		return err
+	}
+	for _, db := range databases {
+		if err := db.Provider.Pause(namespace, db.Name); err != nil {
+			return err
+		}
+	}
+	if _, err := setCachesPaused(namespace, true); err != nil {
+		return err
+	}
+	if _, err := setQueuesPaused(namespace, true); err != nil {
+		return err
+	}
+	if _, err := setAppsPaused(namespace, true); err != nil {
+		return err
+	}
+	if _, err := setJobsPaused(namespace, true); err != nil {
+		return err
+	}
+	if err := setBucketUserEnabled(namespace, false); err != nil {
+		return err
+	}
+
+	fmt.Printf("Suspended tenant %s (%d databases paused)\n", namespace, len(databases))
+	return nil
+}
+
+func UnsuspendTenant(namespace string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("tenant namespace %s: %w", namespace, ErrTenantNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get tenant namespace %s: %w", namespace, err)
+	}
+
+	databases, err := listDatabases(namespace)
+	if err != nil {
+		return err
+	}
+	for _, db := range databases {
+		if err := db.Provider.Resume(namespace, db.Name); err != nil {
+			return err
+		}
+	}
+	if _, err := setCachesPaused(namespace, false); err != nil {
+		return err
+	}
+	if _, err := setQueuesPaused(namespace, false); err != nil {
+		return err
+	}
+	if _, err := setAppsPaused(namespace, false); err != nil {
+		return err
+	}
+	if _, err := setJobsPaused(namespace, false); err != nil {
+		return err
+	}
+	if err := setBucketUserEnabled(namespace, true); err != nil {
+		return err
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null,%q:null,%q:null}}}`,
+		TenantSuspendedAnnotation, TenantSuspendedByAnnotation, TenantSuspendedAtAnnotation)
+	_, err = clientset.CoreV1().Namespaces().Patch(context.TODO(), namespace, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to clear suspension on namespace %s: %w", namespace, err)
+	}
+
+	fmt.Printf("Unsuspended tenant %s (%d databases resumed)\n", namespace, len(databases))
+	return nil
+}
+
+// PauseDatabase stops every instance of a database but keeps its volumes;
+// ResumeDatabase restores the previous instance count.
+func PauseDatabase(namespace, dbName string) error {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	return provider.Pause(namespace, dbName)
+}
+
+func ResumeDatabase(namespace, dbName string) error {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return err
+	}
+	return provider.Resume(namespace, dbName)
+}
+
+// DeprovisionTenant deletes every database in the tenant namespace, the
+// tenant's buckets and then the namespace itself, which removes all remaining
+// tenant resources.
+func DeprovisionTenant(namespace string) error {
+	if !IsTenantNamespace(namespace) {
+		return fmt.Errorf("refusing to deprovision non-tenant namespace %s", namespace)
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	databases, err := listDatabases(namespace)
+	if err != nil {
+		return err
+	}
+	for _, db := range databases {
+		if err := db.Provider.Delete(namespace, db.Name); err != nil {
+			return err
+		}
+	}
+	if _, err := deleteTenantBuckets(namespace); err != nil {
+		return err
+	}
+
+	err = clientset.CoreV1().Namespaces().Delete(context.TODO(), namespace, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("tenant namespace %s: %w", namespace, ErrTenantNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete namespace %s: %w", namespace, err)
+	}
+
+	fmt.Printf("Deprovisioned tenant %s (%d databases deleted)\n", namespace, len(databases))
+	return nil
+}
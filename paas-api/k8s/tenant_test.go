@@ -0,0 +1,76 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestIsTenantSuspended(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace *corev1.Namespace
+		getErr    error
+		want      bool
+		wantErr   bool
+	}{
+		{name: "namespace not created yet"},
+		{
+			name:      "active tenant",
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-alice"}},
+		},
+		{
+			name: "suspended tenant",
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "tenant-alice",
+				Annotations: map[string]string{TenantSuspendedAnnotation: "true"},
+			}},
+			want: true,
+		},
+		{
+			name:    "API server error",
+			getErr:  apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "tenant-alice", errors.New("denied")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kube := fake.NewSimpleClientset()
+			if tt.namespace != nil {
+				kube = fake.NewSimpleClientset(tt.namespace)
+			}
+			if tt.getErr != nil {
+				kube.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.getErr
+				})
+			}
+			SetKubeClient(kube)
+			defer SetKubeClient(nil)
+
+			got, err := IsTenantSuspended("tenant-alice")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsTenantSuspended() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsTenantSuspended() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantSlugReservedNames(t *testing.T) {
+	if slug := TenantSlug("pods"); slug == "pods" || !hashedSlug.MatchString(slug) {
+		t.Errorf("TenantSlug(pods) = %q, want a hashed slug", slug)
+	}
+	if slug := TenantSlug("alice"); slug != "alice" {
+		t.Errorf("TenantSlug(alice) = %q", slug)
+	}
+}
@@ -0,0 +1,296 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	TenantDatabaseAPIVersion = "paas.cloudtrack.io/v1alpha1"
+	TenantDatabaseKind       = "TenantDatabase"
+
+	// tenantDatabaseResource is the fully qualified name used with kubectl
+	tenantDatabaseResource = "tenantdatabases.paas.cloudtrack.io"
+)
+
+// Exposure controls who can reach a TenantDatabase.
+const (
+	ExposureNamespace = "namespace" // pods in the tenant namespace
+	ExposureCluster   = "cluster"   // pods in any namespace
+	ExposurePublic    = "public"    // anyone, through a LoadBalancer service
+)
+
+var tenantDatabasesEnabled bool
+
+// UseTenantDatabases switches the API to storing databases as TenantDatabase
+// resources, which the controller turns into clusters of their plan's provider.
+func UseTenantDatabases(enabled bool) {
+	tenantDatabasesEnabled = enabled
+}
+
+func TenantDatabasesEnabled() bool {
+	return tenantDatabasesEnabled
+}
+
+type BackupSpec struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Schedule string `json:"schedule,omitempty"` // cron expression, operator default if empty
+}
+
+// TenantDatabaseSpec is the desired state of a database. The API fills it from
+// create requests; GitOps users write it directly.
+type TenantDatabaseSpec struct {
+	Engine   string     `json:"engine,omitempty"`
+	Plan     string     `json:"plan,omitempty"`
+	Version  string     `json:"version,omitempty"`
+	Replicas int        `json:"replicas,omitempty"`
+	Backups  BackupSpec `json:"backups,omitempty"`
+	Exposure string     `json:"exposure,omitempty"`
+}
+
+// Default fills unset fields with the platform defaults.
+func (s *TenantDatabaseSpec) Default() {
+	if s.Engine == "" {
+		s.Engine = EnginePostgreSQL
+	}
+	if s.Plan == "" {
+		s.Plan = DefaultPlan
+	}
+	if s.Version == "" {
+		s.Version = DefaultVersion(s.Engine)
+	}
+	if s.Replicas <= 0 {
+		s.Replicas = 1
+	}
+	if s.Exposure == "" {
+		s.Exposure = ExposureNamespace
+	}
+}
+
+// Validate returns problems keyed by spec field. It expects a defaulted spec.
+func (s TenantDatabaseSpec) Validate() map[string][]string {
+	problems := map[string][]string{}
+	if p := ValidateEngine(s.Engine); p != nil {
+		problems["engine"] = p
+	}
+	if p := ValidatePlan(s.Plan); p != nil {
+		problems["plan"] = p
+	}
+	if p := ValidateVersion(s.Engine, s.Version); p != nil {
+		problems["version"] = p
+	}
+	if p := ValidateReplicas(s); p != nil {
+		problems["replicas"] = p
+	}
+	if provider, err := ProviderForSpec(s); err == nil && s.Backups.Enabled && !provider.Capabilities().Backups {
+		if s.Engine != EnginePostgreSQL {
+			problems["backups.enabled"] = []string{fmt.Sprintf("is not supported for engine %s", s.Engine)}
+		} else {
+			problems["backups.enabled"] = []string{fmt.Sprintf("is not supported by plan %s", s.Plan)}
+		}
+	}
+	if s.Backups.Schedule != "" {
+		if p := validateCronFields(s.Backups.Schedule); p != nil {
+			problems["backups.schedule"] = p
+		}
+	}
+	switch s.Exposure {
+	case ExposureNamespace, ExposureCluster, ExposurePublic:
+	default:
+		problems["exposure"] = []string{fmt.Sprintf("must be one of %s, %s, %s", ExposureNamespace, ExposureCluster, ExposurePublic)}
+	}
+	return problems
+}
+
+type TenantDatabaseStatus struct {
+	Phase              string             `json:"phase,omitempty"`
+	Message            string             `json:"message,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Host               string             `json:"host,omitempty"`
+	Port               int                `json:"port,omitempty"`
+	SecretName         string             `json:"secretName,omitempty"`
+	Replicas           int                `json:"replicas,omitempty"`
+	ReadyReplicas      int                `json:"readyReplicas,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// TenantDatabase is the platform's own database resource.
+type TenantDatabase struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Metadata   metav1.ObjectMeta    `json:"metadata"`
+	Spec       TenantDatabaseSpec   `json:"spec"`
+	Status     TenantDatabaseStatus `json:"status,omitempty"`
+}
+
+// OwnerReference makes resources created for the database garbage collected with it.
+func (td *TenantDatabase) OwnerReference() metav1.OwnerReference {
+	controller := true
+	return metav1.OwnerReference{
+		APIVersion: TenantDatabaseAPIVersion,
+		Kind:       TenantDatabaseKind,
+		Name:       td.Metadata.Name,
+		UID:        td.Metadata.UID,
+		Controller: &controller,
+	}
+}
+
+// ConnectionSecretName is the secret the controller keeps with ready-to-use
+// connection details, independent of the operator's secret naming.
+func ConnectionSecretName(dbName string) string {
+	return dbName + "-connection"
+}
+
+func kubectlNotFound(output []byte) bool {
+	return strings.Contains(string(output), "NotFound") || strings.Contains(string(output), "not found")
+}
+
+// GetTenantDatabase returns ErrDatabaseNotFound if there is no such resource.
+func GetTenantDatabase(namespace, name string) (*TenantDatabase, error) {
+	output, err := exec.Command("kubectl", "get", tenantDatabaseResource, name, "-n", namespace, "--ignore-not-found", "-o", "json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TenantDatabase %s: %w", name, err)
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, ErrDatabaseNotFound
+	}
+	var td TenantDatabase
+	if err := json.Unmarshal(output, &td); err != nil {
+		return nil, fmt.Errorf("failed to parse TenantDatabase %s: %w", name, err)
+	}
+	return &td, nil
+}
+
+// ListTenantDatabases lists TenantDatabases in a namespace, or in all
+// namespaces when namespace is empty.
+func ListTenantDatabases(namespace string) ([]TenantDatabase, error) {
+	args := []string{"get", tenantDatabaseResource, "-o", "json"}
+	if namespace == "" {
+		args = append(args, "--all-namespaces")
+	} else {
+		args = append(args, "-n", namespace)
+	}
+	output, err := exec.Command("kubectl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list TenantDatabases: %w", err)
+	}
+	var list struct {
+		Items []TenantDatabase `json:"items"`
+	}
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse TenantDatabase list: %w", err)
+	}
+	return list.Items, nil
+}
+
+// CreateTenantDatabase records the desired database and returns right away;
+// the controller provisions the cluster. A name already used by a
+// TenantDatabase or an unmanaged cluster yields ErrDatabaseExists.
+func CreateTenantDatabase(namespace, dbName, owner string, spec TenantDatabaseSpec) (*ProvisionResult, error) {
+	exists, err := DatabaseExists(namespace, dbName)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrDatabaseExists
+	}
+
+	if err := EnsureTenantNamespace(namespace, owner); err != nil {
+		return nil, err
+	}
+
+	spec.Default()
+	provider, err := ProviderForSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	td := TenantDatabase{
+		APIVersion: TenantDatabaseAPIVersion,
+		Kind:       TenantDatabaseKind,
+		Metadata: metav1.ObjectMeta{
+			Name:        dbName,
+			Namespace:   namespace,
+			Annotations: map[string]string{TenantOwnerAnnotation: owner},
+		},
+		Spec: spec,
+	}
+	manifest, err := json.Marshal(td)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("kubectl", "create", "-f", "-")
+	cmd.Stdin = bytes.NewReader(manifest)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "AlreadyExists") {
+			return nil, ErrDatabaseExists
+		}
+		return nil, fmt.Errorf("failed to create TenantDatabase %s: %w, output: %s", dbName, err, string(output))
+	}
+	fmt.Printf("Created TenantDatabase %s/%s\n", namespace, dbName)
+
+	return newProvisionResult(provider, namespace, dbName, ConnectionSecretName(dbName),
+		fmt.Sprintf("kubectl get tenantdatabase %s -n %s", dbName, namespace)), nil
+}
+
+// DeleteTenantDatabase deletes the resource; everything the controller created
+// for it is garbage collected through owner references.
+func DeleteTenantDatabase(namespace, name string) error {
+	output, err := exec.Command("kubectl", "delete", tenantDatabaseResource, name, "-n", namespace).CombinedOutput()
+	if err != nil {
+		if kubectlNotFound(output) {
+			return ErrDatabaseNotFound
+		}
+		return fmt.Errorf("failed to delete TenantDatabase %s: %w, output: %s", name, err, string(output))
+	}
+	fmt.Printf("Deleted TenantDatabase %s/%s\n", namespace, name)
+	return nil
+}
+
+func patchTenantDatabase(namespace, name, patch string, extra ...string) error {
+	args := append([]string{"patch", tenantDatabaseResource, name, "-n", namespace, "--type", "merge", "-p", patch}, extra...)
+	output, err := exec.Command("kubectl", args...).CombinedOutput()
+	if err != nil {
+		if kubectlNotFound(output) {
+			return ErrDatabaseNotFound
+		}
+		return fmt.Errorf("failed to patch TenantDatabase %s: %w, output: %s", name, err, string(output))
+	}
+	return nil
+}
+
+func ScaleTenantDatabase(namespace, name string, replicas int) error {
+	return patchTenantDatabase(namespace, name, fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+}
+
+// UpdateTenantDatabaseStatus writes the status subresource.
+func UpdateTenantDatabaseStatus(namespace, name string, status TenantDatabaseStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	return patchTenantDatabase(namespace, name, string(patch), "--subresource=status")
+}
+
+// DeleteDatabase removes a database whichever way it was created: through its
+// TenantDatabase when that mode is enabled, otherwise through its provider.
+func DeleteDatabase(namespace, dbName string) error {
+	err := ErrDatabaseNotFound
+	if tenantDatabasesEnabled {
+		err = DeleteTenantDatabase(namespace, dbName)
+	}
+	if errors.Is(err, ErrDatabaseNotFound) {
+		err = DeleteTenantDB(namespace, dbName)
+	}
+	if err != nil {
+		return err
+	}
+	return deleteDatabaseBindings(namespace, dbName)
+}
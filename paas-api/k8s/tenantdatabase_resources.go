@@ -0,0 +1,232 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseQuotaName is the ResourceQuota holding the tenant storage quota.
+const DatabaseQuotaName = "paas-databases"
+
+// operatorNamespace is where the postgres operators run; they must reach the
+// database pods to manage them, whatever their exposure.
+func operatorNamespace() string {
+	return settings.OperatorNamespace
+}
+
+// ApplyTenantDatabaseCluster creates or updates the cluster of a
+// TenantDatabase with the provider of its engine and plan. A plan or engine
+// change cannot move an existing database to another provider.
+func ApplyTenantDatabaseCluster(td *TenantDatabase) error {
+	namespace, dbName := td.Metadata.Namespace, td.Metadata.Name
+	spec := td.Spec
+	spec.Default()
+
+	provider, err := ProviderForSpec(spec)
+	if err != nil {
+		return err
+	}
+	current, err := providerFor(namespace, dbName)
+	if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
+		return err
+	}
+	if current != nil && current.Name() != provider.Name() {
+		return fmt.Errorf("database %s runs on %s but %s plan %s uses %s, moving between providers is not supported",
+			dbName, current.Name(), spec.Engine, spec.Plan, provider.Name())
+	}
+
+	owner := td.OwnerReference()
+	_, err = provider.Provision(ProvisionRequest{
+		Namespace: namespace,
+		DBName:    dbName,
+		Spec:      spec,
+		Owner:     &owner,
+		Update:    true,
+	})
+	return err
+}
+
+// EnsureConnectionSecret copies the owner credentials from the provider's
+// secret into ConnectionSecretName(dbName) with host, port and a connection
+// URI. It returns false while the credentials have not been created yet.
+func EnsureConnectionSecret(namespace, dbName string, owner metav1.OwnerReference) (bool, error) {
+	provider, err := providerFor(namespace, dbName)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	credentials, err := provider.Credentials(namespace, dbName)
+	if errors.Is(err, ErrCredentialsPending) || errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return false, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	host, port := credentials.Host, credentials.Port
+	username, password := credentials.PrimaryUser["username"], credentials.PrimaryUser["password"]
+	uri := connectionURI(provider.Engine(), username, password, host, port, dbName)
+	uri.RawQuery = sslQuery(provider.Engine())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            ConnectionSecretName(dbName),
+			Namespace:       namespace,
+			Labels:          provider.PodLabels(dbName),
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		StringData: map[string]string{
+			"host":     host,
+			"port":     port,
+			"database": dbName,
+			"username": username,
+			"password": password,
+			"uri":      uri.String(),
+		},
+	}
+
+	existing, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to create secret %s: %w", secret.Name, err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get secret %s: %w", secret.Name, err)
+	}
+
+	if string(existing.Data["password"]) == password && string(existing.Data["username"]) == username && string(existing.Data["host"]) == host {
+		return true, nil
+	}
+	existing.Data = nil
+	existing.StringData = secret.StringData
+	if _, err := clientset.CoreV1().Secrets(namespace).Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("failed to update secret %s: %w", secret.Name, err)
+	}
+	return true, nil
+}
+
+// EnsureDatabaseNetworkPolicy restricts ingress to the cluster's pods according
+// to the exposure. The operator namespace is always allowed in.
+func EnsureDatabaseNetworkPolicy(namespace, dbName, exposure string, owner metav1.OwnerReference) error {
+	provider, err := providerFor(namespace, dbName)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	var ingress []networkingv1.NetworkPolicyIngressRule
+	switch exposure {
+	case ExposurePublic:
+		// An empty rule admits all traffic
+		ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+	case ExposureCluster:
+		ingress = []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+		}}
+	default:
+		ingress = []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: &metav1.LabelSelector{}},
+				{NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"kubernetes.io/metadata.name": operatorNamespace()},
+				}},
+			},
+		}}
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            dbName + "-access",
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: provider.PodLabels(dbName)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     ingress,
+		},
+	}
+
+	policies := clientset.NetworkingV1().NetworkPolicies(namespace)
+	existing, err := policies.Get(context.TODO(), policy.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = policies.Create(context.TODO(), policy, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create network policy for %s: %w", dbName, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get network policy for %s: %w", dbName, err)
+	}
+
+	existing.Spec = policy.Spec
+	if _, err := policies.Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update network policy for %s: %w", dbName, err)
+	}
+	return nil
+}
+
+// EnsureDatabaseQuota caps the storage requests of a tenant namespace at the
+// platform's tenant storage quota. Tenants control their specs, so the limit
+// must not be derived from them.
+func EnsureDatabaseQuota(namespace string) error {
+	if settings.TenantStorageQuota.IsZero() {
+		return nil
+	}
+	storage := settings.TenantStorageQuota
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: DatabaseQuotaName, Namespace: namespace},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{corev1.ResourceRequestsStorage: storage},
+		},
+	}
+
+	quotas := clientset.CoreV1().ResourceQuotas(namespace)
+	existing, err := quotas.Get(context.TODO(), DatabaseQuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = quotas.Create(context.TODO(), quota, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create quota in %s: %w", namespace, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get quota in %s: %w", namespace, err)
+	}
+
+	if current, ok := existing.Spec.Hard[corev1.ResourceRequestsStorage]; ok && current.Cmp(storage) == 0 {
+		return nil
+	}
+	existing.Spec.Hard = quota.Spec.Hard
+	if _, err := quotas.Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update quota in %s: %w", namespace, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	apiTokenLabel        = "paas.cloudtrack.io/api-token"
+	apiTokenSecretPrefix = "api-token-"
+)
+
+// SystemNamespace holds platform-owned objects such as API tokens.
+func SystemNamespace() string {
+	return settings.SystemNamespace
+}
+
+func ensureSystemNamespace() error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Get(context.TODO(), SystemNamespace(), metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	_, err = clientset.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: SystemNamespace()},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s: %w", SystemNamespace(), err)
+	}
+	return nil
+}
+
+func SaveAPIToken(token APIToken) error {
+	if err := ensureSystemNamespace(); err != nil {
+		return err
+	}
+
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      apiTokenSecretPrefix + token.ID,
+			Namespace: SystemNamespace(),
+			Labels:    map[string]string{apiTokenLabel: "true"},
+		},
+		StringData: map[string]string{
+			"name":            token.Name,
+			"owner":           token.Owner,
+			"service_account": token.ServiceAccount,
+			"grants":          strings.Join(token.Grants, ","),
+			"scopes":          strings.Join(token.Scopes, ","),
+			"created_at":      token.CreatedAt.UTC().Format(time.RFC3339),
+			"expires_at":      token.ExpiresAt.UTC().Format(time.RFC3339),
+			"hash":            token.Hash,
+		},
+	}
+
+	_, err = clientset.CoreV1().Secrets(SystemNamespace()).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to store API token: %w", err)
+	}
+	return nil
+}
+
+func tokenFromSecret(secret corev1.Secret) APIToken {
+	token := APIToken{
+		ID:             strings.TrimPrefix(secret.Name, apiTokenSecretPrefix),
+		Name:           string(secret.Data["name"]),
+		Owner:          string(secret.Data["owner"]),
+		ServiceAccount: string(secret.Data["service_account"]),
+		Hash:           string(secret.Data["hash"]),
+		Grants:         []string{},
+		Scopes:         []string{},
+	}
+	if grants := string(secret.Data["grants"]); grants != "" {
+		token.Grants = strings.Split(grants, ",")
+	}
+	if scopes := string(secret.Data["scopes"]); scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	token.CreatedAt, _ = time.Parse(time.RFC3339, string(secret.Data["created_at"]))
+	token.ExpiresAt, _ = time.Parse(time.RFC3339, string(secret.Data["expires_at"]))
+	return token
+}
+
+// GetAPIToken returns nil and no error when the token does not exist.
+func GetAPIToken(id string) (*APIToken, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(SystemNamespace()).Get(context.TODO(), apiTokenSecretPrefix+id, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+	if secret.Labels[apiTokenLabel] != "true" {
+		return nil, nil
+	}
+
+	token := tokenFromSecret(*secret)
+	return &token, nil
+}
+
+func ListAPITokens(owner string) ([]APIToken, error) {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(SystemNamespace()).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: apiTokenLabel + "=true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+
+	tokens := []APIToken{}
+	for _, secret := range secrets.Items {
+		token := tokenFromSecret(secret)
+		if token.Owner == owner {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func DeleteAPIToken(id string) error {
+	clientset, err := getKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to get k8s client: %w", err)
+	}
+
+	err = clientset.CoreV1().Secrets(SystemNamespace()).Delete(context.TODO(), apiTokenSecretPrefix+id, metav1.DeleteOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	return nil
+}
@@ -1,47 +1,117 @@
 package main
 
 import (
+    "context"
+    "fmt"
     "log"
+    "os"
     "github.com/gin-gonic/gin"
+    "paas-api/audit"
     "paas-api/auth"
-    "paas-api/handlers"
+    "paas-api/config"
+    "paas-api/controller"
+    "paas-api/k8s"
+    "paas-api/server"
     "github.com/gin-contrib/cors"
+    "k8s.io/apimachinery/pkg/api/resource"
 
 )
 
 
 func main() {
-    if err := auth.InitJWT(); err != nil {
+    // PAAS_CONFIG_FILE is an optional YAML file; environment variables
+    // override it. See config/config.go for the settings and their variables.
+    cfg, err := config.Load(os.Getenv("PAAS_CONFIG_FILE"))
+    if err != nil {
+        log.Fatalf("Failed to load configuration: %v", err)
+    }
+
+    k8s.Configure(k8s.Settings{
+        Kubeconfig:            cfg.Kubernetes.Kubeconfig,
+        ClientTimeout:         cfg.Kubernetes.Timeout.Duration,
+        TenantNamespacePrefix: cfg.Kubernetes.TenantNamespacePrefix,
+        SystemNamespace:       cfg.Kubernetes.SystemNamespace,
+        OperatorNamespace:     cfg.Kubernetes.OperatorNamespace,
+        TemplateDir:           cfg.Kubernetes.TemplateDir,
+        DefaultTeam:           cfg.Kubernetes.DefaultTeam,
+        SnapshotClass:         cfg.Databases.SnapshotClass,
+        TenantStorageQuota:    resource.MustParse(cfg.Databases.TenantStorageQuota),
+    })
+
+    err = auth.InitJWT(auth.OIDCConfig{
+        IssuerURL:   cfg.Auth.IssuerURL,
+        JWKSURL:     cfg.Auth.JWKSURL,
+        Audience:    cfg.Auth.Audience,
+        RolesClaim:  cfg.Auth.RolesClaim,
+        RoleMapping: cfg.Auth.RoleMapping,
+        TokenUse:    cfg.Auth.TokenUse,
+    })
+    if err != nil {
         log.Fatalf("Failed to initialize JWKS: %v", err)
     }
 
+    // Optional JSON file with custom roles, e.g. {"auditor": ["database.read", "admin.audit.read"]}
+    if err := auth.LoadCustomRoles(cfg.Auth.RolesFile); err != nil {
+        log.Fatalf("Failed to load custom roles: %v", err)
+    }
+
+    // The audit sink is stdout (default), file (target is a path) or postgres (target is a DSN)
+    if err := audit.Init(cfg.Audit.Sink, cfg.Audit.Target); err != nil {
+        log.Fatalf("Failed to initialize audit log: %v", err)
+    }
+
+    // Plan providers override which provider runs a plan, e.g. "medium=cloudnativepg"
+    if err := k8s.SetPlanProviders(cfg.Databases.PlanProviders); err != nil {
+        log.Fatalf("Invalid database plan providers: %v", err)
+    }
+
+    // An object storage endpoint enables /buckets on an S3-compatible MinIO,
+    // administered with the root user and password through the mc client
+    if err := k8s.ConfigureObjectStorage(cfg.ObjectStorage.Endpoint, cfg.ObjectStorage.RootUser, cfg.ObjectStorage.RootPassword); err != nil {
+        log.Fatalf("Invalid object storage configuration: %v", err)
+    }
+
+    // A platform domain enables /routes as <route>--<tenant>.<domain> behind a
+    // wildcard DNS record; the ingress class and cert-manager issuer are optional
+    if err := k8s.ConfigureIngress(cfg.Ingress.Domain, cfg.Ingress.Class, cfg.Ingress.Issuer); err != nil {
+        log.Fatalf("Invalid ingress configuration: %v", err)
+    }
+
+    // Database mode crd stores databases as TenantDatabase resources and runs
+    // the controller that provisions them
+    if cfg.Databases.Mode == "crd" {
+        k8s.UseTenantDatabases(true)
+        go controller.Run(context.Background(), cfg.Databases.ResyncInterval.Duration)
+    }
+
+    // Binding secrets follow credential rotations
+    go controller.RunBindingSync(context.Background(), cfg.Databases.BindingSyncInterval.Duration)
+
+    // Idempotency keys of finished or abandoned requests are deleted once they expire
+    go controller.RunIdempotencySweep(context.Background(), controller.DefaultIdempotencySweepInterval)
+
     r := gin.Default()
+    // Client addresses end up in the audit log, so only the configured
+    // proxies may set them with X-Forwarded-For.
+    if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+        log.Fatalf("Invalid trusted proxies: %v", err)
+    }
+    r.Use(audit.RequestID())
 
-    // 👇 Add CORS configuration here
     r.Use(cors.New(cors.Config{
-        AllowOrigins:     []string{"http://localhost:5173"}, // your frontend URL
-        AllowMethods:     []string{"GET", "POST", "DELETE", "PUT", "OPTIONS"},
-        AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-        ExposeHeaders:    []string{"Content-Length"},
+        AllowOrigins:     cfg.Server.CORSOrigins,
+        AllowMethods:     []string{"GET", "POST", "DELETE", "PUT", "PATCH", "OPTIONS"},
+        AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Request-ID", "Idempotency-Key"},
+        ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "Idempotent-Replayed", "Deprecation", "Link"},
         AllowCredentials: true,
     }))
 
-    // Public / tenant routes
-    r.POST("/databases", auth.AuthMiddleware("tenant"), handlers.CreateDatabase)
-    r.DELETE("/databases", auth.AuthMiddleware("tenant"), handlers.DeleteDatabase)
-    r.GET("/databases/:username/:db_name/status", auth.AuthMiddleware("tenant"), handlers.GetDatabaseStatus)
-    r.GET("/databases/:username/:db_name/credentials", auth.AuthMiddleware("tenant"), handlers.GetDatabaseCredentials)
-    r.GET("/databases/:username/:db_name", auth.AuthMiddleware("tenant"), handlers.GetDatabaseClusterDetails)
-    r.GET("/databases/:username", auth.AuthMiddleware("tenant"), handlers.ListDatabaseClusters)
-    r.GET("/pods/:namespace", auth.AuthMiddleware("tenant"), handlers.ListTenantPodsHandler)
+    // Every route is served under /v1; the unversioned paths remain as deprecated aliases
+    server.Register(r)
 
-    // Admin routes
-    admin := r.Group("/admin")
-    admin.Use(auth.AuthMiddleware("admin"))
-    {
-        admin.GET("/tenants/pods", handlers.ListAllTenantPodsHandler)
+    addr := fmt.Sprintf(":%d", cfg.Server.Port)
+    log.Printf("API listening on port %d", cfg.Server.Port)
+    if err := r.Run(addr); err != nil {
+        log.Fatalf("Server stopped: %v", err)
     }
-
-    log.Println("API listening on port 8080")
-    r.Run(":8080")
 }
@@ -0,0 +1,286 @@
+// Package server holds the route table of the API: which handler serves each
+// path and the permission it requires.
+package server
+
+import (
+	"net/http"
+	"paas-api/api"
+	"paas-api/auth"
+	"paas-api/handlers"
+	"paas-api/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+var apiInfo = api.Info{
+	Title:       "Cloud Track PaaS API",
+	Version:     "1.0.0",
+	Description: "Self-service PostgreSQL databases, Redis caches, RabbitMQ and NATS queues, S3 buckets, container apps, scheduled jobs and HTTP routes for tenants. Errors use the ErrorResponse envelope.",
+}
+
+func perm(p auth.Permission) string { return string(p) }
+
+func chain(h ...gin.HandlerFunc) []gin.HandlerFunc { return h }
+
+// tenantChain is chain for the routes of a tenant's resources, which are
+// closed while the tenant is suspended.
+func tenantChain(h ...gin.HandlerFunc) []gin.HandlerFunc {
+	return append(chain(handlers.RequireActiveTenant()), h...)
+}
+
+// routes is the /v1 API. Each route declares the permission it needs (see
+// auth/rbac.go); Legacy routes are also served unversioned for older clients.
+var routes = []api.Route{
+	// Databases
+	{Method: http.MethodPost, Path: "/databases", Tag: "databases", Summary: "Provision a database",
+		Permission: perm(auth.PermDatabaseCreate), Request: api.CreateDatabaseRequest{}, Response: api.CreateDatabaseResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.Idempotent(), handlers.CreateDatabase)},
+	{Method: http.MethodDelete, Path: "/databases", Tag: "databases", Summary: "Delete a database",
+		Permission: perm(auth.PermDatabaseDelete), Request: api.DeleteDatabaseRequest{}, Response: api.DeleteDatabaseResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.DeleteDatabase)},
+	{Method: http.MethodGet, Path: "/databases/:username/:db_name/status", Tag: "databases", Summary: "Get database status",
+		Permission: perm(auth.PermDatabaseRead), Response: api.DatabaseStatusResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.GetDatabaseStatus)},
+	{Method: http.MethodGet, Path: "/databases/:username/:db_name/credentials", Tag: "databases", Summary: "Get database credentials",
+		Permission: perm(auth.PermCredentialsRead), Response: api.CredentialsResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.GetDatabaseCredentials)},
+	{Method: http.MethodGet, Path: "/databases/:username/:db_name/logs", Tag: "databases", Summary: "Tail the postgres log",
+		Permission: perm(auth.PermPodsRead), Response: api.DatabaseLogsResponse{},
+		Query:    map[string]string{"pod": "Pod name, defaults to the primary", "lines": "Number of lines, default 200"},
+		Handlers: tenantChain(handlers.GetDatabaseLogs)},
+	{Method: http.MethodPost, Path: "/databases/:username/:db_name/backups", Tag: "databases", Summary: "Start an on-demand logical backup",
+		Permission: perm(auth.PermDatabaseBackup), Response: api.BackupResponse{}, Status: http.StatusAccepted,
+		Handlers: tenantChain(handlers.CreateDatabaseBackup)},
+	{Method: http.MethodPost, Path: "/databases/:username/:db_name/bindings", Tag: "databases", Summary: "Write the credentials into a binding secret",
+		Permission: perm(auth.PermDatabaseUpdate), Request: api.CreateBindingRequest{}, Response: api.CreateBindingResponse{},
+		Handlers: tenantChain(handlers.CreateDatabaseBinding)},
+	{Method: http.MethodGet, Path: "/databases/:username/:db_name/bindings", Tag: "databases", Summary: "List the binding secrets of a database",
+		Permission: perm(auth.PermDatabaseRead), Response: api.BindingListResponse{},
+		Handlers: tenantChain(handlers.ListDatabaseBindings)},
+	{Method: http.MethodDelete, Path: "/databases/:username/:db_name/bindings/:binding_name", Tag: "databases", Summary: "Delete a binding secret",
+		Permission: perm(auth.PermDatabaseUpdate), Response: api.DeleteBindingResponse{},
+		Handlers: tenantChain(handlers.DeleteDatabaseBinding)},
+	{Method: http.MethodPatch, Path: "/databases/:username/:db_name", Tag: "databases", Summary: "Change the number of replicas",
+		Permission: perm(auth.PermDatabaseUpdate), Request: api.UpdateDatabaseRequest{}, Response: api.UpdateDatabaseResponse{},
+		Handlers: tenantChain(handlers.UpdateDatabase)},
+	{Method: http.MethodGet, Path: "/databases/:username/:db_name", Tag: "databases", Summary: "Get database cluster details",
+		Permission: perm(auth.PermDatabaseRead), Response: api.DatabaseResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.GetDatabaseClusterDetails)},
+	{Method: http.MethodGet, Path: "/databases/:username", Tag: "databases", Summary: "List a tenant's database clusters",
+		Permission: perm(auth.PermDatabaseRead), Response: api.DatabaseListResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.ListDatabaseClusters)},
+	{Method: http.MethodGet, Path: "/pods/:namespace", Tag: "databases", Summary: "List pods in a tenant namespace",
+		Permission: perm(auth.PermPodsRead), Response: []k8s.PodInfo{},
+		Legacy: true, Handlers: tenantChain(handlers.ListTenantPodsHandler)},
+
+	// Redis caches
+	{Method: http.MethodPost, Path: "/caches", Tag: "caches", Summary: "Provision a Redis cache",
+		Permission: perm(auth.PermCacheCreate), Request: api.CreateCacheRequest{}, Response: api.CreateCacheResponse{},
+		Handlers: tenantChain(handlers.Idempotent(), handlers.CreateCache)},
+	{Method: http.MethodGet, Path: "/caches/:username/:cache_name/status", Tag: "caches", Summary: "Get cache status",
+		Permission: perm(auth.PermCacheRead), Response: api.CacheStatusResponse{},
+		Handlers: tenantChain(handlers.GetCacheStatus)},
+	{Method: http.MethodGet, Path: "/caches/:username/:cache_name/credentials", Tag: "caches", Summary: "Get the cache AUTH password",
+		Permission: perm(auth.PermCacheCredentialsRead), Response: api.CacheCredentialsResponse{},
+		Handlers: tenantChain(handlers.GetCacheCredentials)},
+	{Method: http.MethodDelete, Path: "/caches/:username/:cache_name", Tag: "caches", Summary: "Delete a cache",
+		Permission: perm(auth.PermCacheDelete), Response: api.DeleteCacheResponse{},
+		Handlers: tenantChain(handlers.DeleteCache)},
+	{Method: http.MethodGet, Path: "/caches/:username/:cache_name", Tag: "caches", Summary: "Get cache details",
+		Permission: perm(auth.PermCacheRead), Response: api.CacheResponse{},
+		Handlers: tenantChain(handlers.GetCache)},
+	{Method: http.MethodGet, Path: "/caches/:username", Tag: "caches", Summary: "List a tenant's caches",
+		Permission: perm(auth.PermCacheRead), Response: api.CacheListResponse{},
+		Handlers: tenantChain(handlers.ListCaches)},
+
+	// RabbitMQ and NATS message brokers
+	{Method: http.MethodPost, Path: "/queues", Tag: "queues", Summary: "Provision a RabbitMQ or NATS message broker",
+		Permission: perm(auth.PermQueueCreate), Request: api.CreateQueueRequest{}, Response: api.CreateQueueResponse{},
+		Handlers: tenantChain(handlers.Idempotent(), handlers.CreateQueue)},
+	{Method: http.MethodGet, Path: "/queues/:username/:queue_name/status", Tag: "queues", Summary: "Get queue status",
+		Permission: perm(auth.PermQueueRead), Response: api.QueueStatusResponse{},
+		Handlers: tenantChain(handlers.GetQueueStatus)},
+	{Method: http.MethodGet, Path: "/queues/:username/:queue_name/credentials", Tag: "queues", Summary: "Get the broker user and connection URI",
+		Permission: perm(auth.PermQueueCredentialsRead), Response: api.QueueCredentialsResponse{},
+		Handlers: tenantChain(handlers.GetQueueCredentials)},
+	{Method: http.MethodDelete, Path: "/queues/:username/:queue_name", Tag: "queues", Summary: "Delete a queue",
+		Permission: perm(auth.PermQueueDelete), Response: api.DeleteQueueResponse{},
+		Handlers: tenantChain(handlers.DeleteQueue)},
+	{Method: http.MethodGet, Path: "/queues/:username/:queue_name", Tag: "queues", Summary: "Get queue details",
+		Permission: perm(auth.PermQueueRead), Response: api.QueueResponse{},
+		Handlers: tenantChain(handlers.GetQueue)},
+	{Method: http.MethodGet, Path: "/queues/:username", Tag: "queues", Summary: "List a tenant's queues",
+		Permission: perm(auth.PermQueueRead), Response: api.QueueListResponse{},
+		Handlers: tenantChain(handlers.ListQueues)},
+
+	// S3 buckets on the MinIO backend
+	{Method: http.MethodPost, Path: "/buckets", Tag: "buckets", Summary: "Create a bucket",
+		Permission: perm(auth.PermBucketCreate), Request: api.CreateBucketRequest{}, Response: api.CreateBucketResponse{},
+		Handlers: tenantChain(handlers.Idempotent(), handlers.CreateBucket)},
+	{Method: http.MethodGet, Path: "/buckets/:username/credentials", Tag: "buckets", Summary: "Get the tenant's bucket access key",
+		Permission: perm(auth.PermBucketCredentialsRead), Response: api.BucketCredentialsResponse{},
+		Handlers: tenantChain(handlers.GetBucketCredentials)},
+	{Method: http.MethodPost, Path: "/buckets/:username/credentials/rotate", Tag: "buckets", Summary: "Replace the tenant's bucket access key",
+		Permission: perm(auth.PermBucketUpdate), Response: api.BucketCredentialsResponse{},
+		Handlers: tenantChain(handlers.RotateBucketCredentials)},
+	{Method: http.MethodPatch, Path: "/buckets/:username/:bucket_name", Tag: "buckets", Summary: "Change the bucket quota",
+		Permission: perm(auth.PermBucketUpdate), Request: api.UpdateBucketRequest{}, Response: api.BucketResponse{},
+		Handlers: tenantChain(handlers.UpdateBucket)},
+	{Method: http.MethodDelete, Path: "/buckets/:username/:bucket_name", Tag: "buckets", Summary: "Delete a bucket with its contents",
+		Permission: perm(auth.PermBucketDelete), Response: api.DeleteBucketResponse{},
+		Handlers: tenantChain(handlers.DeleteBucket)},
+	{Method: http.MethodGet, Path: "/buckets/:username/:bucket_name", Tag: "buckets", Summary: "Get a bucket with its usage",
+		Permission: perm(auth.PermBucketRead), Response: api.BucketResponse{},
+		Handlers: tenantChain(handlers.GetBucket)},
+	{Method: http.MethodGet, Path: "/buckets/:username", Tag: "buckets", Summary: "List a tenant's buckets with their usage",
+		Permission: perm(auth.PermBucketRead), Response: api.BucketListResponse{},
+		Handlers: tenantChain(handlers.ListBuckets)},
+
+	// Container apps deployed from an image
+	{Method: http.MethodPost, Path: "/apps", Tag: "apps", Summary: "Deploy a container image as an app",
+		Permission: perm(auth.PermAppCreate), Request: api.CreateAppRequest{}, Response: api.CreateAppResponse{},
+		Handlers: tenantChain(handlers.Idempotent(), handlers.CreateApp)},
+	{Method: http.MethodGet, Path: "/apps/:username/:app_name/rollout", Tag: "apps", Summary: "Get the rollout status and revisions of an app",
+		Permission: perm(auth.PermAppRead), Response: api.AppRolloutResponse{},
+		Handlers: tenantChain(handlers.GetAppRollout)},
+	{Method: http.MethodPost, Path: "/apps/:username/:app_name/rollback", Tag: "apps", Summary: "Roll an app back to an earlier revision",
+		Permission: perm(auth.PermAppUpdate), Request: api.RollbackAppRequest{}, Response: api.AppResponse{},
+		Handlers: tenantChain(handlers.RollbackApp)},
+	{Method: http.MethodPatch, Path: "/apps/:username/:app_name", Tag: "apps", Summary: "Update an app, rolling out a new revision",
+		Permission: perm(auth.PermAppUpdate), Request: api.UpdateAppRequest{}, Response: api.AppResponse{},
+		Handlers: tenantChain(handlers.UpdateApp)},
+	{Method: http.MethodDelete, Path: "/apps/:username/:app_name", Tag: "apps", Summary: "Delete an app",
+		Permission: perm(auth.PermAppDelete), Response: api.DeleteAppResponse{},
+		Handlers: tenantChain(handlers.DeleteApp)},
+	{Method: http.MethodGet, Path: "/apps/:username/:app_name", Tag: "apps", Summary: "Get app details",
+		Permission: perm(auth.PermAppRead), Response: api.AppResponse{},
+		Handlers: tenantChain(handlers.GetApp)},
+	{Method: http.MethodGet, Path: "/apps/:username", Tag: "apps", Summary: "List a tenant's apps",
+		Permission: perm(auth.PermAppRead), Response: api.AppListResponse{},
+		Handlers: tenantChain(handlers.ListApps)},
+
+	// Scheduled jobs run as CronJobs in the tenant namespace
+	{Method: http.MethodPost, Path: "/jobs", Tag: "jobs", Summary: "Schedule a job",
+		Permission: perm(auth.PermJobCreate), Request: api.CreateJobRequest{}, Response: api.CreateJobResponse{},
+		Handlers: tenantChain(handlers.Idempotent(), handlers.CreateJob)},
+	{Method: http.MethodGet, Path: "/jobs/:username/:job_name/logs", Tag: "jobs", Summary: "Get the output of a job run",
+		Permission: perm(auth.PermJobRead), Response: api.JobLogsResponse{},
+		Query:    map[string]string{"run": "Run name, defaults to the latest run", "lines": "Number of lines, default 200"},
+		Handlers: tenantChain(handlers.GetJobLogs)},
+	{Method: http.MethodPost, Path: "/jobs/:username/:job_name/run", Tag: "jobs", Summary: "Run a job now, outside its schedule",
+		Permission: perm(auth.PermJobRun), Response: api.TriggerJobResponse{},
+		Handlers: tenantChain(handlers.TriggerJob)},
+	{Method: http.MethodDelete, Path: "/jobs/:username/:job_name", Tag: "jobs", Summary: "Delete a job and its runs",
+		Permission: perm(auth.PermJobDelete), Response: api.DeleteJobResponse{},
+		Handlers: tenantChain(handlers.DeleteJob)},
+	{Method: http.MethodGet, Path: "/jobs/:username/:job_name", Tag: "jobs", Summary: "Get a job with the status of its last run",
+		Permission: perm(auth.PermJobRead), Response: api.JobResponse{},
+		Handlers: tenantChain(handlers.GetJob)},
+	{Method: http.MethodGet, Path: "/jobs/:username", Tag: "jobs", Summary: "List a tenant's jobs",
+		Permission: perm(auth.PermJobRead), Response: api.JobListResponse{},
+		Handlers: tenantChain(handlers.ListJobs)},
+
+	// HTTP routes to tenant services under the platform domain or a verified custom hostname
+	{Method: http.MethodPost, Path: "/routes", Tag: "routes", Summary: "Expose a tenant service over HTTP",
+		Permission: perm(auth.PermRouteCreate), Request: api.CreateRouteRequest{}, Response: api.CreateRouteResponse{},
+		Handlers: tenantChain(handlers.Idempotent(), handlers.CreateRoute)},
+	{Method: http.MethodPost, Path: "/routes/:username/:route_name/verify", Tag: "routes", Summary: "Check the ownership challenge of the custom hostname",
+		Permission: perm(auth.PermRouteUpdate), Request: api.VerifyRouteRequest{}, Response: api.RouteResponse{},
+		Handlers: tenantChain(handlers.VerifyRoute)},
+	{Method: http.MethodDelete, Path: "/routes/:username/:route_name", Tag: "routes", Summary: "Delete a route",
+		Permission: perm(auth.PermRouteDelete), Response: api.DeleteRouteResponse{},
+		Handlers: tenantChain(handlers.DeleteRoute)},
+	{Method: http.MethodGet, Path: "/routes/:username/:route_name", Tag: "routes", Summary: "Get a route with its hostname challenge",
+		Permission: perm(auth.PermRouteRead), Response: api.RouteResponse{},
+		Handlers: tenantChain(handlers.GetRoute)},
+	{Method: http.MethodGet, Path: "/routes/:username", Tag: "routes", Summary: "List a tenant's routes",
+		Permission: perm(auth.PermRouteRead), Response: api.RouteListResponse{},
+		Handlers: tenantChain(handlers.ListRoutes)},
+
+	// API tokens for CI and other non-interactive clients
+	{Method: http.MethodPost, Path: "/tokens", Tag: "tokens", Summary: "Create an API token",
+		Permission: perm(auth.PermTokensManage), Request: api.CreateTokenRequest{}, Response: api.CreateTokenResponse{}, Status: http.StatusCreated,
+		Legacy: true, Handlers: chain(handlers.CreateAPIToken)},
+	{Method: http.MethodGet, Path: "/tokens", Tag: "tokens", Summary: "List your API tokens",
+		Permission: perm(auth.PermTokensManage), Response: api.TokenListResponse{},
+		Legacy: true, Handlers: chain(handlers.ListAPITokens)},
+	{Method: http.MethodDelete, Path: "/tokens/:id", Tag: "tokens", Summary: "Revoke an API token",
+		Permission: perm(auth.PermTokensManage), Response: api.RevokeTokenResponse{},
+		Legacy: true, Handlers: chain(handlers.RevokeAPIToken)},
+
+	// Organizations sharing a tenant namespace
+	{Method: http.MethodPost, Path: "/orgs", Tag: "organizations", Summary: "Create an organization",
+		Permission: perm(auth.PermOrgCreate), Request: api.CreateOrgRequest{}, Response: k8s.Organization{}, Status: http.StatusCreated,
+		Legacy: true, Handlers: chain(handlers.CreateOrg)},
+	{Method: http.MethodGet, Path: "/orgs", Tag: "organizations", Summary: "List organizations you belong to",
+		Response: api.OrgListResponse{},
+		Legacy:   true, Handlers: chain(auth.RequireAuthenticated(), handlers.ListOrgs)},
+	{Method: http.MethodGet, Path: "/orgs/:org", Tag: "organizations", Summary: "Get an organization",
+		Permission: perm(auth.PermOrgRead), Response: k8s.Organization{},
+		Legacy: true, Handlers: chain(handlers.GetOrg)},
+	{Method: http.MethodGet, Path: "/orgs/:org/databases", Tag: "organizations", Summary: "List an organization's databases",
+		Permission: perm(auth.PermDatabaseRead), Response: api.OrgDatabasesResponse{},
+		Legacy: true, Handlers: tenantChain(handlers.ListOrgDatabases)},
+	{Method: http.MethodPost, Path: "/orgs/:org/invitations", Tag: "organizations", Summary: "Invite a member",
+		Permission: perm(auth.PermOrgManage), Request: api.CreateInvitationRequest{}, Response: api.CreateInvitationResponse{}, Status: http.StatusCreated,
+		Legacy: true, Handlers: chain(handlers.CreateOrgInvitation)},
+	{Method: http.MethodDelete, Path: "/orgs/:org/invitations/:id", Tag: "organizations", Summary: "Revoke an invitation",
+		Permission: perm(auth.PermOrgManage), Response: api.RevokeInvitationResponse{},
+		Legacy: true, Handlers: chain(handlers.RevokeOrgInvitation)},
+	{Method: http.MethodPut, Path: "/orgs/:org/members/:member", Tag: "organizations", Summary: "Change a member's role",
+		Permission: perm(auth.PermOrgManage), Request: api.UpdateMemberRequest{}, Response: k8s.OrgMember{},
+		Legacy: true, Handlers: chain(handlers.UpdateOrgMember)},
+	{Method: http.MethodDelete, Path: "/orgs/:org/members/:member", Tag: "organizations", Summary: "Remove a member",
+		Permission: perm(auth.PermOrgManage), Response: api.RemoveMemberResponse{},
+		Legacy: true, Handlers: chain(handlers.RemoveOrgMember)},
+	{Method: http.MethodPost, Path: "/invitations/:code/accept", Tag: "organizations", Summary: "Accept an invitation",
+		Response: api.AcceptInvitationResponse{},
+		Legacy:   true, Handlers: chain(auth.RequireAuthenticated(), handlers.AcceptOrgInvitation)},
+
+	// Admin
+	{Method: http.MethodGet, Path: "/admin/tenants/pods", Tag: "admin", Summary: "List pods across all tenants",
+		Permission: perm(auth.PermAdminTenantsRead), Response: []k8s.PodInfo{},
+		Legacy: true, Handlers: chain(handlers.ListAllTenantPodsHandler)},
+	{Method: http.MethodGet, Path: "/admin/tenants", Tag: "admin", Summary: "List tenants",
+		Permission: perm(auth.PermAdminTenantsRead), Response: api.TenantListResponse{},
+		Legacy: true, Handlers: chain(handlers.ListTenantsHandler)},
+	{Method: http.MethodGet, Path: "/admin/tenants/:tenant", Tag: "admin", Summary: "Get a tenant",
+		Permission: perm(auth.PermAdminTenantsRead), Response: k8s.TenantInfo{},
+		Legacy: true, Handlers: chain(handlers.GetTenantHandler)},
+	{Method: http.MethodPost, Path: "/admin/tenants/:tenant/suspend", Tag: "admin", Summary: "Suspend a tenant",
+		Permission: perm(auth.PermAdminTenantsManage), Response: api.TenantActionResponse{},
+		Legacy: true, Handlers: chain(handlers.SuspendTenantHandler)},
+	{Method: http.MethodPost, Path: "/admin/tenants/:tenant/unsuspend", Tag: "admin", Summary: "Unsuspend a tenant",
+		Permission: perm(auth.PermAdminTenantsManage), Response: api.TenantActionResponse{},
+		Legacy: true, Handlers: chain(handlers.UnsuspendTenantHandler)},
+	{Method: http.MethodDelete, Path: "/admin/tenants/:tenant", Tag: "admin", Summary: "Deprovision a tenant and all its resources",
+		Permission: perm(auth.PermAdminTenantsManage), Response: api.TenantActionResponse{},
+		Legacy: true, Handlers: chain(handlers.DeprovisionTenantHandler)},
+	{Method: http.MethodGet, Path: "/admin/config", Tag: "admin", Summary: "Show the running configuration with secrets redacted",
+		Permission: perm(auth.PermAdminConfigRead), Response: api.ConfigResponse{},
+		Handlers: chain(handlers.GetConfig)},
+	{Method: http.MethodGet, Path: "/admin/audit", Tag: "admin", Summary: "Query the audit log",
+		Permission: perm(auth.PermAdminAuditRead), Response: api.AuditEventsResponse{},
+		Query: map[string]string{
+			"actor":     "JWT subject of the caller",
+			"action":    "e.g. database.create",
+			"namespace": "Tenant namespace",
+			"database":  "Database name",
+			"result":    "success, failure or denied",
+			"since":     "RFC3339 timestamp",
+			"until":     "RFC3339 timestamp",
+			"limit":     "Maximum number of events, default 100",
+		},
+		Legacy: true, Handlers: chain(handlers.ListAuditEvents)},
+}
+
+// Register serves the API on r: every route under /v1, the unversioned
+// aliases of legacy routes, and the error envelope for unknown paths.
+func Register(r *gin.Engine) {
+	api.Register(r, apiInfo, routes, requirePermission)
+	r.NoRoute(api.NotFound)
+}
+
+func requirePermission(p string) gin.HandlerFunc {
+	return auth.RequirePermission(auth.Permission(p))
+}
@@ -0,0 +1,182 @@
+// Package servertest runs the API router against a fake Kubernetes cluster,
+// so API clients can be tested end to end without one.
+//
+//	srv := servertest.New(t)
+//	c := client.New(srv.URL, client.WithToken(srv.Token(t, auth.Grant{Role: "owner", Tenant: "alice"})))
+package servertest
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"paas-api/audit"
+	"paas-api/auth"
+	"paas-api/auth/authtest"
+	"paas-api/k8s"
+	"paas-api/server"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Server is the API served by httptest. Kube is the fake cluster behind it,
+// for tests that seed or inspect objects directly.
+type Server struct {
+	*httptest.Server
+	Kube *fake.Clientset
+
+	issuer *authtest.Issuer
+}
+
+// New starts the API. No database operator is installed in the fake cluster,
+// so PostgreSQL databases of every plan run on the StatefulSet provider.
+// Everything is restored when the test ends; tests using New must not run
+// in parallel.
+func New(t testing.TB) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	kube := fake.NewSimpleClientset()
+	kube.PrependReactor("create", "secrets", mergeStringData)
+	kube.PrependReactor("update", "secrets", mergeStringData)
+	k8s.SetKubeClient(kube)
+
+	plans := map[string]k8s.DatabasePlan{}
+	overrides := map[string]string{}
+	for name, plan := range k8s.Plans {
+		plans[name] = plan
+		overrides[name] = k8s.ProviderStatefulSet
+	}
+	if err := k8s.SetPlanProviders(overrides); err != nil {
+		t.Fatalf("failed to use the StatefulSet provider: %v", err)
+	}
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("failed to distrust proxies: %v", err)
+	}
+	r.Use(audit.RequestID())
+	server.Register(r)
+	ts := httptest.NewServer(r)
+
+	t.Cleanup(func() {
+		ts.Close()
+		k8s.SetKubeClient(nil)
+		for name, plan := range plans {
+			k8s.Plans[name] = plan
+		}
+	})
+	return &Server{Server: ts, Kube: kube}
+}
+
+// Token stores an API token with every scope for the grants and returns it.
+func (s *Server) Token(t testing.TB, grants ...auth.Grant) string {
+	t.Helper()
+	plaintext, token, err := auth.GenerateAPIToken("test", "test-user", "", grants, auth.ValidScopes, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	if err := k8s.SaveAPIToken(token); err != nil {
+		t.Fatalf("failed to save token: %v", err)
+	}
+	return plaintext
+}
+
+// Login signs an identity provider access token for an interactive user
+// holding roles, e.g. Login(t, "284739291", "alice", "tenant"). The first
+// call makes the API trust a local issuer.
+func (s *Server) Login(t testing.TB, subject, username string, roles ...string) string {
+	t.Helper()
+	if s.issuer == nil {
+		s.issuer = authtest.NewIssuer(t)
+		if err := auth.InitJWT(auth.OIDCConfig{IssuerURL: s.issuer.URL, RolesClaim: "roles"}); err != nil {
+			t.Fatalf("failed to trust the test issuer: %v", err)
+		}
+	}
+	return s.issuer.Sign(t, s.issuer.Claims(subject, username, roles...))
+}
+
+// RunStatefulSets does what the StatefulSet controller would: every
+// StatefulSet in the fake cluster gets as many running, ready pods as its
+// spec asks for.
+func (s *Server) RunStatefulSets(t testing.TB) {
+	t.Helper()
+	ctx := context.Background()
+
+	statefulSets, err := s.Kube.AppsV1().StatefulSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list StatefulSets: %v", err)
+	}
+	for _, sts := range statefulSets.Items {
+		replicas := 1
+		if sts.Spec.Replicas != nil {
+			replicas = int(*sts.Spec.Replicas)
+		}
+		pods := s.Kube.CoreV1().Pods(sts.Namespace)
+		for i := 0; i < replicas; i++ {
+			name := fmt.Sprintf("%s-%d", sts.Name, i)
+			if _, err := pods.Get(ctx, name, metav1.GetOptions{}); err == nil {
+				continue
+			}
+			if _, err := pods.Create(ctx, readyPod(name, sts.Namespace, sts.Spec.Template.Labels), metav1.CreateOptions{}); err != nil {
+				t.Fatalf("failed to create pod %s: %v", name, err)
+			}
+		}
+
+		// Scaling down removes the pods with the highest ordinals
+		existing, err := pods.List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(sts.Spec.Template.Labels).String()})
+		if err != nil {
+			t.Fatalf("failed to list pods of %s: %v", sts.Name, err)
+		}
+		for _, pod := range existing.Items {
+			ordinal, err := strconv.Atoi(strings.TrimPrefix(pod.Name, sts.Name+"-"))
+			if err != nil || ordinal < replicas {
+				continue
+			}
+			if err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("failed to delete pod %s: %v", pod.Name, err)
+			}
+		}
+	}
+}
+
+// mergeStringData moves a secret's stringData into data, as the API server
+// does on write, then lets the fake store the secret.
+func mergeStringData(action k8stesting.Action) (bool, runtime.Object, error) {
+	secret, ok := action.(k8stesting.CreateAction).GetObject().(*corev1.Secret)
+	if !ok {
+		return false, nil, nil
+	}
+	if secret.Data == nil && len(secret.StringData) > 0 {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+	secret.StringData = nil
+	return false, nil, nil
+}
+
+func readyPod(name, namespace string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: podLabels},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "main",
+				Ready: true,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,31 @@
+terraform {
+  required_providers {
+    cloudtrack = {
+      source = "cloudtrack/cloudtrack"
+    }
+  }
+}
+
+# api_url, token and tenant fall back to CLOUDTRACK_API_URL, CLOUDTRACK_TOKEN
+# and CLOUDTRACK_TENANT
+provider "cloudtrack" {
+  api_url = "https://api.cloudtrack.example.com"
+  tenant  = "my-team"
+}
+
+resource "cloudtrack_database" "app" {
+  name     = "orders"
+  replicas = 2
+}
+
+data "cloudtrack_database_credentials" "app" {
+  name = cloudtrack_database.app.name
+}
+
+output "database_url" {
+  value     = data.cloudtrack_database_credentials.app.connection_string
+  sensitive = true
+}
+
+# Existing databases can be imported as <tenant>/<name>:
+#   terraform import cloudtrack_database.app my-team/orders
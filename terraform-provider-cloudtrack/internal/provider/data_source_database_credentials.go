@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// databaseCredentialsDataSource reads the owner credentials of a database.
+type databaseCredentialsDataSource struct{}
+
+func (databaseCredentialsDataSource) schema() *tfprotov6.Schema {
+	return &tfprotov6.Schema{
+		Block: &tfprotov6.SchemaBlock{
+			Description: "Connection details and owner credentials of a CloudTrack database.",
+			Attributes: []*tfprotov6.SchemaAttribute{
+				{Name: "tenant", Type: tftypes.String, Optional: true, Computed: true,
+					Description: "Tenant owning the database. Defaults to the provider tenant."},
+				{Name: "name", Type: tftypes.String, Required: true,
+					Description: "Database name."},
+				{Name: "host", Type: tftypes.String, Computed: true},
+				{Name: "port", Type: tftypes.Number, Computed: true},
+				{Name: "database", Type: tftypes.String, Computed: true},
+				{Name: "username", Type: tftypes.String, Computed: true},
+				{Name: "password", Type: tftypes.String, Computed: true, Sensitive: true},
+				{Name: "connection_string", Type: tftypes.String, Computed: true, Sensitive: true,
+					Description: "postgresql:// URL including the password."},
+			},
+		},
+	}
+}
+
+func (databaseCredentialsDataSource) read(ctx context.Context, p *Provider, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic) {
+	tenant := stringOr(config, "tenant", p.tenant)
+	if tenant == "" {
+		return nil, []*tfprotov6.Diagnostic{attributeError("tenant", "Missing tenant",
+			"Set tenant on the data source or in the provider block, or set CLOUDTRACK_TENANT.")}
+	}
+	name := getString(config, "name")
+
+	creds, err := p.client.GetDatabaseCredentials(ctx, tenant, name)
+	if err != nil {
+		return nil, apiError("Failed to read database credentials", err)
+	}
+
+	port, err := strconv.Atoi(creds.Port)
+	if err != nil {
+		port = postgresPort
+	}
+	database := creds.ConnectionInfo["database"]
+	if database == "" {
+		database = creds.DatabaseName
+	}
+
+	return map[string]tftypes.Value{
+		"tenant":            stringValue(tenant),
+		"name":              stringValue(name),
+		"host":              stringValue(creds.Host),
+		"port":              intValue(port),
+		"database":          stringValue(database),
+		"username":          stringValue(creds.PrimaryUser["username"]),
+		"password":          stringValue(creds.PrimaryUser["password"]),
+		"connection_string": stringValue(creds.ConnectionString),
+	}, nil
+}
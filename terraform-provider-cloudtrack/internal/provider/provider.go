@@ -0,0 +1,377 @@
+// Package provider implements the CloudTrack Terraform provider directly on
+// the plugin protocol, using the API's own client package.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"paas-api/client"
+)
+
+// Address is the registry address the provider is served under.
+const Address = "registry.terraform.io/cloudtrack/cloudtrack"
+
+// resource is implemented by each managed resource type.
+type resource interface {
+	schema() *tfprotov6.Schema
+	validate(ctx context.Context, config map[string]tftypes.Value) []*tfprotov6.Diagnostic
+	plan(ctx context.Context, p *Provider, prior, proposed map[string]tftypes.Value) (planned map[string]tftypes.Value, replace []*tftypes.AttributePath, diags []*tfprotov6.Diagnostic)
+	apply(ctx context.Context, p *Provider, prior, planned map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic)
+	read(ctx context.Context, p *Provider, state map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic)
+	importState(ctx context.Context, p *Provider, id string) (map[string]tftypes.Value, []*tfprotov6.Diagnostic)
+}
+
+// dataSource is implemented by each data source type.
+type dataSource interface {
+	schema() *tfprotov6.Schema
+	read(ctx context.Context, p *Provider, config map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic)
+}
+
+// Provider is a tfprotov6.ProviderServer for the CloudTrack API.
+type Provider struct {
+	version     string
+	resources   map[string]resource
+	dataSources map[string]dataSource
+
+	client *client.Client
+	// tenant is used by resources that do not set one themselves
+	tenant string
+}
+
+var _ tfprotov6.ProviderServer = (*Provider)(nil)
+
+func New(version string) *Provider {
+	return &Provider{
+		version: version,
+		resources: map[string]resource{
+			"cloudtrack_database": databaseResource{},
+		},
+		dataSources: map[string]dataSource{
+			"cloudtrack_database_credentials": databaseCredentialsDataSource{},
+		},
+	}
+}
+
+func providerSchema() *tfprotov6.Schema {
+	return &tfprotov6.Schema{
+		Block: &tfprotov6.SchemaBlock{
+			Attributes: []*tfprotov6.SchemaAttribute{
+				{Name: "api_url", Type: tftypes.String, Optional: true,
+					Description: "Base URL of the CloudTrack API. Defaults to $CLOUDTRACK_API_URL."},
+				{Name: "token", Type: tftypes.String, Optional: true, Sensitive: true,
+					Description: "Bearer token (OIDC access token or personal API token). Defaults to $CLOUDTRACK_TOKEN."},
+				{Name: "tenant", Type: tftypes.String, Optional: true,
+					Description: "Default tenant for resources that do not set one. Defaults to $CLOUDTRACK_TENANT."},
+			},
+		},
+	}
+}
+
+func (p *Provider) GetMetadata(ctx context.Context, req *tfprotov6.GetMetadataRequest) (*tfprotov6.GetMetadataResponse, error) {
+	resp := &tfprotov6.GetMetadataResponse{
+		ServerCapabilities: &tfprotov6.ServerCapabilities{GetProviderSchemaOptional: true},
+	}
+	for name := range p.resources {
+		resp.Resources = append(resp.Resources, tfprotov6.ResourceMetadata{TypeName: name})
+	}
+	for name := range p.dataSources {
+		resp.DataSources = append(resp.DataSources, tfprotov6.DataSourceMetadata{TypeName: name})
+	}
+	return resp, nil
+}
+
+func (p *Provider) GetProviderSchema(ctx context.Context, req *tfprotov6.GetProviderSchemaRequest) (*tfprotov6.GetProviderSchemaResponse, error) {
+	resp := &tfprotov6.GetProviderSchemaResponse{
+		ServerCapabilities: &tfprotov6.ServerCapabilities{GetProviderSchemaOptional: true},
+		Provider:           providerSchema(),
+		ResourceSchemas:    map[string]*tfprotov6.Schema{},
+		DataSourceSchemas:  map[string]*tfprotov6.Schema{},
+	}
+	for name, r := range p.resources {
+		resp.ResourceSchemas[name] = r.schema()
+	}
+	for name, d := range p.dataSources {
+		resp.DataSourceSchemas[name] = d.schema()
+	}
+	return resp, nil
+}
+
+func (p *Provider) GetResourceIdentitySchemas(ctx context.Context, req *tfprotov6.GetResourceIdentitySchemasRequest) (*tfprotov6.GetResourceIdentitySchemasResponse, error) {
+	return &tfprotov6.GetResourceIdentitySchemasResponse{}, nil
+}
+
+func (p *Provider) ValidateProviderConfig(ctx context.Context, req *tfprotov6.ValidateProviderConfigRequest) (*tfprotov6.ValidateProviderConfigResponse, error) {
+	return &tfprotov6.ValidateProviderConfigResponse{PreparedConfig: req.Config}, nil
+}
+
+func (p *Provider) ConfigureProvider(ctx context.Context, req *tfprotov6.ConfigureProviderRequest) (*tfprotov6.ConfigureProviderResponse, error) {
+	config, err := decode(req.Config, providerSchema())
+	if err != nil {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: errorDiags("Invalid provider configuration", err)}, nil
+	}
+
+	apiURL := stringOr(config, "api_url", os.Getenv("CLOUDTRACK_API_URL"))
+	token := stringOr(config, "token", os.Getenv("CLOUDTRACK_TOKEN"))
+	p.tenant = stringOr(config, "tenant", os.Getenv("CLOUDTRACK_TENANT"))
+
+	var diags []*tfprotov6.Diagnostic
+	if apiURL == "" {
+		diags = append(diags, attributeError("api_url", "Missing API URL", "Set api_url in the provider block or CLOUDTRACK_API_URL."))
+	}
+	if token == "" {
+		diags = append(diags, attributeError("token", "Missing API token", "Set token in the provider block or CLOUDTRACK_TOKEN."))
+	}
+	if len(diags) > 0 {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: diags}, nil
+	}
+
+	p.client = client.New(apiURL,
+		client.WithToken(token),
+		client.WithUserAgent("terraform-provider-cloudtrack/"+p.version),
+	)
+	return &tfprotov6.ConfigureProviderResponse{}, nil
+}
+
+func (p *Provider) StopProvider(ctx context.Context, req *tfprotov6.StopProviderRequest) (*tfprotov6.StopProviderResponse, error) {
+	return &tfprotov6.StopProviderResponse{}, nil
+}
+
+func (p *Provider) resource(typeName string) (resource, []*tfprotov6.Diagnostic) {
+	r, ok := p.resources[typeName]
+	if !ok {
+		return nil, errorDiags("Unknown resource type", fmt.Errorf("%s is not supported by this provider", typeName))
+	}
+	return r, nil
+}
+
+// configured reports an error diagnostic if ConfigureProvider has not run,
+// which happens when Terraform validates or plans with an unknown provider config.
+func (p *Provider) configured() []*tfprotov6.Diagnostic {
+	if p.client == nil {
+		return errorDiags("Provider not configured", fmt.Errorf("the cloudtrack provider has not been configured"))
+	}
+	return nil
+}
+
+func (p *Provider) ValidateResourceConfig(ctx context.Context, req *tfprotov6.ValidateResourceConfigRequest) (*tfprotov6.ValidateResourceConfigResponse, error) {
+	r, diags := p.resource(req.TypeName)
+	if diags != nil {
+		return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: diags}, nil
+	}
+	config, err := decode(req.Config, r.schema())
+	if err != nil {
+		return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: errorDiags("Invalid configuration", err)}, nil
+	}
+	return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: r.validate(ctx, config)}, nil
+}
+
+func (p *Provider) UpgradeResourceState(ctx context.Context, req *tfprotov6.UpgradeResourceStateRequest) (*tfprotov6.UpgradeResourceStateResponse, error) {
+	r, diags := p.resource(req.TypeName)
+	if diags != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: diags}, nil
+	}
+
+	// Only schema version 0 exists, so the stored state is decoded as-is
+	typ := r.schema().ValueType()
+	val, err := req.RawState.Unmarshal(typ)
+	if err != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: errorDiags("Failed to decode state", err)}, nil
+	}
+	state, err := tfprotov6.NewDynamicValue(typ, val)
+	if err != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: errorDiags("Failed to encode state", err)}, nil
+	}
+	return &tfprotov6.UpgradeResourceStateResponse{UpgradedState: &state}, nil
+}
+
+func (p *Provider) ReadResource(ctx context.Context, req *tfprotov6.ReadResourceRequest) (*tfprotov6.ReadResourceResponse, error) {
+	r, diags := p.resource(req.TypeName)
+	if diags == nil {
+		diags = p.configured()
+	}
+	if diags != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: diags}, nil
+	}
+
+	state, err := decode(req.CurrentState, r.schema())
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: errorDiags("Failed to decode state", err)}, nil
+	}
+	newState, diags := r.read(ctx, p, state)
+	if hasError(diags) {
+		return &tfprotov6.ReadResourceResponse{NewState: req.CurrentState, Diagnostics: diags}, nil
+	}
+
+	encoded, err := encode(r.schema(), newState)
+	if err != nil {
+		diags = append(diags, errorDiags("Failed to encode state", err)...)
+	}
+	return &tfprotov6.ReadResourceResponse{NewState: encoded, Diagnostics: diags}, nil
+}
+
+func (p *Provider) PlanResourceChange(ctx context.Context, req *tfprotov6.PlanResourceChangeRequest) (*tfprotov6.PlanResourceChangeResponse, error) {
+	r, diags := p.resource(req.TypeName)
+	if diags != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diags}, nil
+	}
+
+	schema := r.schema()
+	prior, err := decode(req.PriorState, schema)
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: errorDiags("Failed to decode prior state", err)}, nil
+	}
+	proposed, err := decode(req.ProposedNewState, schema)
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: errorDiags("Failed to decode proposed state", err)}, nil
+	}
+	// Destroy plans need no changes
+	if proposed == nil {
+		return &tfprotov6.PlanResourceChangeResponse{PlannedState: req.ProposedNewState}, nil
+	}
+
+	planned, replace, diags := r.plan(ctx, p, prior, proposed)
+	if hasError(diags) {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diags}, nil
+	}
+	encoded, err := encode(schema, planned)
+	if err != nil {
+		diags = append(diags, errorDiags("Failed to encode planned state", err)...)
+	}
+	return &tfprotov6.PlanResourceChangeResponse{PlannedState: encoded, RequiresReplace: replace, Diagnostics: diags}, nil
+}
+
+func (p *Provider) ApplyResourceChange(ctx context.Context, req *tfprotov6.ApplyResourceChangeRequest) (*tfprotov6.ApplyResourceChangeResponse, error) {
+	r, diags := p.resource(req.TypeName)
+	if diags == nil {
+		diags = p.configured()
+	}
+	if diags != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diags}, nil
+	}
+
+	schema := r.schema()
+	prior, err := decode(req.PriorState, schema)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiags("Failed to decode prior state", err)}, nil
+	}
+	planned, err := decode(req.PlannedState, schema)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: errorDiags("Failed to decode planned state", err)}, nil
+	}
+
+	newState, diags := r.apply(ctx, p, prior, planned)
+	// A failed create returns no state; a failed update or delete keeps the prior one
+	if newState == nil && hasError(diags) && prior != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{NewState: req.PriorState, Diagnostics: diags}, nil
+	}
+	encoded, err := encode(schema, newState)
+	if err != nil {
+		diags = append(diags, errorDiags("Failed to encode state", err)...)
+	}
+	return &tfprotov6.ApplyResourceChangeResponse{NewState: encoded, Diagnostics: diags}, nil
+}
+
+func (p *Provider) ImportResourceState(ctx context.Context, req *tfprotov6.ImportResourceStateRequest) (*tfprotov6.ImportResourceStateResponse, error) {
+	r, diags := p.resource(req.TypeName)
+	if diags == nil {
+		diags = p.configured()
+	}
+	if diags != nil {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: diags}, nil
+	}
+
+	state, diags := r.importState(ctx, p, req.ID)
+	if hasError(diags) {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: diags}, nil
+	}
+	encoded, err := encode(r.schema(), state)
+	if err != nil {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: errorDiags("Failed to encode state", err)}, nil
+	}
+	return &tfprotov6.ImportResourceStateResponse{
+		ImportedResources: []*tfprotov6.ImportedResource{{TypeName: req.TypeName, State: encoded}},
+		Diagnostics:       diags,
+	}, nil
+}
+
+func (p *Provider) MoveResourceState(ctx context.Context, req *tfprotov6.MoveResourceStateRequest) (*tfprotov6.MoveResourceStateResponse, error) {
+	return &tfprotov6.MoveResourceStateResponse{
+		Diagnostics: errorDiags("Unsupported operation", fmt.Errorf("moving state into %s is not supported", req.TargetTypeName)),
+	}, nil
+}
+
+func (p *Provider) UpgradeResourceIdentity(ctx context.Context, req *tfprotov6.UpgradeResourceIdentityRequest) (*tfprotov6.UpgradeResourceIdentityResponse, error) {
+	return &tfprotov6.UpgradeResourceIdentityResponse{
+		Diagnostics: errorDiags("Unsupported operation", fmt.Errorf("%s has no resource identity", req.TypeName)),
+	}, nil
+}
+
+func (p *Provider) ValidateDataResourceConfig(ctx context.Context, req *tfprotov6.ValidateDataResourceConfigRequest) (*tfprotov6.ValidateDataResourceConfigResponse, error) {
+	if _, ok := p.dataSources[req.TypeName]; !ok {
+		return &tfprotov6.ValidateDataResourceConfigResponse{
+			Diagnostics: errorDiags("Unknown data source", fmt.Errorf("%s is not supported by this provider", req.TypeName)),
+		}, nil
+	}
+	return &tfprotov6.ValidateDataResourceConfigResponse{}, nil
+}
+
+func (p *Provider) ReadDataSource(ctx context.Context, req *tfprotov6.ReadDataSourceRequest) (*tfprotov6.ReadDataSourceResponse, error) {
+	d, ok := p.dataSources[req.TypeName]
+	if !ok {
+		return &tfprotov6.ReadDataSourceResponse{
+			Diagnostics: errorDiags("Unknown data source", fmt.Errorf("%s is not supported by this provider", req.TypeName)),
+		}, nil
+	}
+	if diags := p.configured(); diags != nil {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: diags}, nil
+	}
+
+	config, err := decode(req.Config, d.schema())
+	if err != nil {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: errorDiags("Invalid configuration", err)}, nil
+	}
+	state, diags := d.read(ctx, p, config)
+	if hasError(diags) {
+		return &tfprotov6.ReadDataSourceResponse{Diagnostics: diags}, nil
+	}
+	encoded, err := encode(d.schema(), state)
+	if err != nil {
+		diags = append(diags, errorDiags("Failed to encode state", err)...)
+	}
+	return &tfprotov6.ReadDataSourceResponse{State: encoded, Diagnostics: diags}, nil
+}
+
+func (p *Provider) GetFunctions(ctx context.Context, req *tfprotov6.GetFunctionsRequest) (*tfprotov6.GetFunctionsResponse, error) {
+	return &tfprotov6.GetFunctionsResponse{}, nil
+}
+
+func (p *Provider) CallFunction(ctx context.Context, req *tfprotov6.CallFunctionRequest) (*tfprotov6.CallFunctionResponse, error) {
+	return &tfprotov6.CallFunctionResponse{
+		Error: &tfprotov6.FunctionError{Text: fmt.Sprintf("function %s is not supported by this provider", req.Name)},
+	}, nil
+}
+
+func (p *Provider) ValidateEphemeralResourceConfig(ctx context.Context, req *tfprotov6.ValidateEphemeralResourceConfigRequest) (*tfprotov6.ValidateEphemeralResourceConfigResponse, error) {
+	return &tfprotov6.ValidateEphemeralResourceConfigResponse{Diagnostics: unsupportedEphemeral(req.TypeName)}, nil
+}
+
+func (p *Provider) OpenEphemeralResource(ctx context.Context, req *tfprotov6.OpenEphemeralResourceRequest) (*tfprotov6.OpenEphemeralResourceResponse, error) {
+	return &tfprotov6.OpenEphemeralResourceResponse{Diagnostics: unsupportedEphemeral(req.TypeName)}, nil
+}
+
+func (p *Provider) RenewEphemeralResource(ctx context.Context, req *tfprotov6.RenewEphemeralResourceRequest) (*tfprotov6.RenewEphemeralResourceResponse, error) {
+	return &tfprotov6.RenewEphemeralResourceResponse{Diagnostics: unsupportedEphemeral(req.TypeName)}, nil
+}
+
+func (p *Provider) CloseEphemeralResource(ctx context.Context, req *tfprotov6.CloseEphemeralResourceRequest) (*tfprotov6.CloseEphemeralResourceResponse, error) {
+	return &tfprotov6.CloseEphemeralResourceResponse{Diagnostics: unsupportedEphemeral(req.TypeName)}, nil
+}
+
+func unsupportedEphemeral(typeName string) []*tfprotov6.Diagnostic {
+	return errorDiags("Unknown ephemeral resource", fmt.Errorf("%s is not supported by this provider", typeName))
+}
@@ -0,0 +1,273 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"paas-api/api"
+	"paas-api/auth"
+	"paas-api/server/servertest"
+)
+
+const databaseType = "cloudtrack_database"
+
+// These tests drive the provider over the plugin protocol the way Terraform
+// does, against the API router with a fake Kubernetes backend.
+
+func newTestProvider(t *testing.T) (*Provider, *servertest.Server) {
+	t.Helper()
+	srv := servertest.New(t)
+	p := New("test")
+
+	config, err := encode(providerSchema(), map[string]tftypes.Value{
+		"api_url": stringValue(srv.URL),
+		"token":   stringValue(srv.Token(t, auth.Grant{Role: "owner", Tenant: "alice"})),
+		"tenant":  stringValue("alice"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := p.ConfigureProvider(context.Background(), &tfprotov6.ConfigureProviderRequest{Config: config})
+	requireNoErrors(t, "ConfigureProvider", resp.Diagnostics)
+	return p, srv
+}
+
+// databaseConfig is a cloudtrack_database block; attributes not in attrs are unset.
+func databaseConfig(attrs map[string]tftypes.Value) map[string]tftypes.Value {
+	config := map[string]tftypes.Value{}
+	for _, attr := range (databaseResource{}).schema().Block.Attributes {
+		config[attr.Name] = tftypes.NewValue(attr.Type, nil)
+	}
+	for name, v := range attrs {
+		config[name] = v
+	}
+	return config
+}
+
+// apply plans config against prior and applies the plan, as terraform apply
+// does. A nil config destroys the resource.
+func apply(t *testing.T, p *Provider, prior *tfprotov6.DynamicValue, config map[string]tftypes.Value) (*tfprotov6.DynamicValue, []*tfprotov6.Diagnostic) {
+	t.Helper()
+	ctx := context.Background()
+	schema := databaseResource{}.schema()
+
+	// Terraform proposes the configuration, keeping computed values from the
+	// prior state for attributes the configuration leaves unset
+	var proposed map[string]tftypes.Value
+	if config != nil {
+		priorAttrs, err := decode(prior, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proposed = map[string]tftypes.Value{}
+		for _, attr := range schema.Block.Attributes {
+			proposed[attr.Name] = config[attr.Name]
+			if attr.Computed && config[attr.Name].IsNull() && priorAttrs != nil {
+				proposed[attr.Name] = priorAttrs[attr.Name]
+			}
+		}
+	}
+	proposedValue := mustEncode(t, proposed)
+	if prior == nil {
+		prior = mustEncode(t, nil)
+	}
+
+	plan, _ := p.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         databaseType,
+		PriorState:       prior,
+		ProposedNewState: proposedValue,
+		Config:           proposedValue,
+	})
+	if hasError(plan.Diagnostics) {
+		return nil, plan.Diagnostics
+	}
+	resp, _ := p.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:     databaseType,
+		PriorState:   prior,
+		PlannedState: plan.PlannedState,
+		Config:       proposedValue,
+	})
+	return resp.NewState, resp.Diagnostics
+}
+
+func read(t *testing.T, p *Provider, state *tfprotov6.DynamicValue) map[string]tftypes.Value {
+	t.Helper()
+	resp, _ := p.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{TypeName: databaseType, CurrentState: state})
+	requireNoErrors(t, "ReadResource", resp.Diagnostics)
+	attrs, err := decode(resp.NewState, databaseResource{}.schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attrs
+}
+
+func mustEncode(t *testing.T, attrs map[string]tftypes.Value) *tfprotov6.DynamicValue {
+	t.Helper()
+	dv, err := encode(databaseResource{}.schema(), attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dv
+}
+
+func mustDecode(t *testing.T, dv *tfprotov6.DynamicValue) map[string]tftypes.Value {
+	t.Helper()
+	attrs, err := decode(dv, databaseResource{}.schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attrs
+}
+
+func requireNoErrors(t *testing.T, call string, diags []*tfprotov6.Diagnostic) {
+	t.Helper()
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			t.Fatalf("%s: %s: %s", call, d.Summary, d.Detail)
+		}
+	}
+}
+
+func TestDatabaseResourceLifecycle(t *testing.T) {
+	p, srv := newTestProvider(t)
+	config := databaseConfig(map[string]tftypes.Value{
+		"name":           stringValue("orders"),
+		"wait_for_ready": boolValue(false),
+	})
+
+	created, diags := apply(t, p, nil, config)
+	requireNoErrors(t, "create", diags)
+	state := mustDecode(t, created)
+	if getString(state, "id") != "alice/orders" || getString(state, "namespace") != "tenant-alice" {
+		t.Errorf("created state: id %q, namespace %q", getString(state, "id"), getString(state, "namespace"))
+	}
+	if getInt(state, "replicas") != 1 || getInt(state, "port") != 5432 || getString(state, "status") != api.StatusProvisioning {
+		t.Errorf("created state: replicas %d, port %d, status %q", getInt(state, "replicas"), getInt(state, "port"), getString(state, "status"))
+	}
+
+	// No pod is running yet, which must not read as a change of replicas
+	state = read(t, p, created)
+	if getInt(state, "replicas") != 1 {
+		t.Errorf("replicas before the pods start = %d, want 1", getInt(state, "replicas"))
+	}
+
+	srv.RunStatefulSets(t)
+	refreshed := mustEncode(t, read(t, p, created))
+	state = mustDecode(t, refreshed)
+	if getString(state, "status") != api.StatusReady {
+		t.Errorf("status = %q, want Ready", getString(state, "status"))
+	}
+
+	plan, _ := p.PlanResourceChange(context.Background(), &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         databaseType,
+		PriorState:       refreshed,
+		ProposedNewState: refreshed,
+		Config:           mustEncode(t, config),
+	})
+	requireNoErrors(t, "PlanResourceChange", plan.Diagnostics)
+	planned := mustDecode(t, plan.PlannedState)
+	for name, v := range state {
+		if !planned[name].Equal(v) {
+			t.Errorf("plan after refresh changes %s from %s to %s", name, v, planned[name])
+		}
+	}
+	if len(plan.RequiresReplace) > 0 {
+		t.Errorf("plan after refresh replaces the database: %v", plan.RequiresReplace)
+	}
+
+	destroyed, diags := apply(t, p, refreshed, nil)
+	requireNoErrors(t, "destroy", diags)
+	if mustDecode(t, destroyed) != nil {
+		t.Error("state after destroy is not null")
+	}
+
+	resp, _ := p.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{TypeName: databaseType, CurrentState: refreshed})
+	requireNoErrors(t, "ReadResource", resp.Diagnostics)
+	if mustDecode(t, resp.NewState) != nil {
+		t.Error("a database deleted outside Terraform is still in state")
+	}
+}
+
+func TestDatabaseResourceImport(t *testing.T) {
+	p, srv := newTestProvider(t)
+	_, diags := apply(t, p, nil, databaseConfig(map[string]tftypes.Value{
+		"name":           stringValue("orders"),
+		"wait_for_ready": boolValue(false),
+	}))
+	requireNoErrors(t, "create", diags)
+	srv.RunStatefulSets(t)
+
+	resp, _ := p.ImportResourceState(context.Background(), &tfprotov6.ImportResourceStateRequest{TypeName: databaseType, ID: "alice/orders"})
+	requireNoErrors(t, "ImportResourceState", resp.Diagnostics)
+	state := read(t, p, resp.ImportedResources[0].State)
+	if getString(state, "tenant") != "alice" || getString(state, "name") != "orders" || getInt(state, "replicas") != 1 {
+		t.Errorf("imported state: tenant %q, name %q, replicas %d", getString(state, "tenant"), getString(state, "name"), getInt(state, "replicas"))
+	}
+
+	bad, _ := p.ImportResourceState(context.Background(), &tfprotov6.ImportResourceStateRequest{TypeName: databaseType, ID: "orders"})
+	if !hasError(bad.Diagnostics) {
+		t.Error("import ID without a tenant was accepted")
+	}
+}
+
+func TestDatabaseResourceErrors(t *testing.T) {
+	p, _ := newTestProvider(t)
+	config := databaseConfig(map[string]tftypes.Value{
+		"name":           stringValue("orders"),
+		"wait_for_ready": boolValue(false),
+	})
+	created, diags := apply(t, p, nil, config)
+	requireNoErrors(t, "create", diags)
+
+	_, diags = apply(t, p, nil, config)
+	if !hasError(diags) || diags[0].Summary != "Database already exists" {
+		t.Errorf("second create: %+v, want Database already exists", diags)
+	}
+
+	// The StatefulSet provider of the fake backend runs a single instance
+	config["replicas"] = intValue(3)
+	state, diags := apply(t, p, created, config)
+	if !hasError(diags) {
+		t.Fatal("scaling beyond the provider's limit succeeded")
+	}
+	if getInt(mustDecode(t, state), "replicas") != 1 {
+		t.Error("a failed update did not keep the prior state")
+	}
+}
+
+func TestDatabaseCredentialsDataSource(t *testing.T) {
+	p, srv := newTestProvider(t)
+	ctx := context.Background()
+	_, diags := apply(t, p, nil, databaseConfig(map[string]tftypes.Value{
+		"name":           stringValue("orders"),
+		"wait_for_ready": boolValue(false),
+	}))
+	requireNoErrors(t, "create", diags)
+	srv.RunStatefulSets(t)
+
+	schema := databaseCredentialsDataSource{}.schema()
+	config, err := encode(schema, map[string]tftypes.Value{
+		"tenant": tftypes.NewValue(tftypes.String, nil), "name": stringValue("orders"),
+		"host": tftypes.NewValue(tftypes.String, nil), "port": tftypes.NewValue(tftypes.Number, nil),
+		"database": tftypes.NewValue(tftypes.String, nil), "username": tftypes.NewValue(tftypes.String, nil),
+		"password": tftypes.NewValue(tftypes.String, nil), "connection_string": tftypes.NewValue(tftypes.String, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := p.ReadDataSource(ctx, &tfprotov6.ReadDataSourceRequest{TypeName: "cloudtrack_database_credentials", Config: config})
+	requireNoErrors(t, "ReadDataSource", resp.Diagnostics)
+	creds, err := decode(resp.State, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getString(creds, "tenant") != "alice" || getString(creds, "username") != "orders" || getString(creds, "password") == "" {
+		t.Errorf("credentials: tenant %q, username %q", getString(creds, "tenant"), getString(creds, "username"))
+	}
+	if getInt(creds, "port") != 5432 || getString(creds, "connection_string") == "" {
+		t.Errorf("credentials: port %d, connection string %q", getInt(creds, "port"), getString(creds, "connection_string"))
+	}
+}
@@ -0,0 +1,259 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"paas-api/api"
+	"paas-api/client"
+	"paas-api/k8s"
+)
+
+const (
+	defaultReplicas = 1
+	postgresPort    = 5432
+
+	// waitTimeout bounds how long create waits for the cluster to accept connections
+	waitTimeout  = 20 * time.Minute
+	waitInterval = 10 * time.Second
+)
+
+// databaseResource manages a PostgreSQL cluster in a tenant namespace.
+type databaseResource struct{}
+
+// computedOnly lists attributes the API sets and users never configure.
+var computedOnly = []string{"id", "namespace", "status", "host", "port"}
+
+func (databaseResource) schema() *tfprotov6.Schema {
+	return &tfprotov6.Schema{
+		Block: &tfprotov6.SchemaBlock{
+			Description: "A PostgreSQL database cluster owned by a CloudTrack tenant.",
+			Attributes: []*tfprotov6.SchemaAttribute{
+				{Name: "id", Type: tftypes.String, Computed: true,
+					Description: "Tenant and database name as <tenant>/<name>."},
+				{Name: "tenant", Type: tftypes.String, Optional: true, Computed: true,
+					Description: "Tenant owning the database. Defaults to the provider tenant. Changing it forces a new database."},
+				{Name: "name", Type: tftypes.String, Required: true,
+					Description: "Database name. Changing it forces a new database."},
+				{Name: "replicas", Type: tftypes.Number, Optional: true, Computed: true,
+					Description: "Number of PostgreSQL instances. Defaults to 1."},
+				{Name: "wait_for_ready", Type: tftypes.Bool, Optional: true,
+					Description: "Wait for the cluster to accept connections on create. Defaults to true."},
+				{Name: "namespace", Type: tftypes.String, Computed: true,
+					Description: "Kubernetes namespace of the cluster."},
+				{Name: "status", Type: tftypes.String, Computed: true,
+					Description: "Cluster status as reported by the API."},
+				{Name: "host", Type: tftypes.String, Computed: true,
+					Description: "In-cluster hostname of the primary."},
+				{Name: "port", Type: tftypes.Number, Computed: true,
+					Description: "PostgreSQL port."},
+			},
+		},
+	}
+}
+
+func (databaseResource) validate(ctx context.Context, config map[string]tftypes.Value) []*tfprotov6.Diagnostic {
+	var diags []*tfprotov6.Diagnostic
+	if known(config, "name") {
+		for _, problem := range k8s.ValidateDBName(getString(config, "name")) {
+			diags = append(diags, attributeError("name", "Invalid database name", "name "+problem))
+		}
+	}
+	if known(config, "replicas") && getInt(config, "replicas") < 1 {
+		diags = append(diags, attributeError("replicas", "Invalid replicas", "replicas must be at least 1"))
+	}
+	return diags
+}
+
+func (databaseResource) plan(ctx context.Context, p *Provider, prior, proposed map[string]tftypes.Value) (map[string]tftypes.Value, []*tftypes.AttributePath, []*tfprotov6.Diagnostic) {
+	planned := proposed
+
+	if prior == nil {
+		if planned["tenant"].IsNull() {
+			if p.tenant == "" {
+				return nil, nil, []*tfprotov6.Diagnostic{attributeError("tenant", "Missing tenant",
+					"Set tenant on the resource or in the provider block, or set CLOUDTRACK_TENANT.")}
+			}
+			planned["tenant"] = stringValue(p.tenant)
+		}
+		if planned["replicas"].IsNull() {
+			planned["replicas"] = intValue(defaultReplicas)
+		}
+		for _, name := range computedOnly {
+			planned[name] = unknown(planned[name].Type())
+		}
+		return planned, nil, nil
+	}
+
+	// Optional+computed attributes the user removed keep their current value
+	for _, name := range []string{"tenant", "replicas"} {
+		if planned[name].IsNull() {
+			planned[name] = prior[name]
+		}
+	}
+	for _, name := range computedOnly {
+		planned[name] = prior[name]
+	}
+
+	var replace []*tftypes.AttributePath
+	for _, name := range []string{"tenant", "name"} {
+		if !planned[name].Equal(prior[name]) {
+			replace = append(replace, tftypes.NewAttributePath().WithAttributeName(name))
+		}
+	}
+	if len(replace) > 0 {
+		for _, name := range computedOnly {
+			planned[name] = unknown(planned[name].Type())
+		}
+	} else if !planned["replicas"].Equal(prior["replicas"]) {
+		planned["status"] = unknown(tftypes.String)
+	}
+	return planned, replace, nil
+}
+
+func (r databaseResource) apply(ctx context.Context, p *Provider, prior, planned map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic) {
+	switch {
+	case planned == nil:
+		return nil, r.delete(ctx, p, prior)
+	case prior == nil:
+		return r.create(ctx, p, planned)
+	default:
+		return r.update(ctx, p, prior, planned)
+	}
+}
+
+func (r databaseResource) create(ctx context.Context, p *Provider, planned map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic) {
+	tenant := getString(planned, "tenant")
+	name := getString(planned, "name")
+
+	created, err := p.client.CreateDatabase(ctx, api.CreateDatabaseRequest{
+		Username: tenant,
+		DBName:   name,
+		Replicas: getInt(planned, "replicas"),
+	})
+	if errors.Is(err, client.ErrConflict) {
+		return nil, errorDiags("Database already exists",
+			fmt.Errorf("%s/%s already exists; import it with terraform import", tenant, name))
+	}
+	if err != nil {
+		return nil, apiError("Failed to create database", err)
+	}
+
+	state := planned
+	state["id"] = stringValue(tenant + "/" + name)
+	state["namespace"] = stringValue(created.Namespace)
+	reported := ""
+	if created.Credentials != nil {
+		reported = created.Credentials.Host
+	}
+	state["host"] = stringValue(databaseHost(name, created.Namespace, reported))
+	state["port"] = intValue(postgresPort)
+	state["status"] = stringValue(api.StatusProvisioning)
+
+	if !getBool(planned, "wait_for_ready", true) {
+		return state, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+	cluster, err := p.client.WaitForReady(waitCtx, tenant, name, waitInterval)
+	if cluster != nil {
+		state["status"] = stringValue(cluster.Status)
+	}
+	if err != nil {
+		// The database exists, so keep it in state; Terraform marks it tainted
+		return state, errorDiags("Database did not become ready", err)
+	}
+	return state, nil
+}
+
+func (r databaseResource) update(ctx context.Context, p *Provider, prior, planned map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic) {
+	tenant := getString(prior, "tenant")
+	name := getString(prior, "name")
+
+	// Replicas are the only attribute changed in place; tenant and name force
+	// replacement and wait_for_ready only affects create
+	state := planned
+	replicas := getInt(planned, "replicas")
+	if replicas == getInt(prior, "replicas") {
+		return state, nil
+	}
+
+	if _, err := p.client.UpdateDatabase(ctx, tenant, name, api.UpdateDatabaseRequest{Replicas: replicas}); err != nil {
+		return nil, apiError("Failed to scale database", err)
+	}
+	cluster, err := p.client.GetDatabaseCluster(ctx, tenant, name)
+	if err != nil {
+		return nil, apiError("Failed to read database", err)
+	}
+	state["status"] = stringValue(cluster.Status)
+	return state, nil
+}
+
+func (databaseResource) delete(ctx context.Context, p *Provider, prior map[string]tftypes.Value) []*tfprotov6.Diagnostic {
+	err := p.client.DeleteDatabase(ctx, getString(prior, "tenant"), getString(prior, "name"))
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		return apiError("Failed to delete database", err)
+	}
+	return nil
+}
+
+func (databaseResource) read(ctx context.Context, p *Provider, state map[string]tftypes.Value) (map[string]tftypes.Value, []*tfprotov6.Diagnostic) {
+	tenant := getString(state, "tenant")
+	name := getString(state, "name")
+
+	cluster, err := p.client.GetDatabaseCluster(ctx, tenant, name)
+	if errors.Is(err, client.ErrNotFound) {
+		// Deleted outside Terraform; a nil state removes it so the next plan recreates it
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apiError("Failed to read database", err)
+	}
+
+	state["id"] = stringValue(tenant + "/" + name)
+	state["namespace"] = stringValue(cluster.Namespace)
+	state["status"] = stringValue(cluster.Status)
+	// The spec, not the pods that happen to exist, so starting or failed
+	// pods do not show up as drift. A paused cluster asks for no instances
+	// and keeps the configured count.
+	if cluster.DesiredReplicas > 0 {
+		state["replicas"] = intValue(cluster.DesiredReplicas)
+	}
+	state["host"] = stringValue(databaseHost(name, cluster.Namespace, cluster.ConnectionInfo["host"]))
+	state["port"] = intValue(postgresPort)
+	return state, nil
+}
+
+// importState accepts <tenant>/<name>. Database names cannot contain a slash,
+// so the last one separates the two.
+func (r databaseResource) importState(ctx context.Context, p *Provider, id string) (map[string]tftypes.Value, []*tfprotov6.Diagnostic) {
+	i := strings.LastIndex(id, "/")
+	if i <= 0 || i == len(id)-1 {
+		return nil, errorDiags("Invalid import ID", fmt.Errorf("expected <tenant>/<name>, got %q", id))
+	}
+
+	state := map[string]tftypes.Value{}
+	for _, attr := range r.schema().Block.Attributes {
+		state[attr.Name] = tftypes.NewValue(attr.Type, nil)
+	}
+	state["id"] = stringValue(id)
+	state["tenant"] = stringValue(id[:i])
+	state["name"] = stringValue(id[i+1:])
+	return state, nil
+}
+
+// databaseHost prefers the host the API reports, which depends on the
+// database's provider, and falls back to the default service name.
+func databaseHost(name, namespace, reported string) string {
+	if reported != "" {
+		return reported
+	}
+	return fmt.Sprintf("%s.%s.svc.cluster.local", name, namespace)
+}
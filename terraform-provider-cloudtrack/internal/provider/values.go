@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"paas-api/client"
+)
+
+// decode turns a dynamic value into its top-level attributes. A null object,
+// such as the prior state on create, decodes to a nil map.
+func decode(dv *tfprotov6.DynamicValue, schema *tfprotov6.Schema) (map[string]tftypes.Value, error) {
+	if dv == nil {
+		return nil, nil
+	}
+	val, err := dv.Unmarshal(schema.ValueType())
+	if err != nil {
+		return nil, err
+	}
+	if val.IsNull() {
+		return nil, nil
+	}
+	attrs := map[string]tftypes.Value{}
+	if err := val.As(&attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// encode is the inverse of decode; a nil map encodes to a null object.
+func encode(schema *tfprotov6.Schema, attrs map[string]tftypes.Value) (*tfprotov6.DynamicValue, error) {
+	typ := schema.ValueType()
+	var raw interface{}
+	if attrs != nil {
+		raw = attrs
+	}
+	if err := tftypes.ValidateValue(typ, raw); err != nil {
+		return nil, err
+	}
+	dv, err := tfprotov6.NewDynamicValue(typ, tftypes.NewValue(typ, raw))
+	if err != nil {
+		return nil, err
+	}
+	return &dv, nil
+}
+
+func known(attrs map[string]tftypes.Value, name string) bool {
+	v, ok := attrs[name]
+	return ok && v.IsKnown() && !v.IsNull()
+}
+
+func getString(attrs map[string]tftypes.Value, name string) string {
+	var s string
+	if known(attrs, name) {
+		_ = attrs[name].As(&s)
+	}
+	return s
+}
+
+func getInt(attrs map[string]tftypes.Value, name string) int {
+	if !known(attrs, name) {
+		return 0
+	}
+	var f big.Float
+	if err := attrs[name].As(&f); err != nil {
+		return 0
+	}
+	n, _ := f.Int64()
+	return int(n)
+}
+
+func getBool(attrs map[string]tftypes.Value, name string, def bool) bool {
+	if !known(attrs, name) {
+		return def
+	}
+	var b bool
+	if err := attrs[name].As(&b); err != nil {
+		return def
+	}
+	return b
+}
+
+func stringOr(attrs map[string]tftypes.Value, name, def string) string {
+	if s := getString(attrs, name); s != "" {
+		return s
+	}
+	return def
+}
+
+func stringValue(s string) tftypes.Value {
+	return tftypes.NewValue(tftypes.String, s)
+}
+
+func intValue(n int) tftypes.Value {
+	return tftypes.NewValue(tftypes.Number, int64(n))
+}
+
+func boolValue(b bool) tftypes.Value {
+	return tftypes.NewValue(tftypes.Bool, b)
+}
+
+func unknown(typ tftypes.Type) tftypes.Value {
+	return tftypes.NewValue(typ, tftypes.UnknownValue)
+}
+
+func errorDiags(summary string, err error) []*tfprotov6.Diagnostic {
+	return []*tfprotov6.Diagnostic{{
+		Severity: tfprotov6.DiagnosticSeverityError,
+		Summary:  summary,
+		Detail:   err.Error(),
+	}}
+}
+
+func attributeError(attr, summary, detail string) *tfprotov6.Diagnostic {
+	return &tfprotov6.Diagnostic{
+		Severity:  tfprotov6.DiagnosticSeverityError,
+		Summary:   summary,
+		Detail:    detail,
+		Attribute: tftypes.NewAttributePath().WithAttributeName(attr),
+	}
+}
+
+func hasError(diags []*tfprotov6.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// apiError reports an API failure, pointing field-level validation errors
+// at the matching attributes.
+func apiError(summary string, err error) []*tfprotov6.Diagnostic {
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		return errorDiags(summary, err)
+	}
+
+	var diags []*tfprotov6.Diagnostic
+	for field, problems := range apiErr.FieldErrors() {
+		attr := field
+		switch field {
+		case "username":
+			attr = "tenant"
+		case "db_name":
+			attr = "name"
+		}
+		for _, problem := range problems {
+			diags = append(diags, attributeError(attr, summary, fmt.Sprintf("%s %s", attr, problem)))
+		}
+	}
+	if len(diags) == 0 {
+		return errorDiags(summary, err)
+	}
+	return diags
+}